@@ -5,6 +5,7 @@ TODO
 */
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"go.dedis.ch/kyber"
@@ -17,8 +18,6 @@ import (
 // ServiceName is used for registration on the onet.
 const ServiceName = "daga"
 
-var suite = daga.NewSuiteEC()
-
 // CreateContext issue a CreateContext call to the daga cothority specified by roster.
 // (API call to the CreateContext endpoint of a random server in roster, that will,
 // if accepted, trigger the dagacontextgeneration protocol with the nodes in roster)
@@ -27,9 +26,10 @@ func (ac AdminCLient) CreateContext(subscribers []kyber.Point, roster *onet.Rost
 	// build request
 	request := CreateContext{
 		ServiceID:       ac.ServiceID,
-		SubscribersKeys: subscribers,
+		SubscribersKeys: EncodePoints(subscribers),
 		DagaNodes:       roster,
 		Signature:       make([]byte, 32), // TODO openPGP sig or other way to auth. admin of 3rd-party service etc..
+		SuiteName:       ac.Suite.String(),
 	}
 	reply := CreateContextReply{}
 
@@ -46,9 +46,15 @@ func (ac AdminCLient) CreateContext(subscribers []kyber.Point, roster *onet.Rost
 // the returned function accept PKClient commitments as parameter
 // and returns the master challenge.
 func (c Client) NewPKclientVerifier(context Context, dst *network.ServerIdentity) daga.PKclientVerifier {
+	return c.NewPKclientVerifierForSession(context, nil, dst)
+}
+
+// NewPKclientVerifierForSession is NewPKclientVerifier requesting a challenge bound to session, the session of a
+// relying party (see Session), nil for a challenge that is not bound to a session
+func (c Client) NewPKclientVerifierForSession(context Context, session *Session, dst *network.ServerIdentity) daga.PKclientVerifier {
 	// poor man's curry
 	sendCommitsReceiveChallenge := func(proverCommitments []kyber.Point) (daga.Challenge, error) {
-		return c.pKClient(dst, context, proverCommitments)
+		return c.pKClient(dst, context, session, proverCommitments)
 	}
 	return sendCommitsReceiveChallenge
 }
@@ -56,51 +62,167 @@ func (c Client) NewPKclientVerifier(context Context, dst *network.ServerIdentity
 // Auth performs the client protocol and proof of knowledge, to :
 // - generate a new authentication message,
 // - send it (API call to Auth endpoint of a random server)
-// - finally extract the final linkage tag after completion of the auth. process
+// - finally verify the receipt of the auth. process and extract the final linkage tag from it
+// the returned errors wrap the errors of sign/daga, e.g. errors.Is(err, daga.ErrMisbehavingClient) when the servers
+// flagged the client as misbehaving
 func (c Client) Auth(context Context) (kyber.Point, error) {
+	precomputation, err := c.Precompute(context)
+	if err != nil {
+		return nil, err
+	}
+	return c.AuthPrecomputed(context, precomputation)
+}
+
+// Precompute performs, ahead of time, the part of the client protocol that doesn't depend on the servers (see
+// daga.Precompute), the returned precomputation can be kept or persisted until the client authenticates using
+// AuthPrecomputed (once)
+func (c Client) Precompute(context Context) (*daga.Precomputation, error) {
 
 	// TODO check the signatures and eventually the public keys of the servers should be fetched and trusted through
 	//  other means, same trust issues as when obtaining a signed binary release, need to trust the key..
 
+	// resolve the suite of the context
+	suite, err := context.Suite()
+	if err != nil {
+		return nil, errors.New("failed to resolve context's suite: " + err.Error())
+	}
+	precomputation, err := daga.Precompute(suite, context, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to precompute authentication message: %w", err)
+	}
+	return precomputation, nil
+}
+
+// AuthPrecomputed is Auth using the precomputation of the authentication message of the client under context
+// (see Precompute), the online part only costs the network round-trips and the verification of the signatures
+func (c Client) AuthPrecomputed(context Context, precomputation *daga.Precomputation) (kyber.Point, error) {
+	receipt, err := c.AuthPrecomputedWithReceipt(context, precomputation)
+	if err != nil {
+		return nil, err
+	}
+	suite, err := context.Suite()
+	if err != nil {
+		return nil, errors.New("failed to resolve context's suite: " + err.Error())
+	}
+	return daga.DecodePoint(suite, receipt.Tag)
+}
+
+// AuthWithReceipt is Auth returning the receipt of the authentication, collectively signed by all the servers of the
+// context, that the client or a 3rd-party service can keep and verify later (see Context.VerifyReceipt)
+func (c Client) AuthWithReceipt(context Context) (*AuthReceipt, error) {
+	precomputation, err := c.Precompute(context)
+	if err != nil {
+		return nil, err
+	}
+	return c.AuthPrecomputedWithReceipt(context, precomputation)
+}
+
+// AuthPrecomputedWithReceipt is AuthWithReceipt using the precomputation of the authentication message of the client
+// under context (see Precompute)
+func (c Client) AuthPrecomputedWithReceipt(context Context, precomputation *daga.Precomputation) (*AuthReceipt, error) {
+	return c.authenticate(context, precomputation, nil)
+}
+
+// AuthForSession is AuthWithReceipt binding the authentication to session, the session of the relying party the client
+// logs into, the returned receipt is bound to the session and can't be used for another session
+// (see Context.VerifyReceiptForSession)
+func (c Client) AuthForSession(context Context, session *Session) (*AuthReceipt, error) {
+	precomputation, err := c.Precompute(context)
+	if err != nil {
+		return nil, err
+	}
+	return c.AuthPrecomputedForSession(context, precomputation, session)
+}
+
+// AuthPrecomputedForSession is AuthForSession using the precomputation of the authentication message of the client
+// under context (see Precompute)
+func (c Client) AuthPrecomputedForSession(context Context, precomputation *daga.Precomputation, session *Session) (*AuthReceipt, error) {
+	if session == nil {
+		return nil, errors.New("nil session")
+	}
+	return c.authenticate(context, precomputation, session)
+}
+
+// performs the online part of the authentication of the client under context, bound to session if not nil
+func (c Client) authenticate(context Context, precomputation *daga.Precomputation, session *Session) (*AuthReceipt, error) {
+	// resolve the suite of the context
+	suite, err := context.Suite()
+	if err != nil {
+		return nil, errors.New("failed to resolve context's suite: " + err.Error())
+	}
+	if precomputed, ok := precomputation.Context().(Context); !ok || !precomputed.Equals(context) {
+		return nil, errors.New("precomputation of another context")
+	}
+
 	// abstraction of remote servers/verifiers for PKclient, it is a function that wrap an API call to PKclient
-	PKclientVerifier := c.NewPKclientVerifier(context, context.Roster.RandomServerIdentity())
+	PKclientVerifier := c.NewPKclientVerifierForSession(context, session, context.Roster.RandomServerIdentity())
 
 	// build daga auth. message
-	if M0, err := daga.NewAuthenticationMessage(suite, context, c, PKclientVerifier); err != nil {
+	if M0, err := precomputation.Authenticate(c, PKclientVerifier); err != nil {
 		return nil, errors.New("failed to build new authentication message: " + err.Error())
 	} else {
-		// send it to random server (API call to Auth)
-		request := *NetEncodeAuthenticationMessage(context, *M0)
+		// send it to random server (API call to Auth), without the commitments of the proof (recomputed by the servers)
+		request := NetEncodeAuthenticationMessage(context, *M0).WithVersion(AuthVersionCompact)
 		reply := AuthReply{}
 		dst := context.Roster.RandomServerIdentity()
 		if err := c.Onet.SendProtobuf(dst, &request, &reply); err != nil {
 			return nil, fmt.Errorf("error sending auth. request to %s : %s", dst, err)
 		}
-		// decode reply
-		serverMsg, context := reply.NetDecode()
 
-		// TODO check that received context match sent context (or don't/avoid by rewriting daga API)
+		// verify that the receipt is signed by all the servers and is the receipt of our request in our session
+		// (the errors wrap the daga errors, callers can use errors.Is, e.g. with daga.ErrMisbehavingClient)
+		receipt := reply.Receipt
+		if err := context.VerifyReceiptForSession(receipt, session); err != nil {
+			return nil, fmt.Errorf("invalid receipt in server reply: %w", err)
+		}
+		requestHash, err := AuthRequestHash(suite, *M0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash the authentication message: %w", err)
+		}
+		if !bytes.Equal(receipt.RequestHash, requestHash) {
+			return nil, errors.New("invalid receipt in server reply: receipt of another request")
+		}
 		// extract final linkage tag
-		if Tf, err := daga.GetFinalLinkageTag(suite, context, *serverMsg); err != nil {
-			return nil, errors.New("failed to extract final linkage tag from server reply: " + err.Error())
-		} else {
-			return Tf, nil
+		tag, err := daga.DecodePoint(suite, receipt.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid receipt in server reply: %w", err)
 		}
+		if err := daga.CheckFinalLinkageTag(suite, tag); err != nil {
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+		return &receipt, nil
 	}
 }
 
-// send PKclient commitments and receive master challenge
-func (c Client) pKClient(dst *network.ServerIdentity, context Context, commitments []kyber.Point) (daga.Challenge, error) {
+// send PKclient commitments and receive master challenge (bound to session if not nil)
+func (c Client) pKClient(dst *network.ServerIdentity, context Context, session *Session, commitments []kyber.Point) (daga.Challenge, error) {
+	suite, err := context.Suite()
+	if err != nil {
+		return daga.Challenge{}, errors.New("pKClient, failed to resolve context's suite: " + err.Error())
+	}
+	binding, err := session.Binding(suite)
+	if err != nil {
+		return daga.Challenge{}, errors.New("pKClient, invalid session: " + err.Error())
+	}
 	log.Lvl3("pKClient, sending commitments to: ", dst)
 	request := PKclientCommitments{
-		Commitments: commitments,
+		Commitments: EncodePoints(commitments),
 		Context:     context,
+		Session:     session,
 	}
 	reply := PKclientChallenge{}
-	err := c.Onet.SendProtobuf(dst, &request, &reply)
+	err = c.Onet.SendProtobuf(dst, &request, &reply)
 	if err != nil {
 		return daga.Challenge{}, fmt.Errorf("pKClient, error sending commitments to %s : %s", dst, err)
 	}
 	log.Lvl3("pKClient, received master challenge from: ", dst)
-	return *reply.NetDecode(), nil
+	// the signatures are verified by sign/daga, but they can be valid for another binding
+	if !bytes.Equal(reply.Session, binding) {
+		return daga.Challenge{}, fmt.Errorf("pKClient, challenge from %s bound to another session", dst)
+	}
+	challenge, err := reply.NetDecode(suite)
+	if err != nil {
+		return daga.Challenge{}, fmt.Errorf("pKClient, invalid challenge from %s: %w", dst, err)
+	}
+	return *challenge, nil
 }
@@ -0,0 +1,163 @@
+package dagacothority
+
+import (
+	"container/heap"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/dedis/student_18_daga/sign/daga"
+	"sync"
+	"time"
+)
+
+/*
+This holds the challenge sessions, the PKClient challenges are issued with a fresh identifier and an expiry, covered by
+the signatures of all the servers (see dagachallengegeneration), and the servers reject the authentications that use an
+expired challenge or a challenge already used by another authentication (see dagaauth).
+since the signatures of all the servers already prove that a challenge was issued, the servers don't need to remember
+the challenges they issue, only the ones that were consumed by an authentication, until they expire.
+*/
+
+const (
+	// ChallengeIDSize is the size of the identifiers of the challenges (see NewChallengeID)
+	ChallengeIDSize = 16
+)
+
+var (
+	// ChallengeLifetime is the time during which an issued challenge can be used by an authentication
+	ChallengeLifetime = 5 * time.Minute
+	// ChallengeMaxClockSkew is the maximum difference between the clocks of the servers tolerated by the expiry checks
+	ChallengeMaxClockSkew = 1 * time.Minute
+)
+
+var (
+	// ErrChallengeExpired is returned when an authentication uses a challenge that expired or without an expiry
+	ErrChallengeExpired = errors.New("challenge expired")
+	// ErrChallengeReplayed is returned when an authentication uses a challenge already consumed by another authentication
+	ErrChallengeReplayed = errors.New("challenge already used")
+)
+
+// NewChallengeID returns a new random identifier for a challenge
+func NewChallengeID() ([]byte, error) {
+	id := make([]byte, ChallengeIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.New("NewChallengeID: failed to pick identifier: " + err.Error())
+	}
+	return id, nil
+}
+
+// NewChallengeExpiry returns the expiry of a challenge issued at now
+func NewChallengeExpiry(now time.Time) int64 {
+	return now.Add(ChallengeLifetime).Unix()
+}
+
+// CheckChallengeExpiry returns an error wrapping ErrChallengeExpired if the challenge has no identifier or expiry,
+// expired at now or expires later than what a server would have issued at now (accounting for the clock skew)
+func CheckChallengeExpiry(challenge daga.Challenge, now time.Time) error {
+	if len(challenge.ID) != ChallengeIDSize {
+		return fmt.Errorf("CheckChallengeExpiry: invalid challenge identifier: %w", ErrChallengeExpired)
+	}
+	if challenge.Expiry == 0 {
+		return fmt.Errorf("CheckChallengeExpiry: no expiry: %w", ErrChallengeExpired)
+	}
+	if challenge.Expiry < now.Add(-ChallengeMaxClockSkew).Unix() {
+		return fmt.Errorf("CheckChallengeExpiry: expired at %d: %w", challenge.Expiry, ErrChallengeExpired)
+	}
+	if challenge.Expiry > now.Add(ChallengeLifetime+ChallengeMaxClockSkew).Unix() {
+		return fmt.Errorf("CheckChallengeExpiry: expiry %d too far in the future: %w", challenge.Expiry, ErrChallengeExpired)
+	}
+	return nil
+}
+
+// ConsumedChallenges keeps track of the challenges consumed by the authentications accepted by a server
+// until they expire, safe for concurrent use
+type ConsumedChallenges struct {
+	sync.Mutex
+	expiries map[string]int64 // expiry of the consumed challenges by context ID || challenge ID
+	byExpiry expiryHeap       // the same, ordered by expiry, s.t. the expired challenges are found without scanning all of them
+}
+
+// NewConsumedChallenges returns a new empty ConsumedChallenges
+func NewConsumedChallenges() *ConsumedChallenges {
+	return &ConsumedChallenges{
+		expiries: make(map[string]int64),
+	}
+}
+
+func consumedChallengeKey(contextID ContextID, challenge daga.Challenge) string {
+	return string(contextID[:]) + string(challenge.ID)
+}
+
+// Check returns an error if the challenge of an authentication under the context contextID expired at now
+// (see CheckChallengeExpiry) or was already consumed (wrapping ErrChallengeReplayed)
+func (cc *ConsumedChallenges) Check(contextID ContextID, challenge daga.Challenge, now time.Time) error {
+	if err := CheckChallengeExpiry(challenge, now); err != nil {
+		return fmt.Errorf("Check: %w", err)
+	}
+	cc.Lock()
+	defer cc.Unlock()
+	if _, consumed := cc.expiries[consumedChallengeKey(contextID, challenge)]; consumed {
+		return fmt.Errorf("Check: %w", ErrChallengeReplayed)
+	}
+	return nil
+}
+
+// Consume records that the challenge was consumed by an authentication under the context contextID at now,
+// returns an error if it can't be used (see Check), the check and the record are atomic s.t. only one of
+// concurrent authentications using the same challenge is accepted
+func (cc *ConsumedChallenges) Consume(contextID ContextID, challenge daga.Challenge, now time.Time) error {
+	if err := CheckChallengeExpiry(challenge, now); err != nil {
+		return fmt.Errorf("Consume: %w", err)
+	}
+	cc.Lock()
+	defer cc.Unlock()
+	cc.prune(now)
+	key := consumedChallengeKey(contextID, challenge)
+	if _, consumed := cc.expiries[key]; consumed {
+		return fmt.Errorf("Consume: %w", ErrChallengeReplayed)
+	}
+	cc.expiries[key] = challenge.Expiry
+	heap.Push(&cc.byExpiry, consumedChallenge{key: key, expiry: challenge.Expiry})
+	return nil
+}
+
+// Len returns the number of consumed challenges that are remembered
+func (cc *ConsumedChallenges) Len() int {
+	cc.Lock()
+	defer cc.Unlock()
+	return len(cc.expiries)
+}
+
+// forgets the consumed challenges that expired at now, they are rejected by CheckChallengeExpiry anyway.
+// (the caller must hold the lock)
+func (cc *ConsumedChallenges) prune(now time.Time) {
+	limit := now.Add(-ChallengeMaxClockSkew).Unix()
+	for len(cc.byExpiry) != 0 && cc.byExpiry[0].expiry < limit {
+		expired := heap.Pop(&cc.byExpiry).(consumedChallenge)
+		delete(cc.expiries, expired.key)
+	}
+}
+
+// a consumed challenge, its key in ConsumedChallenges.expiries and its expiry
+type consumedChallenge struct {
+	key    string
+	expiry int64
+}
+
+// min-heap of consumed challenges ordered by expiry, implements heap.Interface
+type expiryHeap []consumedChallenge
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiry < h[j].expiry }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) {
+	*h = append(*h, x.(consumedChallenge))
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,125 @@
+package dagacothority_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dedis/onet/network"
+	"github.com/dedis/student_18_daga/dagacothority"
+	"github.com/dedis/student_18_daga/sign/daga"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// returns a (unsigned) challenge issued at issuance
+func newTestChallenge(t *testing.T, suite daga.Suite, issuance time.Time) daga.Challenge {
+	id, err := dagacothority.NewChallengeID()
+	require.NoError(t, err)
+	return daga.Challenge{
+		Cs:     suite.Scalar().Pick(suite.RandomStream()),
+		ID:     id,
+		Expiry: dagacothority.NewChallengeExpiry(issuance),
+	}
+}
+
+func TestNetEncodeDecode_ChallengeSession(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			challenge := newTestChallenge(t, suite, time.Now())
+			buf, err := network.Marshal(dagacothority.NetEncodeChallenge(challenge))
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.PKclientChallenge)
+			require.True(t, ok)
+			decodedChallenge, err := decoded.NetDecode(suite)
+			require.NoError(t, err)
+			require.True(t, decodedChallenge.Cs.Equal(challenge.Cs))
+			require.Equal(t, challenge.ID, decodedChallenge.ID)
+			require.Equal(t, challenge.Expiry, decodedChallenge.Expiry)
+		})
+	}
+}
+
+func TestCheckChallengeExpiry(t *testing.T) {
+	suite := daga.NewSuiteEC()
+	now := time.Now()
+	challenge := newTestChallenge(t, suite, now)
+	require.Len(t, challenge.ID, dagacothority.ChallengeIDSize)
+	require.NoError(t, dagacothority.CheckChallengeExpiry(challenge, now))
+	require.NoError(t, dagacothority.CheckChallengeExpiry(challenge, now.Add(dagacothority.ChallengeLifetime)))
+	// tolerates the skew of the clock of the issuer
+	require.NoError(t, dagacothority.CheckChallengeExpiry(challenge, now.Add(-dagacothority.ChallengeMaxClockSkew/2)))
+
+	for _, at := range []time.Time{
+		now.Add(dagacothority.ChallengeLifetime + 2*dagacothority.ChallengeMaxClockSkew), // expired
+		now.Add(-2 * dagacothority.ChallengeMaxClockSkew),                                // issued in the future
+	} {
+		err := dagacothority.CheckChallengeExpiry(challenge, at)
+		require.True(t, errors.Is(err, dagacothority.ErrChallengeExpired), "wrong error: %s", err)
+	}
+
+	// challenges without identifier or expiry
+	noID := challenge
+	noID.ID = nil
+	require.True(t, errors.Is(dagacothority.CheckChallengeExpiry(noID, now), dagacothority.ErrChallengeExpired))
+	noExpiry := challenge
+	noExpiry.Expiry = 0
+	require.True(t, errors.Is(dagacothority.CheckChallengeExpiry(noExpiry, now), dagacothority.ErrChallengeExpired))
+}
+
+func TestConsumedChallenges(t *testing.T) {
+	suite := daga.NewSuiteEC()
+	now := time.Now()
+	contextID := dagacothority.ContextID(uuid.Must(uuid.NewV4()))
+	otherContextID := dagacothority.ContextID(uuid.Must(uuid.NewV4()))
+	challenges := dagacothority.NewConsumedChallenges()
+
+	challenge := newTestChallenge(t, suite, now)
+	require.NoError(t, challenges.Check(contextID, challenge, now))
+	require.NoError(t, challenges.Consume(contextID, challenge, now))
+	err := challenges.Check(contextID, challenge, now)
+	require.True(t, errors.Is(err, dagacothority.ErrChallengeReplayed), "wrong error: %s", err)
+	err = challenges.Consume(contextID, challenge, now)
+	require.True(t, errors.Is(err, dagacothority.ErrChallengeReplayed), "wrong error: %s", err)
+	// the identifiers are per context
+	require.NoError(t, challenges.Check(otherContextID, challenge, now))
+
+	// expired challenges are rejected and never recorded
+	expired := newTestChallenge(t, suite, now.Add(-dagacothority.ChallengeLifetime-2*dagacothority.ChallengeMaxClockSkew))
+	err = challenges.Consume(contextID, expired, now)
+	require.True(t, errors.Is(err, dagacothority.ErrChallengeExpired), "wrong error: %s", err)
+	require.Equal(t, 1, challenges.Len())
+
+	// and forgotten once they expire
+	later := now.Add(dagacothority.ChallengeLifetime + 2*dagacothority.ChallengeMaxClockSkew)
+	require.NoError(t, challenges.Consume(contextID, newTestChallenge(t, suite, later), later))
+	require.Equal(t, 1, challenges.Len())
+
+	// only one of concurrent authentications using the same challenge is accepted
+	challenge = newTestChallenge(t, suite, now)
+	var wg sync.WaitGroup
+	accepted := make(chan struct{}, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if challenges.Consume(contextID, challenge, now) == nil {
+				accepted <- struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, accepted, 1)
+
+	// only the expired ones are forgotten, whatever the order they were consumed in
+	challenges = dagacothority.NewConsumedChallenges()
+	for _, issued := range []time.Time{now.Add(2 * time.Minute), now, now.Add(time.Minute)} {
+		require.NoError(t, challenges.Consume(contextID, newTestChallenge(t, suite, issued), issued))
+	}
+	later = now.Add(dagacothority.ChallengeLifetime + dagacothority.ChallengeMaxClockSkew + time.Second)
+	require.NoError(t, challenges.Consume(contextID, newTestChallenge(t, suite, later), later))
+	require.Equal(t, 3, challenges.Len())
+}
@@ -19,9 +19,9 @@ type Client struct {
 	Onet *onet.Client
 }
 
-// NewClient is used to initialize a new Client with a given index
+// NewClient is used to initialize a new Client of the given suite with a given index
 // If no private key is given, a random one is chosen
-func NewClient(i int, s kyber.Scalar) (*Client, error) {
+func NewClient(suite daga.Suite, i int, s kyber.Scalar) (*Client, error) {
 	if dagaClient, err := daga.NewClient(suite, i, s); err != nil {
 		return nil, err
 	} else {
@@ -32,15 +32,22 @@ func NewClient(i int, s kyber.Scalar) (*Client, error) {
 	}
 }
 
+// Release zeroes the private key of the client, see daga.ReleaseSecrets
+func (c *Client) Release() {
+	daga.ReleaseSecrets(c.Client)
+}
+
 // AdminCLient is the client side struct used by 3rd-party services admins (!not daga node admin!) to call context management endpoints.
 // TODO FIXME move elsewhere later or remove completely (used now to test api/cli)
 type AdminCLient struct {
 	// TODO PGP identity or whatever etc.. when we will authenticate/authorize those partners
 	ServiceID ServiceID
+	// the suite of the contexts created by the admin
+	Suite daga.Suite
 	*onet.Client
 }
 
-// NewAdminClient is used to initialize a new AdminClient
-func NewAdminClient() *AdminCLient {
-	return &AdminCLient{Client: onet.NewClient(suite, ServiceName), ServiceID: ServiceID(uuid.Must(uuid.NewV4()))}
+// NewAdminClient is used to initialize a new AdminClient that will create contexts of the given suite
+func NewAdminClient(suite daga.Suite) *AdminCLient {
+	return &AdminCLient{Client: onet.NewClient(suite, ServiceName), ServiceID: ServiceID(uuid.Must(uuid.NewV4())), Suite: suite}
 }
@@ -19,8 +19,6 @@ import (
 	"strconv"
 )
 
-var suite = daga.NewSuiteEC()
-
 func main() {
 	cliApp := cli.NewApp()
 	cliApp.Usage = "Used for building other apps."
@@ -43,6 +41,13 @@ func main() {
 			Aliases:     []string{"c"},
 			ArgsUsage:   "NUMCLIENTS the number of clients, ROSTER the public group definition file",
 			Action:      cmdSetup,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "suite, s",
+					Value: dagacothority.DefaultSuiteName,
+					Usage: "name of the DAGA suite of the context",
+				},
+			},
 		},
 	}
 	cliApp.Flags = []cli.Flag{
@@ -70,6 +75,12 @@ func cmdSetup(c *cli.Context) error {
 	// read roster
 	roster := readRoster(c.Args().Tail())
 
+	// resolve suite
+	suite, err := dagacothority.SuiteByName(c.String("suite"))
+	if err != nil {
+		return err
+	}
+
 	// to encode, to save to FS
 	network.RegisterMessages(dagacothority.Context{}, dagacothority.NetClient{})
 
@@ -88,7 +99,7 @@ func cmdSetup(c *cli.Context) error {
 	// 3rd-party service admin that generate keypairs of the clients but the clients themselves
 
 	// create AdminClient (3rd-party service admin, !not daga admin!) to call daga API endpoint
-	serviceProvider := dagacothority.NewAdminClient()
+	serviceProvider := dagacothority.NewAdminClient(suite)
 
 	var errs []error
 	// create and register context with running daga cothority and save it to FS
@@ -100,7 +111,7 @@ func cmdSetup(c *cli.Context) error {
 	}
 
 	// save clients conf to disk
-	netClients, err := dagacothority.NetEncodeClients(clients)
+	netClients, err := dagacothority.NetEncodeClients(suite, clients)
 	errs = append(errs, err)
 	for i, netClient := range netClients {
 		errs = append(errs, saveToFile(fmt.Sprintf("./client%d.bin", i), &netClient)) // TODO remove magic strings
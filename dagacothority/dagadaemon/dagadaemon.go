@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"errors"
 	"github.com/dedis/onet/log"
 	"github.com/dedis/onet/network"
@@ -10,8 +11,6 @@ import (
 	"net/http"
 )
 
-var suite = daga.NewSuiteEC()
-
 func main() {
 	http.HandleFunc("/dagadaemon/ws", func(w http.ResponseWriter, r *http.Request) {
 
@@ -41,6 +40,13 @@ func main() {
 			}
 		}()
 
+		// the session of the relying party the authentication is meant for, if provided by the webUI
+		session, err := readSession(r)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
 		// receive client and context from webUI (via websocket)
 		network.RegisterMessages(dagacothority.Context{}, dagacothority.NetClient{}, dagacothority.Auth{})
 		context, err := readContext(conn)
@@ -56,9 +62,15 @@ func main() {
 		}
 
 		// build daga auth. msg (call PKClient endpoint to build proof, then build correct auth. msg)
+		suite, err := context.Suite()
+		if err != nil {
+			log.Error(err)
+			return
+		}
 
 		// abstraction of remote servers/verifiers for PKclient, it is a function that wrap an API call to PKclient
-		PKclientVerifier := client.NewPKclientVerifier(*context, context.Roster.RandomServerIdentity())
+		// (bound to the session if any, the resulting auth. msg can't be used in another session)
+		PKclientVerifier := client.NewPKclientVerifierForSession(*context, session, context.Roster.RandomServerIdentity())
 		M0, err := daga.NewAuthenticationMessage(suite, context, client, PKclientVerifier)
 		if err != nil {
 			log.Error(errors.New("failed to build new authentication message: " + err.Error()))
@@ -83,6 +95,25 @@ func main() {
 	}
 }
 
+// reads the session of the relying party from the query of the websocket URL, its audience and base64url encoded nonce,
+// e.g. /dagadaemon/ws?audience=https%3A%2F%2Frp.example.com&nonce=..., nil if the webUI doesn't provide a session
+func readSession(r *http.Request) (*dagacothority.Session, error) {
+	query := r.URL.Query()
+	audience, encodedNonce := query.Get("audience"), query.Get("nonce")
+	if audience == "" && encodedNonce == "" {
+		return nil, nil
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(encodedNonce)
+	if err != nil {
+		return nil, errors.New("readSession: " + err.Error())
+	}
+	session := &dagacothority.Session{Audience: audience, Nonce: nonce}
+	if err := session.Validate(); err != nil {
+		return nil, errors.New("readSession: " + err.Error())
+	}
+	return session, nil
+}
+
 func readClient(conn *websocket.Conn) (*dagacothority.Client, error) {
 	if contextPtr, err := readProto(conn); err != nil {
 		return nil, errors.New("readClient: " + err.Error())
@@ -108,6 +139,11 @@ func readProto(conn *websocket.Conn) (network.Message, error) {
 	if err != nil {
 		return nil, errors.New("readProto: " + err.Error())
 	}
+	// TODO decodes using the default suite, see the TODO in dagacothority.SuiteByName
+	suite, err := dagacothority.SuiteByName(dagacothority.DefaultSuiteName)
+	if err != nil {
+		return nil, errors.New("readProto: " + err.Error())
+	}
 	if _, msg, err := network.Unmarshal(proto, suite); err != nil {
 		return nil, errors.New("readProto: " + err.Error())
 	} else {
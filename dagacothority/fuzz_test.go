@@ -0,0 +1,173 @@
+package dagacothority_test
+
+import (
+	"testing"
+
+	"github.com/dedis/onet/network"
+	"github.com/dedis/student_18_daga/dagacothority"
+	"github.com/dedis/student_18_daga/sign/daga"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/xof/blake2xb"
+)
+
+// the suite used by the fuzz targets to decode the messages (as onet does with the suite of the conode)
+var fuzzSuite = daga.NewSuiteEC()
+
+// the servers, context and valid messages of the seed corpus of the fuzz targets
+type fuzzFixture struct {
+	servers     []daga.Server
+	context     *dagacothority.Context
+	commitments []kyber.Point
+	challenges  [][]byte // encoded PKclientChallenge
+	requests    [][]byte // encoded Auth
+	replies     [][]byte // encoded AuthReply, with and without transcript
+}
+
+func newFuzzFixture(f *testing.F) *fuzzFixture {
+	seeded := daga.WithRandomStream(fuzzSuite, blake2xb.New([]byte("dagacothority fuzz seed corpus")))
+	clients, servers, dagaContext, err := daga.GenerateTestContext(seeded, 3, 3)
+	require.NoError(f, err)
+	serviceID := dagacothority.ServiceID(uuid.NewV5(uuid.NamespaceOID, "dagacothority fuzz"))
+	context, err := dagacothority.NewContext(fuzzSuite, dagaContext, nil, serviceID, nil)
+	require.NoError(f, err)
+	fixture := &fuzzFixture{servers: servers, context: context}
+	marshal := func(msg network.Message) []byte {
+		buf, err := network.Marshal(msg)
+		require.NoError(f, err)
+		return buf
+	}
+
+	// dummy challenge, signed by all the servers
+	cs := seeded.Scalar().Pick(seeded.RandomStream())
+	sendCommitsReceiveChallenge := func(commitments []kyber.Point) (daga.Challenge, error) {
+		challenge := daga.Challenge{Cs: cs}
+		data, err := challenge.ToBytes(daga.ContextEncoding(context), commitments)
+		require.NoError(f, err)
+		for _, server := range servers {
+			sig, err := context.SigningScheme().Sign(seeded, server.PrivateKey(), data)
+			require.NoError(f, err)
+			challenge.Sigs = append(challenge.Sigs, daga.ServerSignature{Index: server.Index(), Sig: sig})
+		}
+		fixture.commitments = commitments
+		fixture.challenges = append(fixture.challenges, marshal(dagacothority.NetEncodeChallenge(challenge)))
+		return challenge, nil
+	}
+	authMsg, err := daga.NewAuthenticationMessage(seeded, *context, clients[2], sendCommitsReceiveChallenge)
+	require.NoError(f, err)
+
+	for _, version := range []int{dagacothority.AuthVersionFull, dagacothority.AuthVersionCompact} {
+		request := dagacothority.NetEncodeAuthenticationMessage(*context, *authMsg).WithVersion(version)
+		fixture.requests = append(fixture.requests, marshal(&request))
+		decoded, _, err := request.NetDecode()
+		require.NoError(f, err)
+		servMsg, err := daga.InitializeServerMessage(decoded)
+		require.NoError(f, err)
+		for _, server := range servers {
+			require.NoError(f, daga.ServerProtocol(seeded, servMsg, server))
+			transcript := dagacothority.NetEncodeServerMessage(*context, servMsg).WithVersion(version)
+			fixture.replies = append(fixture.replies, marshal(&dagacothority.AuthReply{Transcript: &transcript}))
+		}
+
+		// receipt signed by all the servers
+		Tf, err := daga.GetFinalLinkageTag(seeded, context, *servMsg)
+		require.NoError(f, err)
+		requestHash, err := dagacothority.AuthRequestHash(seeded, *decoded)
+		require.NoError(f, err)
+		receipt := dagacothority.AuthReceipt{ContextID: context.ContextID, Tag: dagacothority.EncodePoint(Tf), RequestHash: requestHash, Timestamp: 1234567890}
+		data, err := receipt.SignData()
+		require.NoError(f, err)
+		receipt.CollectiveSignature, receipt.ParticipationMask = cosignData(f, seeded, servers, *context, []int{0, 1, 2}, data)
+		fixture.replies = append(fixture.replies, marshal(&dagacothority.AuthReply{Receipt: receipt}))
+	}
+	return fixture
+}
+
+// decodes data as onet does with the messages it receives, nil if data is not a message
+func unmarshalFuzz(data []byte) network.Message {
+	_, msg, err := network.Unmarshal(data, fuzzSuite)
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+func FuzzAuth_NetDecode(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	for _, data := range fixture.requests {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		auth, ok := unmarshalFuzz(data).(*dagacothority.Auth)
+		if !ok || auth.ValidateVersion() != nil {
+			return
+		}
+		// as a relying party (see Auth.VerifySession)
+		_ = auth.VerifySession(nil)
+		// as the leader of the server's protocol (see dagaauth)
+		request, context, err := auth.NetDecode()
+		if err != nil {
+			return
+		}
+		suite, err := context.Suite()
+		if err != nil {
+			return
+		}
+		servMsg, err := daga.InitializeServerMessage(request)
+		if err != nil {
+			return
+		}
+		_ = daga.ServerProtocol(suite, servMsg, fixture.servers[0])
+	})
+}
+
+func FuzzAuthReply_NetDecode(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	for _, data := range fixture.replies {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reply, ok := unmarshalFuzz(data).(*dagacothority.AuthReply)
+		if !ok {
+			return
+		}
+		// as the client and the 3rd-party services (see api.go)
+		_ = fixture.context.VerifyReceipt(reply.Receipt)
+		if reply.Transcript == nil {
+			return
+		}
+		servMsg, context, err := reply.Transcript.NetDecode()
+		if err != nil {
+			return
+		}
+		suite, err := context.Suite()
+		if err != nil {
+			return
+		}
+		// as the client under the context of the reply and under its own, and as the next server and the leader (see dagaauth)
+		_, _ = daga.GetFinalLinkageTag(suite, context, *servMsg)
+		_, _ = daga.GetFinalLinkageTag(fuzzSuite, fixture.context, *servMsg)
+		_ = daga.ServerProtocol(suite, servMsg, fixture.servers[len(servMsg.Tags)%len(fixture.servers)])
+		_, _ = dagacothority.AuthRequestHash(suite, servMsg.Request)
+	})
+}
+
+func FuzzPKclientChallenge_NetDecode(f *testing.F) {
+	fixture := newFuzzFixture(f)
+	for _, data := range fixture.challenges {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pkc, ok := unmarshalFuzz(data).(*dagacothority.PKclientChallenge)
+		if !ok {
+			return
+		}
+		challenge, err := pkc.NetDecode(fuzzSuite)
+		if err != nil {
+			return
+		}
+		_ = daga.ValidateChallenge(fuzzSuite, fixture.context, *challenge)
+		_ = challenge.VerifySignatures(fuzzSuite, fixture.context, fixture.commitments)
+	})
+}
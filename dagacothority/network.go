@@ -2,6 +2,7 @@ package dagacothority
 
 import (
 	"errors"
+	"fmt"
 	"go.dedis.ch/kyber"
 	"github.com/dedis/student_18_daga/sign/daga"
 )
@@ -11,42 +12,153 @@ import (
 // TODO QUESTION ask, dumb IMO but feel kind of bad exporting things that are intended to be immutable and private so the in between solution is to have a separate struct
 // TODO ~messy IMO, how to do it in a idiomatic and educated way ?
 
+// the points and scalars are carried encoded (as bytes) in the messages and in the saved state, and decoded using the
+// suite of their context, since onet decodes the kyber.Point/Scalar fields with the suite of the conode/onet.Client
+
+// EncodePoint returns the canonical encoding of P, nil for a nil point
+func EncodePoint(P kyber.Point) []byte {
+	if P == nil {
+		return nil
+	}
+	buf, err := P.MarshalBinary()
+	if err != nil {
+		// can't happen with the groups of the daga suites
+		panic("EncodePoint: " + err.Error())
+	}
+	return buf
+}
+
+// EncodePoints returns the canonical encodings of the points, nil for an empty slice
+func EncodePoints(points []kyber.Point) [][]byte {
+	if len(points) == 0 {
+		return nil
+	}
+	encoded := make([][]byte, len(points))
+	for i, P := range points {
+		encoded[i] = EncodePoint(P)
+	}
+	return encoded
+}
+
+// EncodeScalar returns the canonical encoding of s, nil for a nil scalar
+func EncodeScalar(s kyber.Scalar) []byte {
+	if s == nil {
+		return nil
+	}
+	buf, err := s.MarshalBinary()
+	if err != nil {
+		// can't happen with the groups of the daga suites
+		panic("EncodeScalar: " + err.Error())
+	}
+	return buf
+}
+
+// EncodeScalars returns the canonical encodings of the scalars, nil for an empty slice
+func EncodeScalars(scalars []kyber.Scalar) [][]byte {
+	if len(scalars) == 0 {
+		return nil
+	}
+	encoded := make([][]byte, len(scalars))
+	for i, s := range scalars {
+		encoded[i] = EncodeScalar(s)
+	}
+	return encoded
+}
+
+// DecodePoints decodes the points encoded with EncodePoints using suite (see daga.DecodePoint), nil for an empty slice
+func DecodePoints(suite daga.Suite, encoded [][]byte) ([]kyber.Point, error) {
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+	points := make([]kyber.Point, len(encoded))
+	for i, buf := range encoded {
+		P, err := daga.DecodePoint(suite, buf)
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %w", i, err)
+		}
+		points[i] = P
+	}
+	return points, nil
+}
+
+// DecodeScalars decodes the scalars encoded with EncodeScalars using suite (see daga.DecodeScalar), nil for an empty slice
+func DecodeScalars(suite daga.Suite, encoded [][]byte) ([]kyber.Scalar, error) {
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+	scalars := make([]kyber.Scalar, len(encoded))
+	for i, buf := range encoded {
+		s, err := daga.DecodeScalar(suite, buf)
+		if err != nil {
+			return nil, fmt.Errorf("scalar %d: %w", i, err)
+		}
+		scalars[i] = s
+	}
+	return scalars, nil
+}
+
+// decodes the point encoded in buf using suite, nil for an empty buf (missing point)
+func decodeOptionalPoint(suite daga.Suite, buf []byte) (kyber.Point, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return daga.DecodePoint(suite, buf)
+}
+
+// decodes the scalar encoded in buf using suite, nil for an empty buf (missing scalar)
+func decodeOptionalScalar(suite daga.Suite, buf []byte) (kyber.Scalar, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return daga.DecodeScalar(suite, buf)
+}
+
 // NetClient is used to represent a daga.Client (which is an interface) in a net friendly way
 // used only to dump client to disk while developing for now
 // ! dont send those around, contains sensitive informations !
 type NetClient struct {
-	PrivateKey kyber.Scalar
+	PrivateKey []byte // encoded with the suite named SuiteName
 	Index      int
+	SuiteName  string
 }
 
 // NetServer is used to represent a daga.Server (which is an interface) in a net friendly way
 // used only to dump server to disk while developing for now
 type NetServer struct {
-	PrivateKey     kyber.Scalar
+	PrivateKey     []byte // encoded with the suite of the context the server is part of
 	Index          int
-	PerRoundSecret kyber.Scalar
+	PerRoundSecret []byte
 }
 
-func netEncodeClient(c daga.Client) *NetClient {
+func netEncodeClient(suite daga.Suite, c daga.Client) *NetClient {
 	return &NetClient{
 		Index:      c.Index(),
-		PrivateKey: c.PrivateKey(),
+		PrivateKey: EncodeScalar(c.PrivateKey()),
+		SuiteName:  suite.String(),
 	}
 }
 
 // NetDecode is used to translate back the net friendly version of Client
 func (nc NetClient) NetDecode() (*Client, error) {
-	return NewClient(nc.Index, nc.PrivateKey)
+	suite, err := SuiteByName(nc.SuiteName)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := daga.DecodeScalar(suite, nc.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(suite, nc.Index, privateKey)
 }
 
-// NetEncodeClients transform a slice of Client into a slice of NetClient (the "net friendly" version of it"
-func NetEncodeClients(clients []daga.Client) ([]NetClient, error) {
+// NetEncodeClients transform a slice of Client (of the given suite) into a slice of NetClient (the "net friendly" version of it"
+func NetEncodeClients(suite daga.Suite, clients []daga.Client) ([]NetClient, error) {
 	if len(clients) == 0 {
 		return nil, errors.New("empty array")
 	}
 	var netClients []NetClient
 	for _, client := range clients {
-		netClient := netEncodeClient(client)
+		netClient := netEncodeClient(suite, client)
 		netClients = append(netClients, *netClient)
 	}
 	return netClients, nil
@@ -72,9 +184,9 @@ func NetEncodeClients(clients []daga.Client) ([]NetClient, error) {
 // ==> TODO/FIXME means that currently unencrypted sensitive data is in bbolt saved state of service
 func NetEncodeServer(s daga.Server) *NetServer {
 	return &NetServer{
-		PrivateKey:     s.PrivateKey(),
+		PrivateKey:     EncodeScalar(s.PrivateKey()),
 		Index:          s.Index(),
-		PerRoundSecret: s.RoundSecret(),
+		PerRoundSecret: EncodeScalar(s.RoundSecret()),
 	}
 }
 
@@ -90,12 +202,33 @@ func NetEncodeServer(s daga.Server) *NetServer {
 //	return netServers, nil
 //}
 
-// NetDecode is used to translate back the net friendly version of a daga.Server
-func (s NetServer) NetDecode() (daga.Server, error) {
-	server, err := daga.NewServer(suite, s.Index, s.PrivateKey)
+// NetDecode is used to translate back the net friendly version of a daga.Server of the given suite
+// (the suite of the context the server is part of), the daga.Server holds its own copy of the secrets of s
+func (s NetServer) NetDecode(suite daga.Suite) (daga.Server, error) {
+	privateKey, err := daga.DecodeScalar(suite, s.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
-	server.SetRoundSecret(s.PerRoundSecret)
+	server, err := daga.NewServer(suite, s.Index, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.PerRoundSecret) != 0 {
+		roundSecret, err := daga.DecodeScalar(suite, s.PerRoundSecret)
+		if err != nil {
+			return nil, err
+		}
+		server.SetRoundSecret(roundSecret)
+	}
 	return server, nil
 }
+
+// Release zeroes the encodings of the private key and of the per-round secret of the server
+// (the daga.Servers decoded from it hold their own copies, released by the protocol instances they are handed to)
+func (s NetServer) Release() {
+	for _, secret := range [][]byte{s.PrivateKey, s.PerRoundSecret} {
+		for i := range secret {
+			secret[i] = 0
+		}
+	}
+}
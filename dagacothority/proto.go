@@ -17,11 +17,11 @@ this means that the content of the file need to stay proto.awk friendly:
 
 additionally this means that the data-structures need to stay onet/protobuf friendly:
 	- only public/exported fields
-	- no interface fields (in general.., kyber.Point/Scalar ok, see how onet package handle encoding/decoding of interfaces etc.)
+	- no interface fields, the kyber.Point/Scalar are carried encoded (see EncodePoint and EncodeScalar)
+	  s.t. they are decoded with the suite of their context instead of the suite used by onet
 */
 
 import (
-	"go.dedis.ch/kyber"
 	"github.com/dedis/onet"
 )
 
@@ -35,10 +35,13 @@ import (
 type CreateContext struct {
 	// used to identify 3rd-party service making the request (maybe we don't need to strictly identify but easier for now, later can rely on other schemes)
 	ServiceID       ServiceID
-	Signature       []byte
-	SubscribersKeys []kyber.Point
+	Signature []byte
+	// public keys of the clients, encoded with the suite named SuiteName
+	SubscribersKeys [][]byte
 	// all the nodes that the 3rd-party service wants to include in its DAGA cothority
 	DagaNodes *onet.Roster
+	// name of the daga.Suite the context will be built upon (empty for the default suite)
+	SuiteName string
 }
 
 // CreateContextReply is the reply to a CreateContext request ... (yes looks like I'll stop trying to satisfy golint quickly ^^)
@@ -49,15 +52,35 @@ type CreateContextReply struct {
 // PKclientCommitments initiates the challenge generation protocol that will result (on success) in a PKclientChallenge
 type PKclientCommitments struct {
 	// to early reject auth requests part of context that the server doesn't care about
-	Context     Context
-	Commitments []kyber.Point
+	Context Context
+	// commitments of the PKclient proof, encoded with the suite of the context
+	Commitments [][]byte
+	// session of the relying party the authentication is meant for (see Session), nil if not bound to a session
+	Session *Session
+}
+
+// Session identifies a login attempt at a relying party (3rd-party service), the relying party picks a fresh nonce for
+// each attempt and hands it, along with its audience, to the client that binds its authentication to the session
+// (see Session.Binding and daga.Challenge.Session), so that the authentication can't be relayed to another session
+type Session struct {
+	// identifier of the relying party, e.g. its URL
+	Audience string
+	// fresh random nonce picked by the relying party (see NewSession)
+	Nonce []byte
 }
 
 // PKclientChallenge is a copy of daga.Challenge to make awk proto generation happy (don't have proto generation in sign/daga + awk doesn't like type aliases)
 // TODO: (find better solution) or why not using same proto.go generation procedure in sign/daga etc..
 type PKclientChallenge struct {
-	Cs   kyber.Scalar
+	// the challenge, encoded with the suite of the context
+	Cs   []byte
 	Sigs []ServerSignature
+	// binding of the challenge to the session of the request (see Session.Binding), empty if not bound to a session
+	Session []byte
+	// identifier of the challenge, to reject the authentications that use it more than once (see ConsumedChallenges)
+	ID []byte
+	// expiry of the challenge, unix time in seconds (see CheckChallengeExpiry)
+	Expiry int64
 }
 
 // ServerSignature is a copy of daga.ServerSignature to make awk proto generation happy (don't have proto generation in sign/daga)
@@ -69,33 +92,63 @@ type ServerSignature struct {
 // Auth will start the authentication of client that will result (on success) in an AuthReply
 // it provides a net (and awk friendly) compatible representation of the daga.AuthenticationMessage struct
 // (which embeds a context which is an interface) // TODO keep an eye on the new features, interface marshaller etc.. probably oportunities to simplify those structs later
+// the session the authentication is bound to, if any, is carried by the challenge of the proof (see Auth.VerifySession)
 type Auth struct {
-	Context  Context
-	SCommits []kyber.Point
-	T0       kyber.Point
+	Context Context
+	// the points of the authentication message, encoded with the suite of the context
+	SCommits [][]byte
+	T0       []byte
 	Proof    ClientProof
+	// encoding of Proof (see AuthVersionFull and AuthVersionCompact), 0 (full proof) for clients predating its introduction
+	Version int
+	// request the full transcript of the authentication (see AuthTranscript) along with the receipt
+	WithTranscript bool
 }
 
-// AuthReply provides a net (and awk friendly) compatible representation of the daga.ServerMessage struct
-// (which embeds an auth message struct which embeds a context which ..)
-// FIXME don't reply with server message but only tag (authentified/endorsed by all servers) and fix the "dumbnesses" in daga.server..
+// AuthReply is the result of a successful authentication, the receipt collectively signed by the daga servers
+// and, if requested (see Auth.WithTranscript), the full transcript of the authentication
 type AuthReply struct {
+	Receipt    AuthReceipt
+	Transcript *AuthTranscript
+}
+
+// AuthReceipt is the proof, collectively signed by all the daga servers of the context, that a client authenticated
+// under the context with the final linkage tag Tag (see Context.VerifyReceipt), small enough to be stored by clients and 3rd-party services
+type AuthReceipt struct {
+	ContextID ContextID
+	// final linkage tag of the client, encoded with the suite of the context
+	Tag []byte
+	// hash of the request of the client (see AuthRequestHash)
+	RequestHash []byte
+	// time of the authentication (unix time in seconds) according to the leader of the servers
+	Timestamp int64
+	// collective signature of the receipt by the daga servers of the context (see AuthReceipt.SignData and daga.VerifyCollectiveSignature)
+	CollectiveSignature []byte
+	// participation mask of the CollectiveSignature (see daga.MaskParticipants)
+	ParticipationMask []byte
+	// binding of the authentication to the session of a relying party (see Session.Binding), empty if not bound to a session
+	Session []byte
+}
+
+// AuthTranscript provides a net (and awk friendly) compatible representation of the daga.ServerMessage struct
+// (which embeds an auth message struct which embeds a context which ..), the full transcript of an authentication
+type AuthTranscript struct {
 	Request Auth
-	Tags    []kyber.Point
+	// the points and scalars of the transcript, encoded with the suite of the context
+	Tags    [][]byte
 	Proofs  []ServerProof
 	Indexes []int
 	Sigs    []ServerSignature
 }
 
 // ServerProof is a copy of daga.ServerProof to make awk proto generation happy (don't have proto generation in sign/daga)
-// TODO see FIXME above
 type ServerProof struct {
-	T1 kyber.Point
-	T2 kyber.Point
-	T3 kyber.Point
-	C  kyber.Scalar
-	R1 kyber.Scalar
-	R2 kyber.Scalar
+	T1 []byte
+	T2 []byte
+	T3 []byte
+	C  []byte
+	R1 []byte
+	R2 []byte
 }
 
 // Context implements the daga.AuthenticationContext interface
@@ -104,22 +157,35 @@ type Context struct {
 	ContextID ContextID
 	// ID of the 3rd-party service that use this context for auth. purposes
 	ServiceID ServiceID
-	// signatures that show endorsement of the context by all the daga servers
+	// signatures that show endorsement of the context by all the daga servers (one per server), empty if the context carries a CollectiveSignature
 	Signatures [][]byte
+	// collective signature of the context by the daga servers (see daga.VerifyCollectiveSignature), replaces Signatures
+	CollectiveSignature []byte
+	// participation mask of the CollectiveSignature (see daga.MaskParticipants)
+	ParticipationMask []byte
 	// awk friendly version of daga.MinimumAuthenticationContext { daga.Members, R, H } that was previously relied upon to implement the interface TODO: create proto files for sign/daga and keep original intent.
-	X      []kyber.Point
-	Y      []kyber.Point
-	R      []kyber.Point
-	H      []kyber.Point
+	// the points are encoded with the suite of the context (see Members)
+	X      [][]byte
+	Y      [][]byte
+	R      [][]byte
+	H      [][]byte
 	Roster *onet.Roster
+	// name of the daga.Suite of the context (see daga.SuiteByName), covered by the signatures, empty for contexts created before its introduction (default suite)
+	SuiteName string
+	// encoding of the data signed and hashed under the context (see daga.ContextEncoding), 0 (legacy encoding) for contexts created before its introduction
+	EncodingVersion int
+	// derivation of the client generators H (see daga.GenerateClientGenerator), 0 (daga.GeneratorsPick) for contexts created before its introduction
+	GeneratorVersion int
+	// scheme of the signatures of the servers (see daga.ContextSignatureScheme), 0 (daga.SignatureSchnorr) for contexts created before its introduction
+	SignatureScheme int
 }
 
 // ClientProof is a copy of daga.Challenge to make awk proto generation happy (don't have proto generation in sign/daga)
 type ClientProof struct {
 	Cs PKclientChallenge
-	T  []kyber.Point
-	C  []kyber.Scalar
-	R  []kyber.Scalar
+	T  [][]byte
+	C  [][]byte
+	R  [][]byte
 }
 
 type Traffic struct {
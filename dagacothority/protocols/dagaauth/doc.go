@@ -3,4 +3,13 @@
 //
 //  The protocol is meant to be launched upon reception of an Auth request by the DAGA service using the
 //  `newDAGAServerProtocol`-method of the service (that will take care of doing things right.)
+//
+//  The protocol ends with all the servers collectively signing the receipt of the authentication
+//  (see dagacothority.AuthReceipt), sent back to the client instead of the whole server message.
+//  The receipt of a request bound to the session of a relying party carries the binding of its challenge
+//  (see dagacothority.Session), hence can be checked by the relying party (see dagacothority.Context.VerifyReceiptForSession).
+//
+//  Every server records the challenge of the request once it verified the client's proof and rejects the requests
+//  whose challenge expired or was already used by another request (see dagacothority.ConsumedChallenges),
+//  s.t. captured challenges and requests can't be replayed.
 package dagaauth
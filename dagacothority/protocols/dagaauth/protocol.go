@@ -11,24 +11,33 @@ package dagaauth
 // `newDAGAServerProtocol`-method of the service (that will take care of doing things right.)
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/dedis/onet/network"
 	"github.com/dedis/student_18_daga/dagacothority"
 	"github.com/dedis/student_18_daga/dagacothority/protocols"
 	"github.com/dedis/student_18_daga/sign/daga"
+	"go.dedis.ch/kyber"
 	"time"
 
 	"github.com/dedis/onet"
 	"github.com/dedis/onet/log"
 )
 
-var suite = daga.NewSuiteEC()
-
 // Timeout represents the max duration/amount of time to wait for result in WaitForResult
 // TODO educated timeout formula that scale with number of nodes etc..
 const Timeout = 2500 * time.Second
 
+// VerificationMode is the way the nodes verify the contributions of the previous nodes in the ring,
+// by default only the contribution of the previous node is verified (linear instead of quadratic work for the ring),
+// the full verification is done by all the nodes at the end, on the final server message (see handleFinishedServerMsg and handleSign)
+var VerificationMode = daga.VerifyPrevious
+
+// ReceiptMaxClockSkew is the maximum difference between the timestamp of a receipt, chosen by the Leader,
+// and the clock of the nodes asked to sign it
+var ReceiptMaxClockSkew = 5 * time.Minute
+
 func init() {
 	network.RegisterMessage(ServerMsg{}) // register here first message of protocol s.t. every node know how to handle them (before NewProtocol has a chance to register all the other, since it won't be called if onet doesnt know what do to with them)
 	// QUESTION protocol is tied to service => according to documentation I need to call Server.ProtocolRegisterName
@@ -36,14 +45,39 @@ func init() {
 	onet.GlobalProtocolRegister(Name, NewProtocol)
 }
 
+// result of the protocol, the signed receipt of the authentication and the completed server message
+type result struct {
+	receipt   dagacothority.AuthReceipt
+	serverMsg daga.ServerMessage
+}
+
 // Protocol holds the state of the protocol instance.
 type Protocol struct {
 	*onet.TreeNodeInstance
-	result chan daga.ServerMessage // channel that will receive the result of the protocol, only root/leader read/write to it
+	result chan result // channel that will receive the result of the protocol, only root/leader read/write to it
 
 	dagaServer    daga.Server                                      // the daga server of this protocol instance, should be populated from infos taken from Service at protocol creation time (see LeaderSetup and ChildSetup)
 	request       dagacothority.Auth                               // the client's request (set by service using LeaderSetup)
 	acceptContext func(dagacothority.Context) (daga.Server, error) // a function to call to verify that context is valid and accepted by our node (set by service at protocol creation time)
+	// a function to call to record that the challenge of the request was used, that fails if it expired or was already used (set by service at protocol creation time)
+	consumeChallenge func(dagacothority.Context, daga.Challenge) error
+
+	cosignSecrets [2]*daga.Secret            // the secrets of our two commitments to the collective signature of the receipt, released once used (or when the protocol is done, see Shutdown)
+	receipt       *dagacothority.AuthReceipt // the receipt being signed (used only by leader)
+	serverMsg     *daga.ServerMessage        // the completed server message (used only by leader)
+	cosign        *cosignature               // the collective signature of the receipt being produced (used only by leader)
+}
+
+// the state of the collective signature of the receipt, the aggregate commitment, the participation mask,
+// the commitments of the nodes (at their index in context), the binding coefficient, the challenge and the responses
+type cosignature struct {
+	commit    kyber.Point
+	mask      []byte
+	commits1  []kyber.Point
+	commits2  []kyber.Point
+	binding   kyber.Scalar
+	challenge kyber.Scalar
+	responses []kyber.Scalar
 }
 
 // NewProtocol initialises the structure for use in one round, callback passed to onet upon protocol registration
@@ -58,7 +92,7 @@ func NewProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
 	t := &Protocol{
 		TreeNodeInstance: n,
 	}
-	for _, handler := range []interface{}{t.handleServerMsg, t.handleFinishedServerMsg} {
+	for _, handler := range []interface{}{t.handleServerMsg, t.handleFinishedServerMsg, t.handleSign, t.handleSignReply} {
 		if err := t.RegisterHandler(handler); err != nil {
 			return nil, errors.New("couldn't register handler: " + err.Error())
 		}
@@ -67,20 +101,24 @@ func NewProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
 }
 
 // LeaderSetup is a setup function that needs to be called after protocol creation on Leader/root (and only at that time !)
-func (p *Protocol) LeaderSetup(req dagacothority.Auth, dagaServer daga.Server) {
-	if p.dagaServer != nil || p.result != nil || p.acceptContext != nil {
+// the protocol instance owns dagaServer and releases its secrets when done (see Shutdown)
+func (p *Protocol) LeaderSetup(req dagacothority.Auth, dagaServer daga.Server, consumeChallenge func(dagacothority.Context, daga.Challenge) error) {
+	if p.dagaServer != nil || p.result != nil || p.acceptContext != nil || p.consumeChallenge != nil {
 		log.Panic("protocol setup: LeaderSetup called on an already initialized node.")
 	}
 	p.setRequest(req)
 	p.setDagaServer(dagaServer)
+	p.setConsumeChallenge(consumeChallenge)
 }
 
 // ChildSetup is a setup function that needs to be called after protocol creation on other (non root/Leader) tree nodes
-func (p *Protocol) ChildSetup(acceptContext func(ctx dagacothority.Context) (daga.Server, error)) {
-	if p.dagaServer != nil || p.result != nil || p.acceptContext != nil {
+// the protocol instance owns the daga.Server returned by the validator and releases its secrets when done (see Shutdown)
+func (p *Protocol) ChildSetup(acceptContext func(ctx dagacothority.Context) (daga.Server, error), consumeChallenge func(dagacothority.Context, daga.Challenge) error) {
+	if p.dagaServer != nil || p.result != nil || p.acceptContext != nil || p.consumeChallenge != nil {
 		log.Panic("protocol setup: ChildSetup called on an already initialized node.")
 	}
 	p.setAcceptContext(acceptContext)
+	p.setConsumeChallenge(consumeChallenge)
 }
 
 // setter to let know the protocol instance "what is the daga Context validation strategy"
@@ -91,6 +129,14 @@ func (p *Protocol) setAcceptContext(acceptContext func(ctx dagacothority.Context
 	p.acceptContext = acceptContext
 }
 
+// setter to let know the protocol instance "how to reject the replayed challenges" (see dagacothority.ConsumedChallenges)
+func (p *Protocol) setConsumeChallenge(consumeChallenge func(dagacothority.Context, daga.Challenge) error) {
+	if consumeChallenge == nil {
+		log.Panic("protocol setup: nil challenge consumer (consumeChallenge())")
+	}
+	p.consumeChallenge = consumeChallenge
+}
+
 // setter to let know the protocol instance "which daga.Server it is"
 func (p *Protocol) setDagaServer(dagaServer daga.Server) {
 	if dagaServer == nil {
@@ -122,43 +168,65 @@ func (p *Protocol) Start() (err error) {
 	log.Lvlf3("leader (%s) started %s", p.ServerIdentity(), Name)
 
 	// initialize the channel used to grab results / synchronize with WaitForResult
-	p.result = make(chan daga.ServerMessage)
+	p.result = make(chan result)
 
 	// leader initialize the server message with the request from the client
-	request, context := p.request.NetDecode()
+	request, context, err := p.request.NetDecode()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
 	serverMsg, err := daga.InitializeServerMessage(request)
 	if err != nil {
 		return fmt.Errorf("%s: %s", Name, err)
 	}
+	suite, err := context.Suite()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
 
 	// run "protocol"
-	if err := daga.ServerProtocol(suite, serverMsg, p.dagaServer); err != nil {
+	if err := daga.ServerProtocolWithMode(suite, serverMsg, p.dagaServer, VerificationMode); err != nil {
+		logBlame(err)
 		return fmt.Errorf("%s: %s", Name, err)
 	}
 
-	return p.sendToNextServer(&ServerMsg{*dagacothority.NetEncodeServerMessage(context, serverMsg)})
+	// the client's proof is verified, use its challenge (if expired or already used, the request is a replay)
+	if err := p.consumeChallenge(context, request.P0.Cs); err != nil {
+		return fmt.Errorf("%s: challenge rejected: %s", Name, err)
+	}
+
+	// commit to the collective signature of the receipt
+	commit1, commit2 := p.newCosignatureCommitments(suite)
+
+	// keep the encoding of the proof chosen by the client
+	return p.sendToNextServer(&ServerMsg{
+		AuthTranscript: dagacothority.NetEncodeServerMessage(context, serverMsg).WithVersion(p.request.Version),
+		Commitments1:   [][]byte{dagacothority.EncodePoint(commit1)},
+		Commitments2:   [][]byte{dagacothority.EncodePoint(commit2)},
+	})
 }
 
-// WaitForResult waits for protocol result (and return it) or timeout, must be called on root instance only (meant to be called by the service, after Start)
-func (p *Protocol) WaitForResult() (daga.ServerMessage, error) {
+// WaitForResult waits for protocol result (and return it) or timeout, must be called on root instance only (meant to be called by the service, after Start),
+// the result is the receipt of the authentication, collectively signed by all the nodes, and the completed server message
+func (p *Protocol) WaitForResult() (dagacothority.AuthReceipt, daga.ServerMessage, error) {
 	if p.result == nil {
 		log.Panic("WaitForResult called on an uninitialized protocol instance or non root/Leader protocol instance")
 	}
 
 	// wait for protocol result or timeout
 	select {
-	case serverMsg := <-p.result:
-		log.Lvlf3("finished %s, resulting message: %v", Name, serverMsg)
-		return serverMsg, nil
+	case res := <-p.result:
+		log.Lvlf3("finished %s, resulting message: %v", Name, res.serverMsg)
+		return res.receipt, res.serverMsg, nil
 	case <-time.After(Timeout):
-		return daga.ServerMessage{}, errors.New(Name + " didn't finish in time")
+		return dagacothority.AuthReceipt{}, daga.ServerMessage{}, errors.New(Name + " didn't finish in time")
 	}
 }
 
 // Handler that is called upon reception of the daga.ServerMessage from previous node.
 // will check that the context of the request is accepted by current node before
-// running the "daga.ServerProtocol" on it and either forwarding it to next node or broadcasting it to all nodes
-// if current node is last node
+// running the "daga.ServerProtocol" on it, committing to the collective signature of the receipt and either forwarding it
+// to next node or sending it to the Leader if current node is last node
 //
 // Step 1-4 of of daga server's protocol described in Syta - 4.3.6
 func (p *Protocol) handleServerMsg(msg StructServerMsg) (err error) {
@@ -170,10 +238,13 @@ func (p *Protocol) handleServerMsg(msg StructServerMsg) (err error) {
 	log.Lvlf3("%s: Received ServerMsg", Name)
 
 	// decode
-	serverMsg, context := msg.NetDecode()
+	serverMsg, context, err := msg.NetDecode()
 	if err != nil {
 		return fmt.Errorf("%s: %s", Name, err)
 	}
+	if len(msg.Commitments1) != len(serverMsg.Indexes) || len(msg.Commitments2) != len(serverMsg.Indexes) {
+		return fmt.Errorf("%s: wrong number of commitments to the collective signature of the receipt", Name)
+	}
 
 	// check if context accepted by our node
 	if dagaServer, err := p.acceptContext(context); err != nil {
@@ -182,55 +253,339 @@ func (p *Protocol) handleServerMsg(msg StructServerMsg) (err error) {
 		p.setDagaServer(dagaServer)
 		p.request.Context = context
 	}
+	suite, err := context.Suite()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
 
 	// run "protocol"
-	if err := daga.ServerProtocol(suite, serverMsg, p.dagaServer); err != nil {
+	if err := daga.ServerProtocolWithMode(suite, serverMsg, p.dagaServer, VerificationMode); err != nil {
+		logBlame(err)
 		return fmt.Errorf("%s: %s", Name, err)
 	}
 
-	// forward to next node or broadcast to everyone if we are the last one
+	// the client's proof is verified, use its challenge (if expired or already used, the request is a replay)
+	if err := p.consumeChallenge(context, serverMsg.Request.P0.Cs); err != nil {
+		return fmt.Errorf("%s: challenge rejected: %s", Name, err)
+	}
+
+	// commit to the collective signature of the receipt
+	commit1, commit2 := p.newCosignatureCommitments(suite)
+
+	// forward to next node or send to Leader if we are the last one
 	members := context.Members()
 	weAreLastServer := len(serverMsg.Indexes) == len(members.Y)
 
-	netServerMsg := *dagacothority.NetEncodeServerMessage(context, serverMsg)
+	// keep the encoding of the proof chosen by the client
+	netServerMsg := ServerMsg{
+		AuthTranscript: dagacothority.NetEncodeServerMessage(context, serverMsg).WithVersion(msg.Request.Version),
+		Commitments1:   append(msg.Commitments1, dagacothority.EncodePoint(commit1)),
+		Commitments2:   append(msg.Commitments2, dagacothority.EncodePoint(commit2)),
+	}
 	if weAreLastServer {
-		// broadcast to everyone (including us)
-		msg := FinishedServerMsg{netServerMsg}
-		errs := p.Broadcast(&msg)
-		if len(errs) != 0 {
-			return fmt.Errorf(Name+": failed to terminate: broadcast of FinishedServerMsg failed with error(s): %v", errs)
-		}
-		return p.SendTo(p.TreeNode(), &msg) // send to self
+		return p.SendTo(p.Root(), &FinishedServerMsg{netServerMsg})
 	} else {
-		return p.sendToNextServer(&ServerMsg{netServerMsg})
+		return p.sendToNextServer(&netServerMsg)
 	}
 }
 
-func (p *Protocol) handleFinishedServerMsg(msg StructFinishedServerMsg) error {
-	defer p.Done()
+// Handler that is called on the Leader upon reception of the completed daga.ServerMessage from the last node.
+// will verify it, extract the final linkage tag, build the receipt of the authentication and request the share of the
+// collective signature of the receipt of all other nodes
+func (p *Protocol) handleFinishedServerMsg(msg StructFinishedServerMsg) (err error) {
+	defer func() {
+		if err != nil {
+			p.Done()
+		}
+	}()
 	log.Lvlf3("%s: Received FinishedServerMsg", Name)
 
-	weAreLeader := p.acceptContext == nil // TODO FIXME what could be a better way ??.. don't like using things for multiple non obvious purposes => maybe decide that leader is at root of tree (and bye bye the potential "ring-tree")
+	if !p.IsRoot() {
+		return fmt.Errorf("%s: received FinishedServerMsg but not Leader", Name)
+	}
+
+	serverMsg, context, err := msg.NetDecode()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	suite, err := context.Suite()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	if !context.Equals(p.request.Context) {
+		return fmt.Errorf("%s: FinishedServerMsg of another context", Name)
+	}
+
+	// verify and extract tag (the receipt of a client flagged as misbehaving carries the tag that tells it)
+	Tf, err := daga.GetFinalLinkageTag(suite, context, *serverMsg)
+	if err != nil {
+		logBlame(err)
+		return fmt.Errorf("%s: cannot verify server message: %s", Name, err)
+	}
+
+	// build the receipt
+	requestHash, err := dagacothority.AuthRequestHash(suite, serverMsg.Request)
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	receipt := dagacothority.AuthReceipt{
+		ContextID:   context.ContextID,
+		Tag:         dagacothority.EncodePoint(Tf),
+		RequestHash: requestHash,
+		Timestamp:   time.Now().Unix(),
+		// the binding of the request to the session of a relying party, if any, signed by all the nodes along with its challenge
+		Session: serverMsg.Request.P0.Cs.Session,
+	}
 
-	serverMsg, context := msg.NetDecode()
+	// and our share of its collective signature
+	cosign, err := p.cosignatureChallenge(suite, context, msg.ServerMsg, serverMsg.Indexes, receipt)
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	response, err := p.cosignatureResponse(suite, context, cosign)
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	cosign.responses = []kyber.Scalar{response}
+
+	// save in state
+	p.receipt = &receipt
+	p.serverMsg = serverMsg
+	p.cosign = cosign
+
+	// request the share of all other nodes
+	errs := p.Broadcast(&Sign{
+		ServerMsg: msg.ServerMsg,
+		Receipt:   receipt,
+	})
+	if len(errs) != 0 {
+		return fmt.Errorf("%s: broadcast of Sign failed with error(s): %v", Name, errs)
+	}
+	return nil
+}
+
+// Handler that is called on the other nodes upon reception of the Leader's Sign message.
+// will verify the completed daga.ServerMessage and that the receipt matches it before sending back our share of the
+// collective signature of the receipt
+func (p *Protocol) handleSign(msg StructSign) (err error) {
+	defer p.Done()
+	log.Lvlf3("%s: Received Leader's Sign", Name)
+
+	// we must have contributed to the server message
+	if p.dagaServer == nil {
+		return fmt.Errorf("%s: received Sign before ServerMsg", Name)
+	}
+	serverMsg, context, err := msg.NetDecode()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	if !context.Equals(p.request.Context) {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: another context", Name)
+	}
+	suite, err := context.Suite()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
 
 	// verify and extract tag
-	_, err := daga.GetFinalLinkageTag(suite, context, *serverMsg)
+	Tf, err := daga.GetFinalLinkageTag(suite, context, *serverMsg)
 	if err != nil {
+		logBlame(err)
 		return fmt.Errorf("%s: cannot verify server message: %s", Name, err)
 	}
 
-	if !weAreLeader {
-		// TODO can do something.. don't know now..keep somewhere tag for later usage in login service
-		//  or to keep stats in context etc..and offer a new endpoint in service
-		//  because it is a "feature" of daga, can revoke context or auth from user based on some policies
-	} else {
-		// make resulting message (and hence final linkage tag available to service => send back to client
-		p.result <- *serverMsg // TODO maybe send netServerMsg instead => save one encoding to the service
+	// verify that the receipt tells what happened
+	requestHash, err := dagacothority.AuthRequestHash(suite, serverMsg.Request)
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
+	}
+	receipt := msg.Receipt
+	if receipt.ContextID != context.ContextID || !bytes.Equal(receipt.Tag, dagacothority.EncodePoint(Tf)) || !bytes.Equal(receipt.RequestHash, requestHash) ||
+		!bytes.Equal(receipt.Session, serverMsg.Request.P0.Cs.Session) {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: receipt doesn't match the server message", Name)
+	}
+	if skew := time.Since(time.Unix(receipt.Timestamp, 0)); skew > ReceiptMaxClockSkew || skew < -ReceiptMaxClockSkew {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: receipt timestamp too far from our clock (%s)", Name, skew)
+	}
+
+	// send our share of the collective signature back to leader
+	cosign, err := p.cosignatureChallenge(suite, context, msg.ServerMsg, serverMsg.Indexes, receipt)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
+	}
+	response, err := p.cosignatureResponse(suite, context, cosign)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
+	}
+	return p.SendTo(msg.TreeNode, &SignReply{
+		Index:    p.dagaServer.Index(),
+		Response: dagacothority.EncodeScalar(response),
+	})
+}
+
+// Handler that will be called by framework when Leader node has received a SignReply from all other nodes (its children),
+// will verify their shares and make the signed receipt available to the service
+func (p *Protocol) handleSignReply(msg []StructSignReply) (err error) {
+	defer p.Done()
+	log.Lvlf3("%s: Leader received all Sign replies", Name)
+
+	if p.cosign == nil {
+		return fmt.Errorf("%s: received SignReply before FinishedServerMsg", Name)
+	}
+	context := p.request.Context
+	suite, err := context.Suite()
+	if err != nil {
+		return fmt.Errorf("%s: %s", Name, err)
 	}
+
+	// verify all the responses (to blame the culprit instead of only rejecting the aggregate)
+	keys := context.Members().Y
+	replied := map[int]bool{p.dagaServer.Index(): true}
+	for _, signReply := range msg {
+		index := signReply.Index
+		if index < 0 || index >= len(keys) || replied[index] {
+			return fmt.Errorf("%s: SignReply for wrong or already signed index %d", Name, index)
+		}
+		replied[index] = true
+		response, err := daga.DecodeScalar(suite, signReply.Response)
+		if err != nil {
+			return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+		}
+		commit := boundCommitment(suite, p.cosign, index)
+		if err := daga.VerifyCosignatureResponse(suite, keys, index, commit, p.cosign.challenge, response); err != nil {
+			return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+		}
+		p.cosign.responses = append(p.cosign.responses, response)
+	}
+	if len(replied) != len(keys) {
+		return fmt.Errorf("%s: only %d of the %d nodes signed the receipt", Name, len(replied), len(keys))
+	}
+
+	// make result available to service
+	receipt := *p.receipt
+	if receipt.CollectiveSignature, err = daga.NewCollectiveSignature(suite, p.cosign.commit, p.cosign.responses); err != nil {
+		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+	}
+	receipt.ParticipationMask = p.cosign.mask
+	if err := context.VerifyReceipt(receipt); err != nil {
+		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+	}
+	p.result <- result{receipt: receipt, serverMsg: *p.serverMsg}
 	return nil
 }
 
+// Shutdown releases the secrets of our commitments to the collective signature of the receipt and those of our daga server
+// (decoded for this protocol instance, see LeaderSetup and ChildSetup), called by onet when the protocol instance is done
+func (p *Protocol) Shutdown() error {
+	p.cosignSecrets[0].Release()
+	p.cosignSecrets[1].Release()
+	daga.ReleaseSecrets(p.dagaServer)
+	return p.TreeNodeInstance.Shutdown()
+}
+
+// returns two fresh commitments to the collective signature of the receipt (see daga.BindCosignatureCommitments) and keeps their secrets
+func (p *Protocol) newCosignatureCommitments(suite daga.Suite) (kyber.Point, kyber.Point) {
+	commit1, secret1 := daga.NewCosignatureCommitment(suite)
+	commit2, secret2 := daga.NewCosignatureCommitment(suite)
+	p.cosignSecrets = [2]*daga.Secret{daga.NewSecret(secret1), daga.NewSecret(secret2)}
+	return commit1, commit2
+}
+
+// returns the state of the collective signature of receipt by all the nodes of context, where msg carries the commitments
+// of the nodes in the order of indexes (the indexes of the completed server message)
+func (p *Protocol) cosignatureChallenge(suite daga.Suite, context dagacothority.Context, msg ServerMsg, indexes []int, receipt dagacothority.AuthReceipt) (*cosignature, error) {
+	keys := context.Members().Y
+	n := len(keys)
+	if n == 0 || len(indexes) != n || len(msg.Commitments1) != n || len(msg.Commitments2) != n {
+		return nil, errors.New("wrong number of commitments to the collective signature of the receipt")
+	}
+	commits1, err := dagacothority.DecodePoints(suite, msg.Commitments1)
+	if err != nil {
+		return nil, err
+	}
+	commits2, err := dagacothority.DecodePoints(suite, msg.Commitments2)
+	if err != nil {
+		return nil, err
+	}
+	cosign := &cosignature{
+		commits1: make([]kyber.Point, n),
+		commits2: make([]kyber.Point, n),
+	}
+	for i, index := range indexes {
+		if index < 0 || index >= n || cosign.commits1[index] != nil {
+			return nil, fmt.Errorf("commitment of wrong or already committed index %d", index)
+		}
+		cosign.commits1[index] = commits1[i]
+		cosign.commits2[index] = commits2[i]
+	}
+	// the participants are the nodes that committed
+	participants := make([]int, 0, n)
+	for index, commit := range cosign.commits1 {
+		if commit != nil {
+			participants = append(participants, index)
+		}
+	}
+	if cosign.mask, err = daga.NewParticipationMask(n, participants); err != nil {
+		return nil, err
+	}
+	data, err := receipt.SignData()
+	if err != nil {
+		return nil, err
+	}
+	aggregateKey, err := daga.AggregateKey(suite, keys, cosign.mask)
+	if err != nil {
+		return nil, err
+	}
+	commit1, err := daga.AggregateCommitment(suite, cosign.commits1, cosign.mask)
+	if err != nil {
+		return nil, err
+	}
+	commit2, err := daga.AggregateCommitment(suite, cosign.commits2, cosign.mask)
+	if err != nil {
+		return nil, err
+	}
+	if cosign.commit, cosign.binding, err = daga.BindCosignatureCommitments(suite, aggregateKey, commit1, commit2, data); err != nil {
+		return nil, err
+	}
+	if cosign.challenge, err = daga.CosignatureChallenge(suite, context.SigningScheme(), aggregateKey, cosign.commit, data); err != nil {
+		return nil, err
+	}
+	return cosign, nil
+}
+
+// returns the commitment V_i1 + b*V_i2 of the node at index to the collective signature
+func boundCommitment(suite daga.Suite, cosign *cosignature, index int) kyber.Point {
+	return suite.Point().Add(cosign.commits1[index], suite.Point().Mul(cosign.binding, cosign.commits2[index]))
+}
+
+// returns our response to the challenge of the collective signature of the receipt,
+// our commitments answer a single challenge (the secrets are released)
+func (p *Protocol) cosignatureResponse(suite daga.Suite, context dagacothority.Context, cosign *cosignature) (kyber.Scalar, error) {
+	secret1, secret2 := p.cosignSecrets[0].Scalar(), p.cosignSecrets[1].Scalar()
+	if secret1 == nil || secret2 == nil {
+		return nil, errors.New("commitments to the collective signature already used")
+	}
+	defer p.cosignSecrets[0].Release()
+	defer p.cosignSecrets[1].Release()
+
+	// verify that our commitments are the ones of the message
+	index := p.dagaServer.Index()
+	if index < 0 || index >= len(cosign.commits1) ||
+		!cosign.commits1[index].Equal(suite.Point().Mul(secret1, nil)) || !cosign.commits2[index].Equal(suite.Point().Mul(secret2, nil)) {
+		return nil, errors.New("wrong node commitments to the collective signature")
+	}
+	secret := suite.Scalar().Add(secret1, suite.Scalar().Mul(cosign.binding, secret2))
+	return daga.CosignatureResponse(suite, context.Members().Y, p.dagaServer, secret, cosign.challenge)
+}
+
+// logs the verification report of the server message if err blames some servers, to help identify the faulty ones
+func logBlame(err error) {
+	var blame *daga.BlameError
+	if errors.As(err, &blame) {
+		log.Errorf("%s: verification report of the server message:\n%s", Name, blame.Report)
+	}
+}
+
 // TODO see remark in protocols/utils, would be nice to share more code between daga protocols
 func (p *Protocol) sendToNextServer(msg interface{}) error {
 	// figure out the node of the next-server in "ring"
@@ -1,6 +1,7 @@
 package dagaauth_test
 
 import (
+	"errors"
 	"github.com/dedis/onet"
 	"github.com/dedis/onet/log"
 	"github.com/dedis/student_18_daga/dagacothority"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 var tSuite = daga.NewSuiteEC()
@@ -18,6 +20,11 @@ func TestMain(m *testing.M) {
 	log.MainTest(m)
 }
 
+// challenge consumer of the tests that don't need to reject replayed challenges
+func acceptChallenge(dagacothority.Context, daga.Challenge) error {
+	return nil
+}
+
 // Tests a 2, 5 and 13-node system. (complete protocol run)
 func TestServerProtocol(t *testing.T) {
 	nodes := []int{2, 5, 13}
@@ -37,7 +44,7 @@ func runProtocol(t *testing.T, nbrNodes int) {
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 	dagaProtocol := services[0].(*protocols_testing.DummyService).NewDAGAServerProtocol(t, *netRequest)
 
-	serverMsg, err := dagaProtocol.WaitForResult()
+	receipt, serverMsg, err := dagaProtocol.WaitForResult()
 	require.NoError(t, err, "failed to get result of protocol run (valid setup)")
 	require.NotZero(t, serverMsg)
 
@@ -45,6 +52,29 @@ func runProtocol(t *testing.T, nbrNodes int) {
 	Tf, err := daga.GetFinalLinkageTag(tSuite, dummyContext, serverMsg)
 	require.NoError(t, err, "failed to extract tag from the resulting ServerMsg")
 	require.NotZero(t, Tf)
+
+	// the receipt is signed by all the nodes and tells the tag of the request
+	require.NoError(t, dummyContext.VerifyReceipt(receipt))
+	require.Equal(t, dagacothority.EncodePoint(Tf), receipt.Tag)
+	requestHash, err := dagacothority.AuthRequestHash(tSuite, *dummyRequest)
+	require.NoError(t, err)
+	require.Equal(t, requestHash, receipt.RequestHash)
+	require.WithinDuration(t, time.Now(), time.Unix(receipt.Timestamp, 0), dagaauth.ReceiptMaxClockSkew)
+	require.Empty(t, receipt.Session, "receipt of a request not bound to a session bound to a session")
+
+	// the challenge of the request was consumed by all the nodes, the request can't be replayed
+	for _, service := range services {
+		service := service.(*protocols_testing.DummyService)
+		err := service.ConsumeChallenge(*dummyContext, dummyRequest.P0.Cs)
+		require.True(t, errors.Is(err, dagacothority.ErrChallengeReplayed), "wrong error: %v", err)
+	}
+	leader := services[0].(*protocols_testing.DummyService)
+	tree := dummyContext.Roster.GenerateNaryTreeWithRoot(nbrNodes-1, leader.ServerIdentity())
+	pi, err := leader.CreateProtocol(dagaauth.Name, tree)
+	require.NoError(t, err)
+	replay := pi.(*dagaauth.Protocol)
+	replay.LeaderSetup(*netRequest, leader.CopyDagaServer(), leader.ConsumeChallenge)
+	require.Error(t, replay.Start(), "replayed request accepted")
 }
 
 // TODO remove the unnecessary local setup in tests that only check behavior of methods/func in isolation
@@ -63,7 +93,7 @@ func TestLeaderSetup(t *testing.T) {
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 
 	require.NotPanics(t, func() {
-		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	}, "should not panic on valid input")
 }
 
@@ -80,10 +110,27 @@ func TestLeaderSetupShouldPanicOnNilServer(t *testing.T) {
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 
 	require.Panics(t, func() {
-		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, nil)
+		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, nil, acceptChallenge)
 	}, "should panic on nil server")
 }
 
+func TestLeaderSetupShouldPanicOnNilChallengeConsumer(t *testing.T) {
+	local := onet.NewLocalTest(tSuite)
+	defer local.CloseAll()
+
+	nbrNodes := 1
+	_, roster, tree := local.GenBigTree(nbrNodes, nbrNodes, nbrNodes-1, true)
+	_, dagaServers, dummyRequest, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
+	pi, _ := local.CreateProtocol(dagaauth.Name, tree)
+	defer pi.(*dagaauth.Protocol).Done()
+
+	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
+
+	require.Panics(t, func() {
+		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], nil)
+	}, "should panic on nil challenge consumer")
+}
+
 func TestLeaderSetupShouldPanicOnInvalidState(t *testing.T) {
 	local := onet.NewLocalTest(tSuite)
 	defer local.CloseAll()
@@ -95,9 +142,9 @@ func TestLeaderSetupShouldPanicOnInvalidState(t *testing.T) {
 
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 
-	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	require.Panics(t, func() {
-		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	}, "should panic on already initialized node")
 	pi.(*dagaauth.Protocol).Done()
 
@@ -106,9 +153,9 @@ func TestLeaderSetupShouldPanicOnInvalidState(t *testing.T) {
 
 	pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 		return dagaServers[0], nil
-	})
+	}, acceptChallenge)
 	require.Panics(t, func() {
-		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+		pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	}, "should panic on already initialized node")
 }
 
@@ -126,7 +173,7 @@ func TestChildrenSetup(t *testing.T) {
 	require.NotPanics(t, func() {
 		pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 			return dagaServers[0], nil
-		})
+		}, acceptChallenge)
 	}, "should not panic on valid input")
 }
 
@@ -141,11 +188,11 @@ func TestChildrenSetupShouldPanicOnInvalidState(t *testing.T) {
 
 	pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 		return dagaServers[0], nil
-	})
+	}, acceptChallenge)
 	require.Panics(t, func() {
 		pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 			return dagaServers[0], nil
-		})
+		}, acceptChallenge)
 	}, "should panic on already initialized node")
 	pi.(*dagaauth.Protocol).Done()
 
@@ -154,11 +201,11 @@ func TestChildrenSetupShouldPanicOnInvalidState(t *testing.T) {
 
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 
-	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	require.Panics(t, func() {
 		pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 			return dagaServers[0], nil
-		})
+		}, acceptChallenge)
 	}, "should panic on already initialized node")
 }
 
@@ -174,7 +221,7 @@ func TestWaitForResultShouldPanicIfCalledBeforeStart(t *testing.T) {
 
 	netRequest := dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyRequest)
 
-	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0])
+	pi.(*dagaauth.Protocol).LeaderSetup(*netRequest, dagaServers[0], acceptChallenge)
 	require.Panics(t, func() {
 		pi.(*dagaauth.Protocol).WaitForResult()
 	})
@@ -194,7 +241,7 @@ func TestWaitForResultShouldPanicOnNonRootInstance(t *testing.T) {
 
 	pi.(*dagaauth.Protocol).ChildSetup(func(ctx dagacothority.Context) (daga.Server, error) {
 		return dagaServers[0], nil
-	})
+	}, acceptChallenge)
 	require.Panics(t, func() {
 		pi.(*dagaauth.Protocol).WaitForResult()
 	})
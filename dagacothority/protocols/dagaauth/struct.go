@@ -15,9 +15,12 @@ so that it can find out who sent the message.
 // Name can be used from other packages to refer to this protocols.
 const Name = "DAGA_Server_protocol"
 
-// ServerMsg represents a daga.ServerMessage that is not yet completed by all servers, a factory
+// ServerMsg represents a daga.ServerMessage that is not yet completed by all servers, a factory,
+// along with the commitments of the servers that processed it to the collective signature of the receipt
 type ServerMsg struct {
-	dagacothority.AuthReply
+	dagacothority.AuthTranscript
+	Commitments1 [][]byte // the (encoded) first commitments of the servers (see daga.BindCosignatureCommitments), in the order of the server message (Indexes)
+	Commitments2 [][]byte // their second commitments, in the same order
 }
 
 // StructServerMsg just contains ServerMsg and the data necessary to identify and
@@ -27,9 +30,9 @@ type StructServerMsg struct {
 	ServerMsg
 }
 
-// FinishedServerMsg represents a daga.ServerMessage that is completed by all servers and ready to be sent to client
+// FinishedServerMsg represents a daga.ServerMessage that is completed by all servers, sent by the last server to the Leader
 type FinishedServerMsg struct {
-	dagacothority.AuthReply
+	ServerMsg
 }
 
 // StructFinishedServerMsg just contains FinishedServerMsg and the data necessary to identify and
@@ -38,3 +41,30 @@ type StructFinishedServerMsg struct {
 	*onet.TreeNode // sender
 	FinishedServerMsg
 }
+
+// Sign is sent from Leader to all other nodes upon reception and verification of the completed server message,
+// it requests their share of the collective signature of the receipt of the authentication
+type Sign struct {
+	ServerMsg
+	Receipt dagacothority.AuthReceipt // the receipt to sign, without its signature
+}
+
+// StructSign just contains Sign and the data necessary to identify and
+// process the message in the framework.
+type StructSign struct {
+	*onet.TreeNode // sender
+	Sign
+}
+
+// SignReply is sent from all nodes back to the Leader, their share of the collective signature of the receipt
+type SignReply struct {
+	Index    int    // the index of the node's daga.Server in the context
+	Response []byte // (encoded) response to the challenge of the collective signature of the receipt (see daga.CosignatureResponse)
+}
+
+// StructSignReply just contains SignReply and the data necessary to identify and
+// process the message in the framework.
+type StructSignReply struct {
+	*onet.TreeNode // sender
+	SignReply
+}
@@ -14,6 +14,7 @@ package dagachallengegeneration
 
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"go.dedis.ch/kyber"
@@ -27,9 +28,6 @@ import (
 	"github.com/dedis/onet/log"
 )
 
-// the DAGA crypto suite
-var suite = daga.NewSuiteEC()
-
 // Timeout represents the max duration/amount of time to wait for result in WaitForResult
 // TODO educated timeout formula that scale with number of nodes etc..
 const Timeout = 2500 * time.Second
@@ -49,13 +47,17 @@ type Protocol struct {
 	*onet.TreeNodeInstance
 	result      chan daga.Challenge        // channel that will receive the result of the protocol, only root/leader read/write to it, used to "sync" with waitForResult
 	commitments []daga.ChallengeCommitment // on the leader/root: to store every commitments (to random challenge) at correct index (in auth. context), on the children to store leaderCommitment at 0
-	openings    []kyber.Scalar             // on the leader/root: to store every opening at correct index (in auth. context), on the children store own opening at 0
+	openings    []kyber.Scalar             // on the leader/root: to store every (revealed) opening at correct index (in auth. context)
+	ownOpening  *daga.Secret               // the opening of our commitment, secret until revealed (released when the protocol is done, see Shutdown)
 
 	dagaServer daga.Server // the daga server of this protocol instance, should be populated from infos taken from Service at protocol creation time (see LeaderSetup and ChildSetup)
 
 	// FIXME store original PKclientCommitments request instead (now I don't have to decode anymore => doesnt make sense to separate the fields)
 	context             dagacothority.Context                                         // the context of the client request (set by leader when received from API call and then propagated to other instances as part of the announce message)
+	suite               daga.Suite                                                    // the DAGA crypto suite of the context
 	pKClientCommitments []kyber.Point                                                 // the commitments of the PKClient PK that were sent by client to request our honest distributed challenge
+	session             *dagacothority.Session                                        // the session of the relying party the client request is bound to, nil if not bound
+	sessionBinding      []byte                                                        // the binding of the challenge to the session (see dagacothority.Session.Binding), signed along with it
 	acceptRequest       func(*dagacothority.PKclientCommitments) (daga.Server, error) // a function to call to verify that request is accepted by our node (set by service at protocol creation time) and valid
 }
 
@@ -82,6 +84,7 @@ func NewProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
 }
 
 // LeaderSetup is a setup function that needs to be called after protocol creation on Leader/root (and only at that time !)
+// the protocol instance owns dagaServer and releases its secrets when done (see Shutdown)
 func (p *Protocol) LeaderSetup(req dagacothority.PKclientCommitments, dagaServer daga.Server) {
 	// TODO consider removing the dagaServer parameter and accept a request validator that returns dagaserver (like in ChildSetup)
 	// TODO (+): less differences between leader and child (-): redundant checks for leader
@@ -91,24 +94,31 @@ func (p *Protocol) LeaderSetup(req dagacothority.PKclientCommitments, dagaServer
 	if len(req.Context.R) == 0 || len(req.Context.H) == 0 || req.Context.Roster == nil { // TODO later maybe remove for "optimization", already checked by service + see remarks above
 		log.Panic("protocol setup: empty request")
 	}
-	p.context = req.Context
+	if err := p.setContext(req.Context); err != nil {
+		log.Panic("protocol setup: " + err.Error())
+	}
 	if len(req.Commitments) != len(p.context.ClientsGenerators())*3 { // TODO later maybe remove for "optimization", already checked by service
 		log.Panic("protocol setup: wrong commitments length")
 	}
-	p.pKClientCommitments = req.Commitments
+	if err := p.setPKClientCommitments(req.Commitments); err != nil {
+		log.Panic("protocol setup: " + err.Error())
+	}
+	if err := p.setSession(req.Session); err != nil {
+		log.Panic("protocol setup: " + err.Error())
+	}
 	p.setDagaServer(dagaServer)
 	p.commitments = make([]daga.ChallengeCommitment, len(p.Tree().List()))
 	p.openings = make([]kyber.Scalar, len(p.Tree().List()))
 }
 
 // ChildSetup is a setup function that needs to be called after protocol creation on other (non root/Leader) tree nodes
+// the protocol instance owns the daga.Server returned by the validator and releases its secrets when done (see Shutdown)
 func (p *Protocol) ChildSetup(acceptRequest func(*dagacothority.PKclientCommitments) (daga.Server, error)) {
 	if p.commitments != nil || p.openings != nil || p.dagaServer != nil || p.result != nil || p.acceptRequest != nil {
 		log.Panic("protocol setup: ChildSetup called on an already initialized node.")
 	}
 	p.setAcceptRequest(acceptRequest)
 	p.commitments = make([]daga.ChallengeCommitment, 1)
-	p.openings = make([]kyber.Scalar, 1)
 }
 
 // TODO see if I keep those setters (if yes maybe add again the one I removed..) or if it is way too overkill and stupid
@@ -128,43 +138,94 @@ func (p *Protocol) setDagaServer(dagaServer daga.Server) {
 	p.dagaServer = dagaServer
 }
 
-// method called to update state of the protocol (add opening) (sanity checks)
-func (p *Protocol) saveOpening(index int, opening kyber.Scalar) {
-	if index >= len(p.openings) {
-		log.Panicf("index (%d) out of bound while setting openings in state, len(p.openings) = %d", index, len(p.openings))
+// setter used to provide the context of the request to the protocol instance, resolves the suite of the context
+func (p *Protocol) setContext(context dagacothority.Context) error {
+	suite, err := context.Suite()
+	if err != nil {
+		return err
+	}
+	p.context = context
+	p.suite = suite
+	return nil
+}
+
+// setter used to provide the PKClient commitments of the request to the protocol instance, decodes them using the suite
+// of the context (call after setContext)
+func (p *Protocol) setPKClientCommitments(commitments [][]byte) error {
+	decoded, err := dagacothority.DecodePoints(p.suite, commitments)
+	if err != nil {
+		return errors.New("invalid PKClient commitments: " + err.Error())
+	}
+	p.pKClientCommitments = decoded
+	return nil
+}
+
+// setter used to provide the session of the request to the protocol instance, computes the binding of the challenge
+func (p *Protocol) setSession(session *dagacothority.Session) error {
+	binding, err := session.Binding(p.suite)
+	if err != nil {
+		return err
+	}
+	p.session = session
+	p.sessionBinding = binding
+	return nil
+}
+
+// method called to update state of the protocol (add opening) (sanity checks),
+// the index and opening can come from the network, hence the errors instead of panics
+func (p *Protocol) saveOpening(index int, opening kyber.Scalar) error {
+	if index < 0 || index >= len(p.openings) {
+		return fmt.Errorf("index (%d) out of bound while setting openings in state, len(p.openings) = %d", index, len(p.openings))
 	}
 	if p.openings[index] != nil {
-		log.Panicf("already one opening at p.openings[%d]", index)
+		return fmt.Errorf("already one opening at p.openings[%d]", index)
 	}
 	if opening == nil {
-		log.Panic("nil opening, not storing")
+		return errors.New("nil opening, not storing")
 	}
 	p.openings[index] = opening
+	return nil
 }
 
-// method called to retrieve opening from protocol state (sanity checks)
-func (p *Protocol) opening(index int) kyber.Scalar {
-	if index >= len(p.openings) {
-		log.Panicf("index (%d) out of bound while getting openings from state, len(p.openings) = %d", index, len(p.openings))
-	}
-	if p.openings[index] == nil {
-		log.Panicf("nil at p.openings[%d]", index)
+// method called to retrieve our opening from protocol state when revealing it (sanity checks)
+func (p *Protocol) revealOwnOpening() kyber.Scalar {
+	opening := p.ownOpening.Scalar()
+	if opening == nil {
+		log.Panic("no opening or opening already released")
 	}
-	return p.openings[index]
+	return opening
+}
+
+// Shutdown releases our opening and the secrets of our daga server (decoded for this protocol instance, see LeaderSetup
+// and ChildSetup), called by onet when the protocol instance is done
+func (p *Protocol) Shutdown() error {
+	p.ownOpening.Release()
+	daga.ReleaseSecrets(p.dagaServer)
+	return p.TreeNodeInstance.Shutdown()
 }
 
 // method called to update state of the protocol (add commitment) (doesn't check commitment signature, call only with commitments whose signature is verified !)
-func (p *Protocol) saveCommitment(index int, commitment daga.ChallengeCommitment) {
-	if index >= len(p.commitments) {
-		log.Panicf("index (%d) out of bound while setting commitment in state, len(p.commitment) = %d, you probably forgot to call ChildSetup", index, len(p.commitments))
+// the index and commitment can come from the network, hence the errors instead of panics
+func (p *Protocol) saveCommitment(index int, commitment daga.ChallengeCommitment) error {
+	if index < 0 || index >= len(p.commitments) {
+		return fmt.Errorf("index (%d) out of bound while setting commitment in state, len(p.commitment) = %d, you probably forgot to call ChildSetup", index, len(p.commitments))
 	}
 	if p.commitments[index].Commit != nil {
-		log.Panicf("already one commitment at p.commitment[%d]", index)
+		return fmt.Errorf("already one commitment at p.commitment[%d]", index)
 	}
 	if commitment.Commit == nil {
-		log.Panic("nil commitment, not storing")
+		return errors.New("nil commitment, not storing")
 	}
 	p.commitments[index] = commitment
+	return nil
+}
+
+// returns an error if index (received from the network) is not the index of a server of the context
+func (p *Protocol) checkServerIndex(index int) error {
+	if n := len(p.context.Members().Y); index < 0 || index >= n {
+		return fmt.Errorf("index (%d) of unknown server, %d servers in context", index, n)
+	}
+	return nil
 }
 
 // method called to retrieve commitment from protocol state (sanity checks)
@@ -197,24 +258,27 @@ func (p *Protocol) Start() (err error) {
 	p.result = make(chan daga.Challenge)
 
 	// create leader challenge, signed commitment and opening
-	leaderChallengeCommit, leaderOpening, err := daga.NewChallengeCommitment(suite, p.dagaServer)
+	leaderChallengeCommit, leaderOpening, err := daga.NewChallengeCommitment(p.suite, p.context, p.dagaServer)
 	if err != nil {
 		return errors.New(Name + ": failed to start: " + err.Error())
 	}
-	// save commitment and opening in state
-	p.saveOpening(p.dagaServer.Index(), leaderOpening)
-	p.saveCommitment(p.dagaServer.Index(), *leaderChallengeCommit)
+	// save commitment and opening in state (the opening is saved with the others when revealed)
+	p.ownOpening = daga.NewSecret(leaderOpening)
+	if err := p.saveCommitment(p.dagaServer.Index(), *leaderChallengeCommit); err != nil {
+		return errors.New(Name + ": failed to start: " + err.Error())
+	}
 
 	// broadcast Announce requesting that all other nodes do the same and send back their signed commitments.
 	// QUESTION do work in new goroutine (here don't see the point but maybe an optimization)
 	//  and send in parallel (that's another thing..) as was done in skipchain ?
 	// TODO add a "BroadcastInParallel" method in onet
 	errs := p.Broadcast(&Announce{
-		LeaderCommit:         *leaderChallengeCommit,
+		LeaderCommit:         netEncodeChallengeCommitment(*leaderChallengeCommit),
 		LeaderIndexInContext: p.dagaServer.Index(),
 		OriginalRequest: dagacothority.PKclientCommitments{
-			Commitments: p.pKClientCommitments,
+			Commitments: dagacothority.EncodePoints(p.pKClientCommitments),
 			Context:     p.context,
+			Session:     p.session,
 		},
 	})
 	if len(errs) != 0 {
@@ -255,8 +319,15 @@ func (p *Protocol) handleAnnounce(msg StructAnnounce) (err error) {
 		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
 	} else {
 		// we can safely use leader provided context, we just validated it against our independent state
-		p.context = msg.OriginalRequest.Context
-		p.pKClientCommitments = msg.OriginalRequest.Commitments
+		if err := p.setContext(msg.OriginalRequest.Context); err != nil {
+			return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+		}
+		if err := p.setPKClientCommitments(msg.OriginalRequest.Commitments); err != nil {
+			return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+		}
+		if err := p.setSession(msg.OriginalRequest.Session); err != nil {
+			return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+		}
 		p.setDagaServer(dagaServer)
 	}
 
@@ -264,26 +335,35 @@ func (p *Protocol) handleAnnounce(msg StructAnnounce) (err error) {
 	// FIXME WHY ?: if we trust the rosters (and the daga context)
 	//  all these node-node signatures/authentication are useless since authenticity and integrity should be protected by the "DEDIS-tls" channels in Onet..
 	members := p.context.Members()
-	err = daga.VerifyChallengeCommitmentSignature(suite, msg.LeaderCommit, members.Y[msg.LeaderIndexInContext])
+	if err := p.checkServerIndex(msg.LeaderIndexInContext); err != nil {
+		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+	}
+	leaderCommit, err := msg.LeaderCommit.netDecode(p.suite)
+	if err != nil {
+		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+	}
+	err = daga.VerifyChallengeCommitmentSignature(p.suite, p.context, leaderCommit, members.Y[msg.LeaderIndexInContext])
 	if err != nil {
 		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
 	}
 
 	// store it in own state for later verification of correct opening
-	p.saveCommitment(0, msg.LeaderCommit)
+	if err := p.saveCommitment(0, leaderCommit); err != nil {
+		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+	}
 
 	// create our signed commitment to our new challenge
-	challengeCommit, opening, err := daga.NewChallengeCommitment(suite, p.dagaServer)
+	challengeCommit, opening, err := daga.NewChallengeCommitment(p.suite, p.context, p.dagaServer)
 	if err != nil {
 		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
 	}
 
 	// store our opening
-	p.saveOpening(0, opening)
+	p.ownOpening = daga.NewSecret(opening)
 
 	// send back signed commitment to leader
 	return p.SendTo(leaderTreeNode, &AnnounceReply{
-		Commit: *challengeCommit,
+		Commit: netEncodeChallengeCommitment(*challengeCommit),
 	})
 }
 
@@ -308,20 +388,32 @@ func (p *Protocol) handleAnnounceReply(msg []StructAnnounceReply) (err error) {
 	// verify signatures of the commitments from all other nodes/children
 	members := p.context.Members()
 	for _, announceReply := range msg {
-		challengeCommit := announceReply.Commit
+		challengeCommit, err := announceReply.Commit.netDecode(p.suite)
+		if err != nil {
+			return fmt.Errorf("%s: failed to handle AnnounceReply, : %s", Name, err.Error())
+		}
 		// verify signature of node's commitment
-		err := daga.VerifyChallengeCommitmentSignature(suite, challengeCommit, members.Y[challengeCommit.Index])
+		if err := p.checkServerIndex(challengeCommit.Index); err != nil {
+			return fmt.Errorf("%s: failed to handle AnnounceReply, : %s", Name, err.Error())
+		}
+		err = daga.VerifyChallengeCommitmentSignature(p.suite, p.context, challengeCommit, members.Y[challengeCommit.Index])
 		if err != nil {
 			return fmt.Errorf("%s: failed to handle AnnounceReply, : %s", Name, err.Error())
 		}
 
 		// store commitment
-		p.saveCommitment(challengeCommit.Index, challengeCommit)
+		if err := p.saveCommitment(challengeCommit.Index, challengeCommit); err != nil {
+			return fmt.Errorf("%s: failed to handle AnnounceReply, : %s", Name, err.Error())
+		}
 	}
 
 	// broadcast Leader's opening, (request other's openings)
+	leaderOpening := p.revealOwnOpening()
+	if err := p.saveOpening(p.dagaServer.Index(), leaderOpening); err != nil {
+		return fmt.Errorf("%s: failed to handle AnnounceReply, : %s", Name, err.Error())
+	}
 	errs := p.Broadcast(&Open{
-		LeaderOpening: p.opening(p.dagaServer.Index()),
+		LeaderOpening: dagacothority.EncodeScalar(leaderOpening),
 	})
 	if len(errs) != 0 {
 		return fmt.Errorf("%s: broadcast of Open failed with error(s): %v", Name, errs)
@@ -343,15 +435,19 @@ func (p *Protocol) handleOpen(msg StructOpen) (err error) {
 
 	// verify that leader's opening correctly open its commitment
 	leaderCommit := p.commitment(0)
-	if !daga.CheckOpening(suite, leaderCommit.Commit, msg.LeaderOpening) {
+	leaderOpening, err := daga.DecodeScalar(p.suite, msg.LeaderOpening)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle Leader's Open: %s", Name, err.Error())
+	}
+	if !daga.CheckOpening(p.suite, leaderCommit.Commit, leaderOpening) {
 		return fmt.Errorf("%s: failed to handle Leader's Open: wrong opening", Name)
 	}
 
 	// send our opening back to leader
 	// TODO maybe check that leader is same leader as in announce.. or some other things.. ??
-	ownOpening := p.opening(0)
+	ownOpening := p.revealOwnOpening()
 	return p.SendTo(msg.TreeNode, &OpenReply{
-		Opening: ownOpening,
+		Opening: dagacothority.EncodeScalar(ownOpening),
 		Index:   p.dagaServer.Index(),
 	})
 }
@@ -375,23 +471,37 @@ func (p *Protocol) handleOpenReply(msg []StructOpenReply) (err error) {
 	//  => (-) we distance ourselves from daga paper (no longer ring communication but star)
 
 	for _, openReply := range msg {
-		p.saveOpening(openReply.Index, openReply.Opening)
+		opening, err := daga.DecodeScalar(p.suite, openReply.Opening)
+		if err != nil {
+			return fmt.Errorf("%s: failed to handle OpenReply, : %s", Name, err.Error())
+		}
+		if err := p.saveOpening(openReply.Index, opening); err != nil {
+			return fmt.Errorf("%s: failed to handle OpenReply, : %s", Name, err.Error())
+		}
 	}
 	//After receiving all the openings, leader verifies them and initializes the challengeCheck structure
 	// TODO nicify kyber.daga "API" / previous code if possible => then clean/hide details of protocols there
-	challengeCheck, err := daga.InitializeChallenge(suite, p.context, p.commitments, p.openings)
+	challengeCheck, err := daga.InitializeChallenge(p.suite, p.context, p.commitments, p.openings)
 	if err != nil {
 		return fmt.Errorf("%s: failed to handle OpenReply, : %s", Name, err.Error())
 	}
+	// bind the challenge to the session of the request, the binding is signed by all the servers along with the challenge
+	challengeCheck.Session = p.sessionBinding
+	// issue the challenge with a fresh identifier and an expiry, signed by all the servers along with the challenge,
+	// s.t. the servers can reject the authentications that use it once expired or more than once (see dagaauth)
+	if challengeCheck.ID, err = dagacothority.NewChallengeID(); err != nil {
+		return fmt.Errorf("%s: failed to handle OpenReply, : %s", Name, err.Error())
+	}
+	challengeCheck.Expiry = dagacothority.NewChallengeExpiry(time.Now())
 
 	//Then it executes CheckUpdateChallenge, to verify again... and add its signature (TODO...pff^^  clean previous code)
-	if err := daga.CheckUpdateChallenge(suite, p.context, challengeCheck, p.pKClientCommitments, p.dagaServer); err != nil {
+	if err := daga.CheckUpdateChallenge(p.suite, p.context, challengeCheck, p.pKClientCommitments, p.dagaServer); err != nil {
 		return fmt.Errorf("%s: failed to handle OpenReply, : %s", Name, err.Error())
 	}
 
 	// forward to next server ("ring topology")
 	return p.sendToNextServer(&Finalize{
-		ChallengeCheck: *challengeCheck,
+		ChallengeCheck: netEncodeChallengeCheck(*challengeCheck),
 	})
 }
 
@@ -401,22 +511,36 @@ func (p *Protocol) handleFinalize(msg StructFinalize) error {
 	defer p.Done()
 	log.Lvlf3("%s: %s Received Finalize", Name, p.ServerIdentity())
 
+	challengeCheck, err := msg.ChallengeCheck.netDecode(p.suite)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle Finalize, : %s", Name, err.Error())
+	}
+
 	// check if we are the leader
 	members := p.context.Members()
-	weAreNotLeader := len(msg.ChallengeCheck.Sigs) != len(members.Y) // TODO once daga API cleaned remove that...
+	weAreNotLeader := len(challengeCheck.Sigs) != len(members.Y) // TODO once daga API cleaned remove that...
+
+	// don't sign a challenge bound to another session than the one of the request we accepted
+	if !bytes.Equal(challengeCheck.Session, p.sessionBinding) {
+		return fmt.Errorf("%s: failed to handle Finalize, challenge bound to another session", Name)
+	}
+	// don't sign a challenge that expired or that will be valid for longer than a challenge we would issue
+	if err := dagacothority.CheckChallengeExpiry(challengeCheck.Challenge, time.Now()); err != nil {
+		return fmt.Errorf("%s: failed to handle Finalize, : %s", Name, err.Error())
+	}
 
 	// Executes CheckUpdateChallenge (to verify and add signature, or verify only if we are last node/leader)
-	if err := daga.CheckUpdateChallenge(suite, p.context, &msg.ChallengeCheck, p.pKClientCommitments, p.dagaServer); err != nil {
+	if err := daga.CheckUpdateChallenge(p.suite, p.context, challengeCheck, p.pKClientCommitments, p.dagaServer); err != nil {
 		return fmt.Errorf("%s: failed to handle Finalize, : %s", Name, err.Error())
 	}
 
 	if weAreNotLeader {
 		// not all nodes have received Finalize => figure out the node of the next-server in "ring" and send to it.
-		return p.sendToNextServer(&Finalize{ChallengeCheck: msg.ChallengeCheck})
+		return p.sendToNextServer(&Finalize{ChallengeCheck: netEncodeChallengeCheck(*challengeCheck)})
 	} else {
 		// step 5
 		// we are the leader, and all nodes already updated the challengecheck struct => Finalize the challenge
-		clientChallenge, err := daga.FinalizeChallenge(p.context, &msg.ChallengeCheck)
+		clientChallenge, err := daga.FinalizeChallenge(p.context, challengeCheck)
 		if err != nil {
 			return fmt.Errorf("%s: failed to handle Finalize, leader failed to finalize the challenge: %s", Name, err.Error())
 		}
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 var tSuite = daga.NewSuiteEC()
@@ -22,21 +23,30 @@ func TestMain(m *testing.M) {
 func TestChallengeGeneration(t *testing.T) {
 	nodes := []int{2, 5, 13}
 	for _, nbrNodes := range nodes {
-		runProtocol(t, nbrNodes)
+		runProtocol(t, nbrNodes, nil)
 	}
 }
 
+// Tests a 5-node system, with a request bound to the session of a relying party
+func TestChallengeGeneration_Session(t *testing.T) {
+	session, err := dagacothority.NewSession("https://rp.example.com")
+	require.NoError(t, err)
+	runProtocol(t, 5, session)
+}
+
 // TODO more DRY helpers fair share of code is .. shared..
 
-func runProtocol(t *testing.T, nbrNodes int) {
+func runProtocol(t *testing.T, nbrNodes int, session *dagacothority.Session) {
 	log.Lvl2("Running", dagachallengegeneration.Name, "with", nbrNodes, "nodes")
 	local := onet.NewLocalTest(tSuite)
 	defer local.CloseAll()
 
 	services, _, dummyContext := protocols_testing.ValidServiceSetup(local, nbrNodes)
+	commitments := protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(commitments),
+		Session:     session,
 	}
 
 	// create and setup root protocol instance + start protocol
@@ -49,8 +59,23 @@ func runProtocol(t *testing.T, nbrNodes int) {
 
 	// verify that all servers correctly signed the challenge
 	// QUESTION: not sure if I should test theses here.. IMO the sut is the protocol, not the daga code it uses
-	members := dummyContext.Members()
-	challenge.VerifySignatures(tSuite, members.Y, dummyReq.Commitments)
+	challenge.VerifySignatures(tSuite, dummyContext, commitments)
+
+	// the challenge is issued with an identifier and an expiry, signed by all the servers
+	require.NoError(t, dagacothority.CheckChallengeExpiry(challenge, time.Now()))
+	expired := challenge
+	expired.Expiry--
+	require.Error(t, expired.VerifySignatures(tSuite, dummyContext, commitments))
+
+	// the challenge is bound to the session of the request, the binding is signed by all the servers
+	binding, err := session.Binding(tSuite)
+	require.NoError(t, err)
+	require.Equal(t, binding, challenge.Session)
+	if session != nil {
+		require.NoError(t, challenge.VerifySignatures(tSuite, dummyContext, commitments))
+		challenge.Session = nil
+		require.Error(t, challenge.VerifySignatures(tSuite, dummyContext, commitments))
+	}
 }
 
 func TestLeaderSetup(t *testing.T) {
@@ -63,7 +88,7 @@ func TestLeaderSetup(t *testing.T) {
 	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 	defer pi.(*dagachallengegeneration.Protocol).Done()
@@ -100,12 +125,31 @@ func TestLeaderSetupShouldPanicOnBadCommitmentsLength(t *testing.T) {
 
 	require.Panics(t, func() {
 		pi.(*dagachallengegeneration.Protocol).LeaderSetup(dagacothority.PKclientCommitments{
-			Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators())),
+			Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()))),
 			Context:     *dummyContext,
 		}, dagaServers[0])
 	}, "should panic on bad commitments size")
 }
 
+func TestLeaderSetupShouldPanicOnInvalidSession(t *testing.T) {
+	local := onet.NewLocalTest(tSuite)
+	defer local.CloseAll()
+
+	nbrNodes := 1
+	_, roster, tree := local.GenBigTree(nbrNodes, nbrNodes, nbrNodes-1, true)
+	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
+	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
+	defer pi.(*dagachallengegeneration.Protocol).Done()
+
+	require.Panics(t, func() {
+		pi.(*dagachallengegeneration.Protocol).LeaderSetup(dagacothority.PKclientCommitments{
+			Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
+			Context:     *dummyContext,
+			Session:     &dagacothority.Session{Audience: "https://rp.example.com"},
+		}, dagaServers[0])
+	}, "should panic on session without nonce")
+}
+
 func TestLeaderSetupShouldPanicOnNilServer(t *testing.T) {
 	local := onet.NewLocalTest(tSuite)
 	defer local.CloseAll()
@@ -115,7 +159,7 @@ func TestLeaderSetupShouldPanicOnNilServer(t *testing.T) {
 	_, _, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 	defer pi.(*dagachallengegeneration.Protocol).Done()
@@ -134,7 +178,7 @@ func TestLeaderSetupShouldPanicOnInvalidState(t *testing.T) {
 	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 
@@ -196,7 +240,7 @@ func TestChildrenSetupShouldPanicOnInvalidState(t *testing.T) {
 	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 
@@ -230,7 +274,7 @@ func TestStartShouldErrorOnInvalidTreeShape(t *testing.T) {
 	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 	pi.(*dagachallengegeneration.Protocol).LeaderSetup(dummyReq, dagaServers[0])
@@ -254,7 +298,7 @@ func TestWaitForResultShouldPanicIfCalledBeforeStart(t *testing.T) {
 	_, dagaServers, _, dummyContext := protocols_testing.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 	dummyReq := dagacothority.PKclientCommitments{
 		Context:     *dummyContext,
-		Commitments: protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3),
+		Commitments: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(len(dummyContext.ClientsGenerators()) * 3)),
 	}
 	pi, _ := local.CreateProtocol(dagachallengegeneration.Name, tree)
 	defer pi.(*dagachallengegeneration.Protocol).Done()
@@ -1,7 +1,7 @@
 package dagachallengegeneration
 
 import (
-	"go.dedis.ch/kyber"
+	"errors"
 	"github.com/dedis/onet"
 	"github.com/dedis/student_18_daga/dagacothority"
 	"github.com/dedis/student_18_daga/sign/daga"
@@ -20,7 +20,7 @@ const Name = "DAGAChallengeGeneration"
 // Announce is sent from Leader upon reception of a client request.
 // it request that all other nodes generate a new challenge and send back a signed commitment to their challenge.
 type Announce struct {
-	LeaderCommit daga.ChallengeCommitment // contains the signed commitment of the Leader
+	LeaderCommit ChallengeCommitment // contains the signed commitment of the Leader
 
 	LeaderIndexInContext int // to allow the nodes to pick correct public key in context
 	// original request:
@@ -39,7 +39,7 @@ type StructAnnounce struct {
 
 // AnnounceReply is sent from all nodes back to the Leader, it contains what the leader asked, their signed commitment to a Challenge
 type AnnounceReply struct {
-	Commit daga.ChallengeCommitment
+	Commit ChallengeCommitment
 }
 
 // StructAnnounceReply just contains AnnounceReply and the data necessary to identify and
@@ -52,7 +52,7 @@ type StructAnnounceReply struct {
 // Open is sent from Leader upon reception and verification of all AnnounceReply.
 // it request that all other nodes send back their openings
 type Open struct {
-	LeaderOpening kyber.Scalar
+	LeaderOpening []byte // encoded with the suite of the context (see dagacothority.EncodeScalar)
 }
 
 // StructOpen just contains Open and the data necessary to identify and
@@ -64,8 +64,8 @@ type StructOpen struct {
 
 // OpenReply is sent from all nodes back to the Leader, it contains what the leader asked, their opening of the previously sent commitment
 type OpenReply struct {
-	Opening kyber.Scalar
-	Index   int // index in auth. context
+	Opening []byte // encoded with the suite of the context (see dagacothority.EncodeScalar)
+	Index   int    // index in auth. context
 }
 
 // StructOpenReply just contains OpenReply and the data necessary to identify and
@@ -78,7 +78,7 @@ type StructOpenReply struct {
 // Finalize is sent from node to node, starting from the leader and back to the leader
 // to collect signatures of the computed master challenge etc..
 type Finalize struct {
-	ChallengeCheck daga.ChallengeCheck
+	ChallengeCheck ChallengeCheck
 }
 
 // StructFinalize just contains Finalize and the data necessary to identify and
@@ -87,3 +87,73 @@ type StructFinalize struct {
 	*onet.TreeNode
 	Finalize
 }
+
+// ChallengeCommitment is the net friendly version of a daga.ChallengeCommitment, its point is encoded with the suite of
+// the context (onet would decode a kyber.Point with the suite of the conode, see dagacothority.EncodePoint)
+type ChallengeCommitment struct {
+	Commit []byte
+	daga.ServerSignature
+}
+
+// ChallengeCheck is the net friendly version of a daga.ChallengeCheck
+type ChallengeCheck struct {
+	Challenge dagacothority.PKclientChallenge
+	Commits   []ChallengeCommitment
+	Openings  [][]byte
+}
+
+func netEncodeChallengeCommitment(commitment daga.ChallengeCommitment) ChallengeCommitment {
+	return ChallengeCommitment{
+		Commit:          dagacothority.EncodePoint(commitment.Commit),
+		ServerSignature: commitment.ServerSignature,
+	}
+}
+
+// netDecode is used to translate back the net friendly version of a daga.ChallengeCommitment of the given suite
+func (c ChallengeCommitment) netDecode(suite daga.Suite) (daga.ChallengeCommitment, error) {
+	commit, err := daga.DecodePoint(suite, c.Commit)
+	if err != nil {
+		return daga.ChallengeCommitment{}, errors.New("commitment: " + err.Error())
+	}
+	return daga.ChallengeCommitment{
+		Commit:          commit,
+		ServerSignature: c.ServerSignature,
+	}, nil
+}
+
+func netEncodeChallengeCheck(challengeCheck daga.ChallengeCheck) ChallengeCheck {
+	commits := make([]ChallengeCommitment, 0, len(challengeCheck.Commits))
+	for _, commitment := range challengeCheck.Commits {
+		commits = append(commits, netEncodeChallengeCommitment(commitment))
+	}
+	return ChallengeCheck{
+		Challenge: *dagacothority.NetEncodeChallenge(challengeCheck.Challenge),
+		Commits:   commits,
+		Openings:  dagacothority.EncodeScalars(challengeCheck.Openings),
+	}
+}
+
+// netDecode is used to translate back the net friendly version of a daga.ChallengeCheck of the given suite
+func (cc ChallengeCheck) netDecode(suite daga.Suite) (*daga.ChallengeCheck, error) {
+	challenge, err := cc.Challenge.NetDecode(suite)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]daga.ChallengeCommitment, 0, len(cc.Commits))
+	for _, netCommitment := range cc.Commits {
+		commitment, err := netCommitment.netDecode(suite)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commitment)
+	}
+	openings, err := dagacothority.DecodeScalars(suite, cc.Openings)
+	if err != nil {
+		return nil, errors.New("openings: " + err.Error())
+	}
+	return &daga.ChallengeCheck{
+		Challenge: *challenge,
+		Commits:   commits,
+		Openings:  openings,
+	}, nil
+}
@@ -22,9 +22,6 @@ import (
 	"github.com/dedis/onet/log"
 )
 
-// the DAGA crypto suite
-var suite = daga.NewSuiteEC()
-
 // Timeout represents the max duration/amount of time to wait for result in WaitForResult
 // TODO educated timeout formula that scale with number of nodes etc.. => forget about it there are countless other timeouts everywhere (tcp, tree cache etc..)
 const Timeout = 2500 * time.Second
@@ -45,16 +42,19 @@ type Protocol struct {
 	context             *contextFactory                                                   // the context being built (used only by leader)
 	indexOf             map[onet.TreeNodeID]int                                           // map treeNodes to their index (used only by leader)
 	dagaServer          daga.Server                                                       // to hold the newly created "daga identity" of the node for the new context/round
+	suite               daga.Suite                                                        // the DAGA crypto suite of the new context, (resolved from the original request)
 	originalRequest     *dagacothority.CreateContext                                      // set by leader/service, from API call and then propagated to other instances as part of the announce message, to allow them to decide to proccess request or not
 	acceptRequest       func(ctx *dagacothority.CreateContext) error                      // used by child nodes to verify that a request (forwarded by leader) is valid and accepted by the node, set by service at protocol creation time
 	startServingContext func(context dagacothority.Context, dagaServer daga.Server) error // used by child nodes to provide result of protocol to the parent service, set by service at protocol creation time
+	cosignSecret        *daga.Secret                                                      // the secret of our commitment to the collective signature, released once used (or when the protocol is done, see Shutdown)
+	cosignCommits       []kyber.Point                                                     // the commitments of the nodes to the collective signature, at their index in context (used only by leader)
+	cosignMask          []byte                                                            // the participation mask of the collective signature (used only by leader)
 }
 
 // TODO move this in sign/daga
 type contextFactory struct {
 	ServiceID dagacothority.ServiceID
 	daga.MinimumAuthenticationContext
-	Signatures [][]byte
 }
 
 // NewProtocol initialises the structure for use in one round, callback passed to onet upon protocol registration
@@ -89,6 +89,15 @@ func (p *Protocol) LeaderSetup(req *dagacothority.CreateContext) {
 		log.Panic("protocol setup: empty request")
 	}
 	p.originalRequest = req
+	suite, err := dagacothority.SuiteByName(req.SuiteName)
+	if err != nil {
+		log.Panic("protocol setup: " + err.Error())
+	}
+	p.suite = suite
+	subscribersKeys, err := dagacothority.DecodePoints(suite, req.SubscribersKeys)
+	if err != nil {
+		log.Panic("protocol setup: invalid subscriber key: " + err.Error())
+	}
 
 	// create context skeleton/factory
 	p.context = &contextFactory{
@@ -97,12 +106,15 @@ func (p *Protocol) LeaderSetup(req *dagacothority.CreateContext) {
 			G: struct {
 				X []kyber.Point
 				Y []kyber.Point
-			}{X: req.SubscribersKeys, Y: make([]kyber.Point, p.Tree().Size())},
-			R: make([]kyber.Point, p.Tree().Size()),
-			H: make([]kyber.Point, len(req.SubscribersKeys)),
+			}{X: subscribersKeys, Y: make([]kyber.Point, p.Tree().Size())},
+			R:                make([]kyber.Point, p.Tree().Size()),
+			H:                make([]kyber.Point, len(req.SubscribersKeys)),
+			EncodingVersion:  daga.CurrentEncoding,
+			GeneratorVersion: daga.CurrentGenerators,
+			SignatureScheme:  daga.DefaultSignatureScheme(suite),
 		},
-		Signatures: make([][]byte, p.Tree().Size()),
 	}
+	p.cosignCommits = make([]kyber.Point, p.Tree().Size())
 
 	p.indexOf = make(map[onet.TreeNodeID]int)
 }
@@ -152,19 +164,23 @@ func (p *Protocol) Start() (err error) {
 	p.result = make(chan dagacothority.Context)
 
 	// create new daga.Server identity for this context (personal choice can decide to reuse one existing Y key...)
-	dagaServer, err := daga.NewServer(suite, 0, nil)
+	dagaServer, err := daga.NewServer(p.suite, 0, nil)
 	if err != nil {
 		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
 	}
 	// pick new random per-round secret r and its commitment R
-	R := daga.GenerateNewRoundSecret(suite, dagaServer)
+	R := daga.GenerateNewRoundSecret(p.suite, dagaServer)
+	// and commit to the collective signature of the context
+	commit, secret := daga.NewCosignatureCommitment(p.suite)
 
 	// save in state
 	p.dagaServer = dagaServer
+	p.cosignSecret = daga.NewSecret(secret)
 
 	// update the context that's being created
 	p.context.G.Y[0] = dagaServer.PublicKey()
 	p.context.R[0] = R
+	p.cosignCommits[0] = commit
 
 	// broadcast Announce requesting that all other nodes do the same and send back their (potentially new) public key Y and commitment R.
 	var errs []error
@@ -216,24 +232,31 @@ func (p *Protocol) handleAnnounce(msg StructAnnounce) (err error) {
 		// store, to verify later that the context built by leader with our participation match the original request
 		p.originalRequest = &msg.OriginalRequest
 	}
+	if p.suite, err = dagacothority.SuiteByName(msg.OriginalRequest.SuiteName); err != nil {
+		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
+	}
 
 	leaderTreeNode := msg.TreeNode
 
 	// create new daga.Server identity for this context (TODO: personal choice can decide to reuse one existing long-term public key (the one in roster ? => needs to be compatible with the daga suite..)
-	dagaServer, err := daga.NewServer(suite, msg.AssignedIndex, nil)
+	dagaServer, err := daga.NewServer(p.suite, msg.AssignedIndex, nil)
 	if err != nil {
 		return errors.New(Name + ": failed to handle Leader's Announce: " + err.Error())
 	}
 	// pick new random per-round secret r and its commitment R
-	R := daga.GenerateNewRoundSecret(suite, dagaServer)
+	R := daga.GenerateNewRoundSecret(p.suite, dagaServer)
+	// and commit to the collective signature of the context
+	commit, secret := daga.NewCosignatureCommitment(p.suite)
 
 	// save in own state
 	p.dagaServer = dagaServer
+	p.cosignSecret = daga.NewSecret(secret)
 
 	// send back infos to leader
 	return p.SendTo(leaderTreeNode, &AnnounceReply{
-		Y: dagaServer.PublicKey(),
-		R: R,
+		Y:          dagacothority.EncodePoint(dagaServer.PublicKey()),
+		R:          dagacothority.EncodePoint(R),
+		Commitment: dagacothority.EncodePoint(commit),
 	})
 }
 
@@ -248,20 +271,42 @@ func (p *Protocol) handleAnnounceReply(msg []StructAnnounceReply) (err error) {
 
 	// update context
 	for _, announceReply := range msg {
-		p.context.G.Y[p.indexOf[announceReply.ID]] = announceReply.Y
-		p.context.R[p.indexOf[announceReply.ID]] = announceReply.R
+		index := p.indexOf[announceReply.ID]
+		for _, field := range []struct {
+			dst *kyber.Point
+			buf []byte
+		}{{&p.context.G.Y[index], announceReply.Y}, {&p.context.R[index], announceReply.R}, {&p.cosignCommits[index], announceReply.Commitment}} {
+			if *field.dst, err = daga.DecodePoint(p.suite, field.buf); err != nil {
+				return fmt.Errorf("%s: failed to handle AnnounceReply: %s", Name, err.Error())
+			}
+		}
+	}
+
+	// every node takes part in the collective signature
+	participants := make([]int, len(p.cosignCommits))
+	for i := range participants {
+		participants[i] = i
+	}
+	if p.cosignMask, err = daga.NewParticipationMask(len(p.cosignCommits), participants); err != nil {
+		return fmt.Errorf("%s: failed to handle AnnounceReply: %s", Name, err.Error())
 	}
 
 	// create client generators
 	for i := range p.context.G.X {
-		if p.context.H[i], err = daga.GenerateClientGenerator(suite, i, p.context.R); err != nil {
+		if p.context.H[i], err = daga.GenerateClientGenerator(p.suite, p.context.GeneratorVersion, i, p.context.R); err != nil {
 			return fmt.Errorf("%s: failed to handle AnnounceReply: %s", Name, err.Error())
 		}
 	}
 
 	// broadcast the now "done" context
+	context, err := dagacothority.NewContext(p.suite, *p.context, p.Roster(), p.context.ServiceID, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle AnnounceReply: %s", Name, err.Error())
+	}
 	errs := p.Broadcast(&Sign{
-		Context: p.context.MinimumAuthenticationContext,
+		Context:     *context,
+		Commitments: dagacothority.EncodePoints(p.cosignCommits),
+		Mask:        p.cosignMask,
 	})
 	if len(errs) != 0 {
 		return fmt.Errorf("%s: broadcast of Sign failed with error(s): %v", Name, errs)
@@ -278,44 +323,84 @@ func (p *Protocol) handleSign(msg StructSign) (err error) {
 	}()
 	log.Lvlf3("%s: Received Leader's Sign", Name)
 
+	// decode the context with the suite we resolved from the original request
+	if msg.Context.SuiteName != p.suite.String() {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong suite in context", Name)
+	}
+	members := msg.Context.Members()
+	serversCommitments := msg.Context.ServersSecretsCommitments()
+	if len(members.Y) == 0 || len(serversCommitments) != len(members.Y) || p.dagaServer.Index() >= len(members.Y) {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: invalid context", Name)
+	}
+	commitments, err := dagacothority.DecodePoints(p.suite, msg.Commitments)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: %s", Name, err)
+	}
+
 	// verify that our Y,R is correct in context
-	R := suite.Point().Mul(p.dagaServer.RoundSecret(), nil)
+	R := p.suite.Point().Mul(p.dagaServer.RoundSecret(), nil)
 	Y := p.dagaServer.PublicKey()
-	if !R.Equal(msg.Context.R[p.dagaServer.Index()]) {
+	if !R.Equal(serversCommitments[p.dagaServer.Index()]) {
 		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong node commitment", Name)
-	} else if !Y.Equal(msg.Context.G.Y[p.dagaServer.Index()]) {
+	} else if !Y.Equal(members.Y[p.dagaServer.Index()]) {
 		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong node public key", Name)
 	}
 
+	// new contexts must use the current (canonical) encoding
+	if msg.Context.Encoding() != daga.CurrentEncoding {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: context uses encoding %d instead of %d", Name, msg.Context.Encoding(), daga.CurrentEncoding)
+	}
+
+	// new contexts must derive their generators using the current (hash-to-curve) derivation
+	if msg.Context.GeneratorDerivation() != daga.CurrentGenerators {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: context uses generators %d instead of %d", Name, msg.Context.GeneratorDerivation(), daga.CurrentGenerators)
+	}
+
+	// new contexts must use the signature scheme of their suite (no downgrade)
+	if scheme := daga.DefaultSignatureScheme(p.suite); msg.Context.SigningScheme() != scheme {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: context uses signature scheme %d instead of %d", Name, msg.Context.SigningScheme(), scheme)
+	}
+
 	// verify that the generators are correctly computed (do it again and compare)
 	// TODO move these things in sign/daga including signature verification etc..
-	for i, leaderGenerator := range msg.Context.H {
-		if generator, err := daga.GenerateClientGenerator(suite, i, msg.Context.R); err != nil {
-			return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
-		} else if !leaderGenerator.Equal(generator) {
-			return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong generator", Name)
-		}
+	if err := daga.VerifyClientGenerators(p.suite, msg.Context); err != nil {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: %s", Name, err)
 	}
 
 	// verify context is actually answering original request (same subscribers)
-	if !dagacothority.ContainsSameElems(p.originalRequest.SubscribersKeys, msg.Context.G.X) {
+	subscribersKeys, err := dagacothority.DecodePoints(p.suite, p.originalRequest.SubscribersKeys)
+	if err != nil || !dagacothority.ContainsSameElems(subscribersKeys, members.X) {
 		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong group members in context", Name)
 	}
 
-	// sign context
+	// verify that our commitment to the collective signature is correct
+	if len(commitments) != len(members.Y) {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong number of commitments to the collective signature", Name)
+	}
+	if secret := p.cosignSecret.Scalar(); secret == nil {
+		return fmt.Errorf("%s: failed to handle Leader's Sign: commitment to the collective signature already used", Name)
+	} else if commit := commitments[p.dagaServer.Index()]; commit == nil || !commit.Equal(p.suite.Point().Mul(secret, nil)) {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Sign: wrong node commitment to the collective signature", Name)
+	}
+
+	// sign context (and the suite we resolved from the original request)
 	// TODO include roster and other metadata in signature
-	contextBytes, err := daga.AuthenticationContextToBytes(msg.Context)
+	contextBytes, err := dagacothority.ContextSignData(p.suite.String(), msg.Context)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
+	}
+	_, challenge, err := p.cosignatureChallenge(members.Y, msg.Context.SigningScheme(), commitments, msg.Mask, contextBytes)
 	if err != nil {
 		return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
 	}
-	signature, err := daga.SchnorrSign(suite, p.dagaServer.PrivateKey(), contextBytes)
+	response, err := p.cosignatureResponse(members.Y, challenge)
 	if err != nil {
 		return fmt.Errorf("%s: failed to handle Leader's Sign: %s", Name, err)
 	}
 
-	// send our signature back to leader
+	// send our share of the collective signature back to leader
 	return p.SendTo(msg.TreeNode, &SignReply{
-		Signature: signature,
+		Response: dagacothority.EncodeScalar(response),
 	})
 }
 
@@ -324,33 +409,47 @@ func (p *Protocol) handleSignReply(msg []StructSignReply) (err error) {
 	defer p.Done()
 	log.Lvlf3("%s: Leader received all Sign replies", Name)
 
-	contextBytes, err := daga.AuthenticationContextToBytes(p.context)
+	contextBytes, err := dagacothority.ContextSignData(p.suite.String(), p.context)
 	if err != nil {
 		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
 	}
 
-	// verify all the signatures and add them to the context being built
+	commit, challenge, err := p.cosignatureChallenge(p.context.G.Y, p.context.SignatureScheme, p.cosignCommits, p.cosignMask, contextBytes)
+	if err != nil {
+		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+	}
+
+	// verify all the responses (to blame the culprit instead of only rejecting the aggregate) and add our own
+	responses := make([]kyber.Scalar, 0, len(msg)+1)
 	for _, signReply := range msg {
 		nodeIndex := p.indexOf[signReply.ID]
-		Y := p.context.G.Y[nodeIndex]
-		if err := daga.SchnorrVerify(suite, Y, contextBytes, signReply.Signature); err != nil {
+		nodeResponse, err := daga.DecodeScalar(p.suite, signReply.Response)
+		if err != nil {
+			return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+		}
+		if err := daga.VerifyCosignatureResponse(p.suite, p.context.G.Y, nodeIndex, p.cosignCommits[nodeIndex], challenge, nodeResponse); err != nil {
 			return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
 		}
-		p.context.Signatures[nodeIndex] = signReply.Signature
+		responses = append(responses, nodeResponse)
 	}
-
-	// add own signature
-	if signature, err := daga.SchnorrSign(suite, p.dagaServer.PrivateKey(), contextBytes); err != nil {
+	response, err := p.cosignatureResponse(p.context.G.Y, challenge)
+	if err != nil {
 		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
-	} else {
-		p.context.Signatures[0] = signature
 	}
+	responses = append(responses, response)
 
 	// make result available to service
-	finalContext, err := dagacothority.NewContext(*p.context, p.Roster(), p.context.ServiceID, p.context.Signatures)
+	finalContext, err := dagacothority.NewContext(p.suite, *p.context, p.Roster(), p.context.ServiceID, nil)
 	if err != nil {
 		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
 	}
+	if finalContext.CollectiveSignature, err = daga.NewCollectiveSignature(p.suite, commit, responses); err != nil {
+		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+	}
+	finalContext.ParticipationMask = p.cosignMask
+	if err := finalContext.VerifyCollectiveSignature(); err != nil {
+		return fmt.Errorf("%s: failed to handle SignReply: %s", Name, err)
+	}
 	p.result <- *finalContext
 
 	// broadcast the now done context
@@ -370,17 +469,47 @@ func (p *Protocol) handleDone(msg StructDone) error {
 
 	// verify signatures
 	// TODO use keys from the context at the handleSign step to prevent leader replacing the keys (if useful, since we can assume we are honest + we cannot ensure leader is not sybil from the start..)
-	members := msg.FinalContext.Members()
-	if contextBytes, err := daga.AuthenticationContextToBytes(msg.FinalContext); err != nil { // TODO see to include other things (roster, Ids etc..)
+	if msg.FinalContext.SuiteName != p.suite.String() {
+		return fmt.Errorf("%s: failed to handle (dishonest)Leader's Done: wrong suite in context", Name)
+	}
+	if err := msg.FinalContext.VerifyCollectiveSignature(); err != nil { // TODO see to include other things (roster, Ids etc..)
 		return fmt.Errorf("%s: failed to handle Done: %s", Name, err)
-	} else {
-		for i, pubKey := range members.Y {
-			if err := daga.SchnorrVerify(suite, pubKey, contextBytes, msg.FinalContext.Signatures[i]); err != nil {
-				return fmt.Errorf("%s: failed to handle Done: %s", Name, err)
-			}
-		}
 	}
 
 	// make context and matching dagaServer identity available to parent service
 	return p.startServingContext(msg.FinalContext, p.dagaServer)
 }
+
+// Shutdown releases the secret of our commitment to the collective signature, called by onet when the protocol instance is done
+func (p *Protocol) Shutdown() error {
+	p.cosignSecret.Release()
+	return p.TreeNodeInstance.Shutdown()
+}
+
+// returns the aggregate commitment and the challenge of the collective signature of data, under scheme, by the nodes whose keys are keys
+func (p *Protocol) cosignatureChallenge(keys []kyber.Point, scheme daga.SignatureScheme, commits []kyber.Point, mask, data []byte) (kyber.Point, kyber.Scalar, error) {
+	commit, err := daga.AggregateCommitment(p.suite, commits, mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	aggregateKey, err := daga.AggregateKey(p.suite, keys, mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	challenge, err := daga.CosignatureChallenge(p.suite, scheme, aggregateKey, commit, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return commit, challenge, nil
+}
+
+// returns our response to the challenge of the collective signature of the nodes whose keys are keys,
+// our commitment answers a single challenge (the secret is released)
+func (p *Protocol) cosignatureResponse(keys []kyber.Point, challenge kyber.Scalar) (kyber.Scalar, error) {
+	secret := p.cosignSecret.Scalar()
+	if secret == nil {
+		return nil, errors.New("commitment to the collective signature already used")
+	}
+	defer p.cosignSecret.Release()
+	return daga.CosignatureResponse(p.suite, keys, p.dagaServer, secret, challenge)
+}
@@ -1,6 +1,7 @@
 package dagacontextgeneration_test
 
 import (
+	"crypto/ed25519"
 	"github.com/dedis/onet"
 	"github.com/dedis/onet/log"
 	"github.com/dedis/student_18_daga/dagacothority"
@@ -43,7 +44,7 @@ func runProtocol(t *testing.T, nbrNodes int) {
 	roster := local.GenRosterFromHost(servers...)
 
 	dummyReq := &dagacothority.CreateContext{
-		SubscribersKeys: protocols_testing.RandomPointSlice(13),
+		SubscribersKeys: dagacothority.EncodePoints(protocols_testing.RandomPointSlice(13)),
 		ServiceID:       dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
 		DagaNodes:       roster,
 		Signature:       make([]byte, 32), // TODO later real signature
@@ -58,11 +59,20 @@ func runProtocol(t *testing.T, nbrNodes int) {
 
 	// verify correctness ...
 	members := context.Members()
-	contextBytes, err := daga.AuthenticationContextToBytes(context) // TODO see to include other things (roster Ids etc..)
+	require.Empty(t, context.Signatures, "context carries the signature of each server instead of a collective signature")
+	require.NoError(t, context.VerifyCollectiveSignature())
+
+	// the collective signature is a standard Ed25519 signature under the aggregate key of the servers
+	contextBytes, err := context.SignData() // TODO see to include other things (roster Ids etc..)
+	require.NoError(t, err)
+	aggregateKey, err := daga.AggregateKey(tSuite, members.Y, context.ParticipationMask)
 	require.NoError(t, err)
+	A, err := aggregateKey.MarshalBinary()
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(A, contextBytes, context.CollectiveSignature))
+
 	present := false
-	for i, pubKey := range members.Y {
-		require.NoError(t, daga.SchnorrVerify(tSuite, pubKey, contextBytes, context.Signatures[i]))
+	for _, pubKey := range members.Y {
 		if pubKey.Equal(dagaServer.PublicKey()) {
 			if !present {
 				present = true
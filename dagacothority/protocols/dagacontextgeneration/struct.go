@@ -1,10 +1,8 @@
 package dagacontextgeneration
 
 import (
-	"go.dedis.ch/kyber"
 	"github.com/dedis/onet"
 	"github.com/dedis/student_18_daga/dagacothority"
-	"github.com/dedis/student_18_daga/sign/daga"
 )
 
 /*
@@ -33,10 +31,13 @@ type StructAnnounce struct {
 }
 
 // AnnounceReply is sent from all other nodes back to the Leader, it contains what the leader asked,
-// the public key Y of their new `daga.Server` identity and the commitment R to their fresh per-round secret r
+// the public key Y of their new `daga.Server` identity and the commitment R to their fresh per-round secret r,
+// along with their commitment to the collective signature of the context
+// (the points are encoded with the suite of the request, see dagacothority.EncodePoint)
 type AnnounceReply struct {
-	Y kyber.Point
-	R kyber.Point
+	Y          []byte
+	R          []byte
+	Commitment []byte // see daga.NewCosignatureCommitment
 }
 
 // StructAnnounceReply just contains AnnounceReply and the data necessary to identify and
@@ -47,9 +48,11 @@ type StructAnnounceReply struct {
 }
 
 // Sign is sent from Leader upon reception and processing of all AnnounceReply.
-// it request approval (a signature) - from all other nodes - for the newly built context
+// it request approval (a collective signature) - from all other nodes - for the newly built context
 type Sign struct {
-	Context daga.MinimumAuthenticationContext // TODO DECIDE what kind of context here (include roster or not ?)
+	Context     dagacothority.Context // the context, not yet signed
+	Commitments [][]byte              // the (encoded) commitments of the nodes to the collective signature, at the index of the nodes in context
+	Mask        []byte                // the participation mask of the collective signature (see daga.MaskParticipants)
 }
 
 // StructSign just contains Sign and the data necessary to identify and
@@ -59,9 +62,9 @@ type StructSign struct {
 	Sign
 }
 
-// SignReply is sent from all nodes back to the Leader, it contains what the leader asked, their approval/signature
+// SignReply is sent from all nodes back to the Leader, it contains what the leader asked, their approval/share of the collective signature
 type SignReply struct {
-	Signature []byte // schnorr signature of the context, verifiable using the context.G.Y[node index] public key
+	Response []byte // (encoded) response to the challenge of the collective signature of the context (see daga.CosignatureResponse)
 }
 
 // StructSignReply just contains SignReply and the data necessary to identify and
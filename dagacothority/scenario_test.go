@@ -40,7 +40,7 @@ func testServerProtocolsOnClientRequests(context daga.AuthenticationContext, ser
 		}
 
 		//The leader asks other servers to generates commitments by publishing its own signed commitment
-		comlead, openlead, err := daga.NewChallengeCommitment(suite, servers[j])
+		comlead, openlead, err := daga.NewChallengeCommitment(suite, context, servers[j])
 		if err != nil {
 			return daga.Challenge{}, fmt.Errorf("error when generating the leader commitment at server %d\n%s\n", j, err)
 		}
@@ -55,7 +55,7 @@ func testServerProtocolsOnClientRequests(context daga.AuthenticationContext, ser
 			if num == j {
 				continue
 			}
-			com, open, e := daga.NewChallengeCommitment(suite, server)
+			com, open, e := daga.NewChallengeCommitment(suite, context, server)
 			if e != nil {
 				return daga.Challenge{}, fmt.Errorf("error when generating the commitment at server %d\n%s\n", num, e)
 			}
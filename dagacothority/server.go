@@ -10,9 +10,9 @@ type Server struct {
 	daga.Server
 }
 
-//NewServer is used to initialize a new server with a given index.
+//NewServer is used to initialize a new server of the given suite with a given index.
 //If no private key is given, a random one is chosen
-func NewServer(i int, s kyber.Scalar) (server *Server, err error) {
+func NewServer(suite daga.Suite, i int, s kyber.Scalar) (server *Server, err error) {
 	if dagaServer, err := daga.NewServer(suite, i, s); err != nil {
 		return nil, err
 	} else {
@@ -21,3 +21,8 @@ func NewServer(i int, s kyber.Scalar) (server *Server, err error) {
 		}, nil
 	}
 }
+
+//Release zeroes the secrets of the server, see daga.ReleaseSecrets
+func (s *Server) Release() {
+	daga.ReleaseSecrets(s.Server)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/dedis/student_18_daga/dagacothority/protocols/dagacontextgeneration"
 	"github.com/dedis/student_18_daga/sign/daga"
 	"github.com/satori/go.uuid"
+	"time"
 )
 
 // DagaID ID of the daga service in onet, exported because needed by the tests
@@ -36,6 +37,10 @@ type Service struct {
 	// are correctly handled.
 	*onet.ServiceProcessor
 	Storage *Storage
+
+	// the challenges consumed by the authentications we took part in, kept in memory until they expire
+	// (no need to store them, a challenge can't be used anymore once expired)
+	challenges *dagacothority.ConsumedChallenges
 }
 
 // storageID reflects the data we're storing - we could store more
@@ -56,6 +61,15 @@ func (s *Service) ValidateCreateContextReq(req *dagacothority.CreateContext) err
 		return errors.New("validateCreateContextReq: malformed request")
 	}
 
+	// check that we support the requested suite and that the keys of the subscribers are points of its group
+	suite, err := dagacothority.SuiteByName(req.SuiteName)
+	if err != nil {
+		return errors.New("validateCreateContextReq: " + err.Error())
+	}
+	if _, err := dagacothority.DecodePoints(suite, req.SubscribersKeys); err != nil {
+		return errors.New("validateCreateContextReq: invalid subscriber key: " + err.Error())
+	}
+
 	// and that the request is indeed from the 3rd-party service admin
 	if err := authenticateRequest(req); err != nil {
 		return errors.New("validateCreateContextReq: failed to authenticate 3rd-party service admin")
@@ -138,16 +152,46 @@ func (s *Service) CreateContext(req *dagacothority.CreateContext) (*dagacothorit
 // helper to quickly validate Auth requests before proceeding further
 func (s Service) validateAuthReq(req *dagacothority.Auth) (daga.Server, error) {
 	// validate initial tag and commitments
-	if req == nil || len(req.SCommits) == 0 || req.T0 == nil {
+	if req == nil || len(req.SCommits) == 0 || len(req.T0) == 0 {
 		return nil, errors.New("validateAuthReq: nil or empty request")
 	}
+	if err := req.ValidateVersion(); err != nil {
+		return nil, errors.New("validateAuthReq: " + err.Error())
+	}
 	// TODO idea/"optimisation" (for when someone rewrite sign/daga API...) maybe validate proof here to avoid spawning a protocol for nothing
 	// validate context
-	return s.validateContext(req.Context)
+	dagaServer, err := s.validateContext(req.Context)
+	if err != nil {
+		return nil, err
+	}
+	suite, err := req.Context.Suite()
+	if err != nil {
+		return nil, errors.New("validateAuthReq: " + err.Error())
+	}
+	challenge, err := req.Proof.Cs.NetDecode(suite)
+	if err != nil {
+		return nil, errors.New("validateAuthReq: " + err.Error())
+	}
+	// early reject the replays (the challenge is consumed by every node once the proof is verified, see consumeChallenge)
+	if err := s.challenges.Check(req.Context.ContextID, *challenge, time.Now()); err != nil {
+		return nil, errors.New("validateAuthReq: " + err.Error())
+	}
+	return dagaServer, nil
+}
+
+// helper used by the dagaauth protocol instances to record that the challenge of a request was used (after having
+// verified the client's proof), returns an error if the challenge expired or was already used
+func (s Service) consumeChallenge(context dagacothority.Context, challenge daga.Challenge) error {
+	if err := s.challenges.Consume(context.ContextID, challenge, time.Now()); err != nil {
+		return errors.New("consumeChallenge: " + err.Error())
+	}
+	return nil
 }
 
 // Auth is an API endpoint, upon reception of a valid request,
-// starts the server's protocol (daga paper 4.3.6) to authenticate an user, the current server/node will take the role of "Leader"
+// starts the server's protocol (daga paper 4.3.6) to authenticate an user, the current server/node will take the role of "Leader".
+// replies with the receipt of the authentication, collectively signed by all the servers,
+// and the full transcript of the authentication if the client asked for it (see Auth.WithTranscript)
 func (s *Service) Auth(req *dagacothority.Auth) (*dagacothority.AuthReply, error) {
 
 	// verify that submitted request is valid and accepted by our node
@@ -160,11 +204,17 @@ func (s *Service) Auth(req *dagacothority.Auth) (*dagacothority.AuthReply, error
 	if dagaProtocol, err := s.newDAGAServerProtocol(req, dagaServer); err != nil {
 		return nil, errors.New("Auth: " + err.Error())
 	} else {
-		serverMsg, err := dagaProtocol.WaitForResult()
-		netServerMsg := dagacothority.NetEncodeServerMessage(req.Context, &serverMsg)
-		// TODO : return Tag + sigs instead of final servermsg (legacy of previous code)
-		//  do it when refactoring sign/daga server code/API
-		return (*dagacothority.AuthReply)(netServerMsg), err
+		receipt, serverMsg, err := dagaProtocol.WaitForResult()
+		if err != nil {
+			return nil, errors.New("Auth: " + err.Error())
+		}
+		reply := &dagacothority.AuthReply{Receipt: receipt}
+		if req.WithTranscript {
+			// in the encoding of the proof chosen by the client
+			transcript := dagacothority.NetEncodeServerMessage(req.Context, &serverMsg).WithVersion(req.Version)
+			reply.Transcript = &transcript
+		}
+		return reply, nil
 	}
 }
 
@@ -175,8 +225,13 @@ func (s Service) validateContext(reqContext dagacothority.Context) (daga.Server,
 		return nil, errors.New("validateContext: empty Context")
 	}
 
-	if err := daga.ValidateContext(reqContext); err != nil {
-		return nil, err
+	suite, err := reqContext.Suite()
+	if err != nil {
+		return nil, errors.New("validateContext: " + err.Error())
+	}
+	// reject malformed or malicious contexts before looking them up
+	if err := daga.ValidateContextElements(suite, reqContext); err != nil {
+		return nil, errors.New("validateContext: " + err.Error())
 	}
 
 	if dagaServer, err := s.acceptContext(reqContext); err != nil {
@@ -201,7 +256,11 @@ func (s Service) acceptContext(reqContext dagacothority.Context) (daga.Server, e
 		//  (+) less or no state, don't need to protect state etc..
 		//  => see equals comments, depend on what features we want, see later when context evolution implemented.
 		if contextState.Context.Equals(reqContext) {
-			return contextState.DagaServer.NetDecode()
+			suite, err := contextState.Context.Suite()
+			if err != nil {
+				return nil, errors.New("acceptContext: " + err.Error())
+			}
+			return contextState.DagaServer.NetDecode(suite)
 		} else {
 			return nil, errors.New("acceptContext: context not accepted")
 		}
@@ -218,6 +277,11 @@ func (s Service) ValidatePKClientReq(req *dagacothority.PKclientCommitments) (da
 	if len(req.Commitments) == 0 || len(req.Commitments) != len(req.Context.H)*3 {
 		return nil, errors.New("validatePKClientReq: empty or wrongly sized commitments")
 	}
+	if req.Session != nil {
+		if err := req.Session.Validate(); err != nil {
+			return nil, errors.New("validatePKClientReq: invalid session: " + err.Error())
+		}
+	}
 
 	// validate context
 	return s.validateContext(req.Context)
@@ -265,7 +329,7 @@ func (s *Service) newDAGAServerProtocol(req *dagacothority.Auth, dagaServer daga
 		return nil, errors.New("failed to create " + dagaauth.Name + " protocol: " + err.Error())
 	}
 	dagaProtocol := pi.(*dagaauth.Protocol)
-	dagaProtocol.LeaderSetup(*req, dagaServer)
+	dagaProtocol.LeaderSetup(*req, dagaServer, s.consumeChallenge)
 
 	// start  // TODO maybe cleaner to move the start call inside p.waitforresult
 	if err = dagaProtocol.Start(); err != nil {
@@ -351,7 +415,7 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			return nil, err
 		}
 		dagaServerProtocol := pi.(*dagaauth.Protocol)
-		dagaServerProtocol.ChildSetup(s.validateContext)
+		dagaServerProtocol.ChildSetup(s.validateContext, s.consumeChallenge)
 		return dagaServerProtocol, nil
 	case dagacontextgeneration.Name:
 		pi, err := dagacontextgeneration.NewProtocol(tn)
@@ -468,12 +532,26 @@ func (s *Service) startServingContext(context dagacothority.Context, dagaServer
 	return nil
 }
 
+// DropContext stops serving the context contextID of the 3rd-party service serviceID and releases the stored secrets of
+// the daga server used under the context (its private key and per-round secret), the context can't be used anymore.
+// The protocol instances still running under the context are not disturbed, they use their own copy of the daga server
+// and release it when done (see their Shutdown)
+func (s *Service) DropContext(serviceID dagacothority.ServiceID, contextID dagacothority.ContextID) error {
+	if err := s.Storage.State.dropContext(serviceID, contextID); err != nil {
+		return errors.New("DropContext: " + err.Error())
+	}
+	// save all state to bbolt permanent storage
+	s.save()
+	return nil
+}
+
 // newService receives the context that holds information about the node it's
 // running on. Saving and loading can be done using the context. The data will
 // be stored in memory for tests and simulations, and on disk for real deployments
 func newService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor: onet.NewServiceProcessor(c),
+		challenges:       dagacothority.NewConsumedChallenges(),
 	}
 	if err := s.RegisterHandlers(s.Auth, s.PKClient, s.CreateContext, s.traffic); err != nil {
 		return nil, errors.New("Couldn't register service's API handlers/messages: " + err.Error())
@@ -12,7 +12,9 @@ import (
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
 	"math/rand"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TODO create helpers that build various requests, and have the test that test API endpoints accept request as parameter
@@ -58,14 +60,14 @@ func TestService_CreateContext(t *testing.T) {
 		request := dagacothority.CreateContext{
 			ServiceID:       dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
 			DagaNodes:       roster,
-			SubscribersKeys: subscriberKeys,
+			SubscribersKeys: dagacothority.EncodePoints(subscriberKeys),
 		}
 
 		// TODO use openPGP or whatever (now there is no verification, here only to documente one of the options I had in mind)
 		keyPair := key.NewKeyPair(tSuite)
 		hasher := tSuite.Hash()
 		hasher.Write(uuid.UUID(request.ServiceID).Bytes())
-		pointBytes, err := daga.PointArrayToBytes(request.SubscribersKeys)
+		pointBytes, err := daga.PointArrayToBytes(subscriberKeys)
 		require.NoError(t, err)
 		hasher.Write(pointBytes)
 		signature, err := daga.SchnorrSign(tSuite, keyPair.Private, hasher.Sum(nil))
@@ -78,12 +80,8 @@ func TestService_CreateContext(t *testing.T) {
 
 		// verify correctness ...
 		context := reply.Context
-		members := context.Members()
-		contextBytes, err := daga.AuthenticationContextToBytes(context) // TODO see to include other things (roster Ids etc..)
-		require.NoError(t, err)
-		for i, pubKey := range members.Y {
-			require.NoError(t, daga.SchnorrVerify(tSuite, pubKey, contextBytes, context.Signatures[i]))
-		}
+		require.NoError(t, context.VerifyCollectiveSignature()) // TODO see to include other things (roster Ids etc..)
+		require.Equal(t, tSuite.String(), context.SuiteName)
 		require.True(t, dagacothority.ContainsSameElems(subscriberKeys, context.Members().X))
 	}
 }
@@ -108,14 +106,15 @@ func TestService_PKClient(t *testing.T) {
 		reply, err := s.(*Service).PKClient(
 			&dagacothority.PKclientCommitments{
 				Context:     *dummyContext,
-				Commitments: commitments},
+				Commitments: dagacothority.EncodePoints(commitments)},
 		)
 		require.NoError(t, err)
 		require.NotZero(t, reply)
 
 		// verify that all servers correctly signed the challenge
-		members := dummyContext.Members()
-		require.NoError(t, reply.NetDecode().VerifySignatures(tSuite, members.Y, commitments))
+		challenge, err := reply.NetDecode(tSuite)
+		require.NoError(t, err)
+		require.NoError(t, challenge.VerifySignatures(tSuite, dummyContext, commitments))
 	}
 }
 
@@ -126,25 +125,42 @@ func TestService_Auth(t *testing.T) {
 	defer local.CloseAll()
 
 	services := local.GetServices(hosts, DagaID)
-	_, dagaServers, dummyAuthRequest, dummyContext := testing2.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
+	dagaClients, dagaServers, dummyAuthRequest, dummyContext := testing2.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
 
 	// provide initial state to the service (instead of fetching it from FS)
 	populateServicesStates(services, dagaServers, dummyContext)
 
-	for _, s := range services {
+	for i, s := range services {
 		log.Lvl2("Sending request to", s)
 
+		// the challenges are single-use, new request for each service
+		if i > 0 {
+			dummyAuthRequest = testing2.DummyAuthRequest(dagaClients[i%len(dagaClients)], dagaServers, dummyContext)
+		}
 		request := dagacothority.Auth(*dagacothority.NetEncodeAuthenticationMessage(*dummyContext, *dummyAuthRequest))
+		request.WithTranscript = true
 		reply, err := s.(*Service).Auth(&request)
 		require.NoError(t, err)
 		require.NotZero(t, reply)
+		require.NotNil(t, reply.Transcript, "transcript requested but not part of reply")
 
-		serverMsg, context := reply.NetDecode()
+		serverMsg, context, err := reply.Transcript.NetDecode()
+		require.NoError(t, err)
 		require.True(t, context.Equals(*dummyContext), "context part of reply different than context of request")
 		// verify / extract tag
 		Tf, err := daga.GetFinalLinkageTag(tSuite, dummyContext, *serverMsg)
 		require.NoError(t, err, "failed to extract tag from the resulting serverMsg")
 		require.NotZero(t, Tf)
+
+		// the receipt tells the same
+		require.NoError(t, dummyContext.VerifyReceipt(reply.Receipt))
+		require.Equal(t, dagacothority.EncodePoint(Tf), reply.Receipt.Tag)
+
+		// the request can't be replayed, to any service
+		_, err = s.(*Service).Auth(&request)
+		require.Error(t, err, "replayed request accepted")
+		_, err = services[(i+1)%len(services)].(*Service).Auth(&request)
+		require.Error(t, err, "replayed request accepted by another service")
 	}
 }
 
@@ -168,24 +184,30 @@ func TestService_CreateContextAndPKClient(t *testing.T) {
 		reply, err := s.(*Service).PKClient(
 			&dagacothority.PKclientCommitments{
 				Context:     context,
-				Commitments: commitments},
+				Commitments: dagacothority.EncodePoints(commitments)},
 		)
 		require.NoError(t, err)
 		require.NotZero(t, reply)
 
 		// verify that all servers correctly signed the challenge
-		members := context.Members()
-		require.NoError(t, reply.NetDecode().VerifySignatures(tSuite, members.Y, commitments))
+		challenge, err := reply.NetDecode(tSuite)
+		require.NoError(t, err)
+		require.NoError(t, challenge.VerifySignatures(tSuite, context, commitments))
 	}
 }
 
 // retrieve a test context created by calling the CreateContext endpoint with dummy parameters, to use it in other tests
 func getTestContext(t *testing.T, s *Service, roster *onet.Roster, numClients int) (dagacothority.Context, []daga.Client) {
+	return getTestContextWithSuite(t, tSuite, s, roster, numClients)
+}
+
+// same as getTestContext but the context is built upon suite
+func getTestContextWithSuite(t *testing.T, suite daga.Suite, s *Service, roster *onet.Roster, numClients int) (dagacothority.Context, []daga.Client) {
 
 	clients := make([]daga.Client, numClients)
 	keys := make([]kyber.Point, 0, numClients)
 	for i := range clients {
-		client, err := daga.NewClient(tSuite, i, nil)
+		client, err := daga.NewClient(suite, i, nil)
 		require.NoError(t, err)
 		clients[i] = client
 		keys = append(keys, client.PublicKey())
@@ -194,8 +216,9 @@ func getTestContext(t *testing.T, s *Service, roster *onet.Roster, numClients in
 	createContextRequest := dagacothority.CreateContext{
 		Signature:       make([]byte, 32),
 		DagaNodes:       roster,
-		SubscribersKeys: keys,
+		SubscribersKeys: dagacothority.EncodePoints(keys),
 		ServiceID:       dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
+		SuiteName:       suite.String(),
 	}
 	createContextReply, err := s.CreateContext(&createContextRequest)
 	require.NoError(t, err)
@@ -220,12 +243,14 @@ func TestService_CreateContextAndPKclientAndAuth(t *testing.T) {
 		// calls PKClient to build the auth. message
 		authMsg, err := daga.NewAuthenticationMessage(tSuite, context, clients[0], func(commits []kyber.Point) (daga.Challenge, error) {
 			request := dagacothority.PKclientCommitments{
-				Commitments: commits,
+				Commitments: dagacothority.EncodePoints(commits),
 				Context:     context,
 			}
 			reply, err := s.(*Service).PKClient(&request)
 			require.NoError(t, err)
-			return *reply.NetDecode(), nil
+			challenge, err := reply.NetDecode(tSuite)
+			require.NoError(t, err)
+			return *challenge, nil
 		})
 
 		// calls Auth
@@ -233,18 +258,117 @@ func TestService_CreateContextAndPKclientAndAuth(t *testing.T) {
 		authReply, err := s.(*Service).Auth(&authRequest)
 		require.NoError(t, err)
 		require.NotZero(t, authReply)
+		require.Nil(t, authReply.Transcript, "transcript not requested but part of reply")
 
-		serverMsg, context := authReply.NetDecode()
+		// verify the receipt / extract tag
+		receipt := authReply.Receipt
+		require.NoError(t, context.VerifyReceipt(receipt))
+		requestHash, err := dagacothority.AuthRequestHash(tSuite, *authMsg)
+		require.NoError(t, err)
+		require.Equal(t, requestHash, receipt.RequestHash)
+		tag, err := daga.DecodePoint(tSuite, receipt.Tag)
 		require.NoError(t, err)
-		require.True(t, context.Equals(context), "context part of reply different than context of request")
+		require.NoError(t, daga.CheckFinalLinkageTag(tSuite, tag))
 
-		// verify / extract tag
-		Tf, err := daga.GetFinalLinkageTag(tSuite, context, *serverMsg)
-		require.NoError(t, err, "failed to extract tag from the resulting serverMsg")
-		require.NotZero(t, Tf)
+		// the challenge issued by PKClient is single-use
+		require.NoError(t, dagacothority.CheckChallengeExpiry(authMsg.P0.Cs, time.Now()))
+		_, err = s.(*Service).Auth(&authRequest)
+		require.True(t, strings.Contains(err.Error(), dagacothority.ErrChallengeReplayed.Error()), "wrong error: %v", err)
+	}
+}
+
+// verify that the full test works for contexts built upon the other suites, the conodes (and onet) using tSuite
+func TestService_CreateContextAndPKclientAndAuth_OtherSuites(t *testing.T) {
+	local := onet.NewTCPTest(tSuite)
+	hosts, roster, _ := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	s := local.GetServices(hosts, DagaID)[0].(*Service)
+	for _, suite := range []daga.Suite{daga.NewSuiteSchnorr(), daga.NewSuiteRistretto()} {
+		log.Lvl2("Testing suite", suite)
+
+		// calls CreateContext
+		context, clients := getTestContextWithSuite(t, suite, s, roster, 5)
+		require.Equal(t, suite.String(), context.SuiteName)
+		require.NoError(t, context.VerifyCollectiveSignature())
+
+		// calls PKClient to build the auth. message
+		authMsg, err := daga.NewAuthenticationMessage(suite, context, clients[0], func(commits []kyber.Point) (daga.Challenge, error) {
+			request := dagacothority.PKclientCommitments{
+				Commitments: dagacothority.EncodePoints(commits),
+				Context:     context,
+			}
+			reply, err := s.PKClient(&request)
+			require.NoError(t, err)
+			challenge, err := reply.NetDecode(suite)
+			require.NoError(t, err)
+			return *challenge, nil
+		})
+		require.NoError(t, err)
+
+		// calls Auth
+		authRequest := dagacothority.Auth(*dagacothority.NetEncodeAuthenticationMessage(context, *authMsg))
+		authRequest.WithTranscript = true
+		authReply, err := s.Auth(&authRequest)
+		require.NoError(t, err)
+
+		// verify the receipt and the transcript / extract tag
+		require.NoError(t, context.VerifyReceipt(authReply.Receipt))
+		serverMsg, _, err := authReply.Transcript.NetDecode()
+		require.NoError(t, err)
+		Tf, err := daga.GetFinalLinkageTag(suite, context, *serverMsg)
+		require.NoError(t, err)
+		tag, err := daga.DecodePoint(suite, authReply.Receipt.Tag)
+		require.NoError(t, err)
+		require.True(t, tag.Equal(Tf))
 	}
 }
 
+// verify that the full test works for an authentication bound to the session of a relying party
+func TestService_CreateContextAndPKclientAndAuth_Session(t *testing.T) {
+	local := onet.NewTCPTest(tSuite)
+	hosts, roster, _ := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	s := local.GetServices(hosts, DagaID)[0]
+	context, clients := getTestContext(t, s.(*Service), roster, 5)
+	session, err := dagacothority.NewSession("https://rp.example.com")
+	require.NoError(t, err)
+	binding, err := session.Binding(tSuite)
+	require.NoError(t, err)
+
+	// calls PKClient with the session to build the auth. message
+	authMsg, err := daga.NewAuthenticationMessage(tSuite, context, clients[0], func(commits []kyber.Point) (daga.Challenge, error) {
+		request := dagacothority.PKclientCommitments{
+			Commitments: dagacothority.EncodePoints(commits),
+			Context:     context,
+			Session:     session,
+		}
+		reply, err := s.(*Service).PKClient(&request)
+		require.NoError(t, err)
+		require.Equal(t, binding, reply.Session)
+		challenge, err := reply.NetDecode(tSuite)
+		require.NoError(t, err)
+		return *challenge, nil
+	})
+	require.NoError(t, err)
+
+	// the relying party checks the request is meant for its session before calling Auth
+	authRequest := dagacothority.Auth(*dagacothority.NetEncodeAuthenticationMessage(context, *authMsg))
+	require.NoError(t, authRequest.VerifySession(session))
+	authReply, err := s.(*Service).Auth(&authRequest)
+	require.NoError(t, err)
+
+	// the receipt is bound to the session
+	receipt := authReply.Receipt
+	require.NoError(t, context.VerifyReceiptForSession(receipt, session))
+	require.Equal(t, binding, receipt.Session)
+	other, err := dagacothority.NewSession("https://rp.example.com")
+	require.NoError(t, err)
+	require.Error(t, context.VerifyReceiptForSession(receipt, other))
+	require.Error(t, context.VerifyReceiptForSession(receipt, nil))
+}
+
 func TestValidateAuthReqShouldErrorOnNilReq(t *testing.T) {
 	service := &Service{}
 	context, err := service.validateAuthReq(nil)
@@ -274,10 +398,10 @@ func TestValidateContextShouldErrorOnInvalidContext(t *testing.T) {
 	badContext := dagacothority.Context{
 		Roster: roster,
 
-		X: testing2.RandomPointSlice(5),
-		Y: testing2.RandomPointSlice(9),
-		H: testing2.RandomPointSlice(3), // len != 5 => invalid
-		R: testing2.RandomPointSlice(8), // len != 9 => invalid
+		X: dagacothority.EncodePoints(testing2.RandomPointSlice(5)),
+		Y: dagacothority.EncodePoints(testing2.RandomPointSlice(9)),
+		H: dagacothority.EncodePoints(testing2.RandomPointSlice(3)), // len != 5 => invalid
+		R: dagacothority.EncodePoints(testing2.RandomPointSlice(8)), // len != 9 => invalid
 
 		ServiceID: dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
 		ContextID: dagacothority.ContextID(uuid.Must(uuid.NewV4())),
@@ -293,10 +417,10 @@ func TestValidateContextShouldErrorOnEmptyRoster(t *testing.T) {
 	badContext := dagacothority.Context{
 		Roster: &onet.Roster{},
 
-		X: testing2.RandomPointSlice(5),
-		Y: testing2.RandomPointSlice(9),
-		H: testing2.RandomPointSlice(5),
-		R: testing2.RandomPointSlice(9),
+		X: dagacothority.EncodePoints(testing2.RandomPointSlice(5)),
+		Y: dagacothority.EncodePoints(testing2.RandomPointSlice(9)),
+		H: dagacothority.EncodePoints(testing2.RandomPointSlice(5)),
+		R: dagacothority.EncodePoints(testing2.RandomPointSlice(9)),
 
 		ServiceID: dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
 		ContextID: dagacothority.ContextID(uuid.Must(uuid.NewV4())),
@@ -326,10 +450,10 @@ func TestValidateContextShouldErrorOnUnacceptedContext(t *testing.T) {
 	badNetContext := dagacothority.Context{
 		Roster: roster,
 
-		X: testing2.RandomPointSlice(5),
-		Y: testing2.RandomPointSlice(9),
-		H: testing2.RandomPointSlice(5),
-		R: testing2.RandomPointSlice(9),
+		X: dagacothority.EncodePoints(testing2.RandomPointSlice(5)),
+		Y: dagacothority.EncodePoints(testing2.RandomPointSlice(9)),
+		H: dagacothority.EncodePoints(testing2.RandomPointSlice(5)),
+		R: dagacothority.EncodePoints(testing2.RandomPointSlice(9)),
 
 		ServiceID: dagacothority.ServiceID(uuid.Must(uuid.NewV4())),
 		ContextID: dagacothority.ContextID(uuid.Must(uuid.NewV4())),
@@ -358,10 +482,53 @@ func TestValidatePKClientReqShouldErrorOnEmptyOrBadlySizedCommitments(t *testing
 
 	context, err = service.ValidatePKClientReq(&dagacothority.PKclientCommitments{
 		Context: dagacothority.Context{
-			H: testing2.RandomPointSlice(8),
+			H: dagacothority.EncodePoints(testing2.RandomPointSlice(8)),
 		},
-		Commitments: testing2.RandomPointSlice(12), // != 3*8
+		Commitments: dagacothority.EncodePoints(testing2.RandomPointSlice(12)), // != 3*8
 	})
 	require.Error(t, err, "should return error on bad commitments size")
 	require.Zero(t, context)
 }
+
+func TestValidatePKClientReqShouldErrorOnInvalidSession(t *testing.T) {
+	service := &Service{}
+
+	context, err := service.ValidatePKClientReq(&dagacothority.PKclientCommitments{
+		Context: dagacothority.Context{
+			H: dagacothority.EncodePoints(testing2.RandomPointSlice(8)),
+		},
+		Commitments: dagacothority.EncodePoints(testing2.RandomPointSlice(24)),
+		Session:     &dagacothority.Session{Audience: "https://rp.example.com"},
+	})
+	require.Error(t, err, "should return error on session without nonce")
+	require.Zero(t, context)
+}
+
+// verify that a dropped context is not served anymore, that the stored secrets of its daga server are released and that
+// the daga servers already handed to protocol instances are left to them
+func TestService_DropContext(t *testing.T) {
+	local := onet.NewTCPTest(tSuite)
+	hosts, roster, _ := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	services := local.GetServices(hosts, DagaID)
+	_, dagaServers, _, dummyContext := testing2.DummyDagaSetup(rand.Intn(10)+2, len(local.Servers), roster)
+	populateServicesStates(services, dagaServers, dummyContext)
+	service := services[0].(*Service)
+
+	inFlight, err := service.validateContext(*dummyContext)
+	require.NoError(t, err)
+	serviceState, err := service.serviceState(dummyContext.ServiceID)
+	require.NoError(t, err)
+	contextState, err := serviceState.contextState(dummyContext.ContextID)
+	require.NoError(t, err)
+	stored := contextState.DagaServer
+
+	require.NoError(t, service.DropContext(dummyContext.ServiceID, dummyContext.ContextID))
+	require.Equal(t, make([]byte, len(stored.PerRoundSecret)), stored.PerRoundSecret, "round secret not released")
+	require.Equal(t, make([]byte, len(stored.PrivateKey)), stored.PrivateKey, "private key not released")
+	require.True(t, inFlight.RoundSecret().Equal(dagaServers[0].RoundSecret()), "round secret of in-flight protocol released")
+	_, err = service.validateContext(*dummyContext)
+	require.Error(t, err, "should return error on dropped context")
+	require.Error(t, service.DropContext(dummyContext.ServiceID, dummyContext.ContextID))
+}
@@ -78,6 +78,24 @@ func (s *State) set(key dagacothority.ServiceID, value *ServiceState) {
 	s.Data[key] = value
 }
 
+// dropContext removes the context cid of the 3rd-party service sid from the state and releases the stored secrets of its
+// daga server
+func (s *State) dropContext(sid dagacothority.ServiceID, cid dagacothority.ContextID) error {
+	s.Lock()
+	defer s.Unlock()
+	serviceState, ok := s.Data[sid]
+	if !ok {
+		return fmt.Errorf("unknown service ID: %v", sid)
+	}
+	contextState, err := serviceState.contextState(cid)
+	if err != nil {
+		return err
+	}
+	delete(serviceState.ContextStates, cid)
+	contextState.DagaServer.Release()
+	return nil
+}
+
 //type LinkageTag kyber.Point
 
 //type SubscriberState struct {
@@ -69,7 +69,7 @@ func (s *SimServiceCompleteAuth) Run(config *onet.SimulationConfig) error {
 	suite := daga.NewSuiteEC()
 
 	// configure cothority, make it start serving some new context
-	serviceProviderAdmin := dagacothority.NewAdminClient()
+	serviceProviderAdmin := dagacothority.NewAdminClient(suite)
 	// generate clients and retrieve their fresh keys
 	subscriberKeys, clients := func(numClients int) ([]kyber.Point, []daga.Client) {
 		subscriberKeys := make([]kyber.Point, 0, numClients)
@@ -99,7 +99,7 @@ func (s *SimServiceCompleteAuth) Run(config *onet.SimulationConfig) error {
 
 		// create client
 		cIdx := rand.Intn(len(context.Members().X))
-		c, err := dagacothority.NewClient(clients[cIdx].Index(), clients[cIdx].PrivateKey())
+		c, err := dagacothority.NewClient(suite, clients[cIdx].Index(), clients[cIdx].PrivateKey())
 
 		baseTx := make(map[string]uint64, len(config.Roster.List))
 		for _, node := range config.Roster.List {
@@ -128,9 +128,14 @@ func (s *SimServiceCompleteAuth) Run(config *onet.SimulationConfig) error {
 		if err != nil {
 			log.Lvl1("auth error: " + err.Error())
 		}
-		// decode reply + extract final linkage tag
-		serverMsg, _ := reply.NetDecode()
-		Tf, _ := daga.GetFinalLinkageTag(suite, context, *serverMsg)
+		// verify the receipt + extract final linkage tag
+		if err := context.VerifyReceipt(reply.Receipt); err != nil {
+			log.Lvl1("receipt error: " + err.Error())
+		}
+		Tf, err := daga.DecodePoint(suite, reply.Receipt.Tag)
+		if err != nil {
+			log.Lvl1("tag error: " + err.Error())
+		}
 
 		fullAuth.Record()
 
@@ -7,8 +7,11 @@ FIXME in fact no... they are in proto.go ... not sure how to keep kind of cohere
 */
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/ascii85"
 	"errors"
+	"fmt"
 	"go.dedis.ch/kyber"
 	"github.com/dedis/onet"
 	"github.com/dedis/onet/network"
@@ -32,16 +35,49 @@ const (
 	ErrorParse = iota + 4000
 )
 
+// encodings of the client's proof in an Auth request (see Auth.Version), the servers reply and forward the request
+// in the encoding chosen by the client
+const (
+	// AuthVersionFull the proof carries its commitments
+	AuthVersionFull = iota
+	// AuthVersionCompact the proof doesn't carry its commitments, they are recomputed by the receiver
+	// (see daga.ClientProof.Compact), roughly halving the size of the request
+	AuthVersionCompact
+)
+
 // ServiceID represents the ID of 3rd party service (that use DAGA as its auth. mechanism, don't confuse with Onet.ServiceID)
 type ServiceID uuid.UUID // FIXME investigate if satori is still the package to use, saw claims that it should be deprecated in favor of newer forks and see what version of the package to use (vs V1.)
 
 // ContextID represents the ID of a Context
 type ContextID uuid.UUID
 
+// DefaultSuiteName is the name of the daga.Suite used by the contexts that don't specify one
+// (e.g. contexts created before the suite identifier was introduced)
+var DefaultSuiteName = daga.NewSuiteEC().String()
+
+// SuiteByName returns the daga.Suite named name, or the default suite if name is empty
+func SuiteByName(name string) (daga.Suite, error) {
+	if name == "" {
+		name = DefaultSuiteName
+	}
+	return daga.SuiteByName(name)
+}
+
+// ContextSignData returns the data that the daga servers sign to endorse a context,
+// that is the transcript of the context holding the name of its suite (see daga.AuthenticationContextSuiteToBytes).
+// the contexts that don't name their suite or use daga.EncodingLegacy sign the context alone,
+// as before the suite identifier was introduced, to keep accepting the stored contexts
+func ContextSignData(suiteName string, dagaContext daga.AuthenticationContext) ([]byte, error) {
+	if suiteName == "" || daga.ContextEncoding(dagaContext) == daga.EncodingLegacy {
+		return daga.AuthenticationContextToBytes(dagaContext)
+	}
+	return daga.AuthenticationContextSuiteToBytes(dagaContext, suiteName)
+}
+
 // DeriveContextID builds an UUIDv5 as a function of the context's hash
-func DeriveContextID(dagaContext daga.AuthenticationContext) (ContextID, error) {
+func DeriveContextID(suite daga.Suite, dagaContext daga.AuthenticationContext) (ContextID, error) {
 	// compute hash
-	bytes, err := daga.AuthenticationContextToBytes(dagaContext)
+	bytes, err := ContextSignData(suite.String(), dagaContext)
 	if err != nil {
 		return ContextID(uuid.Nil), err
 	}
@@ -59,11 +95,11 @@ func DeriveContextID(dagaContext daga.AuthenticationContext) (ContextID, error)
 
 // NewContext returns a pointer to newly allocated Context struct initialized with the provided daga.AuthenticationContext and roster
 // (the returned context implement daga.AuthenticationContext interface too)
-func NewContext(dagaContext daga.AuthenticationContext, roster *onet.Roster, serviceID ServiceID, signatures [][]byte) (*Context, error) {
+func NewContext(suite daga.Suite, dagaContext daga.AuthenticationContext, roster *onet.Roster, serviceID ServiceID, signatures [][]byte) (*Context, error) {
 	if err := daga.ValidateContext(dagaContext); err != nil {
 		return nil, err
 	} else {
-		contextID, err := DeriveContextID(dagaContext)
+		contextID, err := DeriveContextID(suite, dagaContext)
 		if err != nil {
 			return nil, errors.New("NewContext: failed to derive context's ID: " + err.Error())
 		}
@@ -72,37 +108,156 @@ func NewContext(dagaContext daga.AuthenticationContext, roster *onet.Roster, ser
 			ContextID:  contextID,
 			ServiceID:  serviceID,
 			Signatures: signatures,
-			X:          members.X,
-			Y:          members.Y,
-			R:          dagaContext.ServersSecretsCommitments(),
-			H:          dagaContext.ClientsGenerators(),
+			X:          EncodePoints(members.X),
+			Y:          EncodePoints(members.Y),
+			R:          EncodePoints(dagaContext.ServersSecretsCommitments()),
+			H:          EncodePoints(dagaContext.ClientsGenerators()),
 			Roster:     roster,
+			SuiteName:  suite.String(),
+			// keep the encoding the daga context was built (and signed) with
+			EncodingVersion: int(daga.ContextEncoding(dagaContext)),
+			// and the derivation of its client generators
+			GeneratorVersion: int(daga.ContextGeneratorVersion(dagaContext)),
+			// and the scheme of the signatures of its servers
+			SignatureScheme: int(daga.ContextSignatureScheme(dagaContext)),
 		}, nil
 	}
 }
 
-// Members returns the context members (their public keys)
+// Suite returns the daga.Suite of the context
+func (c Context) Suite() (daga.Suite, error) {
+	return SuiteByName(c.SuiteName)
+}
+
+// SignData returns the data signed by the daga servers to endorse the context (see ContextSignData)
+func (c Context) SignData() ([]byte, error) {
+	return ContextSignData(c.SuiteName, c)
+}
+
+// VerifySignatures verifies that the context is endorsed by all its daga servers,
+// using its collective signature if it has one (see VerifyCollectiveSignature) or the signature of each server otherwise
+func (c Context) VerifySignatures() error {
+	if len(c.CollectiveSignature) != 0 {
+		return c.VerifyCollectiveSignature()
+	}
+	suite, err := c.Suite()
+	if err != nil {
+		return errors.New("VerifySignatures: " + err.Error())
+	}
+	keys, err := DecodePoints(suite, c.Y)
+	if err != nil {
+		return errors.New("VerifySignatures: invalid server keys: " + err.Error())
+	}
+	if len(c.Signatures) != len(keys) {
+		return fmt.Errorf("VerifySignatures: wrong number of signatures: got %d expected %d", len(c.Signatures), len(keys))
+	}
+	data, err := c.SignData()
+	if err != nil {
+		return errors.New("VerifySignatures: " + err.Error())
+	}
+	for i, pubKey := range keys {
+		if err := c.SigningScheme().Verify(suite, pubKey, data, c.Signatures[i]); err != nil {
+			return fmt.Errorf("VerifySignatures: invalid signature of server %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// VerifyCollectiveSignature verifies the collective signature of the context against the aggregate key of its daga servers,
+// all the servers must have participated, the legacy contexts (see ContextSignData) can't be collectively signed
+func (c Context) VerifyCollectiveSignature() error {
+	suite, err := c.Suite()
+	if err != nil {
+		return errors.New("VerifyCollectiveSignature: " + err.Error())
+	}
+	if c.SuiteName == "" || c.Encoding() == daga.EncodingLegacy {
+		return errors.New("VerifyCollectiveSignature: legacy contexts are only endorsed by the signatures of their servers")
+	}
+	keys, err := DecodePoints(suite, c.Y)
+	if err != nil {
+		return errors.New("VerifyCollectiveSignature: invalid server keys: " + err.Error())
+	}
+	participants, err := daga.MaskParticipants(len(keys), c.ParticipationMask)
+	if err != nil {
+		return errors.New("VerifyCollectiveSignature: " + err.Error())
+	}
+	// anytrust, a context is endorsed only if endorsed by all its servers
+	if len(participants) != len(keys) {
+		return fmt.Errorf("VerifyCollectiveSignature: only %d of the %d servers participated", len(participants), len(keys))
+	}
+	data, err := c.SignData()
+	if err != nil {
+		return errors.New("VerifyCollectiveSignature: " + err.Error())
+	}
+	if err := daga.VerifyCollectiveSignature(suite, c.SigningScheme(), keys, c.ParticipationMask, data, c.CollectiveSignature); err != nil {
+		return errors.New("VerifyCollectiveSignature: " + err.Error())
+	}
+	return nil
+}
+
+// Members returns the context members (their public keys), decoded with the suite of the context,
+// empty if they can't be decoded (such a context is rejected by daga.ValidateContext)
 // see the daga.AuthenticationContext interface
 func (c Context) Members() daga.Members {
 	// implement manually the daga.AuthenticationContext interface (to make awk happy with proto.go, but previously and maybe later, daga.MinimumAuthenticationContext was/will be used for that purpose)
+	X, errX := c.decodePoints(c.X) // client/user keys
+	Y, errY := c.decodePoints(c.Y) // server keys
+	if errX != nil || errY != nil {
+		return daga.Members{}
+	}
 	return daga.Members{
-		X: c.X, // client/user keys
-		Y: c.Y, // server keys
+		X: X,
+		Y: Y,
 	}
 }
 
-// ClientsGenerators returns the per round generators (one for each client/user).
+// ClientsGenerators returns the per round generators (one for each client/user), nil if they can't be decoded.
 // see the daga.AuthenticationContext interface
 func (c Context) ClientsGenerators() []kyber.Point {
 	// implement manually the daga.AuthenticationContext interface (to make awk happy with proto.go, but previously and maybe later, daga.MinimumAuthenticationContext was/will be used for that purpose)
-	return c.H
+	H, err := c.decodePoints(c.H)
+	if err != nil {
+		return nil
+	}
+	return H
 }
 
-// ServersSecretsCommitments return the servers' commitments to their per-round secret
+// ServersSecretsCommitments return the servers' commitments to their per-round secret, nil if they can't be decoded.
 // see the daga.AuthenticationContext interface
 func (c Context) ServersSecretsCommitments() []kyber.Point {
 	// implement manually the daga.AuthenticationContext interface (to make awk happy with proto.go, but previously and maybe later, daga.MinimumAuthenticationContext was/will be used for that purpose)
-	return c.R
+	R, err := c.decodePoints(c.R)
+	if err != nil {
+		return nil
+	}
+	return R
+}
+
+// decodes the points of the context using its suite
+func (c Context) decodePoints(encoded [][]byte) ([]kyber.Point, error) {
+	suite, err := c.Suite()
+	if err != nil {
+		return nil, err
+	}
+	return DecodePoints(suite, encoded)
+}
+
+// Encoding returns the encoding of the data signed and hashed under the context
+// see the daga.VersionedContext interface
+func (c Context) Encoding() daga.EncodingVersion {
+	return daga.EncodingVersion(c.EncodingVersion)
+}
+
+// GeneratorDerivation returns the derivation of the client generators of the context
+// see the daga.GeneratorVersionedContext interface
+func (c Context) GeneratorDerivation() daga.GeneratorVersion {
+	return daga.GeneratorVersion(c.GeneratorVersion)
+}
+
+// SigningScheme returns the scheme of the signatures of the servers under the context
+// see the daga.SchemedContext interface
+func (c Context) SigningScheme() daga.SignatureScheme {
+	return daga.SignatureScheme(c.SignatureScheme)
 }
 
 // Equals is to be used by nodes upon reception of request/reply to verify that it is part of same auth.context that was requested/is accepted.
@@ -117,12 +272,26 @@ func (c Context) ServersSecretsCommitments() []kyber.Point {
 //  see later when context evolution scenarios are fixed and implemented.
 //  + consider moving this in sign/daga
 func (c Context) Equals(other Context) bool {
-	members1 := c.Members()
-	members2 := other.Members()
-	return ContainsSameElems(members1.X, members2.X) &&
-		ContainsSameElems(members1.Y, members2.Y) &&
-		ContainsSameElems(c.ClientsGenerators(), other.ClientsGenerators()) &&
-		ContainsSameElems(c.ServersSecretsCommitments(), other.ServersSecretsCommitments())
+	// the points are compared encoded, a point has a single (canonical) encoding, see daga.DecodePoint
+	return sameSuite(c.SuiteName, other.SuiteName) &&
+		c.EncodingVersion == other.EncodingVersion &&
+		c.GeneratorVersion == other.GeneratorVersion &&
+		c.SignatureScheme == other.SignatureScheme &&
+		containsSameEncodings(c.X, other.X) &&
+		containsSameEncodings(c.Y, other.Y) &&
+		containsSameEncodings(c.H, other.H) &&
+		containsSameEncodings(c.R, other.R)
+}
+
+// returns whether the suite names a and b designate the same suite (empty name is the default suite)
+func sameSuite(a, b string) bool {
+	if a == "" {
+		a = DefaultSuiteName
+	}
+	if b == "" {
+		b = DefaultSuiteName
+	}
+	return a == b
 }
 
 // NetEncodeChallenge is used to translate a daga.Challenge to the "proto-awk" friendly version of it
@@ -137,13 +306,21 @@ func NetEncodeChallenge(challenge daga.Challenge) *PKclientChallenge {
 	}
 
 	return &PKclientChallenge{
-		Cs:   challenge.Cs,
-		Sigs: copyOfProofChallengeSigs,
+		Cs:      EncodeScalar(challenge.Cs),
+		Sigs:    copyOfProofChallengeSigs,
+		Session: challenge.Session,
+		ID:      challenge.ID,
+		Expiry:  challenge.Expiry,
 	}
 }
 
-// NetDecode is used to translate back the "proto-awk" friendly version of a daga.Challenge
-func (pkc PKclientChallenge) NetDecode() *daga.Challenge {
+// NetDecode is used to translate back the "proto-awk" friendly version of a daga.Challenge,
+// its scalar is decoded using suite, the suite of the context of the challenge
+func (pkc PKclientChallenge) NetDecode(suite daga.Suite) (*daga.Challenge, error) {
+	cs, err := decodeOptionalScalar(suite, pkc.Cs)
+	if err != nil {
+		return nil, fmt.Errorf("challenge: %w", err)
+	}
 
 	copyOfProofChallengeSigs := make([]daga.ServerSignature, 0, len(pkc.Sigs))
 	for _, serverSig := range pkc.Sigs {
@@ -154,9 +331,12 @@ func (pkc PKclientChallenge) NetDecode() *daga.Challenge {
 	}
 
 	return &daga.Challenge{
-		Cs:   pkc.Cs,
-		Sigs: copyOfProofChallengeSigs,
-	}
+		Cs:      cs,
+		Sigs:    copyOfProofChallengeSigs,
+		Session: pkc.Session,
+		ID:      pkc.ID,
+		Expiry:  pkc.Expiry,
+	}, nil
 }
 
 // NetEncodeAuthenticationMessage is used to translate a daga.AuthenticationMessage to the "net-and-proto-awk" friendly version of it
@@ -167,54 +347,90 @@ func NetEncodeAuthenticationMessage(context Context, msg daga.AuthenticationMess
 
 	copyOfProof := ClientProof{
 		Cs: *copyOfProofChallenge,
-		R:  msg.P0.R,
-		C:  msg.P0.C,
-		T:  msg.P0.T,
+		R:  EncodeScalars(msg.P0.R),
+		C:  EncodeScalars(msg.P0.C),
+		T:  EncodePoints(msg.P0.T),
 	}
 
 	return &Auth{
 		Context:  context, // i.e. discard context part of message and use the one provided
-		T0:       msg.T0,
-		SCommits: msg.SCommits,
+		T0:       EncodePoint(msg.T0),
+		SCommits: EncodePoints(msg.SCommits),
 		Proof:    copyOfProof,
 	}
 }
 
-// NetDecode is used to translate back the "net-and-proto-awk" friendly version of a daga.AuthenticationMessage
-func (a Auth) NetDecode() (*daga.AuthenticationMessage, Context) {
+// NetDecode is used to translate back the "net-and-proto-awk" friendly version of a daga.AuthenticationMessage,
+// its points and scalars are decoded using the suite of its context
+func (a Auth) NetDecode() (*daga.AuthenticationMessage, Context, error) {
+	suite, err := a.Context.Suite()
+	if err != nil {
+		return nil, Context{}, err
+	}
 
 	// "deep-translate" proof
-	copyOfProofChallengeSigs := make([]daga.ServerSignature, 0, len(a.Proof.Cs.Sigs))
-	for _, serverSig := range a.Proof.Cs.Sigs {
-		copyOfProofChallengeSigs = append(copyOfProofChallengeSigs, daga.ServerSignature{
-			Index: serverSig.Index,
-			Sig:   serverSig.Sig,
-		})
+	copyOfProofChallenge, err := a.Proof.Cs.NetDecode(suite)
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("proof: %w", err)
 	}
-
-	copyOfProofChallenge := daga.Challenge{
-		Cs:   a.Proof.Cs.Cs,
-		Sigs: copyOfProofChallengeSigs,
+	R, err := DecodeScalars(suite, a.Proof.R)
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("proof responses: %w", err)
+	}
+	C, err := DecodeScalars(suite, a.Proof.C)
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("proof challenges: %w", err)
+	}
+	T, err := DecodePoints(suite, a.Proof.T)
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("proof commitments: %w", err)
 	}
 
 	copyOfProof := daga.ClientProof{
-		Cs: copyOfProofChallenge,
-		R:  a.Proof.R,
-		C:  a.Proof.C,
-		T:  a.Proof.T,
+		Cs: *copyOfProofChallenge,
+		R:  R,
+		C:  C,
+		T:  T,
+	}
+
+	if a.Version == AuthVersionCompact {
+		// recomputed by sign/daga, (see daga.ExpandClientProof)
+		copyOfProof = copyOfProof.Compact()
 	}
 
 	msg := daga.AuthenticationMessage{
 		C:  a.Context,
 		P0: copyOfProof,
 	}
-	msg.SCommits = a.SCommits
-	msg.T0 = a.T0
-	return &msg, a.Context
+	if msg.SCommits, err = DecodePoints(suite, a.SCommits); err != nil {
+		return nil, Context{}, fmt.Errorf("commitments: %w", err)
+	}
+	if msg.T0, err = decodeOptionalPoint(suite, a.T0); err != nil {
+		return nil, Context{}, fmt.Errorf("initial tag: %w", err)
+	}
+	return &msg, a.Context, nil
+}
+
+// ValidateVersion returns an error if the encoding of the proof of the request is unknown
+func (a Auth) ValidateVersion() error {
+	if a.Version != AuthVersionFull && a.Version != AuthVersionCompact {
+		return fmt.Errorf("unknown encoding of the client's proof: %d", a.Version)
+	}
+	return nil
+}
+
+// WithVersion returns a copy of the request whose proof is encoded according to version (see AuthVersionCompact),
+// the commitments of a full proof can't be restored here (no suite), a compact request stays compact
+func (a Auth) WithVersion(version int) Auth {
+	if version == AuthVersionCompact {
+		a.Proof.T = nil
+		a.Version = AuthVersionCompact
+	}
+	return a
 }
 
 // NetEncodeServerMessage is used to translate a daga.ServerMessage to the "net-and-proto-awk" friendly version of it
-func NetEncodeServerMessage(context Context, msg *daga.ServerMessage) *AuthReply {
+func NetEncodeServerMessage(context Context, msg *daga.ServerMessage) *AuthTranscript {
 	request := NetEncodeAuthenticationMessage(context, msg.Request)
 
 	// "translate" sigs
@@ -230,27 +446,41 @@ func NetEncodeServerMessage(context Context, msg *daga.ServerMessage) *AuthReply
 	copyOfServerProofs := make([]ServerProof, 0, len(msg.Proofs))
 	for _, dagaServerProof := range msg.Proofs {
 		copyOfServerProofs = append(copyOfServerProofs, ServerProof{
-			C:  dagaServerProof.C,
-			R1: dagaServerProof.R1,
-			R2: dagaServerProof.R2,
-			T1: dagaServerProof.T1,
-			T2: dagaServerProof.T2,
-			T3: dagaServerProof.T3,
+			C:  EncodeScalar(dagaServerProof.C),
+			R1: EncodeScalar(dagaServerProof.R1),
+			R2: EncodeScalar(dagaServerProof.R2),
+			T1: EncodePoint(dagaServerProof.T1),
+			T2: EncodePoint(dagaServerProof.T2),
+			T3: EncodePoint(dagaServerProof.T3),
 		})
 	}
 
-	return &AuthReply{
+	return &AuthTranscript{
 		Request: *request,
 		Sigs:    copyOfSigs,
 		Proofs:  copyOfServerProofs,
-		Tags:    msg.Tags,
+		Tags:    EncodePoints(msg.Tags),
 		Indexes: msg.Indexes,
 	}
 }
 
-// NetDecode is used to translate back the "net-and-proto-awk" friendly version of a daga.NetServerMessage
-func (ar AuthReply) NetDecode() (*daga.ServerMessage, Context) {
-	request, context := ar.Request.NetDecode()
+// WithVersion returns a copy of the transcript whose request is encoded according to version (see Auth.WithVersion)
+func (ar AuthTranscript) WithVersion(version int) AuthTranscript {
+	ar.Request = ar.Request.WithVersion(version)
+	return ar
+}
+
+// NetDecode is used to translate back the "net-and-proto-awk" friendly version of a daga.NetServerMessage,
+// its points and scalars are decoded using the suite of the context of its request
+func (ar AuthTranscript) NetDecode() (*daga.ServerMessage, Context, error) {
+	request, context, err := ar.Request.NetDecode()
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("request: %w", err)
+	}
+	suite, err := context.Suite()
+	if err != nil {
+		return nil, Context{}, err
+	}
 
 	// "translate" sigs
 	copyOfSigs := make([]daga.ServerSignature, 0, len(ar.Sigs))
@@ -261,24 +491,218 @@ func (ar AuthReply) NetDecode() (*daga.ServerMessage, Context) {
 		})
 	}
 
-	// "translate" proofs
+	// "translate" proofs, the elements missing from a proof are left nil (e.g. R2 of the proof flagging a misbehaving client)
 	copyOfServerProofs := make([]daga.ServerProof, 0, len(ar.Proofs))
-	for _, serverProof := range ar.Proofs {
-		copyOfServerProofs = append(copyOfServerProofs, daga.ServerProof{
-			C:  serverProof.C,
-			R1: serverProof.R1,
-			R2: serverProof.R2,
-			T1: serverProof.T1,
-			T2: serverProof.T2,
-			T3: serverProof.T3,
-		})
+	for i, serverProof := range ar.Proofs {
+		var proof daga.ServerProof
+		for _, field := range []struct {
+			dst *kyber.Scalar
+			buf []byte
+		}{{&proof.C, serverProof.C}, {&proof.R1, serverProof.R1}, {&proof.R2, serverProof.R2}} {
+			if *field.dst, err = decodeOptionalScalar(suite, field.buf); err != nil {
+				return nil, Context{}, fmt.Errorf("proof %d: %w", i, err)
+			}
+		}
+		for _, field := range []struct {
+			dst *kyber.Point
+			buf []byte
+		}{{&proof.T1, serverProof.T1}, {&proof.T2, serverProof.T2}, {&proof.T3, serverProof.T3}} {
+			if *field.dst, err = decodeOptionalPoint(suite, field.buf); err != nil {
+				return nil, Context{}, fmt.Errorf("proof %d: %w", i, err)
+			}
+		}
+		copyOfServerProofs = append(copyOfServerProofs, proof)
+	}
+
+	tags, err := DecodePoints(suite, ar.Tags)
+	if err != nil {
+		return nil, Context{}, fmt.Errorf("tags: %w", err)
 	}
 
 	return &daga.ServerMessage{
 		Request: *request,
-		Tags:    ar.Tags,
+		Tags:    tags,
 		Proofs:  copyOfServerProofs,
 		Sigs:    copyOfSigs,
 		Indexes: ar.Indexes,
-	}, context
+	}, context, nil
+}
+
+// domain of the transcript signed by the daga servers in the receipts (see AuthReceipt.SignData),
+// distinct from the domain of the context transcripts signed to endorse a context (see ContextSignData),
+// (the legacy contexts, signed alone, are only endorsed by the individual signatures of the servers, never collectively)
+const receiptDomain = "dagacothority/auth-receipt"
+
+// AuthRequestHash returns the hash of the request of a client, covered by the receipt of its authentication,
+// the hash doesn't depend on the encoding of the proof of the request (see AuthVersionCompact)
+func AuthRequestHash(suite daga.Suite, request daga.AuthenticationMessage) ([]byte, error) {
+	// the commitments of a compact proof are recomputed on a copy, the caller's request is left untouched
+	if err := daga.ExpandClientProof(suite, &request); err != nil {
+		return nil, err
+	}
+	data, err := request.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	hasher := suite.Hash()
+	hasher.Write(data)
+	return hasher.Sum(nil), nil
+}
+
+// SignData returns the data collectively signed by the daga servers in the receipt,
+// that is the transcript (see daga.Transcript) of the receipt domain holding the context ID, the tag, the request hash,
+// the timestamp and, if the authentication is bound to a session, the session binding
+func (r AuthReceipt) SignData() ([]byte, error) {
+	if len(r.Tag) == 0 {
+		return nil, errors.New("SignData: receipt without tag")
+	}
+	t := daga.NewTranscript(receiptDomain).
+		AppendBytes("context", uuid.UUID(r.ContextID).Bytes()).
+		AppendBytes("tag", r.Tag).
+		AppendBytes("request", r.RequestHash).
+		AppendInt64("timestamp", r.Timestamp)
+	if len(r.Session) != 0 {
+		t.AppendBytes("session", r.Session)
+	}
+	return t.Bytes()
+}
+
+// VerifyReceipt verifies that receipt is the receipt of an authentication under the context, collectively signed by
+// all the daga servers of the context.
+// the receipt of a client flagged as misbehaving by the servers carries a tag rejected by daga.CheckFinalLinkageTag
+func (c Context) VerifyReceipt(receipt AuthReceipt) error {
+	if receipt.ContextID != c.ContextID {
+		return errors.New("VerifyReceipt: receipt of another context")
+	}
+	suite, err := c.Suite()
+	if err != nil {
+		return errors.New("VerifyReceipt: " + err.Error())
+	}
+	keys, err := DecodePoints(suite, c.Y)
+	if err != nil {
+		return errors.New("VerifyReceipt: invalid server keys: " + err.Error())
+	}
+	participants, err := daga.MaskParticipants(len(keys), receipt.ParticipationMask)
+	if err != nil {
+		return errors.New("VerifyReceipt: " + err.Error())
+	}
+	// anytrust, an authentication is endorsed only if endorsed by all the servers
+	if len(participants) != len(keys) {
+		return fmt.Errorf("VerifyReceipt: only %d of the %d servers participated", len(participants), len(keys))
+	}
+	data, err := receipt.SignData()
+	if err != nil {
+		return errors.New("VerifyReceipt: " + err.Error())
+	}
+	if err := daga.VerifyCollectiveSignature(suite, c.SigningScheme(), keys, receipt.ParticipationMask, data, receipt.CollectiveSignature); err != nil {
+		return fmt.Errorf("VerifyReceipt: %w", err)
+	}
+	return nil
+}
+
+// VerifyReceiptForSession verifies the receipt (see VerifyReceipt) and that it is the receipt of an authentication
+// bound to session, the session of the relying party, returns an error wrapping daga.ErrSessionMismatch otherwise
+func (c Context) VerifyReceiptForSession(receipt AuthReceipt, session *Session) error {
+	if err := c.VerifyReceipt(receipt); err != nil {
+		return fmt.Errorf("VerifyReceiptForSession: %w", err)
+	}
+	suite, err := c.Suite()
+	if err != nil {
+		return errors.New("VerifyReceiptForSession: " + err.Error())
+	}
+	binding, err := session.Binding(suite)
+	if err != nil {
+		return errors.New("VerifyReceiptForSession: " + err.Error())
+	}
+	if !bytes.Equal(receipt.Session, binding) {
+		return fmt.Errorf("VerifyReceiptForSession: %w", daga.ErrSessionMismatch)
+	}
+	return nil
+}
+
+// sizes of the nonce of a Session and maximum size of its audience
+const (
+	// SessionNonceSize is the size of the nonces picked by NewSession
+	SessionNonceSize = 32
+	// MinSessionNonceSize is the minimum size of the nonce of a valid Session
+	MinSessionNonceSize = 16
+	// MaxSessionNonceSize is the maximum size of the nonce of a valid Session
+	MaxSessionNonceSize = 64
+	// MaxSessionAudienceSize is the maximum size of the audience of a valid Session
+	MaxSessionAudienceSize = 256
+)
+
+// domain of the transcript and label of the hash binding an authentication to a session (see Session.Binding)
+const (
+	sessionDomain                 = "dagacothority/session"
+	sessionBinding daga.HashLabel = "session-binding"
+)
+
+// NewSession returns a new session of the relying party audience with a fresh random nonce
+func NewSession(audience string) (*Session, error) {
+	nonce := make([]byte, SessionNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.New("NewSession: failed to pick nonce: " + err.Error())
+	}
+	session := &Session{Audience: audience, Nonce: nonce}
+	if err := session.Validate(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Validate returns an error if the session doesn't have an audience or if its audience or nonce have invalid sizes
+func (s Session) Validate() error {
+	if len(s.Audience) == 0 || len(s.Audience) > MaxSessionAudienceSize {
+		return fmt.Errorf("Validate: session audience of invalid size %d", len(s.Audience))
+	}
+	if len(s.Nonce) < MinSessionNonceSize || len(s.Nonce) > MaxSessionNonceSize {
+		return fmt.Errorf("Validate: session nonce of invalid size %d", len(s.Nonce))
+	}
+	return nil
+}
+
+// Binding returns the binding of an authentication under a context of suite to the session (see daga.Challenge.Session),
+// that is the labeled hash of the transcript (see daga.Transcript) of the session domain holding the audience and
+// the nonce, nil for a nil session (not bound)
+func (s *Session) Binding(suite daga.Suite) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if err := s.Validate(); err != nil {
+		return nil, errors.New("Binding: " + err.Error())
+	}
+	binding, err := daga.NewTranscript(sessionDomain).
+		AppendString("audience", s.Audience).
+		AppendBytes("nonce", s.Nonce).
+		Hash(suite, sessionBinding)
+	if err != nil {
+		return nil, errors.New("Binding: " + err.Error())
+	}
+	if len(binding) > daga.MaxSessionSize {
+		return nil, errors.New("Binding: hash of the suite too large")
+	}
+	return binding, nil
+}
+
+// VerifySession returns an error wrapping daga.ErrSessionMismatch if the request is not bound to session
+// (see daga.CheckSession), e.g. used by a relying party before forwarding a request built by the client to the servers.
+// the binding is authentic once the servers accepted the request (see Context.VerifyReceiptForSession)
+func (a Auth) VerifySession(session *Session) error {
+	suite, err := a.Context.Suite()
+	if err != nil {
+		return errors.New("VerifySession: " + err.Error())
+	}
+	binding, err := session.Binding(suite)
+	if err != nil {
+		return errors.New("VerifySession: " + err.Error())
+	}
+	request, _, err := a.NetDecode()
+	if err != nil {
+		return errors.New("VerifySession: " + err.Error())
+	}
+	if err := daga.CheckSession(*request, binding); err != nil {
+		return fmt.Errorf("VerifySession: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,579 @@
+package dagacothority_test
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/dedis/onet/network"
+	"github.com/dedis/student_18_daga/dagacothority"
+	"github.com/dedis/student_18_daga/sign/daga"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+// the network encoding of the messages needs to be suite-agnostic
+var suites = []daga.Suite{
+	daga.NewSuiteEC(),
+	daga.NewSuiteSchnorr(),
+	daga.NewSuiteRistretto(),
+}
+
+// the suite of the conodes, used by onet to unmarshal the messages whatever the suite of their context
+var onetSuite = daga.NewSuiteEC()
+
+func TestNetEncodeDecode_Context(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, _, dagaContext, err := daga.GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+
+			buf, err := network.Marshal(context)
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.Context)
+			require.True(t, ok)
+			require.True(t, decoded.Equals(*context))
+			require.NoError(t, daga.ValidateContext(decoded))
+		})
+	}
+}
+
+func TestNetEncodeDecode_Auth(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, dagaContext, err := daga.GenerateTestContext(suite, 2, 2)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+
+			// dummy challenge, signed by all the servers
+			cs := suite.Scalar().Pick(suite.RandomStream())
+			sendCommitsReceiveChallenge := func(commitments []kyber.Point) (daga.Challenge, error) {
+				challenge := daga.Challenge{Cs: cs}
+				data, err := challenge.ToBytes(daga.ContextEncoding(context), commitments)
+				require.NoError(t, err)
+				for _, server := range servers {
+					sig, err := context.SigningScheme().Sign(suite, server.PrivateKey(), data)
+					require.NoError(t, err)
+					challenge.Sigs = append(challenge.Sigs, daga.ServerSignature{Index: server.Index(), Sig: sig})
+				}
+				return challenge, nil
+			}
+			authMsg, err := daga.NewAuthenticationMessage(suite, *context, clients[0], sendCommitsReceiveChallenge)
+			require.NoError(t, err)
+
+			buf, err := network.Marshal(dagacothority.NetEncodeAuthenticationMessage(*context, *authMsg))
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.Auth)
+			require.True(t, ok)
+			decodedMsg, decodedContext, err := decoded.NetDecode()
+			require.NoError(t, err)
+			require.True(t, decodedContext.Equals(*context))
+			require.True(t, decodedMsg.T0.Equal(authMsg.T0))
+
+			// the decoded request must still be accepted by the servers
+			servMsg, err := daga.InitializeServerMessage(decodedMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, daga.ServerProtocol(suite, servMsg, server))
+			}
+			_, err = daga.GetFinalLinkageTag(suite, decodedContext, *servMsg)
+			require.NoError(t, err)
+
+			// compact request, without the commitments of the proof
+			compact := dagacothority.NetEncodeAuthenticationMessage(*context, *authMsg).WithVersion(dagacothority.AuthVersionCompact)
+			compactBuf, err := network.Marshal(&compact)
+			require.NoError(t, err)
+			require.True(t, len(compactBuf) < len(buf), "compact request not smaller than full request")
+			_, msg, err = network.Unmarshal(compactBuf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok = msg.(*dagacothority.Auth)
+			require.True(t, ok)
+			require.Equal(t, dagacothority.AuthVersionCompact, decoded.Version)
+			require.NoError(t, decoded.ValidateVersion())
+			decodedMsg, decodedContext, err = decoded.NetDecode()
+			require.NoError(t, err)
+			require.True(t, decodedMsg.P0.IsCompact())
+
+			// accepted by the servers, forwarded and replied in compact form
+			servMsg, err = daga.InitializeServerMessage(decodedMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, daga.ServerProtocol(suite, servMsg, server))
+				reply := dagacothority.NetEncodeServerMessage(decodedContext, servMsg).WithVersion(decoded.Version)
+				require.Empty(t, reply.Request.Proof.T)
+				servMsg, _, err = reply.NetDecode()
+				require.NoError(t, err)
+			}
+			_, err = daga.GetFinalLinkageTag(suite, decodedContext, *servMsg)
+			require.NoError(t, err)
+
+			// the receipts cover the same request hash whatever the encoding of the proof
+			fullHash, err := dagacothority.AuthRequestHash(suite, *authMsg)
+			require.NoError(t, err)
+			compactHash, err := dagacothority.AuthRequestHash(suite, *decodedMsg)
+			require.NoError(t, err)
+			require.Equal(t, fullHash, compactHash)
+			require.True(t, decodedMsg.P0.IsCompact(), "request expanded in place")
+
+			decoded.Version = 42
+			require.Error(t, decoded.ValidateVersion())
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	session, err := dagacothority.NewSession("https://rp.example.com")
+	require.NoError(t, err)
+	require.Len(t, session.Nonce, dagacothority.SessionNonceSize)
+	other, err := dagacothority.NewSession("https://rp.example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, session.Nonce, other.Nonce)
+
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			binding, err := session.Binding(suite)
+			require.NoError(t, err)
+			require.NotEmpty(t, binding)
+			require.True(t, len(binding) <= daga.MaxSessionSize)
+			again, err := session.Binding(suite)
+			require.NoError(t, err)
+			require.Equal(t, binding, again)
+			// the labeled hash of the transcript of the session
+			expected, err := daga.NewTranscript("dagacothority/session").
+				AppendString("audience", session.Audience).
+				AppendBytes("nonce", session.Nonce).
+				Hash(suite, "session-binding")
+			require.NoError(t, err)
+			require.Equal(t, expected, binding)
+
+			// every field is bound
+			otherBinding, err := other.Binding(suite)
+			require.NoError(t, err)
+			require.NotEqual(t, binding, otherBinding)
+			otherAudience := dagacothority.Session{Audience: "https://other.example.com", Nonce: session.Nonce}
+			otherBinding, err = otherAudience.Binding(suite)
+			require.NoError(t, err)
+			require.NotEqual(t, binding, otherBinding)
+
+			// no session, no binding
+			var unbound *dagacothority.Session
+			binding, err = unbound.Binding(suite)
+			require.NoError(t, err)
+			require.Nil(t, binding)
+		})
+	}
+
+	_, err = dagacothority.NewSession("")
+	require.Error(t, err)
+	require.Error(t, dagacothority.Session{Audience: "https://rp.example.com", Nonce: session.Nonce[:dagacothority.MinSessionNonceSize-1]}.Validate())
+	require.Error(t, dagacothority.Session{Audience: "https://rp.example.com", Nonce: make([]byte, dagacothority.MaxSessionNonceSize+1)}.Validate())
+	require.Error(t, dagacothority.Session{Audience: string(make([]byte, dagacothority.MaxSessionAudienceSize+1)), Nonce: session.Nonce}.Validate())
+	_, err = (&dagacothority.Session{Audience: "https://rp.example.com"}).Binding(suites[0])
+	require.Error(t, err)
+}
+
+func TestAuth_VerifySession(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, dagaContext, err := daga.GenerateTestContext(suite, 2, 2)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+			session, err := dagacothority.NewSession("https://rp.example.com")
+			require.NoError(t, err)
+			binding, err := session.Binding(suite)
+			require.NoError(t, err)
+
+			// dummy challenge bound to the session, signed by all the servers
+			cs := suite.Scalar().Pick(suite.RandomStream())
+			sendCommitsReceiveChallenge := func(commitments []kyber.Point) (daga.Challenge, error) {
+				challenge := daga.Challenge{Cs: cs, Session: binding}
+				data, err := challenge.ToBytes(daga.ContextEncoding(context), commitments)
+				require.NoError(t, err)
+				for _, server := range servers {
+					sig, err := context.SigningScheme().Sign(suite, server.PrivateKey(), data)
+					require.NoError(t, err)
+					challenge.Sigs = append(challenge.Sigs, daga.ServerSignature{Index: server.Index(), Sig: sig})
+				}
+				// survives the network encoding
+				buf, err := network.Marshal(dagacothority.NetEncodeChallenge(challenge))
+				require.NoError(t, err)
+				_, msg, err := network.Unmarshal(buf, onetSuite)
+				require.NoError(t, err)
+				decoded, ok := msg.(*dagacothority.PKclientChallenge)
+				require.True(t, ok)
+				require.Equal(t, binding, decoded.Session)
+				decodedChallenge, err := decoded.NetDecode(suite)
+				require.NoError(t, err)
+				return *decodedChallenge, nil
+			}
+			authMsg, err := daga.NewAuthenticationMessage(suite, *context, clients[0], sendCommitsReceiveChallenge)
+			require.NoError(t, err)
+
+			request := dagacothority.NetEncodeAuthenticationMessage(*context, *authMsg).WithVersion(dagacothority.AuthVersionCompact)
+			buf, err := network.Marshal(&request)
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.Auth)
+			require.True(t, ok)
+			require.NoError(t, decoded.VerifySession(session))
+			other, err := dagacothority.NewSession("https://rp.example.com")
+			require.NoError(t, err)
+			err = decoded.VerifySession(other)
+			require.True(t, errors.Is(err, daga.ErrSessionMismatch), "wrong error: %s", err)
+			err = decoded.VerifySession(nil)
+			require.True(t, errors.Is(err, daga.ErrSessionMismatch), "wrong error: %s", err)
+
+			// accepted by the servers, that sign the binding along with the request
+			decodedMsg, decodedContext, err := decoded.NetDecode()
+			require.NoError(t, err)
+			servMsg, err := daga.InitializeServerMessage(decodedMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, daga.ServerProtocol(suite, servMsg, server))
+			}
+			_, err = daga.GetFinalLinkageTag(suite, decodedContext, *servMsg)
+			require.NoError(t, err)
+			unboundHash, err := dagacothority.AuthRequestHash(suite, *decodedMsg)
+			require.NoError(t, err)
+
+			// but not once relayed in another session
+			relayed := *decoded
+			relayed.Proof.Cs.Session, err = other.Binding(suite)
+			require.NoError(t, err)
+			require.NoError(t, relayed.VerifySession(other))
+			relayedMsg, _, err := relayed.NetDecode()
+			require.NoError(t, err)
+			servMsg, err = daga.InitializeServerMessage(relayedMsg)
+			require.NoError(t, err)
+			require.Error(t, daga.ServerProtocol(suite, servMsg, servers[0]))
+			relayedHash, err := dagacothority.AuthRequestHash(suite, *relayedMsg)
+			require.NoError(t, err)
+			require.NotEqual(t, unboundHash, relayedHash)
+		})
+	}
+}
+
+func TestContext_Suite(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, servers, dagaContext, err := daga.GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+			require.Equal(t, suite.String(), context.SuiteName)
+			resolved, err := context.Suite()
+			require.NoError(t, err)
+			require.Equal(t, suite.String(), resolved.String())
+
+			// signatures cover the suite identifier
+			data, err := context.SignData()
+			require.NoError(t, err)
+			for _, server := range servers {
+				sig, err := context.SigningScheme().Sign(suite, server.PrivateKey(), data)
+				require.NoError(t, err)
+				context.Signatures = append(context.Signatures, sig)
+			}
+			require.NoError(t, context.VerifySignatures())
+
+			// on edwards25519 the endorsements are standard Ed25519 signatures
+			require.Equal(t, int(daga.DefaultSignatureScheme(suite)), context.SignatureScheme)
+			if context.SigningScheme() == daga.SignatureEd25519 {
+				for i, pubKey := range context.Y {
+					require.True(t, ed25519.Verify(pubKey, data, context.Signatures[i]), "signature of server %d rejected by crypto/ed25519", i)
+				}
+			}
+			otherSchemeContext := *context
+			otherSchemeContext.SignatureScheme = int(daga.SignatureEd25519)
+			if context.SigningScheme() == daga.SignatureEd25519 {
+				otherSchemeContext.SignatureScheme = int(daga.SignatureSchnorr)
+			}
+			require.False(t, otherSchemeContext.Equals(*context), "contexts of different signature schemes are equal")
+			require.Error(t, otherSchemeContext.VerifySignatures())
+
+			otherSuiteData, err := dagacothority.ContextSignData("other suite", context)
+			require.NoError(t, err)
+			require.Error(t, context.SigningScheme().Verify(suite, context.Members().Y[0], otherSuiteData, context.Signatures[0]), "signature still valid after change of suite")
+			otherSuiteContext := *context
+			otherSuiteContext.SuiteName = "other suite"
+			require.False(t, otherSuiteContext.Equals(*context), "contexts of different suites are equal")
+
+			// missing signature
+			missingSig := *context
+			missingSig.Signatures = missingSig.Signatures[1:]
+			require.Error(t, missingSig.VerifySignatures())
+		})
+	}
+
+	// contexts that don't specify a suite use the default one
+	legacy := dagacothority.Context{}
+	suite, err := legacy.Suite()
+	require.NoError(t, err)
+	require.Equal(t, dagacothority.DefaultSuiteName, suite.String())
+
+	// unknown suite
+	_, err = dagacothority.Context{SuiteName: "unknown"}.Suite()
+	require.Error(t, err)
+}
+
+// test helper that returns the collective signature of data by the participants among the servers of context and its mask
+func cosignData(t require.TestingT, suite daga.Suite, servers []daga.Server, context dagacothority.Context, participants []int, data []byte) ([]byte, []byte) {
+	keys := context.Members().Y
+	mask, err := daga.NewParticipationMask(len(keys), participants)
+	require.NoError(t, err)
+	commits := make([]kyber.Point, len(keys))
+	secrets := make([]kyber.Scalar, len(keys))
+	for _, i := range participants {
+		commits[i], secrets[i] = daga.NewCosignatureCommitment(suite)
+	}
+	V, err := daga.AggregateCommitment(suite, commits, mask)
+	require.NoError(t, err)
+	A, err := daga.AggregateKey(suite, keys, mask)
+	require.NoError(t, err)
+	c, err := daga.CosignatureChallenge(suite, context.SigningScheme(), A, V, data)
+	require.NoError(t, err)
+	var responses []kyber.Scalar
+	for _, i := range participants {
+		response, err := daga.CosignatureResponse(suite, keys, servers[i], secrets[i], c)
+		require.NoError(t, err)
+		responses = append(responses, response)
+	}
+	sig, err := daga.NewCollectiveSignature(suite, V, responses)
+	require.NoError(t, err)
+	return sig, mask
+}
+
+func TestContext_VerifyCollectiveSignature(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, servers, dagaContext, err := daga.GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+			data, err := context.SignData()
+			require.NoError(t, err)
+
+			// collective signature of the context by all the servers
+			cosign := func(participants []int) {
+				context.CollectiveSignature, context.ParticipationMask = cosignData(t, suite, servers, *context, participants, data)
+			}
+			cosign([]int{0, 1, 2})
+			require.NoError(t, context.VerifyCollectiveSignature())
+			require.NoError(t, context.VerifySignatures(), "collective signature not used")
+
+			// survives the network encoding
+			buf, err := network.Marshal(context)
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.Context)
+			require.True(t, ok)
+			require.NoError(t, decoded.VerifyCollectiveSignature())
+
+			// bad signature, other suite
+			tampered := *context
+			tampered.CollectiveSignature = append([]byte{}, context.CollectiveSignature...)
+			tampered.CollectiveSignature[0] ^= 0x01
+			require.Error(t, tampered.VerifySignatures())
+			otherSuite := *context
+			otherSuite.SuiteName = "other suite"
+			require.Error(t, otherSuite.VerifyCollectiveSignature())
+
+			// all the servers must participate
+			cosign([]int{0, 2})
+			require.Error(t, context.VerifyCollectiveSignature())
+			context.ParticipationMask = nil
+			require.Error(t, context.VerifyCollectiveSignature())
+		})
+	}
+}
+
+// context endorsed by its servers before the suite identifier and EncodingV1 were introduced, on edwards25519
+// (the signed data was the raw concatenation of the points, the signatures were kyber schnorr signatures)
+var legacyContext = struct {
+	X, Y, H, R []string
+	Signatures []string
+	ContextID  string
+}{
+	X: []string{
+		"56549a364be6c1d731d9c41e16d5895cb5e3877c27df1ce857c1c3c08a94a76b",
+		"ed3baa38aa7f0a6015b59cfeaf19caa27a6934d189c9e4e50da7292bcd115b3e",
+		"50235c51f464f5d9c7557d92e71643845ea01001ba464c7b2457fbc94df9feeb",
+	},
+	Y: []string{
+		"db14f355b26d9c175f3cc8a0cb8ebca82b6fe5ebc85a13264528a2e07eb0be1e",
+		"1960bc9cd0c03572a34198458e3c737df76e51d634645d1df177b863a1dac1ac",
+	},
+	H: []string{
+		"e10e58ce2533dac780f00ff8427eead9ba4750fbfc0dfe67036508d23e0fd1fb",
+		"355693995379439e3ff1d75b754ac6936dfc1d7243d8f7225efdae52c65c12db",
+		"0e4e9b2ae8216cd32c5b2f303d806a5bfa42535906e464e68331e67259af0c19",
+	},
+	R: []string{
+		"2b3466a6e4d4dfde0a18269455beaa9fb3e8fde9ebbef2867eb87b84c356ba6e",
+		"e12ccb0f61957dcb725c9d738e758a83471b4bab77fcabc444004ff3c0e0943e",
+	},
+	Signatures: []string{
+		"57a025e37e83e09bd873143261b85d814773ceabd778412e684269addc4ebb845536c6167489b6cf776668339cf2583c49a11bf2a553193897178517bf34280c",
+		"7db646c274645a392c1ea3cd90ae7186e73afabeeddc9f162c4f8cbee625c3676506ffb91d9f55e1c58173093203963b3be5861c7788d9322eb8c57638a3bc02",
+	},
+	ContextID: "de060692-2452-574e-abce-b0b08c8c7f24",
+}
+
+// test helper that decodes the hex encoded points or signatures
+func decodeHex(t *testing.T, encoded []string) [][]byte {
+	decoded := make([][]byte, len(encoded))
+	for i, e := range encoded {
+		b, err := hex.DecodeString(e)
+		require.NoError(t, err)
+		decoded[i] = b
+	}
+	return decoded
+}
+
+func TestContext_Legacy(t *testing.T) {
+	suite := daga.NewSuiteEC()
+	context := dagacothority.Context{
+		X:          decodeHex(t, legacyContext.X),
+		Y:          decodeHex(t, legacyContext.Y),
+		H:          decodeHex(t, legacyContext.H),
+		R:          decodeHex(t, legacyContext.R),
+		Signatures: decodeHex(t, legacyContext.Signatures),
+	}
+	contextID, err := uuid.FromString(legacyContext.ContextID)
+	require.NoError(t, err)
+	context.ContextID = dagacothority.ContextID(contextID)
+
+	// the stored contexts (no suite, legacy encoding) are still accepted and keep their identifier
+	require.NoError(t, context.VerifySignatures())
+	derived, err := dagacothority.DeriveContextID(suite, context)
+	require.NoError(t, err)
+	require.Equal(t, context.ContextID, derived)
+	data, err := context.SignData()
+	require.NoError(t, err)
+	legacyData, err := daga.AuthenticationContextToBytes(context)
+	require.NoError(t, err)
+	require.Equal(t, legacyData, data)
+
+	// same when rebuilt, that names the suite but keeps the legacy encoding
+	rebuilt, err := dagacothority.NewContext(suite, context, nil, context.ServiceID, context.Signatures)
+	require.NoError(t, err)
+	require.Equal(t, dagacothority.DefaultSuiteName, rebuilt.SuiteName)
+	require.Equal(t, context.ContextID, rebuilt.ContextID)
+	require.NoError(t, rebuilt.VerifySignatures())
+
+	// but can't be collectively signed
+	rebuilt.CollectiveSignature, rebuilt.ParticipationMask = context.Signatures[0], []byte{0x03}
+	require.Error(t, rebuilt.VerifyCollectiveSignature())
+
+	// while the contexts using EncodingV1 sign the suite too
+	current := context
+	current.SuiteName = dagacothority.DefaultSuiteName
+	current.EncodingVersion = int(daga.EncodingV1)
+	require.Error(t, current.VerifySignatures())
+	currentData, err := current.SignData()
+	require.NoError(t, err)
+	require.NotEqual(t, legacyData, currentData)
+}
+
+func TestContext_VerifyReceipt(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, servers, dagaContext, err := daga.GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			context, err := dagacothority.NewContext(suite, dagaContext, nil, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+			require.NoError(t, err)
+
+			receipt := dagacothority.AuthReceipt{
+				ContextID:   context.ContextID,
+				Tag:         dagacothority.EncodePoint(suite.Point().Pick(suite.RandomStream())),
+				RequestHash: []byte("request hash"),
+				Timestamp:   1234567890,
+			}
+			data, err := receipt.SignData()
+			require.NoError(t, err)
+			// the layout of the transcripts of daga
+			expected, err := daga.NewTranscript("dagacothority/auth-receipt").
+				AppendBytes("context", uuid.UUID(receipt.ContextID).Bytes()).
+				AppendBytes("tag", receipt.Tag).
+				AppendBytes("request", receipt.RequestHash).
+				AppendInt64("timestamp", receipt.Timestamp).
+				Bytes()
+			require.NoError(t, err)
+			require.Equal(t, expected, data)
+			receipt.CollectiveSignature, receipt.ParticipationMask = cosignData(t, suite, servers, *context, []int{0, 1, 2}, data)
+			require.NoError(t, context.VerifyReceipt(receipt))
+
+			// survives the network encoding, with or without transcript
+			buf, err := network.Marshal(&dagacothority.AuthReply{Receipt: receipt})
+			require.NoError(t, err)
+			_, msg, err := network.Unmarshal(buf, onetSuite)
+			require.NoError(t, err)
+			decoded, ok := msg.(*dagacothority.AuthReply)
+			require.True(t, ok)
+			require.Nil(t, decoded.Transcript)
+			require.NoError(t, context.VerifyReceipt(decoded.Receipt))
+
+			// every field is signed
+			tampered := receipt
+			tampered.Tag = dagacothority.EncodePoint(suite.Point().Pick(suite.RandomStream()))
+			require.Error(t, context.VerifyReceipt(tampered))
+			tampered = receipt
+			tampered.RequestHash = []byte("other hash")
+			require.Error(t, context.VerifyReceipt(tampered))
+			tampered = receipt
+			tampered.Timestamp++
+			require.Error(t, context.VerifyReceipt(tampered))
+			tampered = receipt
+			tampered.ContextID = dagacothority.ContextID(uuid.Must(uuid.NewV4()))
+			require.Error(t, context.VerifyReceipt(tampered))
+			tampered = receipt
+			tampered.Tag = nil
+			require.Error(t, context.VerifyReceipt(tampered))
+
+			// not a context signature, and all the servers must participate
+			context.CollectiveSignature, context.ParticipationMask = receipt.CollectiveSignature, receipt.ParticipationMask
+			require.Error(t, context.VerifyCollectiveSignature())
+			tampered = receipt
+			tampered.CollectiveSignature, tampered.ParticipationMask = cosignData(t, suite, servers, *context, []int{0, 2}, data)
+			require.Error(t, context.VerifyReceipt(tampered))
+
+			// receipts of authentications bound to a session
+			session, err := dagacothority.NewSession("https://rp.example.com")
+			require.NoError(t, err)
+			other, err := dagacothority.NewSession("https://rp.example.com")
+			require.NoError(t, err)
+			require.NoError(t, context.VerifyReceiptForSession(receipt, nil))
+			err = context.VerifyReceiptForSession(receipt, session)
+			require.True(t, errors.Is(err, daga.ErrSessionMismatch), "wrong error: %s", err)
+			bound := receipt
+			bound.Session, err = session.Binding(suite)
+			require.NoError(t, err)
+			boundData, err := bound.SignData()
+			require.NoError(t, err)
+			require.NotEqual(t, data, boundData)
+			bound.CollectiveSignature, bound.ParticipationMask = cosignData(t, suite, servers, *context, []int{0, 1, 2}, boundData)
+			require.NoError(t, context.VerifyReceiptForSession(bound, session))
+			err = context.VerifyReceiptForSession(bound, other)
+			require.True(t, errors.Is(err, daga.ErrSessionMismatch), "wrong error: %s", err)
+			err = context.VerifyReceiptForSession(bound, nil)
+			require.True(t, errors.Is(err, daga.ErrSessionMismatch), "wrong error: %s", err)
+			tampered = bound
+			tampered.Session, err = other.Binding(suite)
+			require.NoError(t, err)
+			require.Error(t, context.VerifyReceiptForSession(tampered, other))
+			tampered.Session = nil
+			require.Error(t, context.VerifyReceiptForSession(tampered, nil))
+		})
+	}
+}
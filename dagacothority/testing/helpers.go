@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 var tSuite = daga.NewSuiteEC()
@@ -38,16 +39,35 @@ type DummyService struct {
 	// Has to be initialised by the tests
 	DagaServer    daga.Server
 	AcceptContext func(dagacothority.Context) (daga.Server, error)
+
+	// the challenges consumed by the authentications (see ConsumeChallenge)
+	Challenges *dagacothority.ConsumedChallenges
 }
 
 // NewDummyService returns a new DummyService
 func NewDummyService(c *onet.Context) (onet.Service, error) {
 	s := &DummyService{
 		ServiceProcessor: onet.NewServiceProcessor(c),
+		Challenges:       dagacothority.NewConsumedChallenges(),
 	}
 	return s, nil
 }
 
+// CopyDagaServer returns a copy of the daga server of the service, to hand to a protocol instance
+// (the protocol instances own their daga server and release its secrets when done)
+func (s DummyService) CopyDagaServer() daga.Server {
+	dagaServer, err := dagacothority.NetEncodeServer(s.DagaServer).NetDecode(tSuite)
+	if err != nil {
+		log.Panic(err.Error())
+	}
+	return dagaServer
+}
+
+// ConsumeChallenge "dummy" counterpart of dagacothority.service.consumeChallenge() keep them more or less in sync
+func (s DummyService) ConsumeChallenge(context dagacothority.Context, challenge daga.Challenge) error {
+	return s.Challenges.Consume(context.ContextID, challenge, time.Now())
+}
+
 // NewDAGAChallengeGenerationProtocol is called to initialize and start a new dagachallengegeneration protocol where current node takes a Leader role
 // "dummy" counterpart of dagacothority.service.newDAGAChallengeGenerationProtocol() keep them more or less in sync
 func (s DummyService) NewDAGAChallengeGenerationProtocol(t *testing.T, req dagacothority.PKclientCommitments) *dagachallengegeneration.Protocol {
@@ -63,7 +83,7 @@ func (s DummyService) NewDAGAChallengeGenerationProtocol(t *testing.T, req dagac
 	require.NotNil(t, pi, "nil protocol instance but no error")
 
 	challengeGeneration := pi.(*dagachallengegeneration.Protocol)
-	challengeGeneration.LeaderSetup(req, s.DagaServer)
+	challengeGeneration.LeaderSetup(req, s.CopyDagaServer())
 
 	// start
 	err = challengeGeneration.Start()
@@ -88,7 +108,7 @@ func (s DummyService) NewDAGAServerProtocol(t *testing.T, req dagacothority.Auth
 	require.NotNil(t, pi, "nil protocol instance but no error")
 
 	dagaProtocol := pi.(*dagaauth.Protocol)
-	dagaProtocol.LeaderSetup(req, s.DagaServer)
+	dagaProtocol.LeaderSetup(req, s.CopyDagaServer(), s.ConsumeChallenge)
 
 	// start
 	err = dagaProtocol.Start()
@@ -142,7 +162,7 @@ func (s *DummyService) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 			return nil, err
 		}
 		dagaProtocol := pi.(*dagaauth.Protocol)
-		dagaProtocol.ChildSetup(s.AcceptContext)
+		dagaProtocol.ChildSetup(s.AcceptContext, s.ConsumeChallenge)
 		return dagaProtocol, nil
 	case dagacontextgeneration.Name:
 		pi, err := dagacontextgeneration.NewProtocol(tn)
@@ -171,21 +191,32 @@ func DummyDagaSetup(numClients, numServers int, roster *onet.Roster) (dagaClient
 	if err != nil {
 		log.Panic(err.Error())
 	}
-	dummyContext, _ = dagacothority.NewContext(minDagaContext, roster, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+	dummyContext, _ = dagacothority.NewContext(tSuite, minDagaContext, roster, dagacothority.ServiceID(uuid.Must(uuid.NewV4())), nil)
+	dummyAuthRequest = DummyAuthRequest(dagaClients[0], dagaServers, dummyContext)
+	return
+}
 
+// DummyAuthRequest returns a new dummy authRequest of the client under the context, with a fresh challenge signed by the servers
+func DummyAuthRequest(dagaClient daga.Client, dagaServers []daga.Server, dummyContext *dagacothority.Context) *daga.AuthenticationMessage {
 	// TODO what would be the best way to share test helpers with sign/daga (have the ~same) ? new daga testing package exported under sign/daga with all helper ?
 	dummyChallengeChannel := func(commitments []kyber.Point) (daga.Challenge, error) {
+		id, err := dagacothority.NewChallengeID()
+		if err != nil {
+			return daga.Challenge{}, err
+		}
 		challenge := daga.Challenge{
-			Cs: tSuite.Scalar().Pick(tSuite.RandomStream()),
+			Cs:     tSuite.Scalar().Pick(tSuite.RandomStream()),
+			ID:     id,
+			Expiry: dagacothority.NewChallengeExpiry(time.Now()),
 		}
-		signData, err := challenge.ToBytes(commitments)
+		signData, err := challenge.ToBytes(daga.ContextEncoding(dummyContext), commitments)
 		if err != nil {
 			return daga.Challenge{}, err
 		}
 		var sigs []daga.ServerSignature
 		//Make each test server sign the challenge
 		for _, server := range dagaServers {
-			if sig, err := daga.SchnorrSign(tSuite, server.PrivateKey(), signData); err != nil {
+			if sig, err := dummyContext.SigningScheme().Sign(tSuite, server.PrivateKey(), signData); err != nil {
 				return daga.Challenge{}, err
 			} else {
 				sigs = append(sigs, daga.ServerSignature{Index: server.Index(), Sig: sig})
@@ -195,8 +226,8 @@ func DummyDagaSetup(numClients, numServers int, roster *onet.Roster) (dagaClient
 		return challenge, nil
 	}
 
-	dummyAuthRequest, _ = daga.NewAuthenticationMessage(tSuite, dummyContext, dagaClients[0], dummyChallengeChannel)
-	return
+	dummyAuthRequest, _ := daga.NewAuthenticationMessage(tSuite, dummyContext, dagaClient, dummyChallengeChannel)
+	return dummyAuthRequest
 }
 
 // ValidServiceSetup is used to test the protocols, returns dummy test service (correctly initialized) and dummy context and request
@@ -215,7 +246,7 @@ func ValidServiceSetup(local *onet.LocalTest, nbrNodes int) ([]onet.Service, *da
 		service.DagaServer = dagaServers[i]
 		service.AcceptContext = func(context dagacothority.Context) (daga.Server, error) {
 			if context.Equals(*dummyContext) {
-				return service.DagaServer, nil
+				return service.CopyDagaServer(), nil
 			} else {
 				return nil, errors.New("not accepted")
 			}
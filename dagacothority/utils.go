@@ -29,6 +29,24 @@ func ContainsSameElems(a, b []kyber.Point) bool {
 	return true
 }
 
+// checks if two slices of encoded points are containing the same elements
+func containsSameEncodings(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]struct{}, len(a))
+	exist := struct{}{}
+	for _, p := range a {
+		set[string(p)] = exist
+	}
+	for _, p := range b {
+		if _, present := set[string(p)]; !present {
+			return false
+		}
+	}
+	return true
+}
+
 //ReadContext read a Context from a binary file on FS (that was encoded using network.Marshal)
 func ReadContext(path string) (*Context, error) {
 	if msg, err := read(path); err != nil {
@@ -55,7 +73,12 @@ func ReadClient(path string) (*Client, error) {
 	}
 }
 
+// the points and scalars of the messages are carried encoded (see EncodePoint), hence the suite used to unmarshal doesn't matter
 func read(path string) (interface{}, error) {
+	suite, err := SuiteByName(DefaultSuiteName)
+	if err != nil {
+		return nil, err
+	}
 	if bytes, err := ioutil.ReadFile(path); err != nil {
 		return nil, err
 	} else {
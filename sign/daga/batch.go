@@ -0,0 +1,167 @@
+package daga
+
+// This file contains the batch verification of authentication messages and server messages, for a server that needs to
+// verify bursts of requests. the verification equations of all the proofs of the batch are combined in a random linear
+// combination, checked with a single multi-scalar multiplication (see msm.go), the items are verified one by one only
+// if the combined check fails, to pinpoint the invalid ones. the results are the same as the ones of the per-item
+// verifications.
+//
+// the Schnorr signatures (of the challenges and of the servers) are still verified one by one.
+
+import (
+	"crypto/cipher"
+
+	"go.dedis.ch/kyber"
+)
+
+// VerifyAuthenticationMessages verifies a batch of authentication messages (well formed AND valid/accepted proof),
+// returns the errors of the messages, nil for the valid ones
+func VerifyAuthenticationMessages(suite Suite, msgs []AuthenticationMessage) []error {
+	errs := make([]error, len(msgs))
+	scalars := make([][]kyber.Scalar, len(msgs))
+	points := make([][]kyber.Point, len(msgs))
+	workers := getClientProofWorkers()
+	parallelFor(workers, len(msgs), func(m int) error {
+		msg := msgs[m]
+		compact := msg.P0.IsCompact()
+		if err := ExpandClientProof(suite, &msg); err != nil {
+			errs[m] = newError(nil, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			return nil
+		}
+		if err := validateAuthenticationMessage(suite, msg); err != nil {
+			errs[m] = newError(nil, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			return nil
+		}
+		if compact {
+			// nothing to batch, the verification equations hold by construction
+			if err := verifyCompactClientProof(suite, msg.C, msg.P0); err != nil {
+				errs[m] = newError(nil, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			}
+			return nil
+		}
+		if err := verifyClientProofTranscript(suite, msg.C, msg.P0); err != nil {
+			errs[m] = newError(nil, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			return nil
+		}
+		if err := verifySubChallenges(suite, msg.P0.Cs.Cs, msg.P0.C); err != nil {
+			errs[m] = newError(ErrInvalidClientProof, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			return nil
+		}
+		scalars[m], points[m] = clientOrProofCombinedTerms(suite, msg.C, msg.initialTagAndCommitments, msg.P0.T, msg.P0.C, msg.P0.R)
+		return nil
+	})
+
+	if batchCheck(suite, workers, scalars, points) {
+		return errs
+	}
+	// pinpoint the invalid proofs
+	parallelFor(workers, len(msgs), func(m int) error {
+		if scalars[m] != nil {
+			if err := verifyAuthenticationMessage(suite, msgs[m]); err != nil {
+				errs[m] = newError(nil, err, "VerifyAuthenticationMessages: message %d: %s", m, err)
+			}
+		}
+		return nil
+	})
+	return errs
+}
+
+// VerifyServerMessages verifies a batch of server messages (see VerifyServerMessage),
+// returns the reports and the errors of the messages, the error of a message is non nil only if it is malformed and
+// cannot be verified at all
+func VerifyServerMessages(suite Suite, context AuthenticationContext, msgs []ServerMessage) ([]*VerificationReport, []error) {
+	reports := make([]*VerificationReport, len(msgs))
+	errs := make([]error, len(msgs))
+	prepared := make([]ServerMessage, len(msgs))
+	copy(prepared, msgs)
+	scalars := make([][]kyber.Scalar, len(msgs))
+	points := make([][]kyber.Point, len(msgs))
+	batched := make([][]bool, len(msgs))
+	workers := getClientProofWorkers()
+	parallelFor(workers, len(msgs), func(m int) error {
+		msg := &prepared[m]
+		if errs[m] = prepareServerMessage(suite, context, msg); errs[m] != nil {
+			return nil
+		}
+		batched[m] = make([]bool, len(msg.Proofs))
+		rand := verifierRandomStream()
+		for i, p := range msg.Proofs {
+			// the (rare) misbehaving proofs are verified one by one
+			if p.R2 == nil {
+				continue
+			}
+			if s, P, ok := serverProofCombinedTerms(suite, context, i, msg, rand); ok {
+				scalars[m] = append(scalars[m], s...)
+				points[m] = append(points[m], P...)
+				batched[m][i] = true
+			}
+		}
+		return nil
+	})
+
+	valid := batchCheck(suite, workers, scalars, points)
+	parallelFor(workers, len(msgs), func(m int) error {
+		if errs[m] != nil {
+			return nil
+		}
+		verifyProof := func(suite Suite, context AuthenticationContext, i int, msg *ServerMessage) bool {
+			return (valid && batched[m][i]) || verifyServerProof(suite, context, i, msg)
+		}
+		reports[m], _, errs[m] = verifyServerMessageWith(suite, context, prepared[m], 0, verifyProof)
+		return nil
+	})
+	return reports, errs
+}
+
+// returns whether the sum of all the terms is the identity
+func batchCheck(suite Suite, workers int, scalars [][]kyber.Scalar, points [][]kyber.Point) bool {
+	var allScalars []kyber.Scalar
+	var allPoints []kyber.Point
+	for m := range scalars {
+		allScalars = append(allScalars, scalars[m]...)
+		allPoints = append(allPoints, points[m]...)
+	}
+	return parallelMultiScalarMul(suite, workers, allScalars, allPoints).Equal(suite.Point().Null())
+}
+
+// returns the terms of a random linear combination of the verification equations of the (non misbehaving) proof i
+// of msg, using the commitments sent with the proof:
+//
+//	T1 == r1*Tprevious - r2*T,  T2 == r1*G + c*Rj,  T3 == r2*Sj-1 + c*Sj
+//
+// and whether the challenge of the proof is the hash of these commitments (see serverProofChallenge).
+// if so and if the equations hold the proof is accepted by verifyServerProof, (the converse is true for the proofs
+// generated by generateServerProof, the other ones are verified by verifyServerProof when the batch fails)
+func serverProofCombinedTerms(suite Suite, context AuthenticationContext, i int, msg *ServerMessage, rand cipher.Stream) ([]kyber.Scalar, []kyber.Point, bool) {
+	p := msg.Proofs[i]
+	index := msg.Indexes[i]
+	R := context.ServersSecretsCommitments()[index]
+	Tprevious := msg.Request.T0
+	if i > 0 {
+		Tprevious = msg.Tags[i-1]
+	}
+	Sj, SjPrevious := msg.Request.SCommits[index+2], msg.Request.SCommits[index+1]
+	c, err := serverProofChallenge(suite, ContextEncoding(context), Tprevious, msg.Tags[i], R, Sj, SjPrevious, p.T1, p.T2, p.T3)
+	if err != nil || !c.Equal(p.C) {
+		return nil, nil, false
+	}
+
+	rho := [3]kyber.Scalar{suite.Scalar().Pick(rand), suite.Scalar().Pick(rand), suite.Scalar().Pick(rand)}
+	scalars := []kyber.Scalar{
+		suite.Scalar().Mul(rho[0], p.R1),
+		suite.Scalar().Neg(suite.Scalar().Mul(rho[0], p.R2)),
+		suite.Scalar().Neg(rho[0]),
+		suite.Scalar().Mul(rho[1], p.R1),
+		suite.Scalar().Mul(rho[1], p.C),
+		suite.Scalar().Neg(rho[1]),
+		suite.Scalar().Mul(rho[2], p.R2),
+		suite.Scalar().Mul(rho[2], p.C),
+		suite.Scalar().Neg(rho[2]),
+	}
+	points := []kyber.Point{
+		Tprevious, msg.Tags[i], p.T1,
+		nil, R, p.T2,
+		SjPrevious, Sj, p.T3,
+	}
+	return scalars, points, true
+}
@@ -0,0 +1,107 @@
+package daga
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestVerifyAuthenticationMessages(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 4, 2)
+			require.NoError(t, err)
+			msgs := make([]AuthenticationMessage, 0, 2*len(clients))
+			for i := 0; i < 2; i++ {
+				for _, client := range clients {
+					authMsg, err := NewAuthenticationMessage(suite, context, client, func(pkClientCommitments []kyber.Point) (Challenge, error) {
+						return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+					})
+					require.NoError(t, err)
+					msgs = append(msgs, *authMsg)
+				}
+			}
+			msgs[1].P0 = msgs[1].P0.Compact()
+
+			//Normal execution
+			for _, err := range VerifyAuthenticationMessages(suite, msgs) {
+				require.NoError(t, err)
+			}
+			require.Empty(t, VerifyAuthenticationMessages(suite, nil))
+
+			// invalid proof, invalid compact proof and malformed message
+			msgs[2].P0.R = append([]kyber.Scalar{}, msgs[2].P0.R...)
+			msgs[2].P0.R[0] = suite.Scalar().Pick(suite.RandomStream())
+			msgs[1].P0.R = append([]kyber.Scalar{}, msgs[1].P0.R...)
+			msgs[1].P0.R[3] = suite.Scalar().Pick(suite.RandomStream())
+			msgs[5].T0 = nil
+			errs := VerifyAuthenticationMessages(suite, msgs)
+			require.Len(t, errs, len(msgs))
+			for m, err := range errs {
+				switch m {
+				case 1, 2:
+					require.True(t, errors.Is(err, ErrInvalidClientProof), "message %d: wrong error: %v", m, err)
+				case 5:
+					require.True(t, errors.Is(err, ErrMalformedMessage), "message %d: wrong error: %v", m, err)
+				default:
+					require.NoError(t, err, "message %d", m)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyServerMessages(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 3, 3)
+			require.NoError(t, err)
+			msgs := make([]ServerMessage, 0, len(clients))
+			for _, client := range clients {
+				authMsg, err := NewAuthenticationMessage(suite, context, client, func(pkClientCommitments []kyber.Point) (Challenge, error) {
+					return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+				})
+				require.NoError(t, err)
+				servMsg, err := InitializeServerMessage(authMsg)
+				require.NoError(t, err)
+				for _, server := range servers {
+					require.NoError(t, ServerProtocol(suite, servMsg, server))
+				}
+				msgs = append(msgs, *servMsg)
+			}
+
+			//Normal execution
+			reports, errs := VerifyServerMessages(suite, context, msgs)
+			for m := range msgs {
+				require.NoError(t, errs[m])
+				require.True(t, reports[m].Valid(), "message %d: %s", m, reports[m])
+			}
+
+			// same reports as the per-item verification
+			msgs[0].Tags = append([]kyber.Point{}, msgs[0].Tags...)
+			msgs[0].Tags[1] = suite.Point().Pick(suite.RandomStream())
+			msgs[2].Proofs = append([]ServerProof{}, msgs[2].Proofs...)
+			msgs[2].Proofs[0].R1 = suite.Scalar().Pick(suite.RandomStream())
+			msgs[1].Indexes = nil
+			reports, errs = VerifyServerMessages(suite, context, msgs)
+			for m := range msgs {
+				expected, err := VerifyServerMessage(suite, context, msgs[m])
+				if m == 1 {
+					require.Error(t, err)
+					require.True(t, errors.Is(errs[m], ErrMalformedMessage), "wrong error: %v", errs[m])
+					continue
+				}
+				require.NoError(t, errs[m])
+				require.Equal(t, expected.FirstFailure, reports[m].FirstFailure, "message %d", m)
+				for i := range expected.Servers {
+					require.Equal(t, expected.Servers[i].ProofValid, reports[m].Servers[i].ProofValid, "message %d, server %d", m, i)
+					require.Equal(t, expected.Servers[i].SignatureValid, reports[m].Servers[i].SignatureValid, "message %d, server %d", m, i)
+				}
+			}
+			require.Equal(t, 1, reports[0].FirstFailure)
+			require.Equal(t, 0, reports[2].FirstFailure)
+		})
+	}
+}
@@ -1,11 +1,11 @@
 package daga
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"go.dedis.ch/kyber"
 	"go.dedis.ch/kyber/util/key"
-	"strconv"
 )
 
 // Client represents an entity (see terminology in "Syta - Identity Management Through Privacy Preserving Aut 2.3").
@@ -23,18 +23,24 @@ type Client interface {
 // minimum daga client containing nothing but what DAGA needs to work internally (and implement Client interface)
 // used only for the test suite and/or to build other more complete Clients (e.g. done in dagacothority)
 type minimumClient struct {
-	key   key.Pair
-	index int
+	public  kyber.Point
+	private *Secret
+	index   int
 }
 
 // PublicKey returns the public key of the client/server
 func (c minimumClient) PublicKey() kyber.Point {
-	return c.key.Public
+	return c.public
 }
 
-// PrivateKey returns the private key of the client/server
+// PrivateKey returns the private key of the client/server, nil once released
 func (c minimumClient) PrivateKey() kyber.Scalar {
-	return c.key.Private
+	return c.private.Scalar()
+}
+
+// Release zeroes the private key of the client/server, to be called when the client is no longer used
+func (c minimumClient) Release() {
+	c.private.Release()
 }
 
 // Index returns the client's (or server's) index in auth. context
@@ -50,7 +56,8 @@ func (c minimumClient) Index() int {
 //}
 
 // NewClient returns a Client that holds a newly allocated minimumClient initialized with index i and secret key s (if provided)
-// if not provided a new key is picked at random
+// if not provided a new key is picked at random.
+// the client takes ownership of s, which is zeroed when the client is released (see ReleaseSecrets)
 func NewClient(suite Suite, i int, s kyber.Scalar) (Client, error) {
 	if i < 0 {
 		return nil, errors.New("invalid parameters, negative index")
@@ -66,16 +73,17 @@ func NewClient(suite Suite, i int, s kyber.Scalar) (Client, error) {
 		// FIXME .. or trust user to not shoot itself in the foot
 		// FIXME .. or don't care and see later when usage/contract/etc of edwards25519 clearly defined
 		//  and issue regarding key generator fixed (https://go.dedis.ch/kyber/issues/351)
+		//  (not an issue with the prime order suites, NewSuiteRistretto and NewSuiteSchnorr, where any non-zero scalar is a proper secret)
 		kp = &key.Pair{
 			Private: s, // <- could (e.g. edwards25519) be attacked if not in proper form
 			Public:  suite.Point().Mul(s, nil),
 		}
 	}
 
-	// FIXME use something similar to https://github.com/awnumar/memguard to protect the secret key
 	return minimumClient{
-		index: i,
-		key:   *kp,
+		index:   i,
+		public:  kp.Public,
+		private: NewSecret(kp.Private),
 	}, nil
 }
 
@@ -107,32 +115,25 @@ func NewAuthenticationMessage(suite Suite, context AuthenticationContext,
 	client Client,
 	sendCommitsReceiveChallenge PKclientVerifier) (*AuthenticationMessage, error) {
 
-	if len(context.ClientsGenerators()) <= client.Index() || ValidateContext(context) != nil {
-		return nil, errors.New("context not valid, or wrong client index")
+	// DAGA client Steps 1, 2, 3 and first move of Step 4 (see client_precompute.go)
+	precomputation, err := Precompute(suite, context, client)
+	if err != nil {
+		return nil, err
 	}
 
-	// DAGA client Steps 1, 2, 3:
-	members := context.Members()
-	TAndS, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[client.Index()])
-
 	// DAGA client Step 4: sigma protocol / interactive proof of knowledge PKclient, with one random server (abstracted by sendCommitsReceiveChallenge)
-	if P, err := newClientProof(suite, context, client, *TAndS, s, sendCommitsReceiveChallenge); err != nil {
-		return nil, err
-	} else {
-		// DAGA client Step 5
-		M0 := AuthenticationMessage{
-			C:                        context,
-			initialTagAndCommitments: *TAndS,
-			P0:                       P,
-		}
-		return &M0, nil
-	}
+	// and Step 5
+	return precomputation.Authenticate(client, sendCommitsReceiveChallenge)
 }
 
 // validateAuthenticationMessage is an utility function to validate that a client message is correctly formed
+// and that all its elements (and those of its context) are valid, see validation.go
 func validateAuthenticationMessage(suite Suite, msg AuthenticationMessage) error {
 	if msg.C == nil {
-		return errors.New("validateAuthenticationMessage: nil context")
+		return newError(ErrInvalidContext, nil, "validateAuthenticationMessage: nil context")
+	}
+	if err := ValidateContextElements(suite, msg.C); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
 	}
 	members := msg.C.Members()
 	//Number of clients
@@ -141,18 +142,41 @@ func validateAuthenticationMessage(suite Suite, msg AuthenticationMessage) error
 	j := len(members.Y)
 	//A commitment for each server exists and the second element is the generator S=(Z,g,S1,..,Sj)
 	if len(msg.SCommits) != j+2 {
-		return fmt.Errorf("validateAuthenticationMessage: wrong number of commitments in sCommits (%d), expected: %d", len(msg.SCommits), j+2)
+		return newError(ErrMalformedMessage, nil, "validateAuthenticationMessage: wrong number of commitments in sCommits (%d), expected: %d", len(msg.SCommits), j+2)
 	}
 	if !msg.SCommits[1].Equal(suite.Point().Base()) {
-		return errors.New("validateAuthenticationMessage: second group element in sCommits is not the group generator")
+		return newError(ErrMalformedMessage, nil, "validateAuthenticationMessage: second group element in sCommits is not the group generator")
 	}
 	//T0 not empty
 	if msg.T0 == nil {
-		return errors.New("validateAuthenticationMessage: initial tag T0 is nil")
+		return newError(ErrMalformedMessage, nil, "validateAuthenticationMessage: initial tag T0 is nil")
 	}
 	//Proof fields have the correct size
 	if len(msg.P0.C) != i || len(msg.P0.R) != 2*i || len(msg.P0.T) != 3*i || msg.P0.Cs.Cs == nil {
-		return fmt.Errorf("validateAuthenticationMessage: malformed ClientProof, %v", msg.P0)
+		return newError(ErrMalformedMessage, nil, "validateAuthenticationMessage: malformed ClientProof, %v", msg.P0)
+	}
+	//All the elements are valid, Z is not the identity (otherwise the shared secrets are known to everyone),
+	//the other commitments and T0 can be the identity (misbehaving client, detected by the servers)
+	if err := validatePoint(suite, "Z", msg.SCommits[0], false); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := validatePoints(suite, "SCommits", msg.SCommits[2:], true); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := validatePoint(suite, "T0", msg.T0, true); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := validatePoints(suite, "P0.T", msg.P0.T, true); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := validateScalars(suite, "P0.C", msg.P0.C); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := validateScalars(suite, "P0.R", msg.P0.R); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
+	}
+	if err := ValidateChallenge(suite, msg.C, msg.P0.Cs); err != nil {
+		return newError(nil, err, "validateAuthenticationMessage: %s", err)
 	}
 	return nil
 }
@@ -161,11 +185,27 @@ func validateAuthenticationMessage(suite Suite, msg AuthenticationMessage) error
 //
 // msg the authenticationMessage to verify
 func verifyAuthenticationMessage(suite Suite, msg AuthenticationMessage) error {
-	if err := validateAuthenticationMessage(suite, msg); err != nil {
-		return errors.New("verifyAuthenticationMessage:" + err.Error())
+	return verifyAndExpandAuthenticationMessage(suite, &msg)
+}
+
+// verifies msg (see verifyAuthenticationMessage), if its proof is compact the commitments are recomputed and stored in msg
+// (see ExpandClientProof) and only what doesn't hold by construction is verified (see verifyCompactClientProof)
+func verifyAndExpandAuthenticationMessage(suite Suite, msg *AuthenticationMessage) error {
+	compact := msg.P0.IsCompact()
+	if err := ExpandClientProof(suite, msg); err != nil {
+		return newError(nil, err, "verifyAuthenticationMessage:%s", err)
+	}
+	if err := validateAuthenticationMessage(suite, *msg); err != nil {
+		return newError(nil, err, "verifyAuthenticationMessage:%s", err)
+	}
+	var err error
+	if compact {
+		err = verifyCompactClientProof(suite, msg.C, msg.P0)
+	} else {
+		err = verifyClientProof(suite, msg.C, msg.P0, msg.initialTagAndCommitments)
 	}
-	if err := verifyClientProof(suite, msg.C, msg.P0, msg.initialTagAndCommitments); err != nil {
-		return errors.New("verifyAuthenticationMessage:" + err.Error())
+	if err != nil {
+		return newError(nil, err, "verifyAuthenticationMessage:%s", err)
 	}
 	return nil
 }
@@ -189,11 +229,13 @@ type initialTagAndCommitments struct {
 // and an opening s (product of all secrets that client shares with the servers) of Sm (that is needed later to build client's proof PKclient)
 // (i.e. performs client protocols Steps 1,2 and 3)
 //
+// version the encoding of the AuthenticationContext (see ContextEncoding)
+//
 // serverKeys the public keys of the servers (of a particular AuthenticationContext)
 //
 // clientGenerator the client's per-round generator
 //
-func newInitialTagAndCommitments(suite Suite, serverKeys []kyber.Point, clientGenerator kyber.Point) (*initialTagAndCommitments, kyber.Scalar) {
+func newInitialTagAndCommitments(suite Suite, version EncodingVersion, serverKeys []kyber.Point, clientGenerator kyber.Point) (*initialTagAndCommitments, kyber.Scalar, error) {
 
 	// QUESTION here assert that client generator is indeed a generator of the prime order group ?
 	//  (should not be needed if we are only concerned with DH on curve25519
@@ -214,17 +256,24 @@ func newInitialTagAndCommitments(suite Suite, serverKeys []kyber.Point, clientGe
 
 	//DAGA client Step 1: generate ephemeral DH key pair
 	ephemeralKey := key.NewKeyPair(suite)
-	z := ephemeralKey.Private // FIXME how to securely erase it ? => maybe use https://github.com/awnumar/memguard !!
-	Z := ephemeralKey.Public
+	zSecret := NewSecret(ephemeralKey.Private)
+	defer zSecret.Release()
+	return initialTagAndCommitmentsOf(suite, version, zSecret.Scalar(), serverKeys, clientGenerator)
+}
+
+// performs client protocols Steps 2 and 3 (see newInitialTagAndCommitments) for the ephemeral DH private key z
+func initialTagAndCommitmentsOf(suite Suite, version EncodingVersion, z kyber.Scalar, serverKeys []kyber.Point, clientGenerator kyber.Point) (*initialTagAndCommitments, kyber.Scalar, error) {
+	Z := suite.Point().Mul(z, nil)
 
 	//DAGA client Step 2: generate shared secret exponents with the servers
 	sharedSecrets := make([]kyber.Scalar, 0, len(serverKeys))
 	for _, serverKey := range serverKeys {
 		// shared secret = suite.Hash(DH(z, Y))
-		hasher := suite.Hash()
-		suite.Point().Mul(z, serverKey).MarshalTo(hasher)
-		hash := hasher.Sum(nil)
-		sharedSecret := suite.Scalar().SetBytes(hash)
+		// (z is secret, constant-time multiplication, not through the tables of fixed_base.go)
+		sharedSecret, err := sharedSecretOf(suite, version, suite.Point().Mul(z, serverKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("newInitialTagAndCommitments: %s", err)
+		}
 		// QUESTION: do we need to mask the resulting bits (sharedSecret) to avoid small subgroup attacks (e.g. Lim and Lee + pollard kangaroo) ?
 		//  (if we consider them effective..but then becomes tied to edwards25519 group..=> need to use suite.NewKey with a stream built from the hash)
 		// 	e.g. if clientGenerator is not a generator (is not in (cyclic)subgroup of prime order generated by base) and has order 8,
@@ -257,22 +306,44 @@ func newInitialTagAndCommitments(suite Suite, serverKeys []kyber.Point, clientGe
 		exp.Mul(exp, sharedSecret)
 	}
 	T0 := suite.Point().Mul(exp, clientGenerator)
+	zeroScalar(exp)
 
 	//	Computes the commitments to the shared secrets, S=(Z, S0, S1, .., Sm) // TODO merge with previous loop
 	S := make([]kyber.Point, 0, len(serverKeys)+2)
 	S = append(S, Z, suite.Point().Base()) // append Z, S0=g
 	exp = sharedSecrets[0]                 // s1
 	for _, sharedSecret := range sharedSecrets[1:] {  // s2..sm
-		S = append(S, suite.Point().Mul(exp, nil))  // append S1..Sm-1
+		S = append(S, suite.Point().Mul(exp, nil))    // append S1..Sm-1
 		exp.Mul(exp, sharedSecret)
 	}
-	S = append(S, suite.Point().Mul(exp, nil))  // append Sm
+	S = append(S, suite.Point().Mul(exp, nil))    // append Sm
 	s := exp
+	// s (=sharedSecrets[0]) is returned, the other shared secrets were only needed to compute it
+	for _, sharedSecret := range sharedSecrets[1:] {
+		zeroScalar(sharedSecret)
+	}
 
 	return &initialTagAndCommitments{
 		T0:       T0,
 		SCommits: S,
-	}, s
+	}, s, nil
+}
+
+// returns the secret shared by the owners of the key pairs (private, .) and (., public), i.e. Hash1(public^private)
+// (see 4.3.5 client's protocols step 2 and 4.3.6 server's protocol step 2)
+func newSharedSecret(suite Suite, version EncodingVersion, private kyber.Scalar, public kyber.Point) (*Secret, error) {
+	s, err := sharedSecretOf(suite, version, suite.Point().Mul(private, public))
+	if err != nil {
+		return nil, err
+	}
+	return NewSecret(s), nil
+}
+
+// returns the shared secret Hash1(DH) corresponding to the Diffie-Hellman point DH
+func sharedSecretOf(suite Suite, version EncodingVersion, DH kyber.Point) (kyber.Scalar, error) {
+	return newTranscript(version, transcriptSharedSecret).
+		appendPoint("DH", DH).
+		hashToScalar(suite, HashSharedSecret)
 }
 
 // GetFinalLinkageTag checks the server's signatures and proofs
@@ -284,42 +355,49 @@ func GetFinalLinkageTag(suite Suite, context AuthenticationContext, msg ServerMe
 
 	//Input checks
 	if context == nil || len(msg.Tags) == 0 || len(msg.Tags) != len(msg.Proofs) || len(msg.Proofs) != len(msg.Sigs) || len(msg.Sigs) != len(msg.Indexes) {
-		return nil, errors.New("invalid inputs")
+		return nil, newError(ErrMalformedMessage, nil, "invalid inputs")
+	}
+	if err := validateServerMessage(suite, msg); err != nil {
+		return nil, newError(nil, err, "invalid inputs: %s", err)
+	}
+	// the servers signed the full proof of the request
+	if err := ExpandClientProof(suite, &msg.Request); err != nil {
+		return nil, newError(nil, err, "invalid inputs: %s", err)
+	}
+	if err := validateAuthenticationMessage(suite, msg.Request); err != nil {
+		return nil, newError(nil, err, "invalid inputs: %s", err)
 	}
 
-	data, e := msg.Request.ToBytes()
-	if e != nil {
-		return nil, fmt.Errorf("error in request: %s", e)
+	// verify the signatures and proofs of all the servers, report the culprit(s) if some did not verify
+	report, err := VerifyServerMessage(suite, context, msg)
+	if err != nil {
+		return nil, err
 	}
-	members := context.Members()
-	for i, p := range msg.Proofs {
-		//verify signatures
-		temp, err := msg.Tags[i].MarshalBinary()
-		if err != nil {
-			return nil, fmt.Errorf("error in tags: %s", err)
-		}
-		data = append(data, temp...)
-		temp, err = p.ToBytes()
-		if err != nil {
-			return nil, fmt.Errorf("error in proofs: %s", err)
-		}
-		data = append(data, temp...)
-		data = append(data, []byte(strconv.Itoa(msg.Indexes[i]))...)
-		err = SchnorrVerify(suite, members.Y[msg.Sigs[i].Index], data, msg.Sigs[i].Sig)
-		if err != nil {
-			return nil, fmt.Errorf("error in signature: %d\n%s", i, err)
-		}
-		//verify proofs
-		var valid bool
-		if p.R2 == nil {
-			valid = verifyMisbehavingProof(suite, members.Y[i], &p, msg.Request.SCommits[0])
-			// TODO and then if valid, what ??...
-		} else {
-			valid = verifyServerProof(suite, context, i, &msg)
-		}
-		if !valid {
-			return nil, errors.New("invalid server proof")
-		}
+	if !report.Valid() {
+		return nil, &BlameError{Report: report}
 	}
 	return msg.Tags[len(msg.Tags)-1], nil
 }
+
+// CheckFinalLinkageTag returns ErrMisbehavingClient if Tf is the identity, that is the final linkage tag
+// the servers give to a client they flagged as misbehaving (see ServerProtocol)
+func CheckFinalLinkageTag(suite Suite, Tf kyber.Point) error {
+	if Tf == nil {
+		return newError(ErrMalformedMessage, nil, "CheckFinalLinkageTag: nil final linkage tag")
+	}
+	if Tf.Equal(suite.Point().Null()) {
+		return ErrMisbehavingClient
+	}
+	return nil
+}
+
+// CheckSession returns ErrSessionMismatch if the authentication message msg is not bound to the session of a relying
+// party whose binding is session (see Challenge.Session), nil session for the messages that are not bound.
+// the binding is authentic only once the message is verified (e.g. by the servers, see ServerProtocol, or by
+// GetFinalLinkageTag), its challenge being signed by the servers along with the binding
+func CheckSession(msg AuthenticationMessage, session []byte) error {
+	if !bytes.Equal(msg.P0.Cs.Session, session) {
+		return ErrSessionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package daga
+
+// This file contains the implementation of the client's OR-proof PKclient, producing the same transcript as the
+// reference implementation built using the kyber.Proof framework (see client_proof_kyber_test.go),
+// without the reflection and the string-keyed maps of the framework. the per-member work (commitments and responses
+// of each clause) is split across goroutines and the verification equations are checked with a multi-scalar
+// multiplication (see msm.go).
+//
+// PKclient is the OR over all the clients k of the AND of the 3 predicates:
+//	Xk = xk*G,  Sm = s*G,  T0 = s*Hk
+// for each clause k the transcript holds 3 commitments T[3k..3k+2], a sub-challenge C[k] and 2 responses R[2k..2k+1].
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"go.dedis.ch/kyber"
+)
+
+// number of goroutines used to generate and verify the client proofs, see SetClientProofWorkers
+var clientProofWorkers = int32(runtime.NumCPU())
+
+// SetClientProofWorkers sets the number of goroutines among which the per-member work of the generation and
+// verification of the client proofs is split (1 or less means sequential), defaults to runtime.NumCPU().
+// the proofs are the same whatever the number of workers.
+func SetClientProofWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&clientProofWorkers, int32(n))
+}
+
+func getClientProofWorkers() int {
+	return int(atomic.LoadInt32(&clientProofWorkers))
+}
+
+// runs f(k) for all k in [0, n), split in contiguous chunks among workers goroutines,
+// returns the error of the smallest k for which f failed (in its chunk), hence the same error as the sequential run
+func parallelFor(workers, n int, f func(k int) error) error {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for k := 0; k < n; k++ {
+			if err := f(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	errs := make([]error, workers)
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > n {
+			hi = n
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			for k := lo; k < hi; k++ {
+				if err := f(k); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w, lo, hi)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the positions in R of the responses of clause k for the secrets xk and s,
+// the kyber.Proof framework sends the responses of a clause in the order of the first appearance of the secrets in the
+// whole OR-predicate: x0, s, x1, x2, ... => (x0, s) for the first clause and (s, xk) for the others
+func clientProofResponseIndexes(k int) (x, s int) {
+	if k == 0 {
+		return 0, 1
+	}
+	return 2*k + 1, 2 * k
+}
+
+// state of the prover of PKclient between the commitments and the responses
+type clientOrProver struct {
+	suite  Suite
+	j      int          // index of the prover, its clause is the true one
+	x      kyber.Scalar // the private key of the prover (owned by its Client)
+	s      *Secret      // the other secret of the prover
+	vx, vs *Secret      // the blindings of the true clause
+	C      []kyber.Scalar
+	R      []kyber.Scalar
+}
+
+// returns a new prover for PKclient and its commitments, all the randomness is drawn (sequentially) from rand,
+// hence the proof only depends on rand (and not on the number of workers).
+// the prover doesn't take ownership of s, its secrets are released by release
+//
+// for the true clause j the commitments are (vx*G, vs*G, vs*Hj) and for the others (simulated) clauses k,
+// with random sub-challenge ck and responses rx, rs, (ck*Xk + rx*G, ck*Sm + rs*G, ck*T0 + rs*Hk)
+func newClientOrProver(suite Suite, context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	client Client, s kyber.Scalar, rand cipher.Stream) (*clientOrProver, []kyber.Point, error) {
+	members := context.Members()
+	n := len(members.X)
+	j := client.Index()
+	if j < 0 || j >= n {
+		return nil, nil, fmt.Errorf("newClientOrProver: client index %d out of range", j)
+	}
+	p := &clientOrProver{
+		suite: suite,
+		j:     j,
+		x:     client.PrivateKey(),
+		s:     NewSecret(suite.Scalar().Set(s)),
+		C:     make([]kyber.Scalar, n),
+		R:     make([]kyber.Scalar, 2*n),
+	}
+	for k := 0; k < n; k++ {
+		ix, is := clientProofResponseIndexes(k)
+		if k == j {
+			p.vx = NewSecret(suite.Scalar().Pick(rand))
+			p.vs = NewSecret(suite.Scalar().Pick(rand))
+		} else {
+			p.C[k] = suite.Scalar().Pick(rand)
+			p.R[ix] = suite.Scalar().Pick(rand)
+			p.R[is] = suite.Scalar().Pick(rand)
+		}
+	}
+
+	Sm := repeatedMul(suite, tagAndCommitments.SCommits[len(tagAndCommitments.SCommits)-1], n)
+	T0 := repeatedMul(suite, tagAndCommitments.T0, n)
+	H := context.ClientsGenerators()
+	T := make([]kyber.Point, 3*n)
+	parallelFor(getClientProofWorkers(), n, func(k int) error {
+		if k == j {
+			// vx and vs are secret (rx = vx - c*x), constant-time multiplications, not through the tables of fixed_base.go
+			T[3*k] = suite.Point().Mul(p.vx.Scalar(), nil)
+			T[3*k+1] = suite.Point().Mul(p.vs.Scalar(), nil)
+			T[3*k+2] = suite.Point().Mul(p.vs.Scalar(), H[k])
+			return nil
+		}
+		ix, is := clientProofResponseIndexes(k)
+		T[3*k], T[3*k+1], T[3*k+2] = clauseCommitments(suite, members.X[k], Sm, T0, H[k], p.C[k], p.R[ix], p.R[is])
+		return nil
+	})
+	return p, T, nil
+}
+
+// returns the commitments of a clause of PKclient determined by its sub-challenge c and responses rx, rs:
+// (c*Xk + rx*G, c*Sm + rs*G, c*T0 + rs*Hk), G, Xk and Hk are points of the context (see fixed_base.go)
+// and Sm, T0 the same for all the clauses (see repeatedMul), c, rx and rs must be public (the ones of the verified
+// proofs or of the simulated clauses, that are part of the proof)
+func clauseCommitments(suite Suite, Xk kyber.Point, Sm, T0 func(kyber.Scalar) kyber.Point, Hk kyber.Point,
+	c, rx, rs kyber.Scalar) (kyber.Point, kyber.Point, kyber.Point) {
+	T1 := fixedBaseMul(suite, c, Xk)
+	T1.Add(T1, fixedBaseMul(suite, rx, nil))
+	T2 := Sm(c)
+	T2.Add(T2, fixedBaseMul(suite, rs, nil))
+	T3 := T0(c)
+	T3.Add(T3, fixedBaseMul(suite, rs, Hk))
+	return T1, T2, T3
+}
+
+// recomputes the commitments T of PKclient from the sub-challenges and the responses (see ClientProof.Compact),
+// C and R must have the right lengths
+func recomputeClientOrProofCommitments(suite Suite, context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	C, R []kyber.Scalar) []kyber.Point {
+	members := context.Members()
+	n := len(members.X)
+	Sm := repeatedMul(suite, tagAndCommitments.SCommits[len(tagAndCommitments.SCommits)-1], n)
+	T0 := repeatedMul(suite, tagAndCommitments.T0, n)
+	H := context.ClientsGenerators()
+	T := make([]kyber.Point, 3*n)
+	parallelFor(getClientProofWorkers(), n, func(k int) error {
+		ix, is := clientProofResponseIndexes(k)
+		T[3*k], T[3*k+1], T[3*k+2] = clauseCommitments(suite, members.X[k], Sm, T0, H[k], C[k], R[ix], R[is])
+		return nil
+	})
+	return T
+}
+
+// returns the sub-challenges and responses of PKclient for the master challenge,
+// the sub-challenge of the true clause is the master challenge minus the sum of the others
+// and its responses are rx = vx - cj*x, rs = vs - cj*s
+func (p *clientOrProver) respond(challenge kyber.Scalar) ([]kyber.Scalar, []kyber.Scalar) {
+	cj := p.suite.Scalar().Set(challenge)
+	for k, c := range p.C {
+		if k != p.j {
+			cj.Sub(cj, c)
+		}
+	}
+	p.C[p.j] = cj
+	ix, is := clientProofResponseIndexes(p.j)
+	tmp := p.suite.Scalar()
+	defer zeroScalar(tmp)
+	p.R[ix] = p.suite.Scalar().Sub(p.vx.Scalar(), tmp.Mul(cj, p.x))
+	p.R[is] = p.suite.Scalar().Sub(p.vs.Scalar(), tmp.Mul(cj, p.s.Scalar()))
+	return p.C, p.R
+}
+
+// zeroes the secrets of the prover, it can't respond anymore
+func (p *clientOrProver) release() {
+	p.s.Release()
+	p.vx.Release()
+	p.vs.Release()
+}
+
+// verifies the OR-proof PKclient: the sub-challenges sum to the master challenge and for each clause k
+//
+//	T[3k] == ck*Xk + rx*G,  T[3k+1] == ck*Sm + rs*G,  T[3k+2] == ck*T0 + rs*Hk
+//
+// the 3n equations are checked at once, see clientOrProofCombinedCheck, and one by one only if the combined check fails,
+// to report the failing clause.
+func verifyClientOrProof(suite Suite, context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	challenge kyber.Scalar, T []kyber.Point, C, R []kyber.Scalar) error {
+	if context == nil {
+		return errors.New("verifyClientOrProof: nil context")
+	}
+	members := context.Members()
+	n := len(members.X)
+	if len(T) != 3*n || len(C) != n || len(R) != 2*n {
+		return errors.New("verifyClientOrProof: wrong number of commitments, sub-challenges or responses")
+	}
+	if err := checkClientOrProofInputs(context, tagAndCommitments, challenge, T, C, R); err != nil {
+		return errors.New("verifyClientOrProof: " + err.Error())
+	}
+
+	if err := verifySubChallenges(suite, challenge, C); err != nil {
+		return errors.New("verifyClientOrProof: " + err.Error())
+	}
+
+	if clientOrProofCombinedCheck(suite, context, tagAndCommitments, T, C, R) {
+		return nil
+	}
+
+	Sm := repeatedMul(suite, tagAndCommitments.SCommits[len(tagAndCommitments.SCommits)-1], n)
+	T0 := repeatedMul(suite, tagAndCommitments.T0, n)
+	H := context.ClientsGenerators()
+	err := parallelFor(getClientProofWorkers(), n, func(k int) error {
+		ix, is := clientProofResponseIndexes(k)
+		T1, T2, T3 := clauseCommitments(suite, members.X[k], Sm, T0, H[k], C[k], R[ix], R[is])
+		if !T[3*k].Equal(T1) || !T[3*k+1].Equal(T2) || !T[3*k+2].Equal(T3) {
+			return fmt.Errorf("verifyClientOrProof: invalid proof, clause %d not verified", k)
+		}
+		return nil
+	})
+	if err == nil {
+		// the combined check failed, do not accept the proof even if it happens (with negligible probability) that
+		// all the equations hold
+		err = errors.New("verifyClientOrProof: invalid proof")
+	}
+	return err
+}
+
+// returns an error if one of the inputs of verifyClientOrProof is missing (wrong lengths, nil elements),
+// the elements themselves are validated beforehand by validateAuthenticationMessage
+func checkClientOrProofInputs(context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	challenge kyber.Scalar, T []kyber.Point, C, R []kyber.Scalar) error {
+	members := context.Members()
+	if len(context.ClientsGenerators()) != len(members.X) {
+		return errors.New("wrong number of client generators")
+	}
+	if len(tagAndCommitments.SCommits) == 0 || isNil(tagAndCommitments.SCommits[len(tagAndCommitments.SCommits)-1]) ||
+		isNil(tagAndCommitments.T0) || isNil(challenge) {
+		return errors.New("missing commitment, initial tag or challenge")
+	}
+	for _, points := range [][]kyber.Point{members.X, context.ClientsGenerators(), T} {
+		for _, P := range points {
+			if isNil(P) {
+				return errors.New("nil point")
+			}
+		}
+	}
+	for _, scalars := range [][]kyber.Scalar{C, R} {
+		for _, s := range scalars {
+			if isNil(s) {
+				return errors.New("nil scalar")
+			}
+		}
+	}
+	return nil
+}
+
+// checks that the sub-challenges C sum to the master challenge
+func verifySubChallenges(suite Suite, challenge kyber.Scalar, C []kyber.Scalar) error {
+	sum := suite.Scalar().Zero()
+	for _, c := range C {
+		sum.Add(sum, c)
+	}
+	if !sum.Equal(challenge) {
+		return errors.New("invalid challenge, sub-challenges don't sum to the master challenge")
+	}
+	return nil
+}
+
+// checks a random linear combination of the 3n verification equations of PKclient with a single multi-scalar
+// multiplication, for random ρ:
+//
+//	Σk ρ3k(ck*Xk + rx*G - T3k) + ρ3k+1(ck*Sm + rs*G - T3k+1) + ρ3k+2(ck*T0 + rs*Hk - T3k+2) == 0
+//
+// if one of the equations doesn't hold, the combination is 0 with negligible probability (1/group order),
+// provided all the points are elements of the prime order group (see validateAuthenticationMessage)
+func clientOrProofCombinedCheck(suite Suite, context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	T []kyber.Point, C, R []kyber.Scalar) bool {
+	scalars, points := clientOrProofCombinedTerms(suite, context, tagAndCommitments, T, C, R)
+	return parallelMultiScalarMul(suite, getClientProofWorkers(), scalars, points).Equal(suite.Point().Null())
+}
+
+// returns the 5n+3 terms of the random linear combination of the verification equations of PKclient
+// (see clientOrProofCombinedCheck), the terms of several proofs can be summed to verify them at once (see batch.go)
+func clientOrProofCombinedTerms(suite Suite, context AuthenticationContext, tagAndCommitments initialTagAndCommitments,
+	T []kyber.Point, C, R []kyber.Scalar) ([]kyber.Scalar, []kyber.Point) {
+	members := context.Members()
+	n := len(members.X)
+	H := context.ClientsGenerators()
+	rand := verifierRandomStream()
+
+	// terms: G, Sm, T0, then Xk and Hk for all k, then all the T
+	scalars := make([]kyber.Scalar, 3+2*n+3*n)
+	points := make([]kyber.Point, 3+2*n+3*n)
+	gCoeff, smCoeff, t0Coeff := suite.Scalar().Zero(), suite.Scalar().Zero(), suite.Scalar().Zero()
+	tmp := suite.Scalar()
+	for k := 0; k < n; k++ {
+		ix, is := clientProofResponseIndexes(k)
+		c, rx, rs := C[k], R[ix], R[is]
+		rho := [3]kyber.Scalar{suite.Scalar().Pick(rand), suite.Scalar().Pick(rand), suite.Scalar().Pick(rand)}
+
+		gCoeff.Add(gCoeff, tmp.Mul(rho[0], rx))
+		gCoeff.Add(gCoeff, tmp.Mul(rho[1], rs))
+		smCoeff.Add(smCoeff, tmp.Mul(rho[1], c))
+		t0Coeff.Add(t0Coeff, tmp.Mul(rho[2], c))
+		scalars[3+2*k], points[3+2*k] = suite.Scalar().Mul(rho[0], c), members.X[k]
+		scalars[3+2*k+1], points[3+2*k+1] = suite.Scalar().Mul(rho[2], rs), H[k]
+		for e := 0; e < 3; e++ {
+			scalars[3+2*n+3*k+e], points[3+2*n+3*k+e] = rho[e].Neg(rho[e]), T[3*k+e]
+		}
+	}
+	scalars[0], points[0] = gCoeff, nil
+	scalars[1], points[1] = smCoeff, tagAndCommitments.SCommits[len(tagAndCommitments.SCommits)-1]
+	scalars[2], points[2] = t0Coeff, tagAndCommitments.T0
+	return scalars, points
+}
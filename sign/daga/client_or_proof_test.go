@@ -0,0 +1,149 @@
+package daga
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/xof/blake2xb"
+)
+
+func TestClientOrProof_Workers(t *testing.T) {
+	defer SetClientProofWorkers(runtime.NumCPU())
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, _, context, err := GenerateTestContext(suite, 7, 2)
+			require.NoError(t, err)
+			members := context.Members()
+			client := clients[3]
+			tagAndCommitments, s, err := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[client.Index()])
+			require.NoError(t, err)
+			challenge := suite.Scalar().Pick(suite.RandomStream())
+
+			prove := func(workers int) ([]kyber.Point, []kyber.Scalar, []kyber.Scalar) {
+				SetClientProofWorkers(workers)
+				prover, T, err := newClientOrProver(suite, context, *tagAndCommitments, client, s, blake2xb.New([]byte("seed")))
+				require.NoError(t, err)
+				C, R := prover.respond(challenge)
+				require.NoError(t, verifyClientOrProof(suite, context, *tagAndCommitments, challenge, T, C, R), "workers: %d", workers)
+				return T, C, R
+			}
+			T, C, R := prove(1)
+			for _, workers := range []int{2, 3, 16} {
+				parallelT, parallelC, parallelR := prove(workers)
+				for i := range T {
+					require.True(t, T[i].Equal(parallelT[i]), "workers: %d, different commitment %d", workers, i)
+				}
+				for i := range C {
+					require.True(t, C[i].Equal(parallelC[i]), "workers: %d, different sub-challenge %d", workers, i)
+				}
+				for i := range R {
+					require.True(t, R[i].Equal(parallelR[i]), "workers: %d, different response %d", workers, i)
+				}
+			}
+
+			//Invalid proofs
+			for _, workers := range []int{1, 4} {
+				SetClientProofWorkers(workers)
+				tamperedR := append([]kyber.Scalar{}, R...)
+				tamperedR[len(R)-1] = suite.Scalar().Pick(suite.RandomStream())
+				require.Error(t, verifyClientOrProof(suite, context, *tagAndCommitments, challenge, T, C, tamperedR))
+				require.Error(t, verifyClientOrProof(suite, context, *tagAndCommitments, suite.Scalar().One(), T, C, R))
+				require.Error(t, verifyClientOrProof(suite, context, *tagAndCommitments, challenge, T[1:], C, R))
+			}
+		})
+	}
+}
+
+// the proofs are accepted by the kyber.Proof reference implementation (see client_proof_kyber_test.go) and vice versa
+func TestClientOrProof_Kyber(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 4, 2)
+			require.NoError(t, err)
+			members := context.Members()
+			sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			}
+			// first clause is special in the kyber.Proof framework (order of the responses)
+			for _, client := range []Client{clients[0], clients[2]} {
+				tagAndCommitments, s, err := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[client.Index()])
+				require.NoError(t, err)
+
+				proof, err := newClientProof(suite, context, client, *tagAndCommitments, s, sendCommitsReceiveChallenge)
+				require.NoError(t, err)
+				require.NoError(t, verifyClientProofKyber(suite, context, proof, *tagAndCommitments), "proof of client %d not accepted by kyber.Proof", client.Index())
+
+				kyberProof, err := newClientProofKyber(suite, context, client, *tagAndCommitments, s, sendCommitsReceiveChallenge)
+				require.NoError(t, err)
+				require.NoError(t, verifyClientProof(suite, context, kyberProof, *tagAndCommitments), "kyber.Proof proof of client %d not accepted", client.Index())
+			}
+		})
+	}
+}
+
+func TestClientProof_Compact(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 5, 3)
+			require.NoError(t, err)
+			authMsg, err := NewAuthenticationMessage(suite, context, clients[2], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+
+			compact := *authMsg
+			compact.P0 = authMsg.P0.Compact()
+			require.True(t, compact.P0.IsCompact())
+			require.False(t, authMsg.P0.IsCompact())
+			require.NoError(t, verifyAuthenticationMessage(suite, compact))
+
+			// the recomputed commitments are the ones of the prover
+			expanded := compact
+			require.NoError(t, ExpandClientProof(suite, &expanded))
+			require.Len(t, expanded.P0.T, len(authMsg.P0.T))
+			for i := range authMsg.P0.T {
+				require.True(t, expanded.P0.T[i].Equal(authMsg.P0.T[i]), "different commitment %d", i)
+			}
+
+			// servers accept the compact request and sign the full proof
+			servMsg, err := InitializeServerMessage(&compact)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, ServerProtocol(suite, servMsg, server))
+				servMsg.Request.P0 = servMsg.Request.P0.Compact()
+			}
+			Tf, err := GetFinalLinkageTag(suite, context, *servMsg)
+			require.NoError(t, err)
+			servMsg.Request = *authMsg
+			fullTf, err := GetFinalLinkageTag(suite, context, *servMsg)
+			require.NoError(t, err)
+			require.True(t, Tf.Equal(fullTf))
+
+			//Invalid proofs
+			tampered := compact
+			tampered.P0.R = append([]kyber.Scalar{}, compact.P0.R...)
+			tampered.P0.R[0] = suite.Scalar().Pick(suite.RandomStream())
+			require.True(t, errors.Is(verifyAuthenticationMessage(suite, tampered), ErrInvalidClientProof))
+
+			// sub-challenges still summing to the master challenge
+			tampered = compact
+			tampered.P0.C = append([]kyber.Scalar{}, compact.P0.C...)
+			delta := suite.Scalar().Pick(suite.RandomStream())
+			tampered.P0.C[0] = suite.Scalar().Add(tampered.P0.C[0], delta)
+			tampered.P0.C[1] = suite.Scalar().Sub(tampered.P0.C[1], delta)
+			require.True(t, errors.Is(verifyAuthenticationMessage(suite, tampered), ErrInvalidClientProof))
+
+			// sub-challenges not summing to the master challenge
+			tampered.P0.C[1] = compact.P0.C[1]
+			require.True(t, errors.Is(verifyAuthenticationMessage(suite, tampered), ErrInvalidClientProof))
+
+			tampered = compact
+			tampered.P0.R = compact.P0.R[1:]
+			require.True(t, errors.Is(verifyAuthenticationMessage(suite, tampered), ErrMalformedMessage))
+			require.True(t, errors.Is(ExpandClientProof(suite, &tampered), ErrMalformedMessage))
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package daga
+
+// This file contains the offline/online split of the client's protocol, Precompute does all the work that doesn't
+// depend on the servers' challenge (Steps 1 to 3 and the commitments of PKclient), ahead of time, and
+// Precomputation.Authenticate completes the authentication message with the servers (Steps 4 and 5), which only
+// costs the verification of the signatures of the challenge and a few scalar multiplications.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.dedis.ch/kyber"
+)
+
+// Precomputation holds the work of a client done before an authentication (see Precompute),
+// it can be kept in memory or persisted (see MarshalBinary and UnmarshalPrecomputation) until the client authenticates.
+//
+// a precomputation must be used for at most one authentication, the responses of the proof to two different challenges
+// reveal the client's private key. Authenticate refuses to run twice but discarding the persisted copies is the job of
+// the caller. the secrets of the precomputation are released after the authentication, or by Release if it is not used.
+type Precomputation struct {
+	suite             Suite
+	context           AuthenticationContext
+	tagAndCommitments initialTagAndCommitments
+	prover            *clientOrProver
+	commitments       []kyber.Point
+
+	mutex sync.Mutex
+	used  bool
+}
+
+// Precompute performs the part of the daga client's protocol that doesn't depend on the servers' challenge,
+// for an authentication of `client` under `context` (see NewAuthenticationMessage):
+//   - build initial tags and commitments
+//   - draw the blindings, sub-challenges and responses of PKclient and compute its commitments
+func Precompute(suite Suite, context AuthenticationContext, client Client) (*Precomputation, error) {
+	if context == nil || len(context.ClientsGenerators()) <= client.Index() || ValidateContext(context) != nil {
+		return nil, newError(ErrInvalidContext, nil, "context not valid, or wrong client index")
+	}
+	members := context.Members()
+	if len(members.X) <= 1 {
+		return nil, newError(ErrInvalidContext, nil, "Precompute: there is only one client in the context, this means DAGA is pointless")
+	}
+
+	// DAGA client Steps 1, 2, 3:
+	TAndS, s, err := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[client.Index()])
+	if err != nil {
+		return nil, err
+	}
+
+	// DAGA client Step 4, first move of PKclient (see client_or_proof.go)
+	prover, commits, err := newClientOrProver(suite, context, *TAndS, client, s, suite.RandomStream())
+	zeroScalar(s) // copied by the prover
+	if err != nil {
+		return nil, errors.New("Precompute: " + err.Error())
+	}
+	// not kept, provided again to Authenticate
+	prover.x = nil
+	return &Precomputation{
+		suite:             suite,
+		context:           context,
+		tagAndCommitments: *TAndS,
+		prover:            prover,
+		commitments:       commits,
+	}, nil
+}
+
+// Context returns the context of the authentication
+func (pc *Precomputation) Context() AuthenticationContext {
+	return pc.context
+}
+
+// Authenticate completes the precomputed authentication of client, it performs the remaining of the daga client's
+// protocol:
+//   - PKclient proof of knowledge with a PKclientVerifier (`sendCommitsReceiveChallenge` abstraction of remote proof verifiers)
+//   - assemble everything
+//
+// the precomputation is used once the proof is completed, if the challenge can't be obtained it can be used again
+func (pc *Precomputation) Authenticate(client Client, sendCommitsReceiveChallenge PKclientVerifier) (*AuthenticationMessage, error) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.used {
+		return nil, errors.New("Authenticate: precomputation already used")
+	}
+	if client.Index() != pc.prover.j {
+		return nil, fmt.Errorf("Authenticate: precomputation of client %d used by client %d", pc.prover.j, client.Index())
+	}
+
+	prover := *pc.prover
+	prover.x = client.PrivateKey()
+	P, err := completeClientProof(pc.suite, pc.context, &prover, pc.commitments, sendCommitsReceiveChallenge)
+	if err != nil {
+		return nil, err
+	}
+	pc.used = true
+	pc.prover.release()
+
+	// DAGA client Step 5
+	return &AuthenticationMessage{
+		C:                        pc.context,
+		initialTagAndCommitments: pc.tagAndCommitments,
+		P0:                       P,
+	}, nil
+}
+
+// Release zeroes the secrets of an unused precomputation (that will not be used), the precomputation can't be used
+// nor marshalled afterwards
+func (pc *Precomputation) Release() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.used = true
+	pc.prover.release()
+}
+
+// MarshalBinary encodes the precomputation (including the secrets of the client's proof, but not its private key),
+// to be read back using UnmarshalPrecomputation, fails if the precomputation was used
+func (pc *Precomputation) MarshalBinary() ([]byte, error) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.used {
+		return nil, errors.New("MarshalBinary: precomputation already used")
+	}
+	digest, err := contextDigest(pc.suite, pc.context)
+	if err != nil {
+		return nil, errors.New("MarshalBinary: " + err.Error())
+	}
+	// the sub-challenge and responses of the true clause are only known after the challenge, encoded as 0
+	p := pc.prover
+	C := append([]kyber.Scalar{}, p.C...)
+	R := append([]kyber.Scalar{}, p.R...)
+	ix, is := clientProofResponseIndexes(p.j)
+	C[p.j], R[ix], R[is] = pc.suite.Scalar().Zero(), pc.suite.Scalar().Zero(), pc.suite.Scalar().Zero()
+
+	data, err := newTranscript(EncodingV1, transcriptPrecomputation).
+		appendBytes("context", digest).
+		appendInt("index", p.j).
+		appendPoints("SCommits", pc.tagAndCommitments.SCommits).
+		appendPoint("T0", pc.tagAndCommitments.T0).
+		appendScalar("s", p.s.Scalar()).
+		appendScalar("vx", p.vx.Scalar()).
+		appendScalar("vs", p.vs.Scalar()).
+		appendScalars("C", C).
+		appendScalars("R", R).
+		appendPoints("T", pc.commitments).
+		Bytes()
+	if err != nil {
+		return nil, errors.New("MarshalBinary: " + err.Error())
+	}
+	return data, nil
+}
+
+// UnmarshalPrecomputation decodes a precomputation encoded using MarshalBinary, for an authentication under context
+func UnmarshalPrecomputation(suite Suite, context AuthenticationContext, data []byte) (*Precomputation, error) {
+	if context == nil {
+		return nil, newError(ErrInvalidContext, nil, "UnmarshalPrecomputation: nil context")
+	}
+	digest, err := contextDigest(suite, context)
+	if err != nil {
+		return nil, newError(ErrInvalidContext, err, "UnmarshalPrecomputation: %s", err)
+	}
+
+	r := newTranscriptReader(data, transcriptPrecomputation)
+	encodedDigest := r.readBytes("context")
+	j := r.readInt("index")
+	SCommits := r.readPoints(suite, "SCommits")
+	T0 := r.readPoint(suite, "T0")
+	s := r.readScalar(suite, "s")
+	vx := r.readScalar(suite, "vx")
+	vs := r.readScalar(suite, "vs")
+	C := r.readScalars(suite, "C")
+	R := r.readScalars(suite, "R")
+	T := r.readPoints(suite, "T")
+	if err := r.Done(); err != nil {
+		return nil, newError(ErrMalformedMessage, err, "UnmarshalPrecomputation: %s", err)
+	}
+
+	if string(encodedDigest) != string(digest) {
+		return nil, newError(ErrInvalidContext, nil, "UnmarshalPrecomputation: precomputation of another context")
+	}
+	n := len(context.Members().X)
+	if j < 0 || j >= n || len(SCommits) != len(context.Members().Y)+2 || len(C) != n || len(R) != 2*n || len(T) != 3*n {
+		return nil, newError(ErrMalformedMessage, nil, "UnmarshalPrecomputation: malformed precomputation")
+	}
+	// the sub-challenge and responses of the true clause are computed by respond
+	ix, is := clientProofResponseIndexes(j)
+	C[j], R[ix], R[is] = nil, nil, nil
+	return &Precomputation{
+		suite:   suite,
+		context: context,
+		tagAndCommitments: initialTagAndCommitments{
+			SCommits: SCommits,
+			T0:       T0,
+		},
+		prover: &clientOrProver{
+			suite: suite,
+			j:     j,
+			s:     NewSecret(s),
+			vx:    NewSecret(vx),
+			vs:    NewSecret(vs),
+			C:     C,
+			R:     R,
+		},
+		commitments: T,
+	}, nil
+}
+
+// returns the digest of the context, binding a persisted precomputation to its context
+func contextDigest(suite Suite, context AuthenticationContext) ([]byte, error) {
+	data, err := AuthenticationContextToBytes(context)
+	if err != nil {
+		return nil, err
+	}
+	h := suite.Hash()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
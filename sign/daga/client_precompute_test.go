@@ -0,0 +1,97 @@
+package daga
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestPrecompute(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+			sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			}
+
+			precomputation, err := Precompute(suite, context, clients[1])
+			require.NoError(t, err)
+			_, err = precomputation.Authenticate(clients[2], sendCommitsReceiveChallenge)
+			require.Error(t, err, "precomputation of another client accepted")
+
+			// not used if the challenge cannot be obtained
+			_, err = precomputation.Authenticate(clients[1], func([]kyber.Point) (Challenge, error) {
+				return Challenge{}, errors.New("network error")
+			})
+			require.Error(t, err)
+
+			//Normal execution
+			authMsg, err := precomputation.Authenticate(clients[1], sendCommitsReceiveChallenge)
+			require.NoError(t, err)
+			require.NoError(t, verifyAuthenticationMessage(suite, *authMsg))
+
+			// used only once
+			_, err = precomputation.Authenticate(clients[1], sendCommitsReceiveChallenge)
+			require.Error(t, err)
+			_, err = precomputation.MarshalBinary()
+			require.Error(t, err)
+
+			// invalid inputs
+			_, err = Precompute(suite, nil, clients[1])
+			require.True(t, errors.Is(err, ErrInvalidContext))
+			outsider, err := NewClient(suite, 3, nil)
+			require.NoError(t, err)
+			_, err = Precompute(suite, context, outsider)
+			require.True(t, errors.Is(err, ErrInvalidContext))
+		})
+	}
+}
+
+func TestPrecomputation_Marshal(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+			sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			}
+
+			for _, client := range clients {
+				precomputation, err := Precompute(suite, context, client)
+				require.NoError(t, err)
+				data, err := precomputation.MarshalBinary()
+				require.NoError(t, err)
+
+				decoded, err := UnmarshalPrecomputation(suite, context, data)
+				require.NoError(t, err)
+				authMsg, err := decoded.Authenticate(client, sendCommitsReceiveChallenge)
+				require.NoError(t, err, "client %d", client.Index())
+				require.NoError(t, verifyAuthenticationMessage(suite, *authMsg), "client %d", client.Index())
+				for i := range authMsg.P0.T {
+					require.True(t, authMsg.P0.T[i].Equal(precomputation.commitments[i]))
+				}
+
+				// malformed data
+				_, err = UnmarshalPrecomputation(suite, context, data[:len(data)-1])
+				require.True(t, errors.Is(err, ErrMalformedMessage), "wrong error: %v", err)
+				_, err = UnmarshalPrecomputation(suite, context, append(data, 0))
+				require.True(t, errors.Is(err, ErrMalformedMessage), "wrong error: %v", err)
+				_, err = UnmarshalPrecomputation(suite, context, nil)
+				require.True(t, errors.Is(err, ErrMalformedMessage), "wrong error: %v", err)
+			}
+
+			// precomputation of another context
+			_, _, otherContext, err := GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+			precomputation, err := Precompute(suite, context, clients[0])
+			require.NoError(t, err)
+			data, err := precomputation.MarshalBinary()
+			require.NoError(t, err)
+			_, err = UnmarshalPrecomputation(suite, otherContext, data)
+			require.True(t, errors.Is(err, ErrInvalidContext), "wrong error: %v", err)
+		})
+	}
+}
@@ -34,7 +34,7 @@ func TestNewInitialTagAndCommitments(t *testing.T) {
 
 	// normal execution
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	T0, S := tagAndCommitments.T0, tagAndCommitments.SCommits
 	require.NotNil(t, T0, "T0 nil")
 	require.NotNil(t, S, "sCommits nil")
@@ -49,14 +49,14 @@ func TestNewInitialTagAndCommitments(t *testing.T) {
 // test helper that returns a properly signed Challenge by signing cs||pkClientCommitments using the keys of the servers
 func signDummyChallenge(cs kyber.Scalar, servers []Server, pkClientCommitments []kyber.Point) (Challenge, error) {
 	challenge := Challenge{Cs: cs}
-	signData, err := challenge.ToBytes(pkClientCommitments)
+	signData, err := challenge.ToBytes(CurrentEncoding, pkClientCommitments)
 	if err != nil {
 		return Challenge{}, err
 	}
 	var sigs []ServerSignature
 	//Make each test server sign the challenge
 	for _, server := range servers {
-		if sig, err := SchnorrSign(suite, server.PrivateKey(), signData); err != nil {
+		if sig, err := DefaultSignatureScheme(suite).Sign(suite, server.PrivateKey(), signData); err != nil {
 			return Challenge{}, err
 		} else {
 			sigs = append(sigs, ServerSignature{Index: server.Index(), Sig: sig})
@@ -75,6 +75,30 @@ func newDummyServerChannels(cs kyber.Scalar, servers []Server) PKclientVerifier
 	return sendCommitsReceiveChallenge
 }
 
+// test helper that returns a "channel" running the challenge generation of the servers under context upon reception of
+// the prover's commitments, update is called on the challenge before the servers check and sign it (to set its session, ID etc.)
+func newChallengeGenerationChannels(t *testing.T, context AuthenticationContext, servers []Server, update func(*ChallengeCheck)) PKclientVerifier {
+	sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		commits := make([]ChallengeCommitment, len(servers))
+		openings := make([]kyber.Scalar, len(servers))
+		for i, server := range servers {
+			commit, opening, err := NewChallengeCommitment(suite, context, server)
+			require.NoError(t, err)
+			commits[i], openings[i] = *commit, opening
+		}
+		challengeCheck, err := InitializeChallenge(suite, context, commits, openings)
+		require.NoError(t, err)
+		update(challengeCheck)
+		for _, server := range servers {
+			require.NoError(t, CheckUpdateChallenge(suite, context, challengeCheck, pkClientCommitments, server))
+		}
+		// back to the leader
+		require.NoError(t, CheckUpdateChallenge(suite, context, challengeCheck, pkClientCommitments, servers[0]))
+		return FinalizeChallenge(context, challengeCheck)
+	}
+	return sendCommitsReceiveChallenge
+}
+
 // test helper that returns bad dummy "channel" to act as a dummy server/verifier
 // that performs send a challenge containing evilCs and evilSigs no matter the commitments received
 func newBadServerChannels(evilCs kyber.Scalar, evilSigs []ServerSignature) func([]kyber.Point) (Challenge, error) {
@@ -96,7 +120,7 @@ func TestNewClientProof(t *testing.T) {
 
 	// normal execution, create client proof
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	proof, err := newClientProof(suite, context, clients[0], *tagAndCommitments, s, sendCommitsReceiveChallenge)
 	require.NoError(t, err, "newClientProof returned an error on valid inputs")
@@ -186,7 +210,7 @@ func newMaliciousClientProof(suite Suite, context AuthenticationContext,
 		return ClientProof{}, errors.New("newMaliciousClientProof:" + err.Error())
 	}
 
-	if err := challenge.VerifySignatures(suite, members.Y, randomPointSlice); err != nil {
+	if err := challenge.VerifySignatures(suite, context, randomPointSlice); err != nil {
 		// FIXME kill prover goroutine
 		return ClientProof{}, errors.New("newMaliciousClientProof:" + err.Error())
 	}
@@ -241,7 +265,7 @@ func TestVerifyClientProof(t *testing.T) {
 
 	// create valid proof and auth. message
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[proverIndex].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[proverIndex].Index()])
 	proof, _ := newClientProof(suite, context, clients[proverIndex], *tagAndCommitments, s, sendCommitsReceiveChallenge)
 
 	clientMsg := AuthenticationMessage{
@@ -336,7 +360,7 @@ func TestGetFinalLinkageTag(t *testing.T) {
 	// TODO add mutliple different scenarios
 	clients, servers, context, _ = GenerateTestContext(suite, rand.Intn(10)+2, 1)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	// 1 server, bad tagAndCommitments, invalid proof => reject proof => cannot get (even try to get) final tag
 	S := tagAndCommitments.SCommits
 
@@ -403,7 +427,7 @@ func TestGetFinalLinkageTag(t *testing.T) {
 	clients, servers, context, _ = GenerateTestContext(suite, rand.Intn(10)+2, rand.Intn(10)+2)
 	//Assemble the client message
 	members = context.Members()
-	tagAndCommitments, s = newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ = newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	S = tagAndCommitments.SCommits
 	S[2] = suite.Point().Null()
 	sendCommitsReceiveChallenge = newDummyServerChannels(cs, servers)
@@ -523,11 +547,108 @@ func TestToBytes_ClientProof(t *testing.T) {
 
 	//Create test client proof
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	proof, _ := newClientProof(suite, context, clients[0], *tagAndCommitments, s, sendCommitsReceiveChallenge)
 
 	//Normal execution
-	data, err := proof.ToBytes()
+	data, err := proof.ToBytes(ContextEncoding(context))
 	require.NoError(t, err, "Cannot convert valid proof to bytes")
 	require.NotNil(t, data, "Data is empty for a correct proof")
 }
+
+// authentication whose challenge is bound to the session of a relying party
+func TestCheckSession(t *testing.T) {
+	clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+	require.NoError(t, err)
+	session := []byte("relying party session binding")
+
+	var commitments []kyber.Point
+	generateChallenge := newChallengeGenerationChannels(t, context, servers, func(challengeCheck *ChallengeCheck) {
+		challengeCheck.Session = session
+	})
+	sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		commitments = pkClientCommitments
+		return generateChallenge(pkClientCommitments)
+	}
+	authMsg, err := NewAuthenticationMessage(suite, context, clients[0], sendCommitsReceiveChallenge)
+	require.NoError(t, err)
+	require.Equal(t, session, authMsg.P0.Cs.Session)
+	require.NoError(t, verifyAuthenticationMessage(suite, *authMsg))
+	require.NoError(t, CheckSession(*authMsg, session))
+	require.True(t, errors.Is(CheckSession(*authMsg, []byte("other session")), ErrSessionMismatch))
+	require.True(t, errors.Is(CheckSession(*authMsg, nil), ErrSessionMismatch))
+
+	// the binding is covered by the signatures of the challenge and by the encoding of the proof
+	bound, err := authMsg.P0.Cs.ToBytes(ContextEncoding(context), commitments)
+	require.NoError(t, err)
+	unbound := authMsg.P0
+	unbound.Cs.Session = nil
+	data, err := unbound.Cs.ToBytes(ContextEncoding(context), commitments)
+	require.NoError(t, err)
+	require.NotEqual(t, bound, data)
+	boundProof, err := authMsg.P0.ToBytes(ContextEncoding(context))
+	require.NoError(t, err)
+	unboundProof, err := unbound.ToBytes(ContextEncoding(context))
+	require.NoError(t, err)
+	require.NotEqual(t, boundProof, unboundProof)
+
+	// the binding can't be removed or replaced
+	for _, other := range [][]byte{nil, []byte("other session")} {
+		relayed := *authMsg
+		relayed.P0.Cs.Session = other
+		require.Error(t, verifyAuthenticationMessage(suite, relayed))
+		servMsg, err := InitializeServerMessage(&relayed)
+		require.NoError(t, err)
+		require.Error(t, ServerProtocol(suite, servMsg, servers[0]))
+	}
+
+	servMsg, err := InitializeServerMessage(authMsg)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, ServerProtocol(suite, servMsg, server))
+	}
+	_, err = GetFinalLinkageTag(suite, context, *servMsg)
+	require.NoError(t, err)
+
+	tooLong := authMsg.P0.Cs
+	tooLong.Session = make([]byte, MaxSessionSize+1)
+	require.Error(t, ValidateChallenge(suite, context, tooLong))
+}
+
+func TestChallengeIDAndExpiry(t *testing.T) {
+	clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+	require.NoError(t, err)
+	id := []byte("challenge session")
+	expiry := int64(1234567890)
+
+	sendCommitsReceiveChallenge := newChallengeGenerationChannels(t, context, servers, func(challengeCheck *ChallengeCheck) {
+		challengeCheck.ID, challengeCheck.Expiry = id, expiry
+	})
+	authMsg, err := NewAuthenticationMessage(suite, context, clients[0], sendCommitsReceiveChallenge)
+	require.NoError(t, err)
+	require.Equal(t, id, authMsg.P0.Cs.ID)
+	require.Equal(t, expiry, authMsg.P0.Cs.Expiry)
+	require.NoError(t, verifyAuthenticationMessage(suite, *authMsg))
+
+	// the identifier and the expiry can't be removed or replaced
+	for _, tamper := range []func(*Challenge){
+		func(c *Challenge) { c.ID = nil },
+		func(c *Challenge) { c.ID = []byte("other session") },
+		func(c *Challenge) { c.Expiry = 0 },
+		func(c *Challenge) { c.Expiry = expiry + 60 },
+	} {
+		replayed := *authMsg
+		tamper(&replayed.P0.Cs)
+		require.Error(t, verifyAuthenticationMessage(suite, replayed))
+		servMsg, err := InitializeServerMessage(&replayed)
+		require.NoError(t, err)
+		require.Error(t, ServerProtocol(suite, servMsg, servers[0]))
+	}
+
+	invalid := authMsg.P0.Cs
+	invalid.ID = make([]byte, MaxChallengeIDSize+1)
+	require.Error(t, ValidateChallenge(suite, context, invalid))
+	invalid = authMsg.P0.Cs
+	invalid.Expiry = -1
+	require.Error(t, ValidateChallenge(suite, context, invalid))
+}
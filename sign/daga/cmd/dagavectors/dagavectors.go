@@ -0,0 +1,81 @@
+// dagavectors generates the known-answer test vectors of DAGA (see daga.TestVector),
+// checked by the conformance runner of the daga package (TestTestVectors) against the committed testdata/vectors.json,
+// regenerate it from sign/daga with: go run ./cmd/dagavectors
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dedis/student_18_daga/sign/daga"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// the (number of clients, number of servers, index of the authenticating client) of the generated vectors,
+// for each suite
+var parameters = [][3]int{
+	{2, 1, 0},
+	{2, 1, 1}, // first clause is special in the kyber.Proof framework (order of the responses)
+	{5, 3, 2},
+}
+
+func main() {
+	cliApp := cli.NewApp()
+	cliApp.Usage = "generate the known-answer test vectors of DAGA"
+	cliApp.Version = "0.1"
+	cliApp.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "output, o",
+			Value: filepath.Join("testdata", "vectors.json"),
+			Usage: "the file where the vectors are written",
+		},
+		cli.StringFlag{
+			Name:  "seed",
+			Value: "daga test vectors",
+			Usage: "the seed from which the seeds of the vectors are derived",
+		},
+	}
+	cliApp.Action = generate
+	if err := cliApp.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generate(c *cli.Context) error {
+	var vectors []daga.TestVector
+	suites := []daga.Suite{daga.NewSuiteEC(), daga.NewSuiteSchnorr(), daga.NewSuiteRistretto()}
+	for _, suite := range suites {
+		for _, p := range parameters {
+			nbrClients, nbrServers, clientIndex := p[0], p[1], p[2]
+			seed := fmt.Sprintf("%s/%s/%d/%d/%d", c.String("seed"), suite.String(), nbrClients, nbrServers, clientIndex)
+			tv, err := daga.GenerateTestVector(suite, []byte(seed), nbrClients, nbrServers, clientIndex)
+			if err != nil {
+				return fmt.Errorf("failed to generate vector %s: %s", seed, err)
+			}
+			tv.Description = fmt.Sprintf("%s, %d clients, %d servers, client %d authenticates", suite.String(), nbrClients, nbrServers, clientIndex)
+			// sanity check
+			if err := daga.CheckTestVector(*tv); err != nil {
+				return fmt.Errorf("generated vector %s is not valid: %s", seed, err)
+			}
+			vectors = append(vectors, *tv)
+		}
+	}
+
+	data, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the vectors: %s", err)
+	}
+	output := c.String("output")
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(output, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write the vectors: %s", err)
+	}
+	fmt.Printf("%d vectors written to %s\n", len(vectors), output)
+	return nil
+}
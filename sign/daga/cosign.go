@@ -0,0 +1,307 @@
+package daga
+
+// This file contains the collective signatures of the servers, a CoSi style Schnorr multi-signature with MuSig key
+// aggregation: a single signature and a participation mask replace the signatures of every server.
+//
+// the participants i commit to V_i = v_i*B, the challenge c is derived from the aggregate commitment V = sum V_i, the
+// aggregate key A = sum a_i*Y_i and the message, each participant responds with s_i = v_i + c*a_i*x_i and the collective
+// signature is V || s where s = sum s_i, valid if s*B == V + c*A. the coefficients a_i = H(Y, Y_i) prevent a server from
+// choosing its key as a function of the keys of the others (rogue key attack).
+// under SignatureEd25519, c is the challenge of RFC 8032 and the collective signature is a standard Ed25519 signature
+// under the aggregate key A.
+//
+// the two rounds (commitment, response) are enough here since the keys of the servers are fresh for each context and
+// sign a single message, a server must never answer two challenges using the same commitment.
+// when the servers sign several messages under the same keys (e.g. the receipts of the authentications), possibly in
+// concurrent sessions, a single commitment per participant is not enough (ROS attacks), the participants then commit to
+// two nonces V_i1, V_i2 and use V_i = V_i1 + b*V_i2 where b binds the commitments of all the participants to the message
+// (MuSig2, see BindCosignatureCommitments), the collective signature stays unchanged.
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber"
+)
+
+// NewParticipationMask returns the participation mask of a collective signature of n servers where the servers at the
+// indexes participants participated: the bit i%8 of the byte i/8 is set if the server of index i participated
+func NewParticipationMask(n int, participants []int) ([]byte, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("NewParticipationMask: invalid number of servers: %d", n)
+	}
+	mask := make([]byte, (n+7)/8)
+	for _, i := range participants {
+		if i < 0 || i >= n {
+			return nil, fmt.Errorf("NewParticipationMask: invalid index %d", i)
+		}
+		mask[i/8] |= 1 << uint(i%8)
+	}
+	return mask, nil
+}
+
+// MaskParticipants returns the indexes, in increasing order, of the servers that participated according to mask,
+// returns an error if mask is not the participation mask of n servers or if nobody participated
+func MaskParticipants(n int, mask []byte) ([]int, error) {
+	if n <= 0 || len(mask) != (n+7)/8 {
+		return nil, newError(ErrMalformedMessage, nil, "MaskParticipants: invalid mask length")
+	}
+	var participants []int
+	for i := 0; i < 8*len(mask); i++ {
+		if mask[i/8]&(1<<uint(i%8)) != 0 {
+			if i >= n {
+				return nil, newError(ErrMalformedMessage, nil, "MaskParticipants: participation of unknown server %d", i)
+			}
+			participants = append(participants, i)
+		}
+	}
+	if len(participants) == 0 {
+		return nil, newError(ErrMalformedMessage, nil, "MaskParticipants: no participant")
+	}
+	return participants, nil
+}
+
+// returns the coefficients a_i of the keys of the servers in the aggregate keys (see AggregateKey)
+func keyAggregationCoefficients(suite Suite, keys []kyber.Point) ([]kyber.Scalar, error) {
+	allKeys, err := PointArrayToBytes(keys)
+	if err != nil {
+		return nil, err
+	}
+	coefficients := make([]kyber.Scalar, len(keys))
+	for i, Y := range keys {
+		data, err := newTranscript(EncodingV1, transcriptKeyAggregation).
+			appendBytes("Y", allKeys).
+			appendPoint("Yi", Y).
+			Bytes()
+		if err != nil {
+			return nil, err
+		}
+		coefficients[i] = suite.hashToScalar(HashKeyAggregation, data)
+	}
+	return coefficients, nil
+}
+
+// AggregateKey returns the aggregate key sum a_i*Y_i of the participants (see MaskParticipants) of a collective signature
+// of the servers whose keys are keys, the key under which the collective signature is verified
+func AggregateKey(suite Suite, keys []kyber.Point, mask []byte) (kyber.Point, error) {
+	participants, err := MaskParticipants(len(keys), mask)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePoints(suite, "keys", keys, false); err != nil {
+		return nil, err
+	}
+	coefficients, err := keyAggregationCoefficients(suite, keys)
+	if err != nil {
+		return nil, fmt.Errorf("AggregateKey: %s", err)
+	}
+	A := suite.Point().Null()
+	for _, i := range participants {
+		A.Add(A, suite.Point().Mul(coefficients[i], keys[i]))
+	}
+	return A, nil
+}
+
+// NewCosignatureCommitment returns a fresh commitment V_i = v_i*B of a server to a collective signature and its secret v_i,
+// the secret is to be used for a single response (see CosignatureResponse) and then released
+func NewCosignatureCommitment(suite Suite) (commit kyber.Point, secret kyber.Scalar) {
+	secret = suite.Scalar().Pick(suite.RandomStream())
+	return suite.Point().Mul(secret, nil), secret
+}
+
+// AggregateCommitment returns the aggregate commitment V of the participants (see MaskParticipants) of a collective
+// signature, commits[i] is the commitment of the server of index i (ignored if the server didn't participate)
+func AggregateCommitment(suite Suite, commits []kyber.Point, mask []byte) (kyber.Point, error) {
+	participants, err := MaskParticipants(len(commits), mask)
+	if err != nil {
+		return nil, err
+	}
+	V := suite.Point().Null()
+	for _, i := range participants {
+		if err := validatePoint(suite, fmt.Sprintf("commits[%d]", i), commits[i], false); err != nil {
+			return nil, err
+		}
+		V.Add(V, commits[i])
+	}
+	return V, nil
+}
+
+// BindCosignatureCommitments returns the aggregate commitment V = V1 + b*V2 of a collective signature of msg whose
+// participants committed to two nonces each, and the coefficient b = H(A, V1, V2, msg), where commit1 and commit2 are the
+// aggregates (see AggregateCommitment) of the first and second commitments of the participants and aggregateKey their
+// aggregate key A (see AggregateKey).
+// each participant then uses V_i1 + b*V_i2 as its commitment and v_i1 + b*v_i2 as its secret (see CosignatureResponse)
+func BindCosignatureCommitments(suite Suite, aggregateKey, commit1, commit2 kyber.Point, msg []byte) (kyber.Point, kyber.Scalar, error) {
+	if aggregateKey == nil || commit1 == nil || commit2 == nil {
+		return nil, nil, errors.New("BindCosignatureCommitments: nil aggregate key or commitment")
+	}
+	data, err := newTranscript(EncodingV1, transcriptCosignatureBinding).
+		appendPoint("A", aggregateKey).
+		appendPoint("V1", commit1).
+		appendPoint("V2", commit2).
+		appendBytes("msg", msg).
+		Bytes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("BindCosignatureCommitments: %s", err)
+	}
+	b := suite.hashToScalar(HashCosignatureBinding, data)
+	return suite.Point().Add(commit1, suite.Point().Mul(b, commit2)), b, nil
+}
+
+// CosignatureChallenge returns the challenge c of the collective signature of msg under scheme,
+// where commit is the aggregate commitment V (see AggregateCommitment) and aggregateKey the aggregate key A of the
+// participants (see AggregateKey)
+func CosignatureChallenge(suite Suite, scheme SignatureScheme, aggregateKey, commit kyber.Point, msg []byte) (kyber.Scalar, error) {
+	if err := scheme.check(suite); err != nil {
+		return nil, errors.New("CosignatureChallenge: " + err.Error())
+	}
+	if aggregateKey == nil || commit == nil {
+		return nil, errors.New("CosignatureChallenge: nil aggregate key or commitment")
+	}
+	if scheme == SignatureEd25519 {
+		// SHA-512(R || A || M) (RFC 8032 5.1.6)
+		R, err := commit.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("CosignatureChallenge: %s", err)
+		}
+		A, err := aggregateKey.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("CosignatureChallenge: %s", err)
+		}
+		h := sha512.New()
+		h.Write(R)
+		h.Write(A)
+		h.Write(msg)
+		return suite.Scalar().SetBytes(h.Sum(nil)), nil
+	}
+	data, err := newTranscript(EncodingV1, transcriptCosignature).
+		appendPoint("V", commit).
+		appendPoint("A", aggregateKey).
+		appendBytes("msg", msg).
+		Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("CosignatureChallenge: %s", err)
+	}
+	return suite.hashToScalar(HashCosignature, data), nil
+}
+
+// CosignatureResponse returns the response s_i = v_i + c*a_i*x_i of server to the challenge of a collective signature of
+// the servers whose keys are keys, secret is the secret of the commitment of the server (see NewCosignatureCommitment)
+func CosignatureResponse(suite Suite, keys []kyber.Point, server Server, secret, challenge kyber.Scalar) (kyber.Scalar, error) {
+	index := server.Index()
+	if index < 0 || index >= len(keys) || keys[index] == nil || !keys[index].Equal(server.PublicKey()) {
+		return nil, newError(ErrInvalidContext, nil, "CosignatureResponse: server %d is not one of the signers", index)
+	}
+	if secret == nil || challenge == nil {
+		return nil, errors.New("CosignatureResponse: nil secret or challenge")
+	}
+	if err := validatePoints(suite, "keys", keys, false); err != nil {
+		return nil, err
+	}
+	coefficients, err := keyAggregationCoefficients(suite, keys)
+	if err != nil {
+		return nil, fmt.Errorf("CosignatureResponse: %s", err)
+	}
+	ca := suite.Scalar().Mul(challenge, coefficients[index])
+	return suite.Scalar().Add(secret, suite.Scalar().Mul(ca, server.PrivateKey())), nil
+}
+
+// VerifyCosignatureResponse verifies the response of the server at index to the challenge of a collective signature
+// of the servers whose keys are keys (s_i*B == V_i + c*a_i*Y_i), commit is the commitment V_i of the server.
+// returns a SignatureError (with the index of the server) if the response is not valid
+func VerifyCosignatureResponse(suite Suite, keys []kyber.Point, index int, commit kyber.Point, challenge, response kyber.Scalar) error {
+	if index < 0 || index >= len(keys) || challenge == nil {
+		return newError(ErrMalformedMessage, nil, "VerifyCosignatureResponse: invalid inputs")
+	}
+	if err := validatePoints(suite, "keys", keys, false); err != nil {
+		return err
+	}
+	if err := validatePoint(suite, "commit", commit, false); err != nil {
+		return err
+	}
+	if err := validateScalar(suite, "response", response); err != nil {
+		return err
+	}
+	coefficients, err := keyAggregationCoefficients(suite, keys)
+	if err != nil {
+		return fmt.Errorf("VerifyCosignatureResponse: %s", err)
+	}
+	expected := suite.Point().Add(commit, suite.Point().Mul(suite.Scalar().Mul(challenge, coefficients[index]), keys[index]))
+	if !suite.Point().Mul(response, nil).Equal(expected) {
+		return newError(nil, &SignatureError{Signer: index, Err: errors.New("invalid response")},
+			"VerifyCosignatureResponse: invalid response of server %d", index)
+	}
+	return nil
+}
+
+// NewCollectiveSignature returns the collective signature V || s, where commit is the aggregate commitment V of the
+// participants (see AggregateCommitment) and s the sum of their responses (see CosignatureResponse)
+func NewCollectiveSignature(suite Suite, commit kyber.Point, responses []kyber.Scalar) ([]byte, error) {
+	if commit == nil || len(responses) == 0 {
+		return nil, errors.New("NewCollectiveSignature: no commitment or no response")
+	}
+	s := suite.Scalar().Zero()
+	for _, response := range responses {
+		if response == nil {
+			return nil, errors.New("NewCollectiveSignature: nil response")
+		}
+		s.Add(s, response)
+	}
+	V, err := commit.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("NewCollectiveSignature: %s", err)
+	}
+	S, err := s.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("NewCollectiveSignature: %s", err)
+	}
+	return append(V, S...), nil
+}
+
+// VerifyCollectiveSignature verifies that sig is a collective signature of msg under scheme by the participants (see
+// MaskParticipants) of the servers whose keys are keys, it checks sig against their aggregate key (see AggregateKey)
+func VerifyCollectiveSignature(suite Suite, scheme SignatureScheme, keys []kyber.Point, mask, msg, sig []byte) error {
+	if err := scheme.check(suite); err != nil {
+		return newError(ErrInvalidContext, nil, "VerifyCollectiveSignature: %s", err)
+	}
+	if len(msg) == 0 {
+		return errors.New("VerifyCollectiveSignature: empty message")
+	}
+	A, err := AggregateKey(suite, keys, mask)
+	if err != nil {
+		return err
+	}
+	if scheme == SignatureEd25519 {
+		if err := Ed25519Verify(suite, A, msg, sig); err != nil {
+			return newError(ErrBadSignature, err, "VerifyCollectiveSignature: %s", err)
+		}
+		return nil
+	}
+
+	pointLen := suite.PointLen()
+	if len(sig) != pointLen+suite.ScalarLen() {
+		return newError(ErrMalformedMessage, nil, "VerifyCollectiveSignature: invalid signature length")
+	}
+	V, s := suite.Point(), suite.Scalar()
+	if err := V.UnmarshalBinary(sig[:pointLen]); err != nil {
+		return newError(ErrMalformedMessage, err, "VerifyCollectiveSignature: %s", err)
+	}
+	if err := s.UnmarshalBinary(sig[pointLen:]); err != nil {
+		return newError(ErrMalformedMessage, err, "VerifyCollectiveSignature: %s", err)
+	}
+	if err := validatePoint(suite, "V", V, true); err != nil {
+		return err
+	}
+	if err := validateScalar(suite, "s", s); err != nil {
+		return err
+	}
+	c, err := CosignatureChallenge(suite, scheme, A, V, msg)
+	if err != nil {
+		return err
+	}
+	if !suite.Point().Mul(s, nil).Equal(suite.Point().Add(V, suite.Point().Mul(c, A))) {
+		return newError(ErrBadSignature, nil, "VerifyCollectiveSignature: invalid signature")
+	}
+	return nil
+}
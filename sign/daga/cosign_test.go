@@ -0,0 +1,183 @@
+package daga
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+// test helper that runs the production of the collective signature of msg by the participants of mask
+func runCosignature(t *testing.T, suite Suite, scheme SignatureScheme, servers []Server, keys []kyber.Point, mask, msg []byte) []byte {
+	participants, err := MaskParticipants(len(keys), mask)
+	require.NoError(t, err)
+	commits := make([]kyber.Point, len(keys))
+	secrets := make([]kyber.Scalar, len(keys))
+	for _, i := range participants {
+		commits[i], secrets[i] = NewCosignatureCommitment(suite)
+	}
+	V, err := AggregateCommitment(suite, commits, mask)
+	require.NoError(t, err)
+	A, err := AggregateKey(suite, keys, mask)
+	require.NoError(t, err)
+	c, err := CosignatureChallenge(suite, scheme, A, V, msg)
+	require.NoError(t, err)
+	var responses []kyber.Scalar
+	for _, i := range participants {
+		response, err := CosignatureResponse(suite, keys, servers[i], secrets[i], c)
+		require.NoError(t, err)
+		require.NoError(t, VerifyCosignatureResponse(suite, keys, i, commits[i], c, response))
+		responses = append(responses, response)
+	}
+	sig, err := NewCollectiveSignature(suite, V, responses)
+	require.NoError(t, err)
+	return sig
+}
+
+func TestParticipationMask(t *testing.T) {
+	mask, err := NewParticipationMask(10, []int{0, 3, 9})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x09, 0x02}, mask)
+	participants, err := MaskParticipants(10, mask)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 3, 9}, participants)
+
+	_, err = NewParticipationMask(10, []int{10})
+	require.Error(t, err)
+	_, err = NewParticipationMask(0, nil)
+	require.Error(t, err)
+
+	// wrong length, unknown server, nobody
+	_, err = MaskParticipants(10, mask[:1])
+	require.True(t, errors.Is(err, ErrMalformedMessage))
+	_, err = MaskParticipants(10, []byte{0x00, 0x04})
+	require.True(t, errors.Is(err, ErrMalformedMessage))
+	_, err = MaskParticipants(10, []byte{0x00, 0x00})
+	require.True(t, errors.Is(err, ErrMalformedMessage))
+}
+
+func TestCollectiveSignature(t *testing.T) {
+	msg := []byte("test message")
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, servers, context, err := GenerateTestContext(suite, 1, 5)
+			require.NoError(t, err)
+			scheme := ContextSignatureScheme(context)
+			keys := context.Members().Y
+			full, err := NewParticipationMask(len(keys), []int{0, 1, 2, 3, 4})
+			require.NoError(t, err)
+			partial, err := NewParticipationMask(len(keys), []int{1, 3})
+			require.NoError(t, err)
+
+			sig := runCosignature(t, suite, scheme, servers, keys, full, msg)
+			require.NoError(t, VerifyCollectiveSignature(suite, scheme, keys, full, msg, sig))
+			err = VerifyCollectiveSignature(suite, scheme, keys, partial, msg, sig)
+			require.True(t, errors.Is(err, ErrBadSignature), "wrong error: %s", err)
+			err = VerifyCollectiveSignature(suite, scheme, keys, full, []byte("other message"), sig)
+			require.True(t, errors.Is(err, ErrBadSignature), "wrong error: %s", err)
+
+			partialSig := runCosignature(t, suite, scheme, servers, keys, partial, msg)
+			require.NoError(t, VerifyCollectiveSignature(suite, scheme, keys, partial, msg, partialSig))
+			require.Error(t, VerifyCollectiveSignature(suite, scheme, keys, full, msg, partialSig))
+
+			// under Ed25519, a standard signature under the aggregate key
+			if scheme == SignatureEd25519 {
+				A, err := AggregateKey(suite, keys, full)
+				require.NoError(t, err)
+				require.True(t, stdlibVerify(t, A, msg, sig), "collective signature rejected by crypto/ed25519")
+				require.Len(t, sig, ed25519.SignatureSize)
+			}
+
+			tampered := append([]byte{}, sig...)
+			tampered[len(tampered)-1] ^= 0x01
+			require.Error(t, VerifyCollectiveSignature(suite, scheme, keys, full, msg, tampered))
+			require.Error(t, VerifyCollectiveSignature(suite, scheme, keys, full, msg, sig[1:]))
+		})
+	}
+}
+
+func TestVerifyCosignatureResponse(t *testing.T) {
+	_, servers, context, err := GenerateTestContext(suite, 1, 3)
+	require.NoError(t, err)
+	keys := context.Members().Y
+	commit, secret := NewCosignatureCommitment(suite)
+	c := suite.Scalar().Pick(suite.RandomStream())
+	response, err := CosignatureResponse(suite, keys, servers[1], secret, c)
+	require.NoError(t, err)
+	require.NoError(t, VerifyCosignatureResponse(suite, keys, 1, commit, c, response))
+
+	// the culprit is reported
+	err = VerifyCosignatureResponse(suite, keys, 2, commit, c, response)
+	var sigErr *SignatureError
+	require.True(t, errors.As(err, &sigErr), "wrong error: %s", err)
+	require.Equal(t, 2, sigErr.Signer)
+	require.True(t, errors.Is(err, ErrBadSignature))
+
+	require.Error(t, VerifyCosignatureResponse(suite, keys, 3, commit, c, response))
+	require.Error(t, VerifyCosignatureResponse(suite, keys, 1, nil, c, response))
+
+	// a server that is not one of the signers
+	outsider, err := NewServer(suite, 1, nil)
+	require.NoError(t, err)
+	_, err = CosignatureResponse(suite, keys, outsider, secret, c)
+	require.True(t, errors.Is(err, ErrInvalidContext), "wrong error: %s", err)
+}
+
+// collective signature whose participants commit to two nonces each
+func TestBindCosignatureCommitments(t *testing.T) {
+	msg := []byte("test message")
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, servers, context, err := GenerateTestContext(suite, 1, 4)
+			require.NoError(t, err)
+			scheme := ContextSignatureScheme(context)
+			keys := context.Members().Y
+			mask, err := NewParticipationMask(len(keys), []int{0, 1, 2, 3})
+			require.NoError(t, err)
+			A, err := AggregateKey(suite, keys, mask)
+			require.NoError(t, err)
+
+			commits1, commits2 := make([]kyber.Point, len(keys)), make([]kyber.Point, len(keys))
+			secrets1, secrets2 := make([]kyber.Scalar, len(keys)), make([]kyber.Scalar, len(keys))
+			for i := range keys {
+				commits1[i], secrets1[i] = NewCosignatureCommitment(suite)
+				commits2[i], secrets2[i] = NewCosignatureCommitment(suite)
+			}
+			V1, err := AggregateCommitment(suite, commits1, mask)
+			require.NoError(t, err)
+			V2, err := AggregateCommitment(suite, commits2, mask)
+			require.NoError(t, err)
+			V, b, err := BindCosignatureCommitments(suite, A, V1, V2, msg)
+			require.NoError(t, err)
+			c, err := CosignatureChallenge(suite, scheme, A, V, msg)
+			require.NoError(t, err)
+
+			var responses []kyber.Scalar
+			for i := range keys {
+				secret := suite.Scalar().Add(secrets1[i], suite.Scalar().Mul(b, secrets2[i]))
+				commit := suite.Point().Add(commits1[i], suite.Point().Mul(b, commits2[i]))
+				response, err := CosignatureResponse(suite, keys, servers[i], secret, c)
+				require.NoError(t, err)
+				require.NoError(t, VerifyCosignatureResponse(suite, keys, i, commit, c, response))
+				// the first commitment alone doesn't verify
+				require.Error(t, VerifyCosignatureResponse(suite, keys, i, commits1[i], c, response))
+				responses = append(responses, response)
+			}
+			sig, err := NewCollectiveSignature(suite, V, responses)
+			require.NoError(t, err)
+			require.NoError(t, VerifyCollectiveSignature(suite, scheme, keys, mask, msg, sig))
+			if scheme == SignatureEd25519 {
+				require.True(t, stdlibVerify(t, A, msg, sig), "collective signature rejected by crypto/ed25519")
+			}
+
+			// the binding depends on the message
+			_, other, err := BindCosignatureCommitments(suite, A, V1, V2, []byte("other message"))
+			require.NoError(t, err)
+			require.False(t, b.Equal(other))
+			_, _, err = BindCosignatureCommitments(suite, A, V1, nil, msg)
+			require.Error(t, err)
+		})
+	}
+}
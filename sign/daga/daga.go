@@ -21,7 +21,6 @@ import (
 	"go.dedis.ch/kyber"
 	"go.dedis.ch/kyber/sign/schnorr"
 	"go.dedis.ch/kyber/util/key"
-	"hash"
 )
 
 // Suite represents the set of functionalities needed for the DAGA package to operate
@@ -44,9 +43,39 @@ type Suite interface {
 	// TODO remove this hashfactory and defines hash1 hash2 as hash functions that should behaves like RO and that map input to scalars and field elements respectively
 	//  and / or eventually keep hashfactory for the usage that don't care and specify which hash is used.
 	//  see too the comment on SchnorSign => better to have signing related things a requirement in the suite
-	hashTwo() hash.Hash // DAGA needs another hash function to map input to field elements (i.e. Zp for the schnorr group example) (that can be of another size depending on the concrete groups used)
+	// DAGA uses its random oracle for different purposes, each use is a distinct hash function mapping input to scalars,
+	// identified by its HashLabel (i.e. hashToScalar(l1, .) and hashToScalar(l2, .) behave as independent ROs when l1 != l2)
+	hashToScalar(label HashLabel, data []byte) kyber.Scalar
+	// hash_to_curve (RFC 9380 or in the same style when the group is not covered), maps msg to a point of the group
+	// of unknown discrete log, dst is the domain separation tag, see hash_to_curve.go
+	hashToCurve(msg, dst []byte) (kyber.Point, error)
+	hashToCurveID() string // the hash-to-curve suite ID, (e.g. "edwards25519_XMD:SHA-512_ELL2_RO_")
+	// returns an error if P (a point of the group) is not an element of the subgroup of prime order used by DAGA,
+	// (e.g. has a torsion component in edwards25519), see validation.go
+	checkPoint(P kyber.Point) error
 }
 
+// HashLabel identifies one of the uses of the random oracle in DAGA, see Suite.hashToScalar
+type HashLabel string
+
+// the labels of the hash functions used in DAGA
+const (
+	// derivation of the secrets shared by the client and the servers (4.3.5 client's protocols step 2)
+	HashSharedSecret HashLabel = "shared-secret"
+	// Fiat-Shamir challenge of the server proofs (4.3.6 server's protocol step 5)
+	HashServerProof HashLabel = "server-proof"
+	// Fiat-Shamir challenge of the proofs of a misbehaving client
+	HashMisbehavingProof HashLabel = "misbehaving-proof"
+	// derivation of the master challenge from the servers' openings (4.3.5 client's protocols step 4)
+	HashChallenge HashLabel = "challenge"
+	// coefficients of the keys of the servers in the aggregate key of a collective signature (see AggregateKey)
+	HashKeyAggregation HashLabel = "key-aggregation"
+	// challenge of a collective signature under SignatureSchnorr (see CosignatureChallenge)
+	HashCosignature HashLabel = "cosignature"
+	// coefficient binding the two commitments of the participants of a collective signature (see BindCosignatureCommitments)
+	HashCosignatureBinding HashLabel = "cosignature-binding"
+)
+
 // AuthenticationContext holds all the constants of a particular DAGA authentication round.
 //
 // In DAGA "we define an authentication round with respect to a particular authentication
@@ -90,12 +119,21 @@ type Members struct {
 // R contains the commitments of the servers to their unique per-round secrets
 //
 // H contains the unique per-round generators of the group (<- the algebraic structure) associated to each clients
+//
+// EncodingVersion the encoding of the data signed and hashed under the context (see ContextEncoding)
+//
+// GeneratorVersion the derivation of the client generators H (see GenerateClientGenerator)
+//
+// SignatureScheme the signature scheme of the servers (see ContextSignatureScheme)
 // TODO maybe remove the G thing (but we lose reading "compatibility with daga paper")
 //  and instead have a slices of struct {x, h} and struct {y, r} to enforce same length
 type MinimumAuthenticationContext struct {
-	G Members
-	R []kyber.Point
-	H []kyber.Point
+	G                Members
+	R                []kyber.Point
+	H                []kyber.Point
+	EncodingVersion  EncodingVersion
+	GeneratorVersion GeneratorVersion
+	SignatureScheme  SignatureScheme
 }
 
 // returns a pointer to a newly allocated MinimumAuthenticationContext initialized with :
@@ -106,15 +144,20 @@ type MinimumAuthenticationContext struct {
 //
 // r the commitments of the servers to their unique per-round secrets
 //
-// h the unique per-round generators of the group associated to each clients
+// h the unique per-round generators of the group associated to each clients, derived using CurrentGenerators
+//
+// the context uses the CurrentEncoding and SignatureSchnorr (set SignatureScheme to DefaultSignatureScheme(suite) to use
+// the scheme of the new contexts of the suite)
 func NewMinimumAuthenticationContext(x, y, r, h []kyber.Point) (*MinimumAuthenticationContext, error) {
 	context := MinimumAuthenticationContext{
 		G: Members{
 			X: x,
 			Y: y,
 		},
-		R: r,
-		H: h,
+		R:                r,
+		H:                h,
+		EncodingVersion:  CurrentEncoding,
+		GeneratorVersion: CurrentGenerators,
 	}
 	if err := ValidateContext(context); err != nil {
 		return nil, err
@@ -137,7 +180,25 @@ func (ac MinimumAuthenticationContext) ServersSecretsCommitments() []kyber.Point
 	return ac.R
 }
 
+// Encoding returns the EncodingVersion of the context, see the VersionedContext interface
+func (ac MinimumAuthenticationContext) Encoding() EncodingVersion {
+	return ac.EncodingVersion
+}
+
+// GeneratorDerivation returns the GeneratorVersion of the context, see the GeneratorVersionedContext interface
+func (ac MinimumAuthenticationContext) GeneratorDerivation() GeneratorVersion {
+	return ac.GeneratorVersion
+}
+
+// SigningScheme returns the SignatureScheme of the context, see the SchemedContext interface
+func (ac MinimumAuthenticationContext) SigningScheme() SignatureScheme {
+	return ac.SignatureScheme
+}
+
 func ValidateContext(context AuthenticationContext) error {
+	if context == nil {
+		return newError(ErrInvalidContext, nil, "ValidateContext: nil context")
+	}
 	members := context.Members()
 	// TODO maybe other thing, notably on generators,
 	//  (points/keys don't have small order, or i.e. generators are generators of the correct subgroup etc..)
@@ -145,7 +206,7 @@ func ValidateContext(context AuthenticationContext) error {
 	//  then decide/fix sign/daga server and context related code/API and rewrite them.
 	//  (now in cothority service implementation a node won't serve auth. requests under a context it didn't built and approve => + anytrust we are ok)
 	if len(members.X) != len(context.ClientsGenerators()) || len(members.Y) != len(context.ServersSecretsCommitments()) || len(members.X) == 0 || len(members.Y) == 0 {
-		return errors.New("ValidateContext: illegal length, len(x) != len(h) Or len(y) != len(r) Or zero length slices")
+		return newError(ErrInvalidContext, nil, "ValidateContext: illegal length, len(x) != len(h) Or len(y) != len(r) Or zero length slices")
 	}
 	return nil
 }
@@ -189,33 +250,46 @@ func SchnorrVerify(suite Suite, public kyber.Point, msg, sig []byte) (err error)
 }
 
 //AuthenticationContextToBytes is a utility function that marshal a context into []byte, used in signatures
+// (using the canonical encoding of the context, see ContextEncoding and transcript)
 func AuthenticationContextToBytes(ac AuthenticationContext) (data []byte, err error) {
-	members := ac.Members()
-	temp, e := PointArrayToBytes(members.X)
-	if e != nil {
-		return nil, fmt.Errorf("AuthenticationContextToBytes: error marshaling X: %s", e)
+	data, err = contextTranscript(ac).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticationContextToBytes: %s", err)
 	}
-	data = append(data, temp...)
+	return data, nil
+}
 
-	temp, e = PointArrayToBytes(members.Y)
-	if e != nil {
-		return nil, fmt.Errorf("AuthenticationContextToBytes: error marshaling Y: %s", e)
+// AuthenticationContextSuiteToBytes marshals a context of the suite named suiteName into []byte, as
+// AuthenticationContextToBytes with the name of the suite as a labeled element of the transcript of the context,
+// used to endorse the contexts of a suite (no substitution of the suite).
+// with EncodingLegacy, that predates the suite identifiers, the suite is omitted and the encoding is the one of
+// AuthenticationContextToBytes
+func AuthenticationContextSuiteToBytes(ac AuthenticationContext, suiteName string) (data []byte, err error) {
+	t := contextTranscript(ac)
+	if ContextEncoding(ac) != EncodingLegacy {
+		t.appendBytes("suite", []byte(suiteName))
 	}
-	data = append(data, temp...)
-
-	temp, e = PointArrayToBytes(ac.ClientsGenerators())
-	if e != nil {
-		return nil, fmt.Errorf("AuthenticationContextToBytes: error marshaling H: %s", e)
+	data, err = t.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticationContextSuiteToBytes: %s", err)
 	}
-	data = append(data, temp...)
+	return data, nil
+}
 
-	temp, e = PointArrayToBytes(ac.ServersSecretsCommitments())
-	if e != nil {
-		return nil, fmt.Errorf("AuthenticationContextToBytes: error marshaling R: %s", e)
+// returns the transcript of the context ac
+func contextTranscript(ac AuthenticationContext) *transcript {
+	members := ac.Members()
+	t := newTranscript(ContextEncoding(ac), transcriptContext).
+		appendPoints("X", members.X).
+		appendPoints("Y", members.Y).
+		appendPoints("H", ac.ClientsGenerators()).
+		appendPoints("R", ac.ServersSecretsCommitments())
+	// the signature scheme is covered (no downgrade to another scheme) unless it is the original one,
+	// to keep the encoding of the contexts created before its introduction
+	if scheme := ContextSignatureScheme(ac); scheme != SignatureSchnorr {
+		t.appendInt("signature", int(scheme))
 	}
-	data = append(data, temp...)
-
-	return data, nil
+	return t
 }
 
 //PointArrayToBytes is a utility function that marshal a kyber.Point array into []byte, used in signatures
@@ -243,29 +317,26 @@ func ScalarArrayToBytes(array []kyber.Scalar) (data []byte, err error) {
 }
 
 //ToBytes is a helper function that marshal a ClientMessage into []byte to be used in signatures
+// (using the encoding of the context of the message)
 func (msg AuthenticationMessage) ToBytes() (data []byte, err error) {
-	data, e := AuthenticationContextToBytes(msg.C)
-	if e != nil {
-		return nil, fmt.Errorf("error in context: %s", e)
+	if msg.C == nil {
+		return nil, errors.New("error in context: nil context")
 	}
-
-	temp, e := PointArrayToBytes(msg.SCommits)
+	contextBytes, e := AuthenticationContextToBytes(msg.C)
 	if e != nil {
-		return nil, fmt.Errorf("error in S: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = msg.T0.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in T0: %s", e)
+		return nil, fmt.Errorf("error in context: %s", e)
 	}
-	data = append(data, temp...)
 
-	temp, e = msg.P0.ToBytes()
+	version := ContextEncoding(msg.C)
+	proofBytes, e := msg.P0.ToBytes(version)
 	if e != nil {
 		return nil, fmt.Errorf("error in proof: %s", e)
 	}
-	data = append(data, temp...)
 
-	return data, nil
+	return newTranscript(version, transcriptAuthenticationMessage).
+		appendBytes("C", contextBytes).
+		appendPoints("S", msg.SCommits).
+		appendPoint("T0", msg.T0).
+		appendBytes("P0", proofBytes).
+		Bytes()
 }
@@ -0,0 +1,92 @@
+package daga
+
+// This file contains the errors returned by sign/daga, allowing the callers to branch on the cause of a failure
+// (using errors.Is and errors.As) without parsing the messages.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the causes of the failures of sign/daga
+var (
+	// the context is not a valid DAGA context (see ValidateContext and ValidateContextElements)
+	ErrInvalidContext = errors.New("invalid context")
+	// a message (AuthenticationMessage, ServerMessage, Challenge, ..) is malformed or contains invalid elements
+	ErrMalformedMessage = errors.New("malformed message")
+	// the client's proof PKclient is not accepted
+	ErrInvalidClientProof = errors.New("invalid client proof")
+	// a server's proof is not accepted, see ServerProofError
+	ErrInvalidServerProof = errors.New("invalid server proof")
+	// a signature of a server is not valid, see SignatureError
+	ErrBadSignature = errors.New("bad signature")
+	// the servers flagged the client as misbehaving (its final linkage tag is the identity), see CheckFinalLinkageTag
+	ErrMisbehavingClient = errors.New("misbehaving client")
+	// an authentication message is not bound to the expected session of a relying party, see CheckSession
+	ErrSessionMismatch = errors.New("session mismatch")
+	// the master challenge does not match the commitments and openings of the servers, see CheckUpdateChallenge
+	ErrInvalidChallenge = errors.New("invalid challenge")
+)
+
+// ServerProofError is returned when the proof of the server at Index (in the context) is not accepted,
+// it matches ErrInvalidServerProof
+type ServerProofError struct {
+	Index int
+}
+
+func (e *ServerProofError) Error() string {
+	return fmt.Sprintf("invalid server proof of server %d", e.Index)
+}
+
+// Unwrap returns ErrInvalidServerProof
+func (e *ServerProofError) Unwrap() error {
+	return ErrInvalidServerProof
+}
+
+// SignatureError is returned when the signature of the server at Signer (in the context) is not valid,
+// Err is the reason returned by the signature verification, it matches ErrBadSignature
+type SignatureError struct {
+	Signer int
+	Err    error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("bad signature of server %d: %s", e.Signer, e.Err)
+}
+
+// Is returns whether target is ErrBadSignature
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrBadSignature
+}
+
+// Unwrap returns the reason why the signature was rejected
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// daga error, used to keep the (previous) messages of the errors while making them match their cause and kind:
+// the error matches kind (if not nil) and unwraps to cause (if not nil)
+type dagaError struct {
+	msg   string
+	kind  error
+	cause error
+}
+
+// returns a new error with message fmt.Sprintf(format, args...) that matches kind and wraps cause
+func newError(kind, cause error, format string, args ...interface{}) error {
+	return &dagaError{msg: fmt.Sprintf(format, args...), kind: kind, cause: cause}
+}
+
+func (e *dagaError) Error() string {
+	return e.msg
+}
+
+// Is returns whether target is the kind of the error
+func (e *dagaError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// Unwrap returns the cause of the error
+func (e *dagaError) Unwrap() error {
+	return e.cause
+}
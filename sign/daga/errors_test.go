@@ -0,0 +1,126 @@
+package daga
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestErrors(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			authMsg, err := NewAuthenticationMessage(suite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+
+			// invalid context
+			tamperedContext := *context.(*MinimumAuthenticationContext)
+			tamperedContext.H = tamperedContext.H[1:]
+			require.True(t, errors.Is(ValidateContextElements(suite, tamperedContext), ErrInvalidContext))
+			require.True(t, errors.Is(ValidateContext(tamperedContext), ErrInvalidContext))
+
+			// invalid client proof
+			tampered := *authMsg
+			tampered.P0.T = append([]kyber.Point{}, authMsg.P0.T...)
+			tampered.P0.T[0] = suite.Point().Pick(suite.RandomStream())
+			err = verifyAuthenticationMessage(suite, tampered)
+			require.True(t, errors.Is(err, ErrInvalidClientProof), "wrong error: %s", err)
+			servMsg, err := InitializeServerMessage(&tampered)
+			require.NoError(t, err)
+			err = ServerProtocol(suite, servMsg, servers[0])
+			require.True(t, errors.Is(err, ErrInvalidClientProof), "wrong error: %s", err)
+
+			// malformed message
+			tampered = *authMsg
+			tampered.T0 = nil
+			err = validateAuthenticationMessage(suite, tampered)
+			require.True(t, errors.Is(err, ErrMalformedMessage), "wrong error: %s", err)
+
+			servMsg, err = InitializeServerMessage(authMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, ServerProtocol(suite, servMsg, server))
+			}
+			Tf, err := GetFinalLinkageTag(suite, context, *servMsg)
+			require.NoError(t, err)
+			require.NoError(t, CheckFinalLinkageTag(suite, Tf))
+
+			// bad signature, the signer is reported
+			badSig := *servMsg
+			badSig.Sigs = append([]ServerSignature{}, servMsg.Sigs...)
+			badSig.Sigs[1].Sig = append([]byte{}, servMsg.Sigs[1].Sig...)
+			badSig.Sigs[1].Sig[0] ^= 0x01
+			_, err = GetFinalLinkageTag(suite, context, badSig)
+			require.True(t, errors.Is(err, ErrBadSignature), "wrong error: %s", err)
+			var sigErr *SignatureError
+			require.True(t, errors.As(err, &sigErr))
+			require.Equal(t, servMsg.Sigs[1].Index, sigErr.Signer)
+
+			// invalid (but correctly signed) server proof, the server is reported
+			badProof := *servMsg
+			last := len(servers) - 1
+			badProof.Proofs = append([]ServerProof{}, servMsg.Proofs...)
+			badProof.Proofs[last].R1 = suite.Scalar().Pick(suite.RandomStream())
+			badProof.Sigs = append([]ServerSignature{}, servMsg.Sigs...)
+			signData, err := newServerMessageTranscript(badProof.Request)
+			require.NoError(t, err)
+			var data []byte
+			for i := range badProof.Proofs {
+				data, err = appendServerContribution(signData, badProof.Tags[i], badProof.Proofs[i], badProof.Indexes[i])
+				require.NoError(t, err)
+			}
+			badProof.Sigs[last].Sig, err = ContextSignatureScheme(context).Sign(suite, servers[badProof.Indexes[last]].PrivateKey(), data)
+			require.NoError(t, err)
+			_, err = GetFinalLinkageTag(suite, context, badProof)
+			require.True(t, errors.Is(err, ErrInvalidServerProof), "wrong error: %s", err)
+			var proofErr *ServerProofError
+			require.True(t, errors.As(err, &proofErr))
+			require.Equal(t, badProof.Indexes[last], proofErr.Index)
+
+			// forged challenge, the signer is reported to the client and to the servers
+			forge := func(pkClientCommitments []kyber.Point) Challenge {
+				forged := runChallengeGeneration(t, suite, context, servers, pkClientCommitments)
+				forged.Sigs[1].Sig = append([]byte{}, forged.Sigs[1].Sig...)
+				forged.Sigs[1].Sig[0] ^= 0x01
+				return forged
+			}
+			forged := forge(authMsg.P0.T)
+			_, err = NewAuthenticationMessage(suite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return forge(pkClientCommitments), nil
+			})
+			require.True(t, errors.Is(err, ErrBadSignature), "wrong error: %s", err)
+			require.True(t, errors.As(err, &sigErr))
+			require.Equal(t, forged.Sigs[1].Index, sigErr.Signer)
+			err = CheckUpdateChallenge(suite, context, &ChallengeCheck{Challenge: forged}, authMsg.P0.T, servers[0])
+			require.True(t, errors.Is(err, ErrBadSignature), "wrong error: %s", err)
+			require.True(t, errors.As(err, &sigErr))
+			require.Equal(t, forged.Sigs[1].Index, sigErr.Signer)
+
+			// invalid master challenge
+			commits := make([]ChallengeCommitment, len(servers))
+			openings := make([]kyber.Scalar, len(servers))
+			for i, server := range servers {
+				commit, opening, err := NewChallengeCommitment(suite, context, server)
+				require.NoError(t, err)
+				commits[i], openings[i] = *commit, opening
+			}
+			challengeCheck, err := InitializeChallenge(suite, context, commits, openings)
+			require.NoError(t, err)
+			challengeCheck.Cs = suite.Scalar().Pick(suite.RandomStream())
+			err = CheckUpdateChallenge(suite, context, challengeCheck, authMsg.P0.T, servers[0])
+			require.True(t, errors.Is(err, ErrInvalidChallenge), "wrong error: %s", err)
+			challengeCheck.Openings = append([]kyber.Scalar{}, openings...)
+			challengeCheck.Openings[0] = suite.Scalar().Pick(suite.RandomStream())
+			err = CheckUpdateChallenge(suite, context, challengeCheck, authMsg.P0.T, servers[0])
+			require.True(t, errors.Is(err, ErrInvalidChallenge), "wrong error: %s", err)
+
+			// misbehaving client
+			require.True(t, errors.Is(CheckFinalLinkageTag(suite, suite.Point().Null()), ErrMisbehavingClient))
+		})
+	}
+}
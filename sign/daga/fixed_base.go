@@ -0,0 +1,331 @@
+package daga
+
+// This file contains the fixed-base precomputation tables of the points of the contexts (the client generators H, the
+// keys of the clients X and of the servers Y, the commitments of the servers R and the base point), that are multiplied
+// again and again for every request under a context.
+//
+// the table of a point P holds all the multiples d*16^i*P for the digits d in [1, 15] of the scalars (in base 16),
+// a multiplication by P is then the addition of one entry per digit, 2*ScalarLen additions and no doubling,
+// for a table of 30*ScalarLen points (~170KB in memory with edwards25519).
+//
+// the tables are built lazily, the table of a point is built at its second multiplication, and kept in a bounded cache
+// (the least recently used tables are dropped), bounded in number of tables and in memory, see SetFixedBaseCacheSize and
+// SetFixedBaseCacheMemory (the tables of the suites with large elements, e.g. ~4.5MB for the 2048-bit Schnorr group,
+// quickly fill the memory bound).
+//
+// the cache is shared by all the contexts and keyed by the encoding of the points rather than attached to each
+// AuthenticationContext: the contexts share most of their points (the base point, the keys of the servers), the
+// services decode a new context value for every request (that would rebuild its tables each time), and a single cache
+// bounds the memory of all the tables, whatever the number of contexts in use.
+//
+// !the lookups skip the zero digits and index the tables with the digits of the scalars, their timing and memory
+// accesses depend on the scalars, use them only with public scalars (the verification of the proofs, the simulated
+// clauses of PKclient), never with secrets (keys, nonces, shared secrets), that use the constant-time Point().Mul!
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+
+	"go.dedis.ch/kyber"
+)
+
+// default maximum number of tables of the cache, see SetFixedBaseCacheSize
+const defaultFixedBaseCacheSize = 256
+
+// default maximum size of the tables of the cache (in bytes of memory), see SetFixedBaseCacheMemory
+const defaultFixedBaseCacheMemory = 32 << 20
+
+// number of multiplications by a point after which its table is built
+const fixedBaseMinUses = 2
+
+// the fixed-base tables of the points, shared by all the contexts using them
+var fixedBaseTables = newFixedBaseCache(defaultFixedBaseCacheSize, defaultFixedBaseCacheMemory)
+
+// SetFixedBaseCacheSize sets the maximum number of fixed-base precomputation tables kept in memory (0 disables them),
+// defaults to 256. it should exceed the number of points of the contexts in use (clients, servers, generators),
+// otherwise the tables of their points are dropped before being reused.
+func SetFixedBaseCacheSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	fixedBaseTables.setCapacity(n)
+}
+
+// SetFixedBaseCacheMemory sets the maximum total size, in bytes, of the fixed-base precomputation tables kept in memory
+// (the memory held by their points, see fixedBaseTableSize), defaults to 32MB.
+// the tables larger than the bound are never built.
+func SetFixedBaseCacheMemory(n int) {
+	if n < 0 {
+		n = 0
+	}
+	fixedBaseTables.setMaxMemory(n)
+}
+
+// fixedBaseMul returns s*P using the table of P (a nil point is the base point), P must be a point of a context or the
+// base point, the multiplications by other points are done with suite.Point().Mul.
+// s must be public, not constant time (see above)
+func fixedBaseMul(suite Suite, s kyber.Scalar, P kyber.Point) kyber.Point {
+	if table := fixedBaseTables.get(suite, P); table != nil {
+		if R, ok := table.mul(suite, s); ok {
+			return R
+		}
+	}
+	return suite.Point().Mul(s, P)
+}
+
+// number of multiplications by a point from which a (temporary) table pays off, see repeatedMul
+const repeatedMulMinUses = 8
+
+// returns a function computing s*P for the n multiplications by a point P that is not a point of the context but is
+// used for all the clauses of a proof (e.g. T0), using a temporary table of P if n is large enough,
+// the scalars s must be public, not constant time (see above)
+func repeatedMul(suite Suite, P kyber.Point, n int) func(kyber.Scalar) kyber.Point {
+	if n >= repeatedMulMinUses && fixedBaseTables.fits(suite) {
+		table := newFixedBaseTable(suite, P)
+		return func(s kyber.Scalar) kyber.Point {
+			if R, ok := table.mul(suite, s); ok {
+				return R
+			}
+			return suite.Point().Mul(s, P)
+		}
+	}
+	return func(s kyber.Scalar) kyber.Point {
+		return suite.Point().Mul(s, P)
+	}
+}
+
+// fixed-base table of a point, rows[pos][d-1] = d*16^(digits-1-pos)*P, pos being the position of the digit (nibble)
+// in the scalars, most significant first (see scalarDigits)
+type fixedBaseTable struct {
+	littleEndian bool
+	rows         [][]kyber.Point
+	size         int // see fixedBaseTableSize
+}
+
+// returns the size in memory of the tables of the points of suite: the headers of the rows, the interface values of
+// the rows and the points they reference (see pointFootprint)
+func fixedBaseTableSize(suite Suite) int {
+	const rowSize = 1<<msmWindow - 1
+	row := int(reflect.TypeOf([]kyber.Point(nil)).Size()) + rowSize*(int(pointInterfaceSize)+pointFootprint(suite))
+	return 2 * suite.ScalarLen() * row
+}
+
+// size of a kyber.Point interface value
+var pointInterfaceSize = reflect.TypeOf((*kyber.Point)(nil)).Elem().Size()
+
+// the footprints of the points of the suites, by suite name, see pointFootprint
+var pointFootprints sync.Map
+
+// returns the size in memory of a point of suite, the size of its value and of the memory it references, not counting
+// the memory it shares with the other points (e.g. the parameters of the group), measured once per suite
+func pointFootprint(suite Suite) int {
+	if size, ok := pointFootprints.Load(suite.String()); ok {
+		return size.(int)
+	}
+	// points with a full-size representation (-1*B is not a small power of the generator of the Schnorr group),
+	// the memory referenced by P is then not counted in the footprint of Q
+	P := suite.Point().Mul(suite.Scalar().SetInt64(-1), nil)
+	Q := suite.Point().Add(P, P)
+	seen := make(map[uintptr]bool)
+	referencedSize(reflect.ValueOf(&P).Elem(), seen)
+	size := referencedSize(reflect.ValueOf(&Q).Elem(), seen)
+	pointFootprints.Store(suite.String(), size)
+	return size
+}
+
+// returns the size of the memory referenced by v (excluding v itself), skipping the pointers in seen and adding the
+// others to it
+func referencedSize(v reflect.Value, seen map[uintptr]bool) int {
+	size := 0
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		size = int(v.Type().Elem().Size()) + referencedSize(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		if e := v.Elem(); e.Kind() == reflect.Ptr {
+			size = referencedSize(e, seen)
+		} else {
+			size = int(e.Type().Size()) + referencedSize(e, seen)
+		}
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		size = v.Cap() * int(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			size += referencedSize(v.Field(i), seen)
+		}
+	case reflect.String:
+		size = v.Len()
+	}
+	return size
+}
+
+// returns the table of P (a nil point is the base point)
+func newFixedBaseTable(suite Suite, P kyber.Point) *fixedBaseTable {
+	const rowSize = 1<<msmWindow - 1
+	digits := 2 * suite.ScalarLen()
+	t := &fixedBaseTable{
+		littleEndian: scalarLittleEndian(suite),
+		rows:         make([][]kyber.Point, digits),
+		size:         fixedBaseTableSize(suite),
+	}
+	base := suite.Point().Base()
+	if P != nil {
+		base.Set(P)
+	}
+	for pos := digits - 1; pos >= 0; pos-- {
+		row := make([]kyber.Point, rowSize)
+		row[0] = suite.Point().Set(base)
+		for d := 1; d < rowSize; d++ {
+			row[d] = suite.Point().Add(row[d-1], base)
+		}
+		t.rows[pos] = row
+		// 16*base = 15*base + base
+		base = suite.Point().Add(row[rowSize-1], base)
+	}
+	return t
+}
+
+// returns s*P, false if s can't be encoded
+func (t *fixedBaseTable) mul(suite Suite, s kyber.Scalar) (kyber.Point, bool) {
+	digits := make([]byte, len(t.rows))
+	if !scalarDigits(s, t.littleEndian, digits) {
+		return nil, false
+	}
+	acc := suite.Point().Null()
+	for pos, d := range digits {
+		if d != 0 {
+			acc.Add(acc, t.rows[pos][d-1])
+		}
+	}
+	return acc, true
+}
+
+// bounded (LRU) cache of the fixed-base tables, the points are identified by their suite and encoding
+type fixedBaseCache struct {
+	mutex     sync.Mutex
+	capacity  int
+	maxMemory int
+	memory    int                      // total size of the tables
+	tables    map[string]*list.Element // values of the elements are *fixedBaseEntry
+	lru       *list.List               // most recently used first
+	uses      map[string]int           // number of multiplications by the points without table
+}
+
+type fixedBaseEntry struct {
+	key   string
+	table *fixedBaseTable
+}
+
+func newFixedBaseCache(capacity, maxMemory int) *fixedBaseCache {
+	return &fixedBaseCache{
+		capacity:  capacity,
+		maxMemory: maxMemory,
+		tables:    make(map[string]*list.Element),
+		lru:       list.New(),
+		uses:      make(map[string]int),
+	}
+}
+
+func (c *fixedBaseCache) setCapacity(capacity int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.capacity = capacity
+	c.evict()
+}
+
+func (c *fixedBaseCache) setMaxMemory(maxMemory int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.maxMemory = maxMemory
+	c.evict()
+}
+
+// returns whether the tables are enabled (see SetFixedBaseCacheSize) and the tables of suite fit in the memory bound
+// (see SetFixedBaseCacheMemory)
+func (c *fixedBaseCache) fits(suite Suite) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.fitsLocked(suite)
+}
+
+func (c *fixedBaseCache) fitsLocked(suite Suite) bool {
+	return c.capacity > 0 && fixedBaseTableSize(suite) <= c.maxMemory
+}
+
+// drops the least recently used tables until there are at most capacity tables, of at most maxMemory bytes
+func (c *fixedBaseCache) evict() {
+	for c.lru.Len() > c.capacity || c.memory > c.maxMemory {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		entry := e.Value.(*fixedBaseEntry)
+		delete(c.tables, entry.key)
+		c.memory -= entry.table.size
+	}
+}
+
+// returns the table of P, building it if P is used for the fixedBaseMinUses-th time, or nil
+func (c *fixedBaseCache) get(suite Suite, P kyber.Point) *fixedBaseTable {
+	key := suite.String() + "/"
+	if P == nil {
+		key += "base"
+	} else {
+		buf, err := P.MarshalBinary()
+		if err != nil {
+			return nil
+		}
+		key += string(buf)
+	}
+
+	c.mutex.Lock()
+	if !c.fitsLocked(suite) {
+		c.mutex.Unlock()
+		return nil
+	}
+	if e, ok := c.tables[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mutex.Unlock()
+		return e.Value.(*fixedBaseEntry).table
+	}
+	c.uses[key]++
+	if c.uses[key] < fixedBaseMinUses {
+		// bound the memory used to count the uses too
+		if len(c.uses) > 4*c.capacity {
+			c.uses = make(map[string]int)
+		}
+		c.mutex.Unlock()
+		return nil
+	}
+	delete(c.uses, key)
+	c.mutex.Unlock()
+
+	// built without holding the lock, (concurrent builds of the same table are harmless)
+	table := newFixedBaseTable(suite, P)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e, ok := c.tables[key]; ok {
+		return e.Value.(*fixedBaseEntry).table
+	}
+	c.tables[key] = c.lru.PushFront(&fixedBaseEntry{key: key, table: table})
+	c.memory += table.size
+	c.evict()
+	return table
+}
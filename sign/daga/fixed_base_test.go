@@ -0,0 +1,179 @@
+package daga
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestFixedBaseMul(t *testing.T) {
+	defer SetFixedBaseCacheSize(defaultFixedBaseCacheSize)
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			scalars := []kyber.Scalar{
+				suite.Scalar().Zero(),
+				suite.Scalar().One(),
+				suite.Scalar().SetInt64(-1),
+				suite.Scalar().Pick(suite.RandomStream()),
+				suite.Scalar().Pick(suite.RandomStream()),
+			}
+			for _, P := range []kyber.Point{nil, suite.Point().Pick(suite.RandomStream())} {
+				table := newFixedBaseTable(suite, P)
+				for _, s := range scalars {
+					expected := suite.Point().Mul(s, P)
+					R, ok := table.mul(suite, s)
+					require.True(t, ok)
+					require.True(t, expected.Equal(R))
+					// through the cache, the table is built at the second multiplication
+					for i := 0; i < fixedBaseMinUses+1; i++ {
+						require.True(t, expected.Equal(fixedBaseMul(suite, s, P)))
+					}
+					for _, n := range []int{1, repeatedMulMinUses} {
+						require.True(t, expected.Equal(repeatedMul(suite, P, n)(s)))
+					}
+				}
+			}
+
+			// disabled tables
+			require.False(t, newFixedBaseCache(defaultFixedBaseCacheSize, 0).fits(suite))
+			SetFixedBaseCacheSize(0)
+			s := scalars[3]
+			require.True(t, suite.Point().Mul(s, nil).Equal(fixedBaseMul(suite, s, nil)))
+			SetFixedBaseCacheSize(defaultFixedBaseCacheSize)
+		})
+	}
+}
+
+func TestFixedBaseCache(t *testing.T) {
+	cache := newFixedBaseCache(2, defaultFixedBaseCacheMemory)
+	points := []kyber.Point{suite.Point().Pick(suite.RandomStream()), suite.Point().Pick(suite.RandomStream()), nil}
+
+	// the table is built at the second use
+	require.Nil(t, cache.get(suite, points[0]))
+	table := cache.get(suite, points[0])
+	require.NotNil(t, table)
+	require.True(t, table == cache.get(suite, points[0]))
+
+	// the least recently used table is dropped
+	for _, P := range points[1:] {
+		cache.get(suite, P)
+		require.NotNil(t, cache.get(suite, P))
+	}
+	require.Equal(t, 2, cache.lru.Len())
+	require.Nil(t, cache.get(suite, points[0]), "least recently used table not dropped")
+
+	cache.setCapacity(0)
+	require.Equal(t, 0, cache.lru.Len())
+	require.False(t, cache.fits(suite))
+	require.Nil(t, cache.get(suite, points[2]))
+	require.Nil(t, cache.get(suite, points[2]))
+}
+
+func TestFixedBaseCache_Memory(t *testing.T) {
+	size := fixedBaseTableSize(suite)
+	cache := newFixedBaseCache(defaultFixedBaseCacheSize, 2*size)
+	points := []kyber.Point{suite.Point().Pick(suite.RandomStream()), suite.Point().Pick(suite.RandomStream()), nil}
+
+	// the least recently used table is dropped once the tables exceed the memory bound
+	for _, P := range points {
+		cache.get(suite, P)
+		require.NotNil(t, cache.get(suite, P))
+	}
+	require.Equal(t, 2, cache.lru.Len())
+	require.Equal(t, 2*size, cache.memory)
+	cache.setMaxMemory(size)
+	require.Equal(t, 1, cache.lru.Len())
+	require.Equal(t, size, cache.memory)
+
+	// the tables larger than the bound are never built (e.g. the ones of the 2048-bit Schnorr group by default)
+	schnorr := NewSuiteSchnorr()
+	require.True(t, fixedBaseTableSize(schnorr) > size)
+	require.False(t, cache.fits(schnorr))
+	P := schnorr.Point().Pick(schnorr.RandomStream())
+	for i := 0; i < fixedBaseMinUses+1; i++ {
+		require.Nil(t, cache.get(schnorr, P))
+	}
+	require.Equal(t, 1, cache.lru.Len())
+}
+
+func TestFixedBaseTableSize(t *testing.T) {
+	// the tables are counted with the size of the points in memory, larger than their encodings
+	for _, suite := range suites {
+		require.True(t, pointFootprint(suite) >= suite.PointLen(), suite.String())
+		require.Equal(t, pointFootprint(suite), pointFootprint(suite))
+		require.True(t, fixedBaseTableSize(suite) > 2*suite.ScalarLen()*(1<<msmWindow-1)*suite.PointLen(), suite.String())
+	}
+	// extended coordinates, 4 field elements of 10 int32
+	require.True(t, pointFootprint(NewSuiteEC()) >= 160)
+
+	// the memory shared by the points (the parameters of the group) is not counted
+	schnorr := NewSuiteSchnorr()
+	P := schnorr.Point().Mul(schnorr.Scalar().SetInt64(-1), nil)
+	require.True(t, pointFootprint(schnorr) < referencedSize(reflect.ValueOf(&P).Elem(), make(map[uintptr]bool)))
+}
+
+// the gains of the tables for the multiplication by a point
+func BenchmarkFixedBaseMul(b *testing.B) {
+	for _, suite := range suites {
+		P := suite.Point().Pick(suite.RandomStream())
+		s := suite.Scalar().Pick(suite.RandomStream())
+		table := newFixedBaseTable(suite, P)
+		b.Run(suite.String()+"/table", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				table.mul(suite, s)
+			}
+		})
+		b.Run(suite.String()+"/plain", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				suite.Point().Mul(s, P)
+			}
+		})
+	}
+}
+
+// runs the benchmark f across context sizes, with and without the fixed-base tables
+func benchmarkContextSizes(b *testing.B, f func(b *testing.B, clients []Client, servers []Server, context AuthenticationContext)) {
+	defer SetFixedBaseCacheSize(defaultFixedBaseCacheSize)
+	for _, nbrClients := range []int{4, 16, 64} {
+		clients, servers, context, err := GenerateTestContext(suite, nbrClients, 3)
+		require.NoError(b, err)
+		for _, size := range []int{defaultFixedBaseCacheSize, 0} {
+			name := "tables"
+			if size == 0 {
+				name = "plain"
+			}
+			b.Run(fmt.Sprintf("clients=%d/%s", nbrClients, name), func(b *testing.B) {
+				SetFixedBaseCacheSize(size)
+				f(b, clients, servers, context)
+			})
+		}
+	}
+}
+
+func BenchmarkPrecompute(b *testing.B) {
+	benchmarkContextSizes(b, func(b *testing.B, clients []Client, servers []Server, context AuthenticationContext) {
+		for i := 0; i < b.N; i++ {
+			_, err := Precompute(suite, context, clients[0])
+			require.NoError(b, err)
+		}
+	})
+}
+
+func BenchmarkServerProtocol(b *testing.B) {
+	benchmarkContextSizes(b, func(b *testing.B, clients []Client, servers []Server, context AuthenticationContext) {
+		authMsg, err := NewAuthenticationMessage(suite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+			return runChallengeGeneration(b, suite, context, servers, pkClientCommitments), nil
+		})
+		require.NoError(b, err)
+		authMsg.P0 = authMsg.P0.Compact()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			servMsg, err := InitializeServerMessage(authMsg)
+			require.NoError(b, err)
+			require.NoError(b, ServerProtocol(suite, servMsg, servers[0]))
+		}
+	})
+}
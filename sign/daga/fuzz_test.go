@@ -0,0 +1,263 @@
+package daga
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/xof/blake2xb"
+)
+
+// the suite of the fuzz targets
+var fuzzSuite = NewSuiteEC()
+
+// domain-separation label of the encoding of the inputs of the fuzz targets
+const fuzzDomain = "daga/fuzz-server-message"
+
+// encodes msg (including the context of its request) as the input of the fuzz targets, see decodeFuzzServerMessage
+func encodeFuzzServerMessage(t testing.TB, msg ServerMessage) []byte {
+	context := msg.Request.C
+	members := context.Members()
+	tr := newTranscript(EncodingV1, fuzzDomain).
+		appendPoints("X", members.X).
+		appendPoints("Y", members.Y).
+		appendPoints("R", context.ServersSecretsCommitments()).
+		appendPoints("H", context.ClientsGenerators()).
+		appendInt("encoding", int(ContextEncoding(context))).
+		appendInt("generators", int(ContextGeneratorVersion(context))).
+		appendInt("signature", int(ContextSignatureScheme(context))).
+		appendPoint("T0", msg.Request.T0).
+		appendPoints("SCommits", msg.Request.SCommits).
+		appendScalar("Cs", msg.Request.P0.Cs.Cs)
+	appendFuzzSignatures(tr, "Cs.Sigs", msg.Request.P0.Cs.Sigs)
+	tr.appendPoints("T", msg.Request.P0.T).
+		appendScalars("C", msg.Request.P0.C).
+		appendScalars("R", msg.Request.P0.R).
+		appendPoints("Tags", msg.Tags).
+		appendInt("Proofs", len(msg.Proofs))
+	for _, proof := range msg.Proofs {
+		tr.appendPoint("T1", proof.T1).
+			appendPoint("T2", proof.T2).
+			appendPoint("T3", proof.T3).
+			appendScalar("C", proof.C).
+			appendScalar("R1", proof.R1)
+		// R2 is nil in the proofs of a misbehaving client
+		var R2 []byte
+		if proof.R2 != nil {
+			var err error
+			R2, err = proof.R2.MarshalBinary()
+			require.NoError(t, err)
+		}
+		tr.appendBytes("R2", R2)
+	}
+	tr.appendInt("Indexes", len(msg.Indexes))
+	for _, index := range msg.Indexes {
+		tr.appendInt("index", index)
+	}
+	appendFuzzSignatures(tr, "Sigs", msg.Sigs)
+	data, err := tr.Bytes()
+	require.NoError(t, err)
+	return data
+}
+
+func appendFuzzSignatures(tr *transcript, label string, sigs []ServerSignature) {
+	tr.appendInt(label, len(sigs))
+	for _, sig := range sigs {
+		tr.appendInt("index", sig.Index).appendBytes("sig", sig.Sig)
+	}
+}
+
+// decodes the input of the fuzz targets, returns false if data is not an encoding of a server message
+func decodeFuzzServerMessage(suite Suite, data []byte) (*ServerMessage, bool) {
+	r := newTranscriptReader(data, fuzzDomain)
+	context := &MinimumAuthenticationContext{
+		G: Members{
+			X: r.readPoints(suite, "X"),
+			Y: r.readPoints(suite, "Y"),
+		},
+		R:                r.readPoints(suite, "R"),
+		H:                r.readPoints(suite, "H"),
+		EncodingVersion:  EncodingVersion(r.readInt("encoding")),
+		GeneratorVersion: GeneratorVersion(r.readInt("generators")),
+		SignatureScheme:  SignatureScheme(r.readInt("signature")),
+	}
+	msg := &ServerMessage{Request: AuthenticationMessage{C: context}}
+	msg.Request.T0 = r.readPoint(suite, "T0")
+	msg.Request.SCommits = r.readPoints(suite, "SCommits")
+	msg.Request.P0.Cs.Cs = r.readScalar(suite, "Cs")
+	msg.Request.P0.Cs.Sigs = readFuzzSignatures(r, "Cs.Sigs")
+	msg.Request.P0.T = r.readPoints(suite, "T")
+	msg.Request.P0.C = r.readScalars(suite, "C")
+	msg.Request.P0.R = r.readScalars(suite, "R")
+	msg.Tags = r.readPoints(suite, "Tags")
+	for i, n := 0, readFuzzCount(r, "Proofs"); i < n; i++ {
+		proof := ServerProof{
+			T1: r.readPoint(suite, "T1"),
+			T2: r.readPoint(suite, "T2"),
+			T3: r.readPoint(suite, "T3"),
+			C:  r.readScalar(suite, "C"),
+			R1: r.readScalar(suite, "R1"),
+		}
+		if R2 := r.readBytes("R2"); len(R2) != 0 {
+			proof.R2 = suite.Scalar()
+			r.unmarshal("R2", R2, proof.R2)
+		}
+		msg.Proofs = append(msg.Proofs, proof)
+	}
+	for i, n := 0, readFuzzCount(r, "Indexes"); i < n; i++ {
+		msg.Indexes = append(msg.Indexes, r.readInt("index"))
+	}
+	msg.Sigs = readFuzzSignatures(r, "Sigs")
+	return msg, r.Done() == nil
+}
+
+// reads the number of items of a list, bounded by the size of the remaining data
+func readFuzzCount(r *transcriptReader, label string) int {
+	n := r.readInt(label)
+	if r.err == nil && (n < 0 || n > len(r.buf)) {
+		r.err = fmt.Errorf("error reading %s: invalid count", label)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
+
+func readFuzzSignatures(r *transcriptReader, label string) []ServerSignature {
+	var sigs []ServerSignature
+	for i, n := 0, readFuzzCount(r, label); i < n && r.err == nil; i++ {
+		sigs = append(sigs, ServerSignature{Index: r.readInt("index"), Sig: r.readBytes("sig")})
+	}
+	return sigs
+}
+
+// returns the servers of the context of the seed corpus and the encodings of valid server messages
+// (at each step of the server's protocol, with a full or compact proof, from a honest or misbehaving client)
+func fuzzSeedCorpus(f *testing.F) ([]Server, AuthenticationContext, [][]byte) {
+	seeded := WithRandomStream(fuzzSuite, blake2xb.New([]byte("daga fuzz seed corpus")))
+	clients, servers, context, err := GenerateTestContext(seeded, 3, 3)
+	require.NoError(f, err)
+	sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		return runChallengeGeneration(f, seeded, context, servers, pkClientCommitments), nil
+	}
+	authMsg, err := NewAuthenticationMessage(seeded, context, clients[1], sendCommitsReceiveChallenge)
+	require.NoError(f, err)
+
+	// misbehaving client, commitment to the shared secret with the 1st server is the identity
+	tagAndCommitments, s, err := newInitialTagAndCommitments(seeded, ContextEncoding(context), context.Members().Y, context.ClientsGenerators()[0])
+	require.NoError(f, err)
+	tagAndCommitments.SCommits[2] = seeded.Point().Null()
+	proof, err := newClientProof(seeded, context, clients[0], *tagAndCommitments, s, sendCommitsReceiveChallenge)
+	require.NoError(f, err)
+	misbehaving := &AuthenticationMessage{C: context, initialTagAndCommitments: *tagAndCommitments, P0: proof}
+
+	compact := *authMsg
+	compact.P0 = authMsg.P0.Compact()
+
+	var corpus [][]byte
+	add := func(msg ServerMessage) {
+		data := encodeFuzzServerMessage(f, msg)
+		_, ok := decodeFuzzServerMessage(fuzzSuite, data)
+		require.True(f, ok, "seed not decoded")
+		corpus = append(corpus, data)
+	}
+	for _, request := range []*AuthenticationMessage{authMsg, &compact, misbehaving} {
+		servMsg, err := InitializeServerMessage(request)
+		require.NoError(f, err)
+		add(*servMsg)
+		for _, server := range servers {
+			require.NoError(f, ServerProtocol(seeded, servMsg, server))
+			add(*servMsg)
+		}
+	}
+	return servers, context, corpus
+}
+
+func FuzzServerProtocol(f *testing.F) {
+	servers, _, corpus := fuzzSeedCorpus(f)
+	for _, data := range corpus {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, ok := decodeFuzzServerMessage(fuzzSuite, data)
+		if !ok {
+			return
+		}
+		// the next server, (the first one again once all the servers did the protocol)
+		server := servers[len(msg.Tags)%len(servers)]
+		_ = ServerProtocol(fuzzSuite, msg, server)
+	})
+}
+
+func FuzzGetFinalLinkageTag(f *testing.F) {
+	_, context, corpus := fuzzSeedCorpus(f)
+	for _, data := range corpus {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, ok := decodeFuzzServerMessage(fuzzSuite, data)
+		if !ok {
+			return
+		}
+		// under the context of the request and under the context of the corpus
+		_, _ = GetFinalLinkageTag(fuzzSuite, msg.Request.C, *msg)
+		_, _ = GetFinalLinkageTag(fuzzSuite, context, *msg)
+	})
+}
+
+func FuzzVerifyClientProof(f *testing.F) {
+	_, _, corpus := fuzzSeedCorpus(f)
+	for _, data := range corpus {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, ok := decodeFuzzServerMessage(fuzzSuite, data)
+		if !ok {
+			return
+		}
+		_ = verifyClientProof(fuzzSuite, msg.Request.C, msg.Request.P0, msg.Request.initialTagAndCommitments)
+	})
+}
+
+// the inputs that used to panic
+func TestFuzzRegressions(t *testing.T) {
+	clients, servers, context, err := GenerateTestContext(fuzzSuite, 3, 3)
+	require.NoError(t, err)
+	authMsg, err := NewAuthenticationMessage(fuzzSuite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		return runChallengeGeneration(t, fuzzSuite, context, servers, pkClientCommitments), nil
+	})
+	require.NoError(t, err)
+	servMsg, err := InitializeServerMessage(authMsg)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, ServerProtocol(fuzzSuite, servMsg, server))
+	}
+
+	// final linkage tag under a context with less servers than the context of the request
+	_, _, smallerContext, err := GenerateTestContext(fuzzSuite, 3, 1)
+	require.NoError(t, err)
+	_, err = GetFinalLinkageTag(fuzzSuite, smallerContext, *servMsg)
+	require.True(t, errors.Is(err, ErrInvalidContext), "wrong error: %s", err)
+
+	// server whose index is out of the context of the request
+	request, err := InitializeServerMessage(authMsg)
+	require.NoError(t, err)
+	outsider, err := NewServer(fuzzSuite, 5, nil)
+	require.NoError(t, err)
+	require.True(t, errors.Is(ServerProtocol(fuzzSuite, request, outsider), ErrInvalidContext))
+
+	// proof with missing elements
+	proof := authMsg.P0
+	proof.C = append([]kyber.Scalar{}, authMsg.P0.C...)
+	proof.C[1] = nil
+	require.Error(t, verifyClientProof(fuzzSuite, context, proof, authMsg.initialTagAndCommitments))
+	require.Error(t, verifyClientProof(fuzzSuite, context, authMsg.P0, initialTagAndCommitments{}))
+
+	// too many challenge commitments, nil opening
+	commits := make([]ChallengeCommitment, len(servers)+1)
+	require.Error(t, VerifyChallengeCommitmentsSignatures(fuzzSuite, context, commits))
+	require.False(t, CheckOpening(fuzzSuite, fuzzSuite.Point().Base(), nil))
+	require.Error(t, CheckUpdateChallenge(fuzzSuite, context, nil, authMsg.P0.T, servers[0]))
+}
@@ -1,11 +1,11 @@
 package daga
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"go.dedis.ch/kyber"
 	"go.dedis.ch/kyber/xof/blake2xb"
-	"strconv"
 )
 
 // create a context with c clients, len(serverKeys) servers whose private keys are in serverKeys, to be used in tests
@@ -65,7 +65,7 @@ func generateContext(suite Suite, c, s int, optServerKeys []kyber.Scalar) ([]Cli
 		clientKeys = append(clientKeys, client.PublicKey())
 		clients = append(clients, client)
 
-		generator, err := GenerateClientGenerator(suite, i, perRoundSecretCommits)
+		generator, err := GenerateClientGenerator(suite, CurrentGenerators, i, perRoundSecretCommits)
 		if err != nil {
 			return nil, nil, nil, errors.New("error while generating client's generators:\n" + err.Error())
 		}
@@ -75,32 +75,102 @@ func generateContext(suite Suite, c, s int, optServerKeys []kyber.Scalar) ([]Cli
 	if context, err := NewMinimumAuthenticationContext(clientKeys, serverKeys, perRoundSecretCommits, clientGenerators); err != nil {
 		return nil, nil, nil, errors.New("failed to create AuthenticationContext: " + err.Error())
 	} else {
+		context.SignatureScheme = DefaultSignatureScheme(suite)
 		return clients, servers, context, nil
 	}
 }
 
+// GeneratorVersion identifies the way the per-round client generators of a context are derived
+type GeneratorVersion int
+
+const (
+	// GeneratorsPick is the original derivation, a point picked using a blake2xb XOF seeded with the hash of index and R,
+	// only kept to be able to check the contexts created before GeneratorsHashToCurve
+	GeneratorsPick GeneratorVersion = 0
+	// GeneratorsHashToCurve derives the generators using the hash-to-curve of the suite (RFC 9380 style), see GenerateClientGenerator
+	GeneratorsHashToCurve GeneratorVersion = 1
+	// CurrentGenerators is the derivation used for the newly created contexts
+	CurrentGenerators = GeneratorsHashToCurve
+)
+
+// GeneratorVersionedContext is implemented by the AuthenticationContexts that specify how their client generators are derived,
+// the contexts that don't implement it use GeneratorsPick.
+type GeneratorVersionedContext interface {
+	AuthenticationContext
+	GeneratorDerivation() GeneratorVersion
+}
+
+// ContextGeneratorVersion returns the GeneratorVersion used to derive the client generators of context
+func ContextGeneratorVersion(context AuthenticationContext) GeneratorVersion {
+	if versioned, ok := context.(GeneratorVersionedContext); ok {
+		return versioned.GeneratorDerivation()
+	}
+	return GeneratorsPick
+}
+
+// VerifyClientGenerators recomputes the client generators of context and returns an error if they differ
+// from the generators in the context
+func VerifyClientGenerators(suite Suite, context AuthenticationContext) error {
+	if err := ValidateContext(context); err != nil {
+		return errors.New("VerifyClientGenerators: " + err.Error())
+	}
+	version := ContextGeneratorVersion(context)
+	for i, H := range context.ClientsGenerators() {
+		generator, err := GenerateClientGenerator(suite, version, i, context.ServersSecretsCommitments())
+		if err != nil {
+			return errors.New("VerifyClientGenerators: " + err.Error())
+		}
+		if H == nil || !H.Equal(generator) {
+			return fmt.Errorf("VerifyClientGenerators: wrong generator for client %d", i)
+		}
+	}
+	return nil
+}
+
 // GenerateClientGenerator generates a per-round generator for a given client
 //
+// version the derivation to use (see GeneratorVersion)
+//
 // commits are the commitments of the servers to their per-round secret
-func GenerateClientGenerator(suite Suite, index int, commits []kyber.Point) (gen kyber.Point, err error) {
+//
+// with GeneratorsHashToCurve the generator is hash_to_curve(I2OSP(index, 8), DST) using the hash-to-curve of the suite
+// and the context-specific DST built from the commitments (see clientGeneratorDST),
+// hence anyone can recompute and check it and no one knows its discrete log relation with the other generators.
+func GenerateClientGenerator(suite Suite, version GeneratorVersion, index int, commits []kyber.Point) (gen kyber.Point, err error) {
 	if index < 0 {
 		return nil, fmt.Errorf("GenerateClientGenerator: bad index: %d", index)
 	}
 	if len(commits) <= 0 {
 		return nil, fmt.Errorf("GenerateClientGenerator: bad commits:\n%v", commits)
 	}
-	hasher := suite.Hash()
-	hasher.Write([]byte(strconv.Itoa(index)))
-	if pointBytes, err := PointArrayToBytes(commits); err != nil {
-		return nil, err
-	} else {
-		hasher.Write(pointBytes)
+	switch version {
+	case GeneratorsHashToCurve:
+		dst, err := clientGeneratorDST(suite, commits)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateClientGenerator: %s", err)
+		}
+		var msg [8]byte
+		binary.BigEndian.PutUint64(msg[:], uint64(index))
+		if gen, err = suite.hashToCurve(msg[:], dst); err != nil {
+			return nil, fmt.Errorf("GenerateClientGenerator: %s", err)
+		}
+		return gen, nil
+	case GeneratorsPick:
+	default:
+		return nil, fmt.Errorf("GenerateClientGenerator: unsupported generator version: %d", version)
+	}
+	// GeneratorsPick: the generators are only computed (and checked) when a new context is created, hence always use the CurrentEncoding
+	hash, err := newTranscript(CurrentEncoding, transcriptClientGenerator).
+		appendInt("index", index).
+		appendPoints("R", commits).
+		Sum(suite.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("GenerateClientGenerator: %s", err)
 	}
-	hash := hasher.Sum(nil)
 
 	// generator s.t. no one knows the relation between it and g, the public base/generator
 	//
-	// uses a xof seeded with bytes = H(index||R) as arg to Pick() instead of UnmarshalBinary(bytes)
+	// uses a xof seeded with bytes = H(index||R) (canonical transcript encoding) as arg to Pick() instead of UnmarshalBinary(bytes)
 	// because seems  that not any 32byte value can be decoded into a point..
 	// (and hence seems that kyber don't use the definitions in rfc7748 and instead the curve is based on Ed25519 primitives and algos or a mix don't know)
 	// using the xof as a cipher stream we can try to pick multiple times until one point is valid
@@ -81,25 +81,70 @@ func TestGenerateClientGenerator(t *testing.T) {
 	for i := 0; i < rand.Intn(10)+1; i++ {
 		commits = append(commits, suite.Point().Mul(suite.Scalar().Pick(suite.RandomStream()), nil))
 	}
-	h, err := GenerateClientGenerator(suite, index, commits)
+	h, err := GenerateClientGenerator(suite, CurrentGenerators, index, commits)
 	if err != nil || h == nil {
 		t.Errorf("Cannot generate generator with index: %d", index)
 	}
-	h, err = GenerateClientGenerator(suite, 0, commits)
+	h, err = GenerateClientGenerator(suite, CurrentGenerators, 0, commits)
 	if err != nil || h == nil {
 		t.Error("Cannot generate generator with index 0")
 	}
 
 	//Test wrong execution of the function
 	neg := -rand.Int()
-	h, err = GenerateClientGenerator(suite, neg, commits)
+	h, err = GenerateClientGenerator(suite, CurrentGenerators, neg, commits)
 	if h != nil || err == nil {
 		t.Errorf("Error in handling negative index: %d", index)
 	}
 
-	h, err = GenerateClientGenerator(suite, index, []kyber.Point{})
+	h, err = GenerateClientGenerator(suite, CurrentGenerators, index, []kyber.Point{})
 	if h != nil || err == nil {
 		t.Errorf("Error in handling empty commits")
 	}
 
+	h, err = GenerateClientGenerator(suite, GeneratorVersion(42), index, commits)
+	if h != nil || err == nil {
+		t.Errorf("Error in handling unsupported version")
+	}
+
+}
+
+func TestVerifyClientGenerators(t *testing.T) {
+	for _, suite := range suites {
+		_, _, context, err := GenerateTestContext(suite, rand.Intn(10)+2, rand.Intn(10)+1)
+		if err != nil {
+			t.Fatal("Impossible to generate context")
+		}
+		if ContextGeneratorVersion(context) != CurrentGenerators {
+			t.Error("new contexts don't use the current generators derivation")
+		}
+		if err := VerifyClientGenerators(suite, context); err != nil {
+			t.Errorf("%s: valid generators rejected: %s", suite, err)
+		}
+
+		// generators of distinct clients and distinct contexts differ
+		H := context.ClientsGenerators()
+		if H[0].Equal(H[1]) {
+			t.Errorf("%s: same generator for 2 clients", suite)
+		}
+		_, _, other, _ := GenerateTestContext(suite, 2, 1)
+		if H[0].Equal(other.ClientsGenerators()[0]) {
+			t.Errorf("%s: same generator in 2 contexts", suite)
+		}
+
+		// generator picked by someone that might know its discrete log
+		tampered := *context.(*MinimumAuthenticationContext)
+		tampered.H = append([]kyber.Point{}, H...)
+		tampered.H[0] = suite.Point().Mul(suite.Scalar().Pick(suite.RandomStream()), nil)
+		if err := VerifyClientGenerators(suite, tampered); err == nil {
+			t.Errorf("%s: wrong generator accepted", suite)
+		}
+
+		// generators derived the other way
+		tampered.H[0] = H[0]
+		tampered.GeneratorVersion = GeneratorsPick
+		if err := VerifyClientGenerators(suite, tampered); err == nil {
+			t.Errorf("%s: generators accepted under another derivation", suite)
+		}
+	}
 }
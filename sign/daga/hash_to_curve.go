@@ -0,0 +1,219 @@
+package daga
+
+// This file contains the hash-to-curve functions (in the style of RFC 9380) used to derive the per-round client generators,
+// so that anyone can recompute the generators of a context and no one knows a discrete log relation between them.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+
+	"go.dedis.ch/kyber"
+)
+
+// expandMessageXMD implements expand_message_xmd of RFC 9380 section 5.3.1 using the hash function newHash,
+// returns lenInBytes uniformly random bytes derived from msg and the domain separation tag dst
+func expandMessageXMD(newHash func() hash.Hash, msg, dst []byte, lenInBytes int) ([]byte, error) {
+	h := newHash()
+	bInBytes := h.Size()
+	sInBytes := h.BlockSize()
+	ell := (lenInBytes + bInBytes - 1) / bInBytes
+	if ell > 255 || lenInBytes > 65535 || lenInBytes <= 0 {
+		return nil, fmt.Errorf("expandMessageXMD: invalid output length: %d", lenInBytes)
+	}
+	if len(dst) > 255 {
+		return nil, errors.New("expandMessageXMD: domain separation tag too long")
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	// b0 = H(Z_pad || msg || l_i_b_str || I2OSP(0, 1) || DST_prime)
+	h.Write(make([]byte, sInBytes))
+	h.Write(msg)
+	h.Write([]byte{byte(lenInBytes >> 8), byte(lenInBytes), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	// b1 = H(b0 || I2OSP(1, 1) || DST_prime), bi = H(strxor(b0, b(i-1)) || I2OSP(i, 1) || DST_prime)
+	uniformBytes := make([]byte, 0, ell*bInBytes)
+	bi := make([]byte, bInBytes)
+	for i := 1; i <= ell; i++ {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniformBytes = append(uniformBytes, bi...)
+	}
+	return uniformBytes[:lenInBytes], nil
+}
+
+// returns the domain separation tag used to hash to the curve of suite the client generators of the context whose
+// servers' per-round commitments are commits:
+//
+//	"DAGA-V01-CTX" || hex(SHA-256(transcript("daga/client-generator", R))[:16]) || "-with-" || hash-to-curve suite ID
+//
+// hence the generators of different contexts (and of different suites) are independent.
+func clientGeneratorDST(suite Suite, commits []kyber.Point) ([]byte, error) {
+	digest, err := newTranscript(EncodingV1, transcriptClientGenerator).
+		appendPoints("R", commits).
+		Sum(sha256.New())
+	if err != nil {
+		return nil, err
+	}
+	return []byte("DAGA-V01-CTX" + hex.EncodeToString(digest[:16]) + "-with-" + suite.hashToCurveID()), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// edwards25519_XMD:SHA-512_ELL2_RO_ (RFC 9380 section 6.7.1 and appendix D.1), implemented with math/big,
+// (the client generators are only computed when a context is created or checked, no need to be fast nor constant time)
+
+// hash-to-curve suite ID of suiteEC
+const edwards25519HashToCurveID = "edwards25519_XMD:SHA-512_ELL2_RO_"
+
+var (
+	// p = 2^255 - 19
+	ed25519FieldP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	// A coefficient of curve25519
+	curve25519A = big.NewInt(486662)
+	// sqrt(-486664) with sgn0 == 0, used by the rational map from curve25519 to edwards25519
+	ed25519SqrtMinusAPlus2 = func() *big.Int {
+		c := new(big.Int).ModSqrt(new(big.Int).Sub(ed25519FieldP, big.NewInt(486664)), ed25519FieldP)
+		if c.Bit(0) == 1 {
+			c.Sub(ed25519FieldP, c)
+		}
+		return c
+	}()
+)
+
+// hashToEdwards25519 implements hash_to_curve for the edwards25519_XMD:SHA-512_ELL2_RO_ suite,
+// newPoint returns new edwards25519 points and newHash the SHA-512 hash
+func hashToEdwards25519(newPoint func() kyber.Point, eight kyber.Scalar, newHash func() hash.Hash, msg, dst []byte) (kyber.Point, error) {
+	// hash_to_field, count = 2, L = 48
+	uniformBytes, err := expandMessageXMD(newHash, msg, dst, 2*48)
+	if err != nil {
+		return nil, err
+	}
+	R := newPoint().Null()
+	for i := 0; i < 2; i++ {
+		u := new(big.Int).SetBytes(uniformBytes[i*48 : (i+1)*48])
+		u.Mod(u, ed25519FieldP)
+		Q, err := mapToEdwards25519(newPoint(), u)
+		if err != nil {
+			return nil, err
+		}
+		R.Add(R, Q)
+	}
+	// clear_cofactor, h_eff = 8
+	return R.Mul(eight, R), nil
+}
+
+// maps the field element u to the point P of edwards25519 using Elligator 2 on curve25519 followed by the rational map
+func mapToEdwards25519(P kyber.Point, u *big.Int) (kyber.Point, error) {
+	p := ed25519FieldP
+	s, t := mapToCurve25519Elligator2(u)
+
+	// rational map: x = sqrt(-486664) * s / t, y = (s - 1) / (s + 1), (0, 1) on exceptional cases
+	x, y := new(big.Int), big.NewInt(1)
+	sPlusOne := new(big.Int).Add(s, big.NewInt(1))
+	sPlusOne.Mod(sPlusOne, p)
+	if t.Sign() != 0 && sPlusOne.Sign() != 0 {
+		x.Mul(ed25519SqrtMinusAPlus2, s)
+		x.Mul(x, new(big.Int).ModInverse(t, p))
+		x.Mod(x, p)
+		y.Sub(s, big.NewInt(1))
+		y.Mul(y, new(big.Int).ModInverse(sPlusOne, p))
+		y.Mod(y, p)
+	}
+
+	// RFC 8032 encoding, little-endian y with the sign of x in the most significant bit
+	buf := make([]byte, 32)
+	be := y.Bytes()
+	for i := range be {
+		buf[i] = be[len(be)-1-i]
+	}
+	buf[31] |= byte(x.Bit(0) << 7)
+	if err := P.UnmarshalBinary(buf); err != nil {
+		return nil, fmt.Errorf("mapToEdwards25519: unexpected error: %s", err)
+	}
+	return P, nil
+}
+
+// map_to_curve_elligator2 for curve25519 (J = 486662, K = 1, Z = 2), returns the montgomery coordinates (s, t)
+func mapToCurve25519Elligator2(u *big.Int) (s, t *big.Int) {
+	p := ed25519FieldP
+	g := func(x *big.Int) *big.Int { // x^3 + J * x^2 + x
+		gx := new(big.Int).Add(x, curve25519A)
+		gx.Mul(gx, x)
+		gx.Add(gx, big.NewInt(1))
+		gx.Mul(gx, x)
+		return gx.Mod(gx, p)
+	}
+
+	// x1 = -J * inv0(1 + Z * u^2), x1 = -J if x1 == 0
+	x1 := new(big.Int).Mul(u, u)
+	x1.Lsh(x1, 1)
+	x1.Add(x1, big.NewInt(1))
+	x1.Mod(x1, p)
+	if x1.Sign() != 0 {
+		x1.ModInverse(x1, p)
+	}
+	x1.Mul(x1, curve25519A)
+	x1.Neg(x1)
+	x1.Mod(x1, p)
+	if x1.Sign() == 0 {
+		x1.Sub(p, curve25519A)
+	}
+	gx1 := g(x1)
+
+	// x2 = -x1 - J
+	x2 := new(big.Int).Add(x1, curve25519A)
+	x2.Neg(x2)
+	x2.Mod(x2, p)
+
+	// x = x1, y = sqrt(gx1) with sgn0(y) == 1 if gx1 is square, else x = x2, y = sqrt(gx2) with sgn0(y) == 0
+	x, y, sgn := x1, new(big.Int).ModSqrt(gx1, p), uint(1)
+	if y == nil {
+		x, y, sgn = x2, new(big.Int).ModSqrt(g(x2), p), 0
+	}
+	if y.Bit(0) != sgn {
+		y.Sub(p, y)
+		y.Mod(y, p)
+	}
+	return x, y
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// ristretto255_XMD:SHA-512_R255MAP_RO_ (RFC 9380 appendix B)
+
+// hash-to-curve suite ID of suiteRistretto
+const ristretto255HashToCurveID = "ristretto255_XMD:SHA-512_R255MAP_RO_"
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// hash to the subgroup of order q of Zp* (not covered by RFC 9380), hash_to_field with L = PointLen + 16 then squaring,
+// since p = 2q+1 the squares modulo p are exactly the elements of the subgroup of order q.
+
+// hash-to-curve suite ID of suiteSchnorr
+const schnorrHashToCurveID = "MODP2048_XMD:SHA-256_SQR_RO_"
+
+// hashToSchnorrGroup hashes msg to an element (different from 1) of the subgroup of order q of the group g
+func hashToSchnorrGroup(g *schnorrGroup, msg, dst []byte) (kyber.Point, error) {
+	uniformBytes, err := expandMessageXMD(sha256.New, msg, dst, g.PointLen()+16)
+	if err != nil {
+		return nil, err
+	}
+	u := new(big.Int).SetBytes(uniformBytes)
+	u.Mod(u, g.P)
+	P := g.Point().(*schnorrPoint)
+	P.V.Exp(u, big.NewInt(2), g.P)
+	if P.V.Cmp(big.NewInt(1)) <= 0 {
+		// happens with negligible probability
+		return nil, errors.New("hashToSchnorrGroup: hashed to the identity")
+	}
+	return P, nil
+}
@@ -0,0 +1,169 @@
+package daga
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors of RFC 9380 appendix K.1 (expand_message_xmd, SHA-256)
+func TestExpandMessageXMD(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	for _, vector := range []struct {
+		msg, uniformBytes string
+	}{
+		{"", "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+	} {
+		expected, err := hex.DecodeString(vector.uniformBytes)
+		require.NoError(t, err)
+		uniformBytes, err := expandMessageXMD(sha256.New, []byte(vector.msg), dst, len(expected))
+		require.NoError(t, err)
+		require.Equal(t, expected, uniformBytes, "wrong output for msg \"%s\"", vector.msg)
+	}
+
+	long, err := expandMessageXMD(sha256.New, []byte("abc"), dst, 3*32+1)
+	require.NoError(t, err)
+	require.Len(t, long, 3*32+1)
+
+	_, err = expandMessageXMD(sha256.New, nil, dst, 0)
+	require.Error(t, err, "empty output accepted")
+	_, err = expandMessageXMD(sha256.New, nil, dst, 255*32+1)
+	require.Error(t, err, "too long output accepted")
+	_, err = expandMessageXMD(sha256.New, nil, make([]byte, 256), 32)
+	require.Error(t, err, "too long DST accepted")
+}
+
+// test vectors of RFC 9380 appendix K.3 (expand_message_xmd, SHA-512), the expander of the edwards25519 and ristretto255 suites
+func TestExpandMessageXMD_SHA512(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA512-256")
+	for _, vector := range []struct {
+		msg          string
+		uniformBytes string
+	}{
+		{"", "6b9a7312411d92f921c6f68ca0b6380730a1a4d982c507211a90964c394179ba"},
+		{"abc", "0da749f12fbe5483eb066a5f595055679b976e93abe9be6f0f6318bce7aca8dc"},
+		{"", "41b037d1734a5f8df225dd8c7de38f851efdb45c372887be655212d07251b921b052b62eaed99b46f72f2ef4cc96bfaf254ebbbec091e1a3b9e4fb5e5b619d2e0c5414800a1d882b62bb5cd1778f098b8eb6cb399d5d9d18f5d5842cf5d13d7eb00a7cff859b605da678b318bd0e65ebff70bec88c753b159a805d2c89c55961"},
+		{"abc", "7f1dddd13c08b543f2e2037b14cefb255b44c83cc397c1786d975653e36a6b11bdd7732d8b38adb4a0edc26a0cef4bb45217135456e58fbca1703cd6032cb1347ee720b87972d63fbf232587043ed2901bce7f22610c0419751c065922b488431851041310ad659e4b23520e1772ab29dcdeb2002222a363f0c2b1c972b3efe1"},
+	} {
+		expected, err := hex.DecodeString(vector.uniformBytes)
+		require.NoError(t, err)
+		uniformBytes, err := expandMessageXMD(sha512.New, []byte(vector.msg), dst, len(expected))
+		require.NoError(t, err)
+		require.Equal(t, expected, uniformBytes, "wrong output for msg \"%s\" (len %d)", vector.msg, len(expected))
+	}
+}
+
+// the messages of the hash_to_curve test vectors of RFC 9380 appendix J
+var hashToCurveMessages = []string{"", "abc", "abcdef0123456789", "q128_" + strings.Repeat("q", 128), "a512_" + strings.Repeat("a", 512)}
+
+// test vectors of RFC 9380 appendix J.5.1 (edwards25519_XMD:SHA-512_ELL2_RO_)
+func TestHashToCurve_Edwards25519(t *testing.T) {
+	suite := NewSuiteEC()
+	dst := []byte("QUUX-V01-CS02-with-edwards25519_XMD:SHA-512_ELL2_RO_")
+	// the affine coordinates (x, y) of P, big-endian
+	for i, P := range [][2]string{
+		{"3c3da6925a3c3c268448dcabb47ccde5439559d9599646a8260e47b1e4822fc6", "09a6c8561a0b22bef63124c588ce4c62ea83a3c899763af26d795302e115dc21"},
+		{"608040b42285cc0d72cbb3985c6b04c935370c7361f4b7fbdb1ae7f8c1a8ecad", "1a8395b88338f22e435bbd301183e7f20a5f9de643f11882fb237f88268a5531"},
+		{"6d7fabf47a2dc03fe7d47f7dddd21082c5fb8f86743cd020f3fb147d57161472", "53060a3d140e7fbcda641ed3cf42c88a75411e648a1add71217f70ea8ec561a6"},
+		{"5fb0b92acedd16f3bcb0ef83f5c7b7a9466b5f1e0d8d217421878ea3686f8524", "2eca15e355fcfa39d2982f67ddb0eea138e2994f5956ed37b7f72eea5e89d2f7"},
+		{"0efcfde5898a839b00997fbe40d2ebe950bc81181afbd5cd6b9618aa336c1e8c", "6dc2fc04f266c5c27f236a80b14f92ccd051ef1ff027f26a07f8c0f327d8f995"},
+	} {
+		x, ok := new(big.Int).SetString(P[0], 16)
+		require.True(t, ok)
+		y, ok := new(big.Int).SetString(P[1], 16)
+		require.True(t, ok)
+		// RFC 8032 encoding, little-endian y with the sign of x in the most significant bit
+		expected := make([]byte, 32)
+		be := y.Bytes()
+		for j := range be {
+			expected[j] = be[len(be)-1-j]
+		}
+		expected[31] |= byte(x.Bit(0) << 7)
+
+		hashed, err := suite.hashToCurve([]byte(hashToCurveMessages[i]), dst)
+		require.NoError(t, err)
+		buf, err := hashed.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, expected, buf, "wrong point for msg \"%s\"", hashToCurveMessages[i])
+	}
+}
+
+// hash_to_ristretto255 (ristretto255_XMD:SHA-512_R255MAP_RO_) is expand_message_xmd (see TestExpandMessageXMD_SHA512)
+// followed by the element derivation map of ristretto255, RFC 9380 has no test vectors for it
+func TestHashToCurve_Ristretto255(t *testing.T) {
+	suite := NewSuiteRistretto()
+
+	// test vectors of RFC 9496 appendix A.3, the map applied to the SHA-512 of the inputs
+	for _, vector := range []struct {
+		input, element string
+	}{
+		{"Ristretto is traditionally a short shot of espresso coffee", "3066f82a1a747d45120d1740f14358531a8f04bbffe6a819f86dfe50f44a0a46"},
+		{"made with the normal amount of ground coffee but extracted with", "f26e5b6f7d362d2d2a94c5d0e7602cb4773c95a2e5c31a64f133189fa76ed61b"},
+		{"about half the amount of water in the same amount of time", "006ccd2a9e6867e6a2c5cea83d3302cc9de128dd2a9a57dd8ee7b9d7ffe02826"},
+		{"by using a finer grind.", "f8f0c87cf237953c5890aec3998169005dae3eca1fbb04548c635953c817f92a"},
+		{"This produces a concentrated shot of coffee per volume.", "ae81e7dedf20a497e10c304a765c1767a42d6e06029758d2d7e8ef7cc4c41179"},
+		{"Just pulling a normal shot short will produce a weaker shot", "e2705652ff9f5e44d3e841bf1c251cf7dddb77d140870d1ab2ed64f1a9ce8628"},
+		{"and is not a Ristretto as some believe.", "80bd07262511cdde4863f8a7434cef696750681cb9510eea557088f76d9e5065"},
+	} {
+		uniformBytes := sha512.Sum512([]byte(vector.input))
+		P := suite.Point().(*ristrettoPoint)
+		P.e.FromUniformBytes(uniformBytes[:])
+		buf, err := P.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, vector.element, hex.EncodeToString(buf), "wrong element for input \"%s\"", vector.input)
+	}
+
+	// the messages of RFC 9380 appendix J with the DST of the suite, outputs of the independent implementation
+	// of github.com/cloudflare/circl (group.Ristretto255.HashToElement)
+	dst := []byte("QUUX-V01-CS02-with-ristretto255_XMD:SHA-512_R255MAP_RO_")
+	for i, element := range []string{
+		"bed61e1ee1966329962880e236dfdc83afd52fd1ce116f64fb806f1e8acea926",
+		"627b997b104ee62543358e22576c75a98dff9dc5f348d5ab228689735d77b258",
+		"90348aa2cced1007a4cd1b4cef9c1105d09a4b491766dad0de7f6ea39423ea32",
+		"a83367182a9928a7188576376291816ccab9e8293007401f3db8f1cbf1fc6934",
+		"eacd8dcc6376d75f11c2e8126385bfb9aecd91b8482b6226835c097a6b503d23",
+	} {
+		hashed, err := suite.hashToCurve([]byte(hashToCurveMessages[i]), dst)
+		require.NoError(t, err)
+		buf, err := hashed.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, element, hex.EncodeToString(buf), "wrong element for msg \"%s\"", hashToCurveMessages[i])
+	}
+}
+
+func TestSuites_HashToCurve(t *testing.T) {
+	msg := []byte("msg")
+	dst := []byte("DAGA-V01-TEST")
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			P, err := suite.hashToCurve(msg, dst)
+			require.NoError(t, err)
+			require.False(t, P.Equal(suite.Point().Null()), "hashed to the identity")
+
+			again, err := suite.hashToCurve(msg, dst)
+			require.NoError(t, err)
+			require.True(t, P.Equal(again), "hashToCurve not deterministic")
+			otherMsg, err := suite.hashToCurve([]byte("other msg"), dst)
+			require.NoError(t, err)
+			require.False(t, P.Equal(otherMsg))
+			otherDST, err := suite.hashToCurve(msg, []byte("other DST"))
+			require.NoError(t, err)
+			require.False(t, P.Equal(otherDST))
+
+			// the point is a valid element of the (prime order) group,
+			// 8 * (1/8 mod order) = 1 + k * order, hence P is unchanged iff P has no torsion component
+			buf, err := P.MarshalBinary()
+			require.NoError(t, err)
+			require.NoError(t, suite.Point().UnmarshalBinary(buf))
+			eight := suite.Scalar().SetInt64(8)
+			inv := suite.Scalar().Inv(eight)
+			require.True(t, suite.Point().Mul(eight, suite.Point().Mul(inv, P)).Equal(P), "point has a torsion component")
+		})
+	}
+}
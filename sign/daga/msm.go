@@ -0,0 +1,127 @@
+package daga
+
+// This file contains the multi-scalar multiplication used to verify the proofs, computing a sum of n scalar
+// multiplications with Straus' method (interleaved fixed windows, sharing the doublings among the n terms).
+
+import (
+	"go.dedis.ch/kyber"
+)
+
+// window size (in bits) of the multi-scalar multiplication, each point needs a table of 2^window - 1 multiples
+const msmWindow = 4
+
+// returns whether the scalars of suite are encoded in little-endian order (e.g. edwards25519) or big-endian order
+// (e.g. the Schnorr group)
+func scalarLittleEndian(suite Suite) bool {
+	buf, err := suite.Scalar().One().MarshalBinary()
+	return err == nil && len(buf) > 1 && buf[0] == 1
+}
+
+// multiScalarMul returns Σ scalars[i]*points[i], a nil point is the base point of the group.
+//
+// Straus' method: for each point the multiples 1*P..15*P are precomputed, then the scalars are processed 4 bits at a
+// time from the most significant bits, with 4 doublings of the accumulator (shared by all the terms) and one addition
+// per term and window, instead of one doubling and addition chain per term.
+func multiScalarMul(suite Suite, scalars []kyber.Scalar, points []kyber.Point) kyber.Point {
+	n := len(scalars)
+	acc := suite.Point().Null()
+	if n == 0 {
+		return acc
+	}
+	const tableSize = 1<<msmWindow - 1
+
+	// the digits (windows of msmWindow = 4 bits, i.e. nibbles) of the scalars, most significant first
+	littleEndian := scalarLittleEndian(suite)
+	digitsPerScalar := 2 * suite.ScalarLen()
+	digits := make([]byte, n*digitsPerScalar)
+	for i, s := range scalars {
+		if !scalarDigits(s, littleEndian, digits[i*digitsPerScalar:(i+1)*digitsPerScalar]) {
+			// cannot happen with a Scalar of suite, fallback to the plain scalar multiplication
+			return fallbackMultiScalarMul(suite, scalars, points)
+		}
+	}
+
+	// the tables of multiples of the points
+	table := make([]kyber.Point, n*tableSize)
+	for i, P := range points {
+		if P == nil {
+			P = suite.Point().Base()
+		}
+		t := table[i*tableSize : (i+1)*tableSize]
+		t[0] = P
+		for d := 1; d < tableSize; d++ {
+			t[d] = suite.Point().Add(t[d-1], P)
+		}
+	}
+
+	for pos := 0; pos < digitsPerScalar; pos++ {
+		if pos > 0 {
+			for k := 0; k < msmWindow; k++ {
+				acc.Add(acc, acc)
+			}
+		}
+		for i := 0; i < n; i++ {
+			if d := digits[i*digitsPerScalar+pos]; d != 0 {
+				acc.Add(acc, table[i*tableSize+int(d)-1])
+			}
+		}
+	}
+	return acc
+}
+
+// writes the 2*ScalarLen digits (nibbles) of s in d, most significant first, returns false if s can't be encoded
+func scalarDigits(s kyber.Scalar, littleEndian bool, d []byte) bool {
+	buf, err := s.MarshalBinary()
+	length := len(d) / 2
+	if err != nil || len(buf) != length {
+		return false
+	}
+	for j := 0; j < length; j++ {
+		b := buf[j]
+		if littleEndian {
+			b = buf[length-1-j]
+		}
+		d[2*j], d[2*j+1] = b>>4, b&0x0f
+	}
+	return true
+}
+
+// returns Σ scalars[i]*points[i] computed term by term
+func fallbackMultiScalarMul(suite Suite, scalars []kyber.Scalar, points []kyber.Point) kyber.Point {
+	acc := suite.Point().Null()
+	tmp := suite.Point()
+	for i, s := range scalars {
+		acc.Add(acc, tmp.Mul(s, points[i]))
+	}
+	return acc
+}
+
+// parallelMultiScalarMul returns multiScalarMul(suite, scalars, points) with the terms split among workers goroutines
+func parallelMultiScalarMul(suite Suite, workers int, scalars []kyber.Scalar, points []kyber.Point) kyber.Point {
+	n := len(scalars)
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		return multiScalarMul(suite, scalars, points)
+	}
+	chunk := (n + workers - 1) / workers
+	partial := make([]kyber.Point, workers)
+	parallelFor(workers, workers, func(w int) error {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > n {
+			hi = n
+		}
+		if lo >= hi {
+			partial[w] = suite.Point().Null()
+			return nil
+		}
+		partial[w] = multiScalarMul(suite, scalars[lo:hi], points[lo:hi])
+		return nil
+	})
+	acc := partial[0]
+	for _, P := range partial[1:] {
+		acc.Add(acc, P)
+	}
+	return acc
+}
@@ -0,0 +1,34 @@
+package daga
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestMultiScalarMul(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			for _, n := range []int{0, 1, 2, 7, 33} {
+				scalars := make([]kyber.Scalar, n)
+				points := make([]kyber.Point, n)
+				for i := range scalars {
+					scalars[i] = suite.Scalar().Pick(suite.RandomStream())
+					points[i] = suite.Point().Pick(suite.RandomStream())
+				}
+				if n > 2 {
+					// base point, zero and small scalars
+					points[0] = nil
+					scalars[1] = suite.Scalar().Zero()
+					scalars[2] = suite.Scalar().SetInt64(-1)
+				}
+				expected := fallbackMultiScalarMul(suite, scalars, points)
+				require.True(t, expected.Equal(multiScalarMul(suite, scalars, points)), "n: %d", n)
+				for _, workers := range []int{1, 2, 5, 64} {
+					require.True(t, expected.Equal(parallelMultiScalarMul(suite, workers, scalars, points)), "n: %d, workers: %d", n, workers)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package daga
+
+// This file contains the verification report of a server message, listing for each server whether its contribution
+// verified, so that a cheating or faulty server can be identified from a single failed authentication transcript.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServerVerification is the result of the verification of the contribution (tag, proof and signature) of one server
+// to a ServerMessage
+type ServerVerification struct {
+	// index of the server in the context
+	Index int
+	// whether the signature of the server on its contribution verified
+	SignatureValid bool
+	// whether the proof of the server verified
+	ProofValid bool
+	// whether the server flagged the client as misbehaving (the server gave a misbehaving proof, and the identity as tag)
+	FlaggedMisbehaving bool
+	// whether the contribution was not verified (see VerifyPrevious)
+	Skipped bool
+
+	// reason why the signature did not verify
+	signatureErr error
+}
+
+// Valid returns whether both the signature and the proof of the server verified
+func (v ServerVerification) Valid() bool {
+	return v.SignatureValid && v.ProofValid
+}
+
+// VerificationReport is the result of the verification of the contributions of the servers to a ServerMessage,
+// the contributions are all verified, even after a failure (except the skipped ones, see VerifyPrevious)
+type VerificationReport struct {
+	// the verifications of the servers, in the order they processed the request
+	Servers []ServerVerification
+	// position in Servers of the first server whose signature or proof did not verify (that broke the chain), -1 if none
+	FirstFailure int
+}
+
+// Valid returns whether all the contributions verified
+func (r *VerificationReport) Valid() bool {
+	return r.FirstFailure < 0
+}
+
+// FlaggedMisbehaving returns whether a server (whose contribution verified) flagged the client as misbehaving
+func (r *VerificationReport) FlaggedMisbehaving() bool {
+	for _, v := range r.Servers {
+		if v.Valid() && v.FlaggedMisbehaving {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the error corresponding to the first failure, nil if all the contributions verified
+func (r *VerificationReport) err() error {
+	if r.Valid() {
+		return nil
+	}
+	v := r.Servers[r.FirstFailure]
+	if !v.SignatureValid {
+		return &SignatureError{Signer: v.Index, Err: v.signatureErr}
+	}
+	return &ServerProofError{Index: v.Index}
+}
+
+func (r *VerificationReport) String() string {
+	var b strings.Builder
+	for i, v := range r.Servers {
+		if v.Skipped {
+			fmt.Fprintf(&b, "%d: server %d, skipped", i, v.Index)
+		} else {
+			fmt.Fprintf(&b, "%d: server %d, signature valid: %t, proof valid: %t, flagged client: %t", i, v.Index, v.SignatureValid, v.ProofValid, v.FlaggedMisbehaving)
+		}
+		if i == r.FirstFailure {
+			b.WriteString(" <- first failure")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// BlameError is returned by ServerProtocol and GetFinalLinkageTag when the contribution of a server did not verify,
+// Report tells which server(s) to blame. it unwraps to a SignatureError or a ServerProofError for the first failure.
+type BlameError struct {
+	Report *VerificationReport
+}
+
+func (e *BlameError) Error() string {
+	v := e.Report.Servers[e.Report.FirstFailure]
+	return fmt.Sprintf("server %d (position %d) broke the chain: %s", v.Index, e.Report.FirstFailure, e.Report.err())
+}
+
+// Unwrap returns the error corresponding to the first failure
+func (e *BlameError) Unwrap() error {
+	return e.Report.err()
+}
+
+// VerifyServerMessage verifies the signatures and proofs of all the servers that processed the request
+// (see ServerProtocol) and returns a report of the verifications,
+// the error is non nil only if the message is malformed and cannot be verified at all.
+func VerifyServerMessage(suite Suite, context AuthenticationContext, msg ServerMessage) (*VerificationReport, error) {
+	if err := prepareServerMessage(suite, context, &msg); err != nil {
+		return nil, err
+	}
+	report, _, err := verifyServerMessage(suite, context, msg, 0)
+	return report, err
+}
+
+// validates msg before the verification of the contributions of the servers and expands the proof of its request
+func prepareServerMessage(suite Suite, context AuthenticationContext, msg *ServerMessage) error {
+	if context == nil || len(msg.Tags) != len(msg.Proofs) || len(msg.Proofs) != len(msg.Sigs) || len(msg.Sigs) != len(msg.Indexes) {
+		return newError(ErrMalformedMessage, nil, "VerifyServerMessage: invalid inputs")
+	}
+	if err := validateServerMessage(suite, *msg); err != nil {
+		return newError(nil, err, "VerifyServerMessage: invalid inputs: %s", err)
+	}
+	// the contributions are verified under context, and were validated under the context of the request
+	if err := ValidateContext(context); err != nil {
+		return newError(nil, err, "VerifyServerMessage: %s", err)
+	}
+	if len(context.Members().Y) != len(msg.Request.C.Members().Y) {
+		return newError(ErrInvalidContext, nil, "VerifyServerMessage: request not issued under context")
+	}
+	// the servers signed the full proof of the request
+	if err := ExpandClientProof(suite, &msg.Request); err != nil {
+		return newError(nil, err, "VerifyServerMessage: invalid inputs: %s", err)
+	}
+	return nil
+}
+
+// verifies the contributions of the servers at position from and after in the (validated) msg,
+// returns the report and the transcript of the data signed by the servers, completed with all their contributions
+// (see appendServerContribution)
+func verifyServerMessage(suite Suite, context AuthenticationContext, msg ServerMessage, from int) (*VerificationReport, *transcript, error) {
+	return verifyServerMessageWith(suite, context, msg, from, verifyServerProof)
+}
+
+// verifyServerMessage with the (non misbehaving) server proofs verified by verifyProof (see VerifyServerMessages)
+func verifyServerMessageWith(suite Suite, context AuthenticationContext, msg ServerMessage, from int,
+	verifyProof func(suite Suite, context AuthenticationContext, i int, msg *ServerMessage) bool) (*VerificationReport, *transcript, error) {
+	signData, err := newServerMessageTranscript(msg.Request)
+	if err != nil {
+		return nil, nil, newError(ErrMalformedMessage, err, "VerifyServerMessage: error in request: %s", err)
+	}
+	version := ContextEncoding(context)
+	members := context.Members()
+	scheme := ContextSignatureScheme(context)
+	report := &VerificationReport{Servers: make([]ServerVerification, len(msg.Proofs)), FirstFailure: -1}
+	for i, p := range msg.Proofs {
+		v := ServerVerification{Index: msg.Indexes[i]}
+
+		// the signature must be the one of the server that contributed
+		data, err := appendServerContribution(signData, msg.Tags[i], p, msg.Indexes[i])
+		if err != nil {
+			return nil, nil, newError(ErrMalformedMessage, err, "VerifyServerMessage: %s", err)
+		}
+		if i < from {
+			v.Skipped = true
+			report.Servers[i] = v
+			continue
+		}
+		if msg.Sigs[i].Index != msg.Indexes[i] {
+			v.signatureErr = fmt.Errorf("signature of server %d instead of %d", msg.Sigs[i].Index, msg.Indexes[i])
+		} else {
+			v.signatureErr = scheme.Verify(suite, members.Y[msg.Indexes[i]], data, msg.Sigs[i].Sig)
+		}
+		v.SignatureValid = v.signatureErr == nil
+
+		if p.R2 == nil {
+			v.FlaggedMisbehaving = msg.Tags[i].Equal(suite.Point().Null())
+			v.ProofValid = v.FlaggedMisbehaving && verifyMisbehavingProof(suite, version, members.Y[msg.Indexes[i]], &p, msg.Request.SCommits[0])
+		} else {
+			v.ProofValid = verifyProof(suite, context, i, &msg)
+		}
+
+		if !v.Valid() && report.Valid() {
+			report.FirstFailure = i
+		}
+		report.Servers[i] = v
+	}
+	return report, signData, nil
+}
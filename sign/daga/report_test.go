@@ -0,0 +1,117 @@
+package daga
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestVerifyServerMessage(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 2, 4)
+			require.NoError(t, err)
+			authMsg, err := NewAuthenticationMessage(suite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+			servMsg, err := InitializeServerMessage(authMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, ServerProtocol(suite, servMsg, server))
+			}
+
+			//Normal execution
+			report, err := VerifyServerMessage(suite, context, *servMsg)
+			require.NoError(t, err)
+			require.True(t, report.Valid())
+			require.Equal(t, -1, report.FirstFailure)
+			require.False(t, report.FlaggedMisbehaving())
+			require.Len(t, report.Servers, len(servers))
+			for i, v := range report.Servers {
+				require.Equal(t, servMsg.Indexes[i], v.Index)
+				require.True(t, v.SignatureValid && v.ProofValid && !v.FlaggedMisbehaving)
+			}
+
+			// malformed message
+			_, err = VerifyServerMessage(suite, context, ServerMessage{Request: *authMsg, Tags: servMsg.Tags})
+			require.Error(t, err)
+
+			// server at position 1 tampers with its tag => its proof and the proof of the next server don't verify,
+			// (and the signatures of all the servers from position 1 on, since they cover the tag)
+			tampered := *servMsg
+			tampered.Tags = append([]kyber.Point{}, servMsg.Tags...)
+			tampered.Tags[1] = suite.Point().Pick(suite.RandomStream())
+			report, err = VerifyServerMessage(suite, context, tampered)
+			require.NoError(t, err)
+			require.False(t, report.Valid())
+			require.Equal(t, 1, report.FirstFailure)
+			require.True(t, report.Servers[0].Valid())
+			require.False(t, report.Servers[1].ProofValid)
+			require.False(t, report.Servers[1].SignatureValid)
+			require.False(t, report.Servers[2].ProofValid)
+			_, err = GetFinalLinkageTag(suite, context, tampered)
+			var blame *BlameError
+			require.True(t, errors.As(err, &blame), "wrong error: %s", err)
+			require.Equal(t, 1, blame.Report.FirstFailure)
+
+			// signatures of other servers
+			tampered = *servMsg
+			tampered.Sigs = append([]ServerSignature{}, servMsg.Sigs...)
+			tampered.Sigs[2], tampered.Sigs[3] = tampered.Sigs[3], tampered.Sigs[2]
+			report, err = VerifyServerMessage(suite, context, tampered)
+			require.NoError(t, err)
+			require.Equal(t, 2, report.FirstFailure)
+			for _, i := range []int{2, 3} {
+				require.False(t, report.Servers[i].SignatureValid)
+				require.True(t, report.Servers[i].ProofValid)
+			}
+		})
+	}
+}
+
+func TestVerifyServerMessage_MisbehavingClient(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			// client sends a bad commitment for server 1 (and a valid proof)
+			members := context.Members()
+			tagAndCommitments, s, err := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
+			require.NoError(t, err)
+			tagAndCommitments.SCommits[3] = suite.Point().Null()
+			proof, err := newClientProof(suite, context, clients[0], *tagAndCommitments, s, func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+			authMsg := &AuthenticationMessage{
+				C:                        context,
+				initialTagAndCommitments: *tagAndCommitments,
+				P0:                       proof,
+			}
+			servMsg, err := InitializeServerMessage(authMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, ServerProtocol(suite, servMsg, server))
+			}
+
+			report, err := VerifyServerMessage(suite, context, *servMsg)
+			require.NoError(t, err)
+			require.True(t, report.Valid(), "%s", report)
+			require.True(t, report.FlaggedMisbehaving())
+			require.False(t, report.Servers[0].FlaggedMisbehaving)
+			require.True(t, report.Servers[1].FlaggedMisbehaving)
+
+			// a server cannot hide behind a misbehaving proof while giving a tag
+			tampered := *servMsg
+			tampered.Tags = append([]kyber.Point{}, servMsg.Tags...)
+			tampered.Tags[1] = suite.Point().Pick(suite.RandomStream())
+			report, err = VerifyServerMessage(suite, context, tampered)
+			require.NoError(t, err)
+			require.Equal(t, 1, report.FirstFailure)
+			require.False(t, report.Servers[1].ProofValid)
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package daga
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/gtank/ristretto255"
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/util/random"
+)
+
+// ristrettoGroup is a kyber.Group implementation of the prime order group ristretto255
+// (see https://ristretto.group and draft-irtf-cfrg-ristretto255-decaf448), built on top of gtank/ristretto255.
+//
+// every valid encoding decodes to an element of the prime order group, hence, contrary to edwards25519,
+// received points never have a torsion component and secrets don't need to be clamped.
+type ristrettoGroup struct{}
+
+// order of the ristretto255 group, 2^252 + 27742317777372353535851937790883648493
+var ristrettoOrder, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+func (g ristrettoGroup) String() string {
+	return "Ristretto255"
+}
+
+// ScalarLen returns the max length of Scalars in bytes
+func (g ristrettoGroup) ScalarLen() int {
+	return 32
+}
+
+// Scalar creates a new Scalar, initialized to zero
+func (g ristrettoGroup) Scalar() kyber.Scalar {
+	return &ristrettoScalar{s: *ristretto255.NewScalar()}
+}
+
+// PointLen returns the max length of Points in bytes
+func (g ristrettoGroup) PointLen() int {
+	return 32
+}
+
+// Point creates a new Point, initialized to the identity element
+func (g ristrettoGroup) Point() kyber.Point {
+	return &ristrettoPoint{e: *ristretto255.NewElement()}
+}
+
+// ristrettoScalar is a kyber.Scalar adapter around a ristretto255.Scalar (an integer modulo the group order)
+type ristrettoScalar struct {
+	s ristretto255.Scalar
+}
+
+func (s *ristrettoScalar) String() string {
+	return hex.EncodeToString(s.bytes())
+}
+
+// Equal tests equality of two Scalars
+func (s *ristrettoScalar) Equal(s2 kyber.Scalar) bool {
+	return s.s.Equal(&s2.(*ristrettoScalar).s) == 1
+}
+
+// Set sets s equal to s2
+func (s *ristrettoScalar) Set(s2 kyber.Scalar) kyber.Scalar {
+	s.s = s2.(*ristrettoScalar).s
+	return s
+}
+
+// Clone creates a new Scalar with the same value
+func (s *ristrettoScalar) Clone() kyber.Scalar {
+	return &ristrettoScalar{s: s.s}
+}
+
+// SetInt64 sets s to the small integer value v (reduced modulo the group order)
+func (s *ristrettoScalar) SetInt64(v int64) kyber.Scalar {
+	return s.setBig(big.NewInt(v))
+}
+
+// Zero sets s to the additive identity (0)
+func (s *ristrettoScalar) Zero() kyber.Scalar {
+	s.s.Zero()
+	return s
+}
+
+// One sets s to the multiplicative identity (1)
+func (s *ristrettoScalar) One() kyber.Scalar {
+	return s.SetInt64(1)
+}
+
+// Add sets s to a + b
+func (s *ristrettoScalar) Add(a, b kyber.Scalar) kyber.Scalar {
+	s.s.Add(&a.(*ristrettoScalar).s, &b.(*ristrettoScalar).s)
+	return s
+}
+
+// Sub sets s to a - b
+func (s *ristrettoScalar) Sub(a, b kyber.Scalar) kyber.Scalar {
+	s.s.Subtract(&a.(*ristrettoScalar).s, &b.(*ristrettoScalar).s)
+	return s
+}
+
+// Neg sets s to -a
+func (s *ristrettoScalar) Neg(a kyber.Scalar) kyber.Scalar {
+	s.s.Negate(&a.(*ristrettoScalar).s)
+	return s
+}
+
+// Mul sets s to a * b
+func (s *ristrettoScalar) Mul(a, b kyber.Scalar) kyber.Scalar {
+	s.s.Multiply(&a.(*ristrettoScalar).s, &b.(*ristrettoScalar).s)
+	return s
+}
+
+// Div sets s to a * 1/b
+func (s *ristrettoScalar) Div(a, b kyber.Scalar) kyber.Scalar {
+	inv := ristretto255.NewScalar().Invert(&b.(*ristrettoScalar).s)
+	s.s.Multiply(&a.(*ristrettoScalar).s, inv)
+	return s
+}
+
+// Inv sets s to the modular inverse of a
+func (s *ristrettoScalar) Inv(a kyber.Scalar) kyber.Scalar {
+	s.s.Invert(&a.(*ristrettoScalar).s)
+	return s
+}
+
+// Pick sets s to a uniformly random scalar, (wide reduction of 64 random bytes)
+func (s *ristrettoScalar) Pick(rand cipher.Stream) kyber.Scalar {
+	s.s.FromUniformBytes(random.Bits(512, false, rand))
+	return s
+}
+
+// SetBytes sets s to the little-endian integer encoded in b, reduced modulo the group order
+// (same convention than the edwards25519 scalars)
+func (s *ristrettoScalar) SetBytes(b []byte) kyber.Scalar {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return s.setBig(new(big.Int).SetBytes(be))
+}
+
+// MarshalSize returns the length of the binary encoding of a scalar
+func (s *ristrettoScalar) MarshalSize() int {
+	return 32
+}
+
+// MarshalBinary returns the canonical 32 bytes little-endian encoding of s
+func (s *ristrettoScalar) MarshalBinary() ([]byte, error) {
+	return s.bytes(), nil
+}
+
+// UnmarshalBinary decodes s from buf, non canonical encodings are rejected
+func (s *ristrettoScalar) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 32 {
+		return errors.New("ristrettoScalar.UnmarshalBinary: wrong size buffer")
+	}
+	return s.s.Decode(buf)
+}
+
+// MarshalTo writes the binary encoding of s to w
+func (s *ristrettoScalar) MarshalTo(w io.Writer) (int, error) {
+	return w.Write(s.bytes())
+}
+
+// UnmarshalFrom reads the binary encoding of a scalar from r
+func (s *ristrettoScalar) UnmarshalFrom(r io.Reader) (int, error) {
+	buf := make([]byte, 32)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return n, err
+	}
+	return n, s.UnmarshalBinary(buf)
+}
+
+// returns the canonical encoding of s
+func (s *ristrettoScalar) bytes() []byte {
+	return s.s.Encode(nil)
+}
+
+// sets s to v modulo the group order
+func (s *ristrettoScalar) setBig(v *big.Int) kyber.Scalar {
+	v = new(big.Int).Mod(v, ristrettoOrder)
+	be := v.Bytes()
+	le := make([]byte, 32)
+	for i := range be {
+		le[i] = be[len(be)-1-i]
+	}
+	if err := s.s.Decode(le); err != nil {
+		panic("ristrettoScalar: failed to decode reduced scalar: " + err.Error())
+	}
+	return s
+}
+
+// ristrettoPoint is a kyber.Point adapter around a ristretto255.Element
+type ristrettoPoint struct {
+	e ristretto255.Element
+}
+
+func (P *ristrettoPoint) String() string {
+	return hex.EncodeToString(P.bytes())
+}
+
+// Equal tests equality of two Points
+func (P *ristrettoPoint) Equal(P2 kyber.Point) bool {
+	return P.e.Equal(&P2.(*ristrettoPoint).e) == 1
+}
+
+// Null sets P to the identity element
+func (P *ristrettoPoint) Null() kyber.Point {
+	P.e.Zero()
+	return P
+}
+
+// Base sets P to the standard base point
+func (P *ristrettoPoint) Base() kyber.Point {
+	P.e.Base()
+	return P
+}
+
+// Pick sets P to a uniformly random element, (hash to group of 64 random bytes)
+func (P *ristrettoPoint) Pick(rand cipher.Stream) kyber.Point {
+	P.e.FromUniformBytes(random.Bits(512, false, rand))
+	return P
+}
+
+// Set sets P equal to P2
+func (P *ristrettoPoint) Set(P2 kyber.Point) kyber.Point {
+	P.e = P2.(*ristrettoPoint).e
+	return P
+}
+
+// Clone creates a new Point with the same value
+func (P *ristrettoPoint) Clone() kyber.Point {
+	return &ristrettoPoint{e: P.e}
+}
+
+// EmbedLen returns 0, embedding data is not supported in ristretto255
+func (P *ristrettoPoint) EmbedLen() int {
+	return 0
+}
+
+// Embed sets P to a random point when data is empty (as Pick does) and panics otherwise,
+// embedding data is not supported in ristretto255 (see EmbedLen)
+func (P *ristrettoPoint) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	if len(data) > 0 {
+		panic("ristrettoPoint.Embed: embedding data is not supported")
+	}
+	return P.Pick(rand)
+}
+
+// Data always returns an error, embedding data is not supported in ristretto255
+func (P *ristrettoPoint) Data() ([]byte, error) {
+	return nil, errors.New("ristrettoPoint.Data: embedding data is not supported")
+}
+
+// Add sets P to P1 + P2
+func (P *ristrettoPoint) Add(P1, P2 kyber.Point) kyber.Point {
+	P.e.Add(&P1.(*ristrettoPoint).e, &P2.(*ristrettoPoint).e)
+	return P
+}
+
+// Sub sets P to P1 - P2
+func (P *ristrettoPoint) Sub(P1, P2 kyber.Point) kyber.Point {
+	P.e.Subtract(&P1.(*ristrettoPoint).e, &P2.(*ristrettoPoint).e)
+	return P
+}
+
+// Neg sets P to -P2
+func (P *ristrettoPoint) Neg(P2 kyber.Point) kyber.Point {
+	P.e.Negate(&P2.(*ristrettoPoint).e)
+	return P
+}
+
+// Mul sets P to s * P2, if P2 is nil the base point is used
+func (P *ristrettoPoint) Mul(s kyber.Scalar, P2 kyber.Point) kyber.Point {
+	if P2 == nil {
+		P.e.ScalarBaseMult(&s.(*ristrettoScalar).s)
+	} else {
+		P.e.ScalarMult(&s.(*ristrettoScalar).s, &P2.(*ristrettoPoint).e)
+	}
+	return P
+}
+
+// MarshalSize returns the length of the binary encoding of a point
+func (P *ristrettoPoint) MarshalSize() int {
+	return 32
+}
+
+// MarshalBinary returns the canonical 32 bytes encoding of P
+func (P *ristrettoPoint) MarshalBinary() ([]byte, error) {
+	return P.bytes(), nil
+}
+
+// UnmarshalBinary decodes P from buf, non canonical encodings are rejected
+func (P *ristrettoPoint) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 32 {
+		return errors.New("ristrettoPoint.UnmarshalBinary: wrong size buffer")
+	}
+	return P.e.Decode(buf)
+}
+
+// MarshalTo writes the binary encoding of P to w
+func (P *ristrettoPoint) MarshalTo(w io.Writer) (int, error) {
+	return w.Write(P.bytes())
+}
+
+// UnmarshalFrom reads the binary encoding of a point from r
+func (P *ristrettoPoint) UnmarshalFrom(r io.Reader) (int, error) {
+	buf := make([]byte, 32)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return n, err
+	}
+	return n, P.UnmarshalBinary(buf)
+}
+
+// returns the canonical encoding of P
+func (P *ristrettoPoint) bytes() []byte {
+	return P.e.Encode(nil)
+}
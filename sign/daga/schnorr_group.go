@@ -0,0 +1,282 @@
+package daga
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/group/mod"
+	"go.dedis.ch/kyber/util/random"
+)
+
+// 2048-bit MODP safe prime p = 2q+1 from RFC 3526 (group 14)
+const rfc3526Modp2048 = "" +
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+// schnorrGroup is a kyber.Group implementation of the Schnorr group used in the DAGA paper,
+// that is the subgroup of prime order q of the multiplicative group Zp*, where p = 2q+1 is a safe prime.
+// (the subgroup of order q is then the subgroup of the quadratic residues modulo p)
+// Points are elements of the subgroup and Scalars are mod.Int modulo q.
+type schnorrGroup struct {
+	name string
+	P    *big.Int // safe prime modulus
+	Q    *big.Int // prime order of the subgroup, (p-1)/2
+	G    *big.Int // generator of the subgroup
+}
+
+// returns a new schnorrGroup built on top of the safe prime p and the generator g,
+// the parameters are checked to be consistent (but p is not tested for primality)
+func newSchnorrGroup(name string, p, g *big.Int) (*schnorrGroup, error) {
+	if p.Sign() <= 0 || p.Bit(0) != 1 {
+		return nil, errors.New("newSchnorrGroup: invalid modulus")
+	}
+	q := new(big.Int).Rsh(p, 1)
+	group := &schnorrGroup{
+		name: name,
+		P:    p,
+		Q:    q,
+		G:    g,
+	}
+	if g.Cmp(big.NewInt(1)) <= 0 || !group.inSubgroup(g) {
+		return nil, errors.New("newSchnorrGroup: generator not in the subgroup of order q")
+	}
+	return group, nil
+}
+
+// returns the Schnorr group built on the RFC 3526 2048-bit MODP safe prime, with generator 2
+// (since p = 7 mod 8, 2 is a quadratic residue, hence generates the subgroup of order q)
+func newSchnorrGroupRFC3526() *schnorrGroup {
+	p, _ := new(big.Int).SetString(rfc3526Modp2048, 16)
+	group, err := newSchnorrGroup("Schnorr-RFC3526-2048", p, big.NewInt(2))
+	if err != nil {
+		panic("newSchnorrGroupRFC3526: " + err.Error())
+	}
+	return group
+}
+
+// returns whether v is an element of the subgroup of order q, i.e. 0 < v < p and v^q = 1 mod p
+func (g *schnorrGroup) inSubgroup(v *big.Int) bool {
+	if v.Sign() <= 0 || v.Cmp(g.P) >= 0 {
+		return false
+	}
+	return new(big.Int).Exp(v, g.Q, g.P).Cmp(big.NewInt(1)) == 0
+}
+
+func (g *schnorrGroup) String() string {
+	return g.name
+}
+
+// ScalarLen returns the max length of Scalars in bytes
+func (g *schnorrGroup) ScalarLen() int {
+	return (g.Q.BitLen() + 7) / 8
+}
+
+// Scalar creates a new Scalar (a mod.Int modulo q)
+func (g *schnorrGroup) Scalar() kyber.Scalar {
+	return mod.NewInt64(0, g.Q)
+}
+
+// PointLen returns the max length of Points in bytes
+func (g *schnorrGroup) PointLen() int {
+	return (g.P.BitLen() + 7) / 8
+}
+
+// Point creates a new Point, initialized to the identity element
+func (g *schnorrGroup) Point() kyber.Point {
+	return &schnorrPoint{V: *big.NewInt(1), g: g}
+}
+
+// schnorrPoint is an element of a schnorrGroup
+type schnorrPoint struct {
+	V big.Int
+	g *schnorrGroup
+}
+
+func (P *schnorrPoint) String() string {
+	return P.V.String()
+}
+
+// Equal tests equality of two Points
+func (P *schnorrPoint) Equal(P2 kyber.Point) bool {
+	return P.V.Cmp(&P2.(*schnorrPoint).V) == 0
+}
+
+// Null sets P to the identity element (1)
+func (P *schnorrPoint) Null() kyber.Point {
+	P.V.SetInt64(1)
+	return P
+}
+
+// Base sets P to the generator of the group
+func (P *schnorrPoint) Base() kyber.Point {
+	P.V.Set(P.g.G)
+	return P
+}
+
+// Pick sets P to a random element of the subgroup, by squaring a random element of Zp*
+func (P *schnorrPoint) Pick(rand cipher.Stream) kyber.Point {
+	for {
+		P.V.Exp(random.Int(P.g.P, rand), big.NewInt(2), P.g.P)
+		if P.V.Cmp(big.NewInt(1)) > 0 {
+			return P
+		}
+	}
+}
+
+// Set sets P equal to P2
+func (P *schnorrPoint) Set(P2 kyber.Point) kyber.Point {
+	P.g = P2.(*schnorrPoint).g
+	P.V.Set(&P2.(*schnorrPoint).V)
+	return P
+}
+
+// Clone creates a new Point with the same value
+func (P *schnorrPoint) Clone() kyber.Point {
+	P2 := &schnorrPoint{g: P.g}
+	P2.V.Set(&P.V)
+	return P2
+}
+
+// EmbedLen returns the maximum number of bytes that can be embedded in a single point,
+// (reserves the 8 most-significant bits for randomness and the 8 least-significant bits for the data length)
+func (P *schnorrPoint) EmbedLen() int {
+	return (P.g.P.BitLen() - 8 - 8) / 8
+}
+
+// Embed sets P to a point containing (a prefix of) data, if data is nil P is set to a random element
+func (P *schnorrPoint) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	l := P.g.PointLen()
+	dl := P.EmbedLen()
+	if dl > len(data) {
+		dl = len(data)
+	}
+	for {
+		b := random.Bits(uint(P.g.P.BitLen()), false, rand)
+		if data != nil {
+			b[l-1] = byte(dl)
+			copy(b[l-dl-1:l-1], data)
+		}
+		P.V.SetBytes(b)
+		if P.g.inSubgroup(&P.V) {
+			return P
+		}
+	}
+}
+
+// Data extracts the data embedded in P
+func (P *schnorrPoint) Data() ([]byte, error) {
+	b := P.bytes()
+	l := len(b)
+	dl := int(b[l-1])
+	if dl > P.EmbedLen() {
+		return nil, errors.New("invalid embedded data length")
+	}
+	return b[l-dl-1 : l-1], nil
+}
+
+// Add sets P to the product of P1 and P2 (the group operation)
+func (P *schnorrPoint) Add(P1, P2 kyber.Point) kyber.Point {
+	P.V.Mul(&P1.(*schnorrPoint).V, &P2.(*schnorrPoint).V)
+	P.V.Mod(&P.V, P.g.P)
+	return P
+}
+
+// Sub sets P to the quotient of P1 by P2
+func (P *schnorrPoint) Sub(P1, P2 kyber.Point) kyber.Point {
+	inv := new(big.Int).ModInverse(&P2.(*schnorrPoint).V, P.g.P)
+	P.V.Mul(&P1.(*schnorrPoint).V, inv)
+	P.V.Mod(&P.V, P.g.P)
+	return P
+}
+
+// Neg sets P to the inverse of P2
+func (P *schnorrPoint) Neg(P2 kyber.Point) kyber.Point {
+	P.V.ModInverse(&P2.(*schnorrPoint).V, P.g.P)
+	return P
+}
+
+// Mul sets P to P2 raised to the power s, if P2 is nil the generator is used
+func (P *schnorrPoint) Mul(s kyber.Scalar, P2 kyber.Point) kyber.Point {
+	base := P.g.G
+	if P2 != nil {
+		base = &P2.(*schnorrPoint).V
+	}
+	P.V.Exp(base, scalarToBig(s), P.g.P)
+	return P
+}
+
+// MarshalSize returns the length of the binary encoding of a point
+func (P *schnorrPoint) MarshalSize() int {
+	return P.g.PointLen()
+}
+
+// MarshalBinary encodes P as a fixed-length big-endian byte slice
+func (P *schnorrPoint) MarshalBinary() ([]byte, error) {
+	return P.bytes(), nil
+}
+
+// UnmarshalBinary decodes P from buf, the decoded value must be an element of the subgroup
+func (P *schnorrPoint) UnmarshalBinary(buf []byte) error {
+	if len(buf) != P.MarshalSize() {
+		return errors.New("schnorrPoint.UnmarshalBinary: wrong size buffer")
+	}
+	v := new(big.Int).SetBytes(buf)
+	if !P.g.inSubgroup(v) {
+		return errors.New("schnorrPoint.UnmarshalBinary: invalid point, not in the subgroup of order q")
+	}
+	P.V.Set(v)
+	return nil
+}
+
+// MarshalTo writes the binary encoding of P to w
+func (P *schnorrPoint) MarshalTo(w io.Writer) (int, error) {
+	buf, err := P.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(buf)
+}
+
+// UnmarshalFrom reads the binary encoding of a point from r
+func (P *schnorrPoint) UnmarshalFrom(r io.Reader) (int, error) {
+	buf := make([]byte, P.MarshalSize())
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return n, err
+	}
+	return n, P.UnmarshalBinary(buf)
+}
+
+// returns the fixed-length big-endian encoding of P
+func (P *schnorrPoint) bytes() []byte {
+	buf := make([]byte, P.g.PointLen())
+	b := P.V.Bytes()
+	copy(buf[len(buf)-len(b):], b)
+	return buf
+}
+
+// returns the value of the scalar s as a big.Int
+func scalarToBig(s kyber.Scalar) *big.Int {
+	if i, ok := s.(*mod.Int); ok {
+		return &i.V
+	}
+	// should not happen, scalars of the schnorrGroup are mod.Int, but be liberal in what we accept
+	buf, err := s.MarshalBinary()
+	if err != nil {
+		panic(fmt.Sprintf("scalarToBig: failed to marshal scalar: %s", err))
+	}
+	return new(big.Int).SetBytes(buf)
+}
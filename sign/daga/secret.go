@@ -0,0 +1,113 @@
+package daga
+
+// This file contains the container of the private scalars of DAGA (private keys, per-round secrets, ephemeral secrets
+// of the client and of the proofs, openings of the challenge commitments).
+
+import (
+	"errors"
+	"sync"
+
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/group/mod"
+)
+
+// error returned by the marshalling methods of Secret
+var errSecretMarshalling = errors.New("daga: refusing to marshal a secret")
+
+// Secret holds a private scalar until it is released, Release zeroes the memory of the scalar.
+//
+// to prevent its accidental inclusion in messages, logs or saved state, a Secret refuses to be marshalled
+// (MarshalBinary, MarshalText and MarshalJSON fail) and is printed redacted. the code that really needs to encode the
+// scalar (e.g. to save the state of a server) must do it explicitly from Scalar.
+//
+// the zeroing is best effort: the copies made by the computations using the scalar (and by the runtime) are not erased
+type Secret struct {
+	mutex sync.RWMutex
+	s     kyber.Scalar
+}
+
+// NewSecret returns a new Secret holding s, the Secret takes ownership of s (s is zeroed when the Secret is released)
+func NewSecret(s kyber.Scalar) *Secret {
+	return &Secret{s: s}
+}
+
+// Scalar returns the scalar held by the secret, nil if the secret was released (or if s is nil),
+// the scalar is zeroed by Release and must not be retained
+func (s *Secret) Scalar() kyber.Scalar {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.s
+}
+
+// Released returns whether the secret was released
+func (s *Secret) Released() bool {
+	return s.Scalar() == nil
+}
+
+// Release zeroes the scalar held by the secret and forgets it, releasing a released (or nil) secret does nothing
+func (s *Secret) Release() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.s != nil {
+		zeroScalar(s.s)
+		s.s = nil
+	}
+}
+
+// MarshalBinary fails, secrets are not to be marshalled
+func (s *Secret) MarshalBinary() ([]byte, error) {
+	return nil, errSecretMarshalling
+}
+
+// UnmarshalBinary fails, secrets are not to be marshalled
+func (s *Secret) UnmarshalBinary([]byte) error {
+	return errSecretMarshalling
+}
+
+// MarshalText fails, secrets are not to be marshalled
+func (s *Secret) MarshalText() ([]byte, error) {
+	return nil, errSecretMarshalling
+}
+
+// MarshalJSON fails, secrets are not to be marshalled
+func (s *Secret) MarshalJSON() ([]byte, error) {
+	return nil, errSecretMarshalling
+}
+
+func (s *Secret) String() string {
+	return "daga.Secret(redacted)"
+}
+
+// GoString is used by the %#v verb
+func (s *Secret) GoString() string {
+	return s.String()
+}
+
+// zeroes the memory of s, setting s to 0.
+// the big.Int of a mod.Int doesn't overwrite its words when set to 0, they are cleared first
+func zeroScalar(s kyber.Scalar) {
+	if s == nil {
+		return
+	}
+	if i, ok := s.(*mod.Int); ok {
+		words := i.V.Bits()
+		for k := range words {
+			words[k] = 0
+		}
+	}
+	s.Zero()
+}
+
+// ReleaseSecrets releases the secrets held by holder, a Client, a Server or a Precomputation of this package
+// (any value with a Release method), does nothing for other values
+func ReleaseSecrets(holder interface{}) {
+	if r, ok := holder.(interface{ Release() }); ok {
+		r.Release()
+	}
+}
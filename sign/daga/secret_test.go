@@ -0,0 +1,91 @@
+package daga
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+func TestSecret(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			s := suite.Scalar().Pick(suite.RandomStream())
+			expected := suite.Scalar().Set(s)
+			secret := NewSecret(s)
+			require.True(t, secret.Scalar().Equal(expected))
+			require.False(t, secret.Released())
+
+			// refuses to be marshalled, and is not printed
+			_, err := secret.MarshalBinary()
+			require.Error(t, err)
+			_, err = json.Marshal(struct{ S *Secret }{secret})
+			require.Error(t, err)
+			for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+				require.NotContains(t, fmt.Sprintf(format, secret), expected.String())
+			}
+
+			// zeroed on release
+			secret.Release()
+			require.True(t, secret.Released())
+			require.Nil(t, secret.Scalar())
+			require.True(t, s.Equal(suite.Scalar().Zero()))
+			secret.Release()
+
+			var nilSecret *Secret
+			require.Nil(t, nilSecret.Scalar())
+			nilSecret.Release()
+		})
+	}
+}
+
+func TestServer_Release(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			server, err := NewServer(suite, 0, nil)
+			require.NoError(t, err)
+			GenerateNewRoundSecret(suite, server)
+			r := server.RoundSecret()
+
+			// the previous round secret is released
+			GenerateNewRoundSecret(suite, server)
+			require.True(t, r.Equal(suite.Scalar().Zero()))
+			r, x := server.RoundSecret(), server.PrivateKey()
+			require.False(t, r.Equal(suite.Scalar().Zero()))
+
+			ReleaseSecrets(server)
+			require.Nil(t, server.RoundSecret())
+			require.Nil(t, server.PrivateKey())
+			require.True(t, r.Equal(suite.Scalar().Zero()))
+			require.True(t, x.Equal(suite.Scalar().Zero()))
+		})
+	}
+}
+
+func TestPrecomputation_Release(t *testing.T) {
+	clients, servers, context, err := GenerateTestContext(suite, 3, 2)
+	require.NoError(t, err)
+	precomputation, err := Precompute(suite, context, clients[1])
+	require.NoError(t, err)
+	vx := precomputation.prover.vx.Scalar()
+
+	precomputation.Release()
+	require.True(t, vx.Equal(suite.Scalar().Zero()))
+	_, err = precomputation.MarshalBinary()
+	require.Error(t, err)
+	_, err = precomputation.Authenticate(clients[1], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+	})
+	require.Error(t, err)
+
+	// released after the authentication
+	precomputation, err = Precompute(suite, context, clients[1])
+	require.NoError(t, err)
+	_, err = precomputation.Authenticate(clients[1], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+	})
+	require.NoError(t, err)
+	require.True(t, precomputation.prover.s.Released())
+}
@@ -12,7 +12,6 @@ import (
 	"fmt"
 	"go.dedis.ch/kyber"
 	"go.dedis.ch/kyber/util/key"
-	"strconv"
 )
 
 // Server represents a DAGA server
@@ -27,7 +26,7 @@ type Server interface {
 
 type server struct {
 	Client
-	r kyber.Scalar //Per round secret
+	r *Secret //Per round secret
 }
 
 //NewServer is used to initialize a new server with a given index
@@ -42,14 +41,24 @@ func NewServer(suite Suite, i int, s kyber.Scalar) (Server, error) {
 	}, nil
 }
 
-//returns the (current) per round (auth. round) secret of the server
+//returns the (current) per round (auth. round) secret of the server, nil if not set or released
 func (s server) RoundSecret() kyber.Scalar {
-	return s.r
+	return s.r.Scalar()
 }
 
-//set the server's round secret to be the provided secret
+//set the server's round secret to be the provided secret, the server takes ownership of secret
+//and the previous round secret is released
 func (s *server) SetRoundSecret(secret kyber.Scalar) {
-	s.r = secret
+	if s.r.Scalar() != secret {
+		s.r.Release()
+	}
+	s.r = NewSecret(secret)
+}
+
+//Release zeroes the round secret and the private key of the server, to be called when the server is no longer used
+func (s *server) Release() {
+	s.r.Release()
+	ReleaseSecrets(s.Client)
 }
 
 // "philosophical" decision either stick with first idea of designing daga in kyber as a set of functions/primitives
@@ -83,46 +92,87 @@ type ServerSignature struct {
 
 // Challenge stores the collectively generated challenge and the signatures of the servers
 // This is the structure sent to the client as part of client proof PKclient
+//
+// Session the opaque binding of the challenge to a session of a relying party (see CheckSession), covered by the
+// signatures of the servers and by the encoding of the client's proof, empty if the challenge is not bound
+//
+// ID and Expiry the identifier of the challenge and its expiry (unix time in seconds) chosen by the servers to reject
+// the expired challenges and the challenges used more than once, covered by the signatures of the servers and by the
+// encoding of the client's proof, empty and 0 if the challenge doesn't have them (enforcing them is up to the servers)
 type Challenge struct {
-	Cs   kyber.Scalar
-	Sigs []ServerSignature //Signatures for cs||PKClientCommitments
+	Cs      kyber.Scalar
+	Sigs    []ServerSignature //Signatures for cs||PKClientCommitments(||Session||ID||Expiry)
+	Session []byte
+	ID      []byte
+	Expiry  int64
+}
+
+// MaxSessionSize is the maximum size of the session binding of a Challenge
+const MaxSessionSize = 64
+
+// MaxChallengeIDSize is the maximum size of the identifier of a Challenge
+const MaxChallengeIDSize = 32
+
+// appends the optional elements of the challenge c to the transcript t, only the ones that are set s.t. the encoding of
+// the challenges that don't have them is the one they had before their introduction, the ones that are set are labeled and
+// length-prefixed in every encoding (see appendField) s.t. they can't be confused with one another
+func (c Challenge) appendOptionals(t *transcript) *transcript {
+	if len(c.Session) != 0 {
+		t.appendField("session", c.Session)
+	}
+	if len(c.ID) != 0 {
+		t.appendField("id", c.ID)
+	}
+	if c.Expiry != 0 {
+		t.appendInt64Field("expiry", c.Expiry)
+	}
+	return t
 }
 
 // verify all the signatures in the Challenge + verify that there are no duplicates
-func (c Challenge) VerifySignatures(suite Suite, serverKeys []kyber.Point, pkClientCommitments []kyber.Point) error {
-	if signData, err := c.ToBytes(pkClientCommitments); err != nil {
+func (c Challenge) VerifySignatures(suite Suite, context AuthenticationContext, pkClientCommitments []kyber.Point) error {
+	if context == nil {
+		return newError(ErrInvalidContext, nil, "empty context")
+	}
+	serverKeys := context.Members().Y
+	scheme := ContextSignatureScheme(context)
+	if signData, err := c.ToBytes(ContextEncoding(context), pkClientCommitments); err != nil {
 		return err
 	} else {
 		encountered := map[int]struct{}{}
 		yes := struct{}{}
 		for _, sig := range c.Sigs {
 			if _, ok := encountered[sig.Index]; ok {
-				return fmt.Errorf("duplicate signature")
+				return newError(ErrMalformedMessage, nil, "duplicate signature")
 			}
 			encountered[sig.Index] = yes
+			if sig.Index < 0 || sig.Index >= len(serverKeys) {
+				return newError(ErrMalformedMessage, nil, "signature of unknown server %d", sig.Index)
+			}
 
-			if err := SchnorrVerify(suite, serverKeys[sig.Index], signData, sig.Sig); err != nil {
-				return errors.New("failed to verify signature of server " + strconv.Itoa(sig.Index) + ": " + err.Error())
+			if err := scheme.Verify(suite, serverKeys[sig.Index], signData, sig.Sig); err != nil {
+				return newError(nil, &SignatureError{Signer: sig.Index, Err: err}, "failed to verify signature of server %d: %s", sig.Index, err)
 			}
 		}
 		return nil
 	}
 }
 
-// used for Challenge signatures, marshall the master challenge and concat with the PKclient's commitments
-func (c Challenge) ToBytes(pkClientCommitments []kyber.Point) ([]byte, error) {
+// used for Challenge signatures, marshall the master challenge, the PKclient's commitments and the optional elements,
+// the session binding, the identifier and the expiry, if any
+//
+// version the encoding of the context under which the challenge is generated (see ContextEncoding)
+func (c Challenge) ToBytes(version EncodingVersion, pkClientCommitments []kyber.Point) ([]byte, error) {
 	if c.Cs == nil {
 		return nil, errors.New("empty challenge, nothing to marshall")
 	}
-	csBytes, err := c.Cs.MarshalBinary()
+	t := newTranscript(version, transcriptChallenge).
+		appendScalar("cs", c.Cs).
+		appendPoints("commitments", pkClientCommitments)
+	signData, err := c.appendOptionals(t).Bytes()
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling master challenge: %s", err)
+		return nil, fmt.Errorf("error marshalling challenge: %s", err)
 	}
-	pkcCommitsBytes, err := PointArrayToBytes(pkClientCommitments)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling PKClient commitments: %s", err)
-	}
-	signData := append(csBytes, pkcCommitsBytes...)
 	return signData, nil
 }
 
@@ -143,15 +193,18 @@ type ServerProof struct {
 	R2 kyber.Scalar
 }
 
-// NewChallengeCommitment creates the server's commitment and its opening for the distributed challenge generation
-func NewChallengeCommitment(suite Suite, server Server) (commit *ChallengeCommitment, opening kyber.Scalar, err error) {
+// NewChallengeCommitment creates the server's commitment and its opening for the distributed challenge generation under context
+func NewChallengeCommitment(suite Suite, context AuthenticationContext, server Server) (commit *ChallengeCommitment, opening kyber.Scalar, err error) {
+	if context == nil {
+		return nil, nil, errors.New("empty context")
+	}
 	opening = suite.Scalar().Pick(suite.RandomStream())
 	com := suite.Point().Mul(opening, nil)
-	msg, err := com.MarshalBinary()
+	msg, err := challengeCommitmentToBytes(ContextEncoding(context), server.Index(), com)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to encode commitment: %s", err)
 	}
-	sig, err := SchnorrSign(suite, server.PrivateKey(), msg)
+	sig, err := ContextSignatureScheme(context).Sign(suite, server.PrivateKey(), msg)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to sign commitment: %s", err)
 	}
@@ -164,27 +217,47 @@ func NewChallengeCommitment(suite Suite, server Server) (commit *ChallengeCommit
 	}, opening, nil
 }
 
-func VerifyChallengeCommitmentSignature(suite Suite, commit ChallengeCommitment, pubKey kyber.Point) error {
+// VerifyChallengeCommitmentSignature verifies that the commitment under context is correctly signed by the owner of pubKey
+func VerifyChallengeCommitmentSignature(suite Suite, context AuthenticationContext, commit ChallengeCommitment, pubKey kyber.Point) error {
+	if context == nil {
+		return errors.New("empty context")
+	}
 	// Convert the commitment and verify the signature
-	msg, err := commit.Commit.MarshalBinary()
+	msg, err := challengeCommitmentToBytes(ContextEncoding(context), commit.Index, commit.Commit)
 	if err != nil {
 		return fmt.Errorf("failed to encode commitment: %s", err)
 	}
-	err = SchnorrVerify(suite, pubKey, msg, commit.Sig)
+	err = ContextSignatureScheme(context).Verify(suite, pubKey, msg, commit.Sig)
 	if err != nil {
-		return fmt.Errorf("failed to verify signature of %dth server's commitment: %s", commit.Index, err)
+		return newError(nil, &SignatureError{Signer: commit.Index, Err: err}, "failed to verify signature of %dth server's commitment: %s", commit.Index, err)
 	}
 	return nil
 }
 
+// returns the data signed by the server of index `index` to endorse its challenge commitment
+func challengeCommitmentToBytes(version EncodingVersion, index int, commit kyber.Point) ([]byte, error) {
+	t := newTranscript(version, transcriptChallengeCommitment)
+	if version != EncodingLegacy {
+		// the legacy encoding only covers the commitment
+		t.appendInt("index", index)
+	}
+	return t.appendPoint("commit", commit).Bytes()
+}
+
 // VerifyChallengeCommitmentsSignatures verifies that all the commitments are valid and correctly signed
 func VerifyChallengeCommitmentsSignatures(suite Suite, context AuthenticationContext, commits []ChallengeCommitment) error {
+	if context == nil {
+		return errors.New("empty context")
+	}
+	members := context.Members()
+	if len(commits) != len(members.Y) {
+		return fmt.Errorf("incorrect number of commits: got %d expected %d", len(commits), len(members.Y))
+	}
 	for i, com := range commits {
 		if i != com.Index {
 			return fmt.Errorf("wrong commitment index: got %d expected %d", com.Index, i)
 		}
-		members := context.Members()
-		if err := VerifyChallengeCommitmentSignature(suite, com, members.Y[i]); err != nil {
+		if err := VerifyChallengeCommitmentSignature(suite, context, com, members.Y[i]); err != nil {
 			return err
 		}
 	}
@@ -193,6 +266,9 @@ func VerifyChallengeCommitmentsSignatures(suite Suite, context AuthenticationCon
 
 // returns whether opening is a valid opening of commitment
 func CheckOpening(suite Suite, commitment kyber.Point, opening kyber.Scalar) bool {
+	if commitment == nil || opening == nil {
+		return false
+	}
 	return commitment.Equal(suite.Point().Mul(opening, nil))
 }
 
@@ -210,14 +286,32 @@ func checkOpenings(suite Suite, context AuthenticationContext, commits []Challen
 		return nil, fmt.Errorf("incorrect number of openings: got %d expected %d", len(openings), len(members.Y))
 	}
 
-	cs = suite.Scalar().Zero()
 	for i := 0; i < len(commits); i++ {
 		if !CheckOpening(suite, commits[i].Commit, openings[i]) {
 			return nil, fmt.Errorf("mismatch opening for server %d", i)
 		}
-		cs = suite.Scalar().Add(cs, openings[i])
 	}
-	return cs, nil
+	return masterChallenge(suite, ContextEncoding(context), commits, openings)
+}
+
+// returns the master challenge derived from the servers' commits and openings,
+// with EncodingLegacy the sum of the openings, otherwise the hash (labeled HashChallenge) of all the commits and openings
+func masterChallenge(suite Suite, version EncodingVersion, commits []ChallengeCommitment, openings []kyber.Scalar) (kyber.Scalar, error) {
+	if version == EncodingLegacy {
+		cs := suite.Scalar().Zero()
+		for _, opening := range openings {
+			cs = suite.Scalar().Add(cs, opening)
+		}
+		return cs, nil
+	}
+	commitPoints := make([]kyber.Point, len(commits))
+	for i, commit := range commits {
+		commitPoints[i] = commit.Commit
+	}
+	return newTranscript(version, transcriptMasterChallenge).
+		appendPoints("commits", commitPoints).
+		appendScalars("openings", openings).
+		hashToScalar(suite, HashChallenge)
 }
 
 // InitializeChallenge creates a ChallengeCheck structure, It checks the openings before doing so
@@ -240,35 +334,38 @@ func InitializeChallenge(suite Suite, context AuthenticationContext, commits []C
 // It also adds the server's signature to the list if the round-robin is not completed (the challenge has not yet made it back to the leader)
 // It must be used after the leader ran InitializeChallenge and after each server received the challenge from the previous server
 func CheckUpdateChallenge(suite Suite, context AuthenticationContext, challengeCheck *ChallengeCheck, pkClientCommitments []kyber.Point, server Server) error {
+	if context == nil || challengeCheck == nil {
+		return newError(ErrMalformedMessage, nil, "CheckUpdateChallenge: invalid inputs")
+	}
 	//Check the signatures and check for duplicates
 	members := context.Members()
-	if err := challengeCheck.Challenge.VerifySignatures(suite, members.Y, pkClientCommitments); err != nil {
-		return fmt.Errorf("CheckUpdateChallenge: %s", err)
+	if err := challengeCheck.Challenge.VerifySignatures(suite, context, pkClientCommitments); err != nil {
+		return newError(nil, err, "CheckUpdateChallenge: %s", err)
 	}
 
 	//Checks the signatures of the commitments
 	if err := VerifyChallengeCommitmentsSignatures(suite, context, challengeCheck.Commits); err != nil {
-		return fmt.Errorf("CheckUpdateChallenge: failed to verify commitment signature %s", err)
+		return newError(nil, err, "CheckUpdateChallenge: failed to verify commitment signature %s", err)
 	}
 	//Checks the openings
 	cs, err := checkOpenings(suite, context, challengeCheck.Commits, challengeCheck.Openings)
 	if err != nil {
-		return fmt.Errorf("CheckUpdateChallenge: failed to verify commitment openings %s", err)
+		return newError(ErrInvalidChallenge, err, "CheckUpdateChallenge: failed to verify commitment openings %s", err)
 	}
 	//Checks that the challenge values match
 	if !cs.Equal(challengeCheck.Cs) {
-		return fmt.Errorf("CheckUpdateChallenge: master challenge values does not match")
+		return newError(ErrInvalidChallenge, nil, "CheckUpdateChallenge: master challenge values does not match")
 	}
 
 	//Add the server's signature to the list if it is not the last challengeCheck call (by leader/root once every server added its grain of salt)
 	if len(challengeCheck.Sigs) == len(members.Y) {
 		return nil
 	}
-	signData, err := challengeCheck.Challenge.ToBytes(pkClientCommitments)
+	signData, err := challengeCheck.Challenge.ToBytes(ContextEncoding(context), pkClientCommitments)
 	if err != nil {
 		return fmt.Errorf("CheckUpdateChallenge: %s", err)
 	}
-	sig, err := SchnorrSign(suite, server.PrivateKey(), signData)
+	sig, err := ContextSignatureScheme(context).Sign(suite, server.PrivateKey(), signData)
 	if err != nil {
 		return fmt.Errorf("CheckUpdateChallenge: failed to sign master challenge")
 	}
@@ -290,7 +387,7 @@ func FinalizeChallenge(context AuthenticationContext, challenge *ChallengeCheck)
 		return Challenge{}, fmt.Errorf("signature count does not match: got %d expected %d", len(challenge.Sigs), len(members.Y))
 	}
 
-	return Challenge{Cs: challenge.Cs, Sigs: challenge.Sigs}, nil
+	return Challenge{Cs: challenge.Cs, Sigs: challenge.Sigs, Session: challenge.Session, ID: challenge.ID, Expiry: challenge.Expiry}, nil
 }
 
 //InitializeServerMessage creates a ServerMessage from a ClientMessage to ease further processing
@@ -307,91 +404,101 @@ func InitializeServerMessage(request *AuthenticationMessage) (msg *ServerMessage
 	}, nil
 }
 
+// VerificationMode selects which contributions of the previous servers a server verifies in ServerProtocolWithMode
+type VerificationMode int
+
+const (
+	// VerifyAll verifies the signatures and proofs of all the previous servers, O(m) work per server, O(m²) for the ring
+	VerifyAll VerificationMode = iota
+	// VerifyPrevious verifies only the signature and proof of the immediately preceding server,
+	// its signature covers the whole prefix of the message (the request and the contributions of all the previous servers),
+	// that it verified in turn. the full verification is left to the end, see GetFinalLinkageTag.
+	// (a server that alters the contribution of a previous server is detected at the end, but the blame then falls on
+	// the altered contribution)
+	VerifyPrevious
+)
+
 // ServerProtocol runs the server part of DAGA upon receiving a message from either a server or a client
 // It processes the message according to "Syta - Identity Management Through Privacy Preserving Aut 4.3.6"
+// (verifies all the contributions of the previous servers, see ServerProtocolWithMode)
 // TODO DRY see what can be shared with GetFinalLinkageTag ...+ probably rewrite ..
 func ServerProtocol(suite Suite, msg *ServerMessage, server Server) error {
+	return ServerProtocolWithMode(suite, msg, server, VerifyAll)
+}
+
+// ServerProtocolWithMode runs ServerProtocol, verifying the contributions of the previous servers according to mode
+func ServerProtocolWithMode(suite Suite, msg *ServerMessage, server Server, mode VerificationMode) error {
 
 	// input checks
 	if msg == nil || len(msg.Indexes) != len(msg.Proofs) || len(msg.Proofs) != len(msg.Tags) || len(msg.Tags) != len(msg.Sigs) {
-		return fmt.Errorf("ServerProtocol: invalid message")
+		return newError(ErrMalformedMessage, nil, "ServerProtocol: invalid message")
+	}
+	// reject malformed or malicious elements before doing any expensive verification
+	if err := validateServerMessage(suite, *msg); err != nil {
+		return newError(nil, err, "ServerProtocol: invalid message: %s", err)
 	}
 
 	//Step 1
 	//Verify that the client's message is correctly formed and its proof correct
-	if err := verifyAuthenticationMessage(suite, msg.Request); err != nil {
-		return errors.New("ServerProtocol: malformed client message or wrong proof")
+	//(a compact proof is expanded, the servers sign the full proof, see ExpandClientProof)
+	if err := verifyAndExpandAuthenticationMessage(suite, &msg.Request); err != nil {
+		return newError(nil, err, "ServerProtocol: malformed client message or wrong proof")
 	}
 
 	context := msg.Request.C
 
 	members := context.Members()
+	// the request is issued under a context chosen by the client, the server must be one of its servers
+	if server.Index() < 0 || server.Index() >= len(members.Y) {
+		return newError(ErrInvalidContext, nil, "ServerProtocol: server %d not in the context of the request", server.Index())
+	}
 	//Checks that not all servers already did the protocols
 	if len(msg.Indexes) >= len(members.Y) {
-		return fmt.Errorf("ServerProtocol: too many calls of the protocols")
+		return newError(ErrMalformedMessage, nil, "ServerProtocol: too many calls of the protocols")
 	}
 
 	// Iteratively checks each signature if this is not the first server to receive the client's request
 	// FIXME dafuck is this ? nowhere to be found in DAGA or (DAGA assumes authenticated channels if I'm correct)??
 	//  is it out of scope or to hack another thing ? what is the pursued goal ?? (to me it should be handled by Onet/TLS...)
-	data, e := msg.Request.ToBytes()
-	if e != nil {
-		return errors.New("ServerProtocol: failed to marshall client's msg, " + e.Error())
-	}
-	if len(msg.Indexes) != 0 {
-		for i := 0; i < len(msg.Indexes); i++ {
-			temp, err := msg.Tags[i].MarshalBinary()
-			if err != nil {
-				return errors.New("ServerProtocol: failed to marshall tags, " + err.Error())
-			}
-			data = append(data, temp...)
-
-			temp, err = msg.Proofs[i].ToBytes()
-			if err != nil {
-				return fmt.Errorf("error in proofs: %s", err)
-			}
-			data = append(data, temp...)
-
-			data = append(data, []byte(strconv.Itoa(msg.Indexes[i]))...)
-
-			err = SchnorrVerify(suite, members.Y[msg.Sigs[i].Index], data, msg.Sigs[i].Sig)
-			if err != nil {
-				return fmt.Errorf("error in signature: "+strconv.Itoa(i)+"\n%s", err)
-			}
-		}
-	}
-
-	//Check all the proofs
+	// (and checks all the proofs, report the culprit(s) if some did not verify)
 	// FIXME so all we need to do to bypass is remove the proofs ?? => rewrite DAGA API and rewrite everything here
-	if len(msg.Proofs) != 0 {
-		for i, p := range msg.Proofs {
-			var valid bool
-			if p.R2 == nil {
-				members := context.Members()
-				valid = verifyMisbehavingProof(suite, members.Y[i], &p, msg.Request.SCommits[0])
-				// and then..?
-			} else {
-				valid = verifyServerProof(suite, context, i, msg)
-			}
-			if !valid {
-				return fmt.Errorf("invalid server proof")
-			}
+	version := ContextEncoding(context)
+	from := 0
+	switch mode {
+	case VerifyAll:
+	case VerifyPrevious:
+		if len(msg.Proofs) > 0 {
+			from = len(msg.Proofs) - 1
 		}
+	default:
+		return fmt.Errorf("ServerProtocol: unknown verification mode %d", mode)
+	}
+	report, signData, e := verifyServerMessage(suite, context, *msg, from)
+	if e != nil {
+		return newError(nil, e, "ServerProtocol: failed to marshall client's msg, %s", e)
+	}
+	if !report.Valid() {
+		return &BlameError{Report: report}
 	}
 
 	//Step 2: Verify the correct behaviour of the client
-	hasher := suite.Hash()
-	suite.Point().Mul(server.PrivateKey(), msg.Request.SCommits[0]).MarshalTo(hasher)
-	s := suite.Scalar().SetBytes(hasher.Sum(nil))
+	sSecret, e := newSharedSecret(suite, version, server.PrivateKey(), msg.Request.SCommits[0])
+	if e != nil {
+		return errors.New("ServerProtocol: failed to compute shared secret, " + e.Error())
+	}
+	defer sSecret.Release()
+	s := sSecret.Scalar()
 	var T kyber.Point
 	var proof *ServerProof
 	//Detect a misbehaving client and generate the elements of the server's message accordingly
 	if !msg.Request.SCommits[server.Index()+2].Equal(suite.Point().Mul(s, msg.Request.SCommits[server.Index()+1])) {
 		T = suite.Point().Null()
-		proof, e = generateMisbehavingProof(suite, msg.Request.SCommits[0], server)
+		proof, e = generateMisbehavingProof(suite, version, msg.Request.SCommits[0], server)
 	} else {
 		inv := suite.Scalar().Inv(s)
+		defer zeroScalar(inv)
 		exp := suite.Scalar().Mul(server.RoundSecret(), inv)
+		defer zeroScalar(exp)
 		if len(msg.Tags) == 0 {
 			T = suite.Point().Mul(exp, msg.Request.T0)
 		} else {
@@ -404,21 +511,12 @@ func ServerProtocol(suite Suite, msg *ServerMessage, server Server) error {
 	}
 
 	//Signs our message // FIXME, again to me this thing has nothing to do here (and guess that it is/should be handled by Onet (TLS) or ??)
-	temp, e := T.MarshalBinary()
-	if e != nil {
-		return fmt.Errorf("error in T: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = proof.ToBytes()
+	data, e := appendServerContribution(signData, T, *proof, server.Index())
 	if e != nil {
-		return fmt.Errorf("error in proof: %s", e)
+		return fmt.Errorf("ServerProtocol: %s", e)
 	}
-	data = append(data, temp...)
 
-	data = append(data, []byte(strconv.Itoa(server.Index()))...)
-
-	sign, e := SchnorrSign(suite, server.PrivateKey(), data)
+	sign, e := ContextSignatureScheme(context).Sign(suite, server.PrivateKey(), data)
 	if e != nil {
 		return fmt.Errorf("error in own signature: %s", e)
 	}
@@ -438,6 +536,26 @@ func ServerProtocol(suite Suite, msg *ServerMessage, server Server) error {
 	return nil
 }
 
+// returns a new transcript of the data signed by the servers when processing the request,
+// to be completed with the contribution of each server in turn (see appendServerContribution)
+func newServerMessageTranscript(request AuthenticationMessage) (*transcript, error) {
+	requestBytes, err := request.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return newTranscript(ContextEncoding(request.C), transcriptServerMessage).appendBytes("request", requestBytes), nil
+}
+
+// appends the contribution (tag T, proof and index) of a server to the transcript of the server message,
+// and returns the resulting data, signed by that server
+func appendServerContribution(t *transcript, T kyber.Point, proof ServerProof, index int) ([]byte, error) {
+	proofBytes, err := proof.ToBytes(t.version)
+	if err != nil {
+		return nil, fmt.Errorf("error in proof: %s", err)
+	}
+	return t.appendPoint("T", T).appendBytes("proof", proofBytes).appendInt("index", index).Bytes()
+}
+
 // generateServerProof creates the server proof for its computations
 func generateServerProof(suite Suite, context AuthenticationContext, s kyber.Scalar, T kyber.Point, msg *ServerMessage, server Server) (proof *ServerProof, err error) {
 	//Input validation
@@ -455,8 +573,11 @@ func generateServerProof(suite Suite, context AuthenticationContext, s kyber.Sca
 	}
 
 	//Step 1
-	v1 := suite.Scalar().Pick(suite.RandomStream())
-	v2 := suite.Scalar().Pick(suite.RandomStream())
+	v1Secret := NewSecret(suite.Scalar().Pick(suite.RandomStream()))
+	defer v1Secret.Release()
+	v2Secret := NewSecret(suite.Scalar().Pick(suite.RandomStream()))
+	defer v2Secret.Release()
+	v1, v2 := v1Secret.Scalar(), v2Secret.Scalar()
 
 	var a kyber.Point
 	if len(msg.Tags) == 0 {
@@ -481,25 +602,20 @@ func generateServerProof(suite Suite, context AuthenticationContext, s kyber.Sca
 		Tprevious = msg.Tags[len(msg.Tags)-1]
 	}
 	//Generating the hash
-	hasher := suite.hashTwo()
-	Tprevious.MarshalTo(hasher)
-	T.MarshalTo(hasher)
-	context.ServersSecretsCommitments()[server.Index()].MarshalTo(hasher)
-	suite.Point().Mul(suite.Scalar().One(), nil).MarshalTo(hasher)
-	msg.Request.SCommits[server.Index()+2].MarshalTo(hasher)
-	msg.Request.SCommits[server.Index()+1].MarshalTo(hasher)
-	t1.MarshalTo(hasher)
-	t2.MarshalTo(hasher)
-	t3.MarshalTo(hasher)
-	challenge := hasher.Sum(nil)
-	c := suite.Scalar().SetBytes(challenge)
+	c, err := serverProofChallenge(suite, ContextEncoding(context), Tprevious, T, context.ServersSecretsCommitments()[server.Index()],
+		msg.Request.SCommits[server.Index()+2], msg.Request.SCommits[server.Index()+1], t1, t2, t3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute challenge: %s", err)
+	}
 	//rand := suite.Cipher(challenge)
 	//c := suite.Scalar().Pick(rand)
 	//Step 3
 	d := suite.Scalar().Mul(c, server.RoundSecret())
+	defer zeroScalar(d)
 	r1 := suite.Scalar().Sub(v1, d)
 
 	e := suite.Scalar().Mul(c, s)
+	defer zeroScalar(e)
 	r2 := suite.Scalar().Sub(v2, e)
 
 	//Step 4
@@ -529,7 +645,13 @@ func verifyServerProof(suite Suite, context AuthenticationContext, i int, msg *S
 		return false
 	}
 
+	if i >= len(msg.Indexes) || i >= len(msg.Tags) || msg.Request.T0 == nil {
+		return false
+	}
 	index := msg.Indexes[i]
+	if index < 0 || index >= len(context.ServersSecretsCommitments()) || index+2 >= len(msg.Request.SCommits) {
+		return false
+	}
 
 	//Step 1
 	var a kyber.Point
@@ -542,8 +664,8 @@ func verifyServerProof(suite Suite, context AuthenticationContext, i int, msg *S
 	b := suite.Point().Mul(msg.Proofs[i].R2, msg.Tags[i])
 	t1 := suite.Point().Sub(a, b)
 
-	d := suite.Point().Mul(msg.Proofs[i].R1, nil)
-	e := suite.Point().Mul(msg.Proofs[i].C, context.ServersSecretsCommitments()[index])
+	d := fixedBaseMul(suite, msg.Proofs[i].R1, nil)
+	e := fixedBaseMul(suite, msg.Proofs[i].C, context.ServersSecretsCommitments()[index])
 	t2 := suite.Point().Add(d, e)
 
 	f := suite.Point().Mul(msg.Proofs[i].R2, msg.Request.SCommits[index+1])
@@ -558,29 +680,33 @@ func verifyServerProof(suite Suite, context AuthenticationContext, i int, msg *S
 		Tprevious = msg.Tags[i-1]
 	}
 
-	hasher := suite.hashTwo()
-	Tprevious.MarshalTo(hasher)
-	msg.Tags[i].MarshalTo(hasher)
-	context.ServersSecretsCommitments()[index].MarshalTo(hasher)
-	suite.Point().Mul(suite.Scalar().One(), nil).MarshalTo(hasher)
-	msg.Request.SCommits[index+2].MarshalTo(hasher)
-	msg.Request.SCommits[index+1].MarshalTo(hasher)
-	t1.MarshalTo(hasher)
-	t2.MarshalTo(hasher)
-	t3.MarshalTo(hasher)
-	challenge := hasher.Sum(nil)
-
-	c := suite.Scalar().SetBytes(challenge)
-
-	if !c.Equal(msg.Proofs[i].C) {
+	c, err := serverProofChallenge(suite, ContextEncoding(context), Tprevious, msg.Tags[i], context.ServersSecretsCommitments()[index],
+		msg.Request.SCommits[index+2], msg.Request.SCommits[index+1], t1, t2, t3)
+	if err != nil || !c.Equal(msg.Proofs[i].C) {
 		return false
 	}
 
 	return true
 }
 
+// returns the (Fiat-Shamir) challenge of a server proof,
+// the hash of the public values and commitments of the proof: Tprevious, T, R, g, Sj, Sj-1, t1, t2, t3
+func serverProofChallenge(suite Suite, version EncodingVersion, Tprevious, T, R, Sj, SjPrevious, t1, t2, t3 kyber.Point) (kyber.Scalar, error) {
+	return newTranscript(version, transcriptServerProofChallenge).
+		appendPoint("Tprevious", Tprevious).
+		appendPoint("T", T).
+		appendPoint("R", R).
+		appendPoint("g", suite.Point().Base()).
+		appendPoint("Sj", Sj).
+		appendPoint("Sj-1", SjPrevious).
+		appendPoint("t1", t1).
+		appendPoint("t2", t2).
+		appendPoint("t3", t3).
+		hashToScalar(suite, HashServerProof)
+}
+
 // generateMisbehavingProof creates the proof of a misbehaving client  // TODO rename....^^
-func generateMisbehavingProof(suite Suite, Z kyber.Point, server Server) (proof *ServerProof, err error) {
+func generateMisbehavingProof(suite Suite, version EncodingVersion, Z kyber.Point, server Server) (proof *ServerProof, err error) {
 	//Input checks
 	if Z == nil {
 		return nil, fmt.Errorf("empty Z")
@@ -589,24 +715,21 @@ func generateMisbehavingProof(suite Suite, Z kyber.Point, server Server) (proof
 	Zs := suite.Point().Mul(server.PrivateKey(), Z)
 
 	//Step 1
-	v := suite.Scalar().Pick(suite.RandomStream())
+	vSecret := NewSecret(suite.Scalar().Pick(suite.RandomStream()))
+	defer vSecret.Release()
+	v := vSecret.Scalar()
 	t1 := suite.Point().Mul(v, Z)
 	t2 := suite.Point().Mul(v, nil)
 
 	//Step 2
-	hasher := suite.hashTwo()
-	Zs.MarshalTo(hasher)
-	Z.MarshalTo(hasher)
-	server.PublicKey().MarshalTo(hasher)
-	suite.Point().Mul(suite.Scalar().One(), nil).MarshalTo(hasher)
-	t1.MarshalTo(hasher)
-	t2.MarshalTo(hasher)
-	challenge := hasher.Sum(nil)
-
-	c := suite.Scalar().SetBytes(challenge)
+	c, err := misbehavingProofChallenge(suite, version, Zs, Z, server.PublicKey(), t1, t2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute challenge: %s", err)
+	}
 
 	//Step 3
 	a := suite.Scalar().Mul(c, server.PrivateKey())
+	defer zeroScalar(a)
 	r := suite.Scalar().Sub(v, a)
 
 	//Step 4
@@ -621,7 +744,7 @@ func generateMisbehavingProof(suite Suite, Z kyber.Point, server Server) (proof
 }
 
 //verifyMisbehavingProof verifies a proof of a misbehaving client TODO rename...
-func verifyMisbehavingProof(suite Suite, serverPublicKey kyber.Point, proof *ServerProof, Z kyber.Point) bool {
+func verifyMisbehavingProof(suite Suite, version EncodingVersion, serverPublicKey kyber.Point, proof *ServerProof, Z kyber.Point) bool {
 	//Input checks
 	if serverPublicKey == nil || proof == nil || Z == nil {
 		return false
@@ -642,70 +765,51 @@ func verifyMisbehavingProof(suite Suite, serverPublicKey kyber.Point, proof *Ser
 	b := suite.Point().Mul(proof.C, proof.T3) //t3 = Zs
 	t1 := suite.Point().Add(a, b)
 
-	d := suite.Point().Mul(proof.R1, nil) //r1 = r
-	e := suite.Point().Mul(proof.C, serverPublicKey)
+	d := fixedBaseMul(suite, proof.R1, nil) //r1 = r
+	e := fixedBaseMul(suite, proof.C, serverPublicKey)
 	t2 := suite.Point().Add(d, e)
 
 	//Step 2
-	hasher := suite.hashTwo()
-	proof.T3.MarshalTo(hasher)
-	Z.MarshalTo(hasher)
-	serverPublicKey.MarshalTo(hasher)
-	suite.Point().Mul(suite.Scalar().One(), nil).MarshalTo(hasher)
-	t1.MarshalTo(hasher)
-	t2.MarshalTo(hasher)
-	challenge := hasher.Sum(nil)
-
-	//rand := suite.Cipher(challhasenge)
-	c := suite.Scalar().SetBytes(challenge)
-
-	if !c.Equal(proof.C) {
+	c, err := misbehavingProofChallenge(suite, version, proof.T3, Z, serverPublicKey, t1, t2)
+	if err != nil || !c.Equal(proof.C) {
 		return false
 	}
 	return true
 }
 
-// ToBytes is a helper function used to convert a ServerProof into []byte to be used in signatures
-func (proof ServerProof) ToBytes() (data []byte, err error) {
-	temp, e := proof.T1.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in t1: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = proof.T2.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in t2: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = proof.T3.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in t3: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = proof.C.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in c: %s", e)
-	}
-	data = append(data, temp...)
-
-	temp, e = proof.R1.MarshalBinary()
-	if e != nil {
-		return nil, fmt.Errorf("error in r1: %s", e)
-	}
-	data = append(data, temp...)
+// returns the (Fiat-Shamir) challenge of a misbehaving proof,
+// the hash of the public values and commitments of the proof: Zs, Z, Y, g, t1, t2
+func misbehavingProofChallenge(suite Suite, version EncodingVersion, Zs, Z, Y, t1, t2 kyber.Point) (kyber.Scalar, error) {
+	return newTranscript(version, transcriptMisbehavingProofChallenge).
+		appendPoint("Zs", Zs).
+		appendPoint("Z", Z).
+		appendPoint("Y", Y).
+		appendPoint("g", suite.Point().Base()).
+		appendPoint("t1", t1).
+		appendPoint("t2", t2).
+		hashToScalar(suite, HashMisbehavingProof)
+}
 
-	//Need to test if r2 == nil (Misbehaving)
+// ToBytes is a helper function used to convert a ServerProof into []byte to be used in signatures
+//
+// version the encoding of the context of the proof (see ContextEncoding)
+func (proof ServerProof) ToBytes(version EncodingVersion) (data []byte, err error) {
+	t := newTranscript(version, transcriptServerProof).
+		appendPoint("t1", proof.T1).
+		appendPoint("t2", proof.T2).
+		appendPoint("t3", proof.T3).
+		appendScalar("c", proof.C).
+		appendScalar("r1", proof.R1)
+	//Need to test if r2 == nil (Misbehaving), if it is the case r2 is encoded as an empty value
 	if proof.R2 != nil {
-		temp, e = proof.R2.MarshalBinary()
-		if e != nil {
-			return nil, fmt.Errorf("error in r2: %s", e)
-		}
-		data = append(data, temp...)
+		t.appendScalar("r2", proof.R2)
+	} else {
+		t.appendBytes("r2", nil)
+	}
+	data, err = t.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("ServerProof.ToBytes: %s", err)
 	}
-
 	return data, nil
 }
 
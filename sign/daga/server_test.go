@@ -2,6 +2,7 @@ package daga
 
 import (
 	"crypto/sha512"
+	"errors"
 	"go.dedis.ch/kyber"
 	"github.com/stretchr/testify/require"
 	"io"
@@ -34,17 +35,17 @@ func TestGetPublicKey_Server(t *testing.T) {
 }
 
 func TestGenerateCommitment(t *testing.T) {
-	_, servers, _, _ := GenerateTestContext(suite, rand.Intn(10)+2, rand.Intn(10)+1)
+	_, servers, context, _ := GenerateTestContext(suite, rand.Intn(10)+2, rand.Intn(10)+1)
 
 	//Normal execution
-	commit, opening, err := NewChallengeCommitment(suite, servers[0])
+	commit, opening, err := NewChallengeCommitment(suite, context, servers[0])
 	require.NoError(t, err, "Cannot generate a commitment")
 	require.True(t, commit.Commit.Equal(suite.Point().Mul(opening, nil)), "Cannot open the commitment")
 
-	msg, err := commit.Commit.MarshalBinary()
+	msg, err := challengeCommitmentToBytes(ContextEncoding(context), servers[0].Index(), commit.Commit)
 	require.NoError(t, err, "failed to marshall commitment")
 
-	err = SchnorrVerify(suite, servers[0].PublicKey(), msg, commit.Sig)
+	err = ContextSignatureScheme(context).Verify(suite, servers[0].PublicKey(), msg, commit.Sig)
 	require.NoError(t, err, "wrong commitment signature, failed to verify")
 }
 
@@ -54,7 +55,7 @@ func TestVerifyCommitmentSignature(t *testing.T) {
 	//Generate commitments
 	var commits []ChallengeCommitment
 	for _, server := range servers {
-		commit, _, _ := NewChallengeCommitment(suite, server)
+		commit, _, _ := NewChallengeCommitment(suite, context, server)
 		commits = append(commits, *commit)
 	}
 
@@ -87,7 +88,7 @@ func TestCheckOpenings(t *testing.T) {
 	var commits []ChallengeCommitment
 	var openings []kyber.Scalar
 	for i := 0; i < len(servers); i++ {
-		commit, open, _ := NewChallengeCommitment(suite, servers[i])
+		commit, open, _ := NewChallengeCommitment(suite, context, servers[i])
 		commits = append(commits, *commit)
 		openings = append(openings, open)
 	}
@@ -96,11 +97,25 @@ func TestCheckOpenings(t *testing.T) {
 	cs, err := checkOpenings(suite, context, commits, openings)
 	require.NoError(t, err, "Cannot check the openings")
 
-	challenge := suite.Scalar().Zero()
+	commitPoints := make([]kyber.Point, len(commits))
+	for i, commit := range commits {
+		commitPoints[i] = commit.Commit
+	}
+	challenge, err := newTranscript(ContextEncoding(context), transcriptMasterChallenge).
+		appendPoints("commits", commitPoints).
+		appendScalars("openings", openings).
+		hashToScalar(suite, HashChallenge)
+	require.NoError(t, err)
+	require.True(t, cs.Equal(challenge), "Wrong computation of challenge cs: %s instead of %s", cs, challenge)
+
+	//Legacy contexts use the sum of the openings
+	cs, err = checkOpenings(suite, legacyContext(t, context), commits, openings)
+	require.NoError(t, err, "Cannot check the openings")
+	challenge = suite.Scalar().Zero()
 	for _, temp := range openings {
 		challenge = suite.Scalar().Add(challenge, temp)
 	}
-	require.True(t, cs.Equal(challenge), "Wrong computation of challenge cs: %s instead of %s", cs, challenge)
+	require.True(t, cs.Equal(challenge), "Wrong computation of legacy challenge cs: %s instead of %s", cs, challenge)
 
 	//Empty inputs
 	cs, err = checkOpenings(suite, nil, commits, openings)
@@ -143,7 +158,7 @@ func TestInitializeChallenge(t *testing.T) {
 	var commits []ChallengeCommitment
 	var openings []kyber.Scalar
 	for i := 0; i < len(servers); i++ {
-		commit, open, _ := NewChallengeCommitment(suite, servers[i])
+		commit, open, _ := NewChallengeCommitment(suite, context, servers[i])
 		commits = append(commits, *commit)
 		openings = append(openings, open)
 	}
@@ -186,7 +201,7 @@ func TestCheckUpdateChallenge(t *testing.T) {
 	var commits []ChallengeCommitment
 	var openings []kyber.Scalar
 	for i := 0; i < len(servers); i++ {
-		commit, open, _ := NewChallengeCommitment(suite, servers[i])
+		commit, open, _ := NewChallengeCommitment(suite, context, servers[i])
 		commits = append(commits, *commit)
 		openings = append(openings, open)
 	}
@@ -259,7 +274,7 @@ func TestFinalizeChallenge(t *testing.T) {
 	var commits []ChallengeCommitment
 	var openings []kyber.Scalar
 	for i := 0; i < len(servers); i++ {
-		commit, open, _ := NewChallengeCommitment(suite, servers[i])
+		commit, open, _ := NewChallengeCommitment(suite, context, servers[i])
 		commits = append(commits, *commit)
 		openings = append(openings, open)
 	}
@@ -321,7 +336,7 @@ func TestInitializeServerMessage(t *testing.T) {
 		}
 	}
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	// setup test server "channels" with valid dummy challenge
 	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
@@ -353,7 +368,7 @@ func TestServerProtocol(t *testing.T) {
 		require.NotNil(t, server.RoundSecret(), "Error in r for server %d", server.Index())
 	}
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	// setup test server "channels" with valid dummy challenge
 	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
@@ -444,10 +459,45 @@ func TestServerProtocol(t *testing.T) {
 	require.NoError(t, err, "Error in Server Protocol for misbehaving client and server 1\n%s", err)
 }
 
+func TestServerProtocolWithMode(t *testing.T) {
+	clients, servers, context, _ := GenerateTestContext(suite, 2, 4)
+	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
+	authMsg, err := NewAuthenticationMessage(suite, context, clients[0], sendCommitsReceiveChallenge)
+	require.NoError(t, err)
+
+	//Same result in both modes
+	fullMsg := ServerMessage{Request: *authMsg}
+	incrementalMsg := ServerMessage{Request: *authMsg}
+	for _, server := range servers {
+		require.NoError(t, ServerProtocolWithMode(suite, &fullMsg, server, VerifyAll))
+		require.NoError(t, ServerProtocolWithMode(suite, &incrementalMsg, server, VerifyPrevious))
+	}
+	fullTf, err := GetFinalLinkageTag(suite, context, fullMsg)
+	require.NoError(t, err)
+	incrementalTf, err := GetFinalLinkageTag(suite, context, incrementalMsg)
+	require.NoError(t, err)
+	require.True(t, fullTf.Equal(incrementalTf))
+
+	//The signature of the previous server covers the contributions of all the previous servers
+	servMsg := ServerMessage{Request: *authMsg}
+	require.NoError(t, ServerProtocolWithMode(suite, &servMsg, servers[0], VerifyPrevious))
+	require.NoError(t, ServerProtocolWithMode(suite, &servMsg, servers[1], VerifyPrevious))
+	servMsg.Proofs[0].R1 = suite.Scalar().Pick(suite.RandomStream())
+	err = ServerProtocolWithMode(suite, &servMsg, servers[2], VerifyPrevious)
+	var blame *BlameError
+	require.True(t, errors.As(err, &blame), "tampered contribution accepted: %s", err)
+	require.True(t, blame.Report.Servers[0].Skipped)
+	require.Equal(t, 1, blame.Report.FirstFailure)
+
+	//Unknown mode
+	servMsg = ServerMessage{Request: *authMsg}
+	require.Error(t, ServerProtocolWithMode(suite, &servMsg, servers[0], VerificationMode(-1)))
+}
+
 func TestGenerateServerProof(t *testing.T) {
 	clients, servers, context, _ := GenerateTestContext(suite, 2, 2)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	T0, _ := tagAndCommitments.T0, tagAndCommitments.SCommits
 
 	// setup test server "channels" with valid dummy challenge
@@ -514,7 +564,7 @@ func TestGenerateServerProof(t *testing.T) {
 func TestVerifyServerProof(t *testing.T) {
 	clients, servers, context, _ := GenerateTestContext(suite, 2, rand.Intn(10)+2)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	// setup test server "channels" with valid dummy challenge
 	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
@@ -632,7 +682,7 @@ func TestVerifyServerProof(t *testing.T) {
 func TestGenerateMisbehavingProof(t *testing.T) {
 	clients, servers, context, _ := GenerateTestContext(suite, 2, 2)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	// setup test server "channels" with valid dummy challenge
 	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
@@ -646,7 +696,7 @@ func TestGenerateMisbehavingProof(t *testing.T) {
 		P0:                       proof,
 	}
 
-	serverProof, err := generateMisbehavingProof(suite, clientMessage.SCommits[0], servers[0])
+	serverProof, err := generateMisbehavingProof(suite, ContextEncoding(context), clientMessage.SCommits[0], servers[0])
 	if err != nil || serverProof == nil {
 		t.Error("Cannot generate misbehaving proof")
 	}
@@ -660,7 +710,7 @@ func TestGenerateMisbehavingProof(t *testing.T) {
 	require.Nil(t, serverProof.R2, "r2 not nil for misbehaving proof")
 
 	//Invalid inputs
-	serverProof, err = generateMisbehavingProof(suite, nil, servers[0])
+	serverProof, err = generateMisbehavingProof(suite, ContextEncoding(context), nil, servers[0])
 	require.Error(t, err, "Wrong check: Invalid Z")
 	require.Nil(t, serverProof, "Wrong check: Invalid Z")
 }
@@ -668,7 +718,7 @@ func TestGenerateMisbehavingProof(t *testing.T) {
 func TestVerifyMisbehavingProof(t *testing.T) {
 	clients, servers, context, _ := GenerateTestContext(suite, 2, 2)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 
 	// setup test server "channels" with valid dummy challenge
 	sendCommitsReceiveChallenge := newDummyServerChannels(suite.Scalar().Pick(suite.RandomStream()), servers)
@@ -682,26 +732,26 @@ func TestVerifyMisbehavingProof(t *testing.T) {
 		P0:                       clientProof,
 	}
 
-	proof, _ := generateMisbehavingProof(suite, clientMessage.SCommits[0], servers[0])
+	proof, _ := generateMisbehavingProof(suite, ContextEncoding(context), clientMessage.SCommits[0], servers[0])
 
-	check := verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check := verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.True(t, check, "Cannot verify valid misbehaving proof")
 
 	//Invalid inputs
-	check = verifyMisbehavingProof(suite, nil, proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), nil, proof, clientMessage.SCommits[0])
 	require.False(t, check, "Wrong check: Invalid public key")
 
-	check = verifyMisbehavingProof(suite, servers[1].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[1].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Wrong check: Invalid index")
 
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), nil, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), nil, clientMessage.SCommits[0])
 	require.False(t, check, "Wrong check: Missing proof")
 
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, nil)
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, nil)
 	require.False(t, check, "Wrong check: Invalid Z")
 
 	//Modify proof values
-	proof, _ = generateMisbehavingProof(suite, clientMessage.SCommits[0], servers[0])
+	proof, _ = generateMisbehavingProof(suite, ContextEncoding(context), clientMessage.SCommits[0], servers[0])
 	saveProof := ServerProof{
 		C:  proof.C,
 		T1: proof.T1,
@@ -713,32 +763,32 @@ func TestVerifyMisbehavingProof(t *testing.T) {
 
 	//Check inputs
 	proof.C = nil
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in challenge verification")
 	proof.C = saveProof.C
 
 	proof.T1 = nil
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in t1 verification")
 	proof.T1 = saveProof.T1
 
 	proof.T2 = nil
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in t2 verification")
 	proof.T2 = saveProof.T2
 
 	proof.T3 = nil
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in t3 verification")
 	proof.T3 = saveProof.T3
 
 	proof.R1 = nil
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in r1 verification")
 	proof.R1 = saveProof.R1
 
 	proof.R2 = suite.Scalar().One()
-	check = verifyMisbehavingProof(suite, servers[0].PublicKey(), proof, clientMessage.SCommits[0])
+	check = verifyMisbehavingProof(suite, ContextEncoding(context), servers[0].PublicKey(), proof, clientMessage.SCommits[0])
 	require.False(t, check, "Error in r2 verification")
 	proof.R2 = saveProof.R2
 	// TODO: Complete the tests
@@ -757,7 +807,7 @@ func TestGenerateNewRoundSecret(t *testing.T) {
 func TestToBytes_ServerProof(t *testing.T) {
 	clients, servers, context, _ := GenerateTestContext(suite, 2, 2)
 	members := context.Members()
-	tagAndCommitments, s := newInitialTagAndCommitments(suite, members.Y, context.ClientsGenerators()[clients[0].Index()])
+	tagAndCommitments, s, _ := newInitialTagAndCommitments(suite, ContextEncoding(context), members.Y, context.ClientsGenerators()[clients[0].Index()])
 	_, S := tagAndCommitments.T0, tagAndCommitments.SCommits
 
 	// setup test server "channels" with valid dummy challenge
@@ -777,13 +827,13 @@ func TestToBytes_ServerProof(t *testing.T) {
 	ServerProtocol(suite, &servMsg, servers[0])
 
 	//Normal execution for correct proof
-	data, err := servMsg.Proofs[0].ToBytes()
+	data, err := servMsg.Proofs[0].ToBytes(ContextEncoding(context))
 	require.NoError(t, err, "Cannot convert normal proof")
 	require.NotNil(t, data, "Cannot convert normal proof")
 
 	//Normal execution for correct misbehaving proof
-	proof, _ := generateMisbehavingProof(suite, S[0], servers[0])
-	data, err = proof.ToBytes()
+	proof, _ := generateMisbehavingProof(suite, ContextEncoding(context), S[0], servers[0])
+	data, err = proof.ToBytes(ContextEncoding(context))
 	require.NoError(t, err, "Cannot convert misbehaving proof")
 	require.NotNil(t, data, "Cannot convert misbehaving proof")
 }
@@ -0,0 +1,169 @@
+package daga
+
+// This file contains the signature schemes used by the servers to endorse the contexts, the challenges, the challenge
+// commitments and the server messages, and the Ed25519 (RFC 8032) implementation on the edwards25519 suite.
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber"
+)
+
+// SignatureScheme identifies the signature scheme used by the servers under a context
+type SignatureScheme int
+
+const (
+	// SignatureSchnorr is the original scheme, the Schnorr signatures of kyber over the group of the suite
+	// (see SchnorrSign), they can only be verified with kyber
+	SignatureSchnorr SignatureScheme = 0
+	// SignatureEd25519 produces standard Ed25519 signatures (RFC 8032), verifiable by any Ed25519 library using the
+	// marshalled public keys of the servers, only available with the edwards25519 suite (see NewSuiteEC)
+	SignatureEd25519 SignatureScheme = 1
+)
+
+// SchemedContext is implemented by the AuthenticationContexts that specify the signature scheme of their servers,
+// the contexts that don't implement it use SignatureSchnorr.
+type SchemedContext interface {
+	AuthenticationContext
+	SigningScheme() SignatureScheme
+}
+
+// ContextSignatureScheme returns the SignatureScheme used for all the signatures produced under context
+func ContextSignatureScheme(context AuthenticationContext) SignatureScheme {
+	if schemed, ok := context.(SchemedContext); ok {
+		return schemed.SigningScheme()
+	}
+	return SignatureSchnorr
+}
+
+// DefaultSignatureScheme returns the SignatureScheme of the new contexts of suite,
+// SignatureEd25519 on the edwards25519 suite and SignatureSchnorr on the others
+func DefaultSignatureScheme(suite Suite) SignatureScheme {
+	if isEdwards25519(suite) {
+		return SignatureEd25519
+	}
+	return SignatureSchnorr
+}
+
+// returns whether suite is the edwards25519 suite (or a suite wrapping it, see WithRandomStream)
+func isEdwards25519(suite Suite) bool {
+	return suite.String() == new(suiteEC).String()
+}
+
+// returns an error if scheme can't be used with suite
+func (scheme SignatureScheme) check(suite Suite) error {
+	switch scheme {
+	case SignatureSchnorr:
+		return nil
+	case SignatureEd25519:
+		if !isEdwards25519(suite) {
+			return fmt.Errorf("Ed25519 signatures need the edwards25519 suite, not %s", suite.String())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown signature scheme %d", scheme)
+	}
+}
+
+// Sign signs msg with private using the signature scheme
+func (scheme SignatureScheme) Sign(suite Suite, private kyber.Scalar, msg []byte) ([]byte, error) {
+	if err := scheme.check(suite); err != nil {
+		return nil, errors.New("cannot sign: " + err.Error())
+	}
+	if scheme == SignatureEd25519 {
+		return Ed25519Sign(suite, private, msg)
+	}
+	return SchnorrSign(suite, private, msg)
+}
+
+// Verify checks if sig is a valid signature of msg by the owner of public using the signature scheme,
+// returns an error if it is not the case
+func (scheme SignatureScheme) Verify(suite Suite, public kyber.Point, msg, sig []byte) error {
+	if err := scheme.check(suite); err != nil {
+		return errors.New("cannot verify: " + err.Error())
+	}
+	if scheme == SignatureEd25519 {
+		return Ed25519Verify(suite, public, msg, sig)
+	}
+	return SchnorrVerify(suite, public, msg, sig)
+}
+
+// Ed25519Sign signs msg with private as specified in RFC 8032 (PureEdDSA on edwards25519), the signature R || S can be
+// verified by any Ed25519 implementation with the marshalled public key private*B.
+//
+// the keys of DAGA are scalars and not the 32 bytes seeds of RFC 8032, hence the nonce can't be derived from the
+// seed. instead it is derived from the private key, 32 bytes of the random stream of the suite and the message,
+// (the verifiers can't tell the difference, and a broken random stream alone doesn't leak the private key)
+func Ed25519Sign(suite Suite, private kyber.Scalar, msg []byte) ([]byte, error) {
+	if !isEdwards25519(suite) {
+		return nil, errors.New("Ed25519Sign: not an edwards25519 suite")
+	}
+	if private == nil {
+		return nil, errors.New("cannot sign, no private key provided")
+	}
+	if len(msg) == 0 {
+		return nil, errors.New("empty message")
+	}
+	a, err := private.MarshalBinary()
+	if err != nil {
+		return nil, errors.New("failed to sign the message: " + err.Error())
+	}
+	A, err := suite.Point().Mul(private, nil).MarshalBinary()
+	if err != nil {
+		return nil, errors.New("failed to sign the message: " + err.Error())
+	}
+
+	// r = H(a || random || M), R = rB
+	random := make([]byte, 32)
+	suite.RandomStream().XORKeyStream(random, random)
+	h := sha512.New()
+	h.Write([]byte("DAGA Ed25519 nonce"))
+	h.Write(a)
+	h.Write(random)
+	h.Write(msg)
+	r := suite.Scalar().SetBytes(h.Sum(nil))
+	R, err := suite.Point().Mul(r, nil).MarshalBinary()
+	if err != nil {
+		return nil, errors.New("failed to sign the message: " + err.Error())
+	}
+
+	// S = r + H(R || A || M)a mod L
+	h.Reset()
+	h.Write(R)
+	h.Write(A)
+	h.Write(msg)
+	k := suite.Scalar().SetBytes(h.Sum(nil))
+	S, err := suite.Scalar().Add(r, suite.Scalar().Mul(k, private)).MarshalBinary()
+	if err != nil {
+		return nil, errors.New("failed to sign the message: " + err.Error())
+	}
+	return append(R, S...), nil
+}
+
+// Ed25519Verify checks if sig is a valid Ed25519 signature (RFC 8032) of msg by the owner of public,
+// returns an error if it is not the case
+func Ed25519Verify(suite Suite, public kyber.Point, msg, sig []byte) error {
+	if !isEdwards25519(suite) {
+		return errors.New("Ed25519Verify: not an edwards25519 suite")
+	}
+	if public == nil {
+		return errors.New("cannot verify, no public key provided")
+	}
+	if len(msg) == 0 {
+		return errors.New("empty message")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return errors.New("invalid signature length")
+	}
+	A, err := public.MarshalBinary()
+	if err != nil || len(A) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	if !ed25519.Verify(A, msg, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
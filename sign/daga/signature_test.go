@@ -0,0 +1,148 @@
+package daga
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+// verifies sig with the Ed25519 of the standard library
+func stdlibVerify(t *testing.T, public kyber.Point, msg, sig []byte) bool {
+	A, err := public.MarshalBinary()
+	require.NoError(t, err)
+	return ed25519.Verify(A, msg, sig)
+}
+
+func TestEd25519Sign(t *testing.T) {
+	priv := suite.NewKey(suite.RandomStream())
+	pub := suite.Point().Mul(priv, nil)
+	msg := []byte("test message")
+
+	sig, err := Ed25519Sign(suite, priv, msg)
+	require.NoError(t, err)
+	require.Len(t, sig, ed25519.SignatureSize)
+	require.True(t, stdlibVerify(t, pub, msg, sig), "signature rejected by crypto/ed25519")
+	require.NoError(t, Ed25519Verify(suite, pub, msg, sig))
+	require.False(t, stdlibVerify(t, pub, []byte("other message"), sig))
+	require.Error(t, Ed25519Verify(suite, pub, []byte("other message"), sig))
+	require.Error(t, Ed25519Verify(suite, pub, msg, sig[1:]))
+
+	_, err = Ed25519Sign(suite, nil, msg)
+	require.Error(t, err)
+	_, err = Ed25519Sign(suite, priv, nil)
+	require.Error(t, err)
+	_, err = Ed25519Sign(NewSuiteRistretto(), priv, msg)
+	require.Error(t, err)
+}
+
+func TestEd25519Verify_Stdlib(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	msg := []byte("test message")
+	sig := ed25519.Sign(private, msg)
+
+	// the secret scalar of the key, (see RFC 8032 5.1.5)
+	h := sha512.Sum512(private.Seed())
+	h[0] &= 248
+	h[31] &= 127
+	h[31] |= 64
+	priv := suite.Scalar().SetBytes(h[:32])
+	pub := suite.Point().Mul(priv, nil)
+	A, err := pub.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, []byte(public), A)
+
+	require.NoError(t, Ed25519Verify(suite, pub, msg, sig))
+	require.Error(t, Ed25519Verify(suite, pub, []byte("other message"), sig))
+}
+
+func TestSignatureScheme(t *testing.T) {
+	require.Equal(t, SignatureEd25519, DefaultSignatureScheme(NewSuiteEC()))
+	require.Equal(t, SignatureSchnorr, DefaultSignatureScheme(NewSuiteSchnorr()))
+	require.Equal(t, SignatureSchnorr, DefaultSignatureScheme(NewSuiteRistretto()))
+
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, _, context, err := GenerateTestContext(suite, 2, 2)
+			require.NoError(t, err)
+			require.Equal(t, DefaultSignatureScheme(suite), ContextSignatureScheme(context), "new contexts don't use the scheme of their suite")
+
+			// the scheme is covered by the encoding of the context, unless it is the original one
+			other := *context.(*MinimumAuthenticationContext)
+			if other.SignatureScheme == SignatureEd25519 {
+				other.SignatureScheme = SignatureSchnorr
+			} else {
+				other.SignatureScheme = SignatureEd25519
+			}
+			current, err := AuthenticationContextToBytes(context)
+			require.NoError(t, err)
+			changed, err := AuthenticationContextToBytes(other)
+			require.NoError(t, err)
+			require.NotEqual(t, current, changed)
+
+			// Ed25519 is rejected outside of edwards25519
+			err = ValidateContextElements(suite, other)
+			if other.SignatureScheme == SignatureEd25519 {
+				require.True(t, errors.Is(err, ErrInvalidContext), "wrong error: %s", err)
+				_, err = SignatureEd25519.Sign(suite, suite.NewKey(suite.RandomStream()), []byte("test"))
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			other.SignatureScheme = 42
+			require.Error(t, ValidateContextElements(suite, other))
+		})
+	}
+
+	// contexts that don't specify their scheme use the original one
+	_, _, context, err := GenerateTestContext(suite, 2, 2)
+	require.NoError(t, err)
+	var unschemed struct{ AuthenticationContext }
+	unschemed.AuthenticationContext = context
+	require.Equal(t, SignatureSchnorr, ContextSignatureScheme(unschemed))
+}
+
+// all the signatures of an authentication on the edwards25519 suite are verifiable by crypto/ed25519
+func TestSignatureEd25519_Authentication(t *testing.T) {
+	clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, SignatureEd25519, ContextSignatureScheme(context))
+	members := context.Members()
+
+	var commitments []kyber.Point
+	authMsg, err := NewAuthenticationMessage(suite, context, clients[1], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		commitments = pkClientCommitments
+		return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+	})
+	require.NoError(t, err)
+	signData, err := authMsg.P0.Cs.ToBytes(ContextEncoding(context), commitments)
+	require.NoError(t, err)
+	for _, sig := range authMsg.P0.Cs.Sigs {
+		require.True(t, stdlibVerify(t, members.Y[sig.Index], signData, sig.Sig), "challenge signature of server %d rejected", sig.Index)
+	}
+
+	commit, _, err := NewChallengeCommitment(suite, context, servers[2])
+	require.NoError(t, err)
+	commitData, err := challengeCommitmentToBytes(ContextEncoding(context), commit.Index, commit.Commit)
+	require.NoError(t, err)
+	require.True(t, stdlibVerify(t, members.Y[2], commitData, commit.Sig), "commitment signature rejected")
+
+	servMsg, err := InitializeServerMessage(authMsg)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, ServerProtocol(suite, servMsg, server))
+	}
+	tr, err := newServerMessageTranscript(servMsg.Request)
+	require.NoError(t, err)
+	for i := range servMsg.Proofs {
+		data, err := appendServerContribution(tr, servMsg.Tags[i], servMsg.Proofs[i], servMsg.Indexes[i])
+		require.NoError(t, err)
+		require.True(t, stdlibVerify(t, members.Y[servMsg.Indexes[i]], data, servMsg.Sigs[i].Sig), "signature of server %d rejected", servMsg.Indexes[i])
+	}
+	_, err = GetFinalLinkageTag(suite, context, *servMsg)
+	require.NoError(t, err)
+}
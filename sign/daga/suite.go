@@ -3,6 +3,10 @@ package daga
 import (
 	"crypto/cipher"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"github.com/dedis/fixbuf"
 	"go.dedis.ch/kyber"
 	"go.dedis.ch/kyber/group/edwards25519"
@@ -11,6 +15,7 @@ import (
 	"hash"
 	"io"
 	"reflect"
+	"sync"
 )
 
 // SuiteEC is the EC crypto concrete implementation of the DAGA Suite interface,
@@ -34,20 +39,96 @@ func (s suiteEC) Hash() hash.Hash {
 	return sha256.New()
 }
 
-// returns new hash.Hash computing the SHA-256 checksum
-// this hash is used in DAGA as a random oracle to build the NIZK proof of the servers.
-func (s suiteEC) hashTwo() hash.Hash {
-	// needs to distribute "uniformly" (RO) the input to the range of valid exponents, (i.e. phi(p) for the shnorr group example)
-	// => all field elements
-	// QUESTION: but I'll have to admit that, since we work in a subgroup of order q I don't get why it was especially
-	//  required a different range, in my mind it is sufficient to map to Zq, or put differently the exponents are eq mod q...(not formally written hope you understand what I mean)
-	return s.Hash()
+// hashToScalar implements the labeled random oracles of DAGA (see Suite), the SHA-512 checksum of the
+// domain-separated input is reduced modulo the group order (64 bytes "wide" reduction, hence the bias is negligible)
+func (s suiteEC) hashToScalar(label HashLabel, data []byte) kyber.Scalar {
+	h := sha512.New()
+	h.Write(labeledHashInput(s.String(), label, data))
+	return s.Scalar().SetBytes(h.Sum(nil))
+}
+
+// hashToCurve implements hash_to_curve for the edwards25519_XMD:SHA-512_ELL2_RO_ suite of RFC 9380
+func (s suiteEC) hashToCurve(msg, dst []byte) (kyber.Point, error) {
+	return hashToEdwards25519(s.Point, s.Scalar().SetInt64(8), sha512.New, msg, dst)
+}
+
+func (s suiteEC) hashToCurveID() string {
+	return edwards25519HashToCurveID
+}
+
+// checkPoint returns an error if P has a torsion component, that is if (l-1)P + P = lP is not the identity,
+// where l is the order of the prime order subgroup
+func (s suiteEC) checkPoint(P kyber.Point) error {
+	lMinusOne := s.Scalar().SetInt64(-1)
+	if !s.Point().Add(s.Point().Mul(lMinusOne, P), P).Equal(s.Point().Null()) {
+		return errors.New("point not in the prime order subgroup")
+	}
+	return nil
 }
 
 func (s suiteEC) RandomStream() cipher.Stream {
 	return random.New()
 }
 
+// the constructors of all the concrete suites, used by SuiteByName
+var suiteConstructors = []func() Suite{
+	NewSuiteEC,
+	NewSuiteSchnorr,
+	NewSuiteRistretto,
+}
+
+// SuiteByName returns a new Suite of the concrete type whose name (as returned by its String method) is name,
+// used to resolve at runtime the suite identified in an auth. context
+func SuiteByName(name string) (Suite, error) {
+	for _, newSuite := range suiteConstructors {
+		if suite := newSuite(); suite.String() == name {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("SuiteByName: unknown suite: \"%s\"", name)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// WithRandomStream returns a Suite that behaves like suite but draws all its randomness from stream, to reproduce
+// byte for byte the outputs of DAGA computed with it (client messages, server proofs and signatures, challenge
+// commitments, generated keys and contexts), e.g. to replay in tests a transcript recorded with a seeded stream
+// (like blake2xb.New(seed)). to use the stream for a single call, pass the returned suite to that call only.
+//
+// the draws are serialized, the outputs only depend on stream if the calls using the suite are not concurrent.
+// the randomness of the verifiers (see verifierRandomStream) is never drawn from stream.
+// a predictable stream breaks the security of DAGA, never use it outside of tests and replays
+func WithRandomStream(suite Suite, stream cipher.Stream) Suite {
+	return suiteWithStream{Suite: suite, stream: &lockedStream{stream: stream}}
+}
+
+// a Suite whose randomness is drawn from a given stream, see WithRandomStream
+type suiteWithStream struct {
+	Suite
+	stream cipher.Stream
+}
+
+func (s suiteWithStream) RandomStream() cipher.Stream {
+	return s.stream
+}
+
+// a cipher.Stream safe for concurrent use
+type lockedStream struct {
+	mutex  sync.Mutex
+	stream cipher.Stream
+}
+
+func (s *lockedStream) XORKeyStream(dst, src []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stream.XORKeyStream(dst, src)
+}
+
+// returns the stream of the randomness of the verifiers (e.g. the coefficients of the combined checks), never the stream
+// of a suite returned by WithRandomStream: a verifier whose coefficients can be predicted can be fooled
+func verifierRandomStream() cipher.Stream {
+	return random.New()
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 // used to give to the kyber.proof framework/package the methods it needs to operate, satisfy both proof.Suite and daga.Suite
 type SuiteProof struct {
@@ -88,12 +169,138 @@ func (s SuiteProof) New(t reflect.Type) interface{} {
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-// TODO concrete implementation that uses same primitives that in DAGA paper (work in a schnorr group)
-// TODO QUESTION did someone already implemented a kyber Schnorr group somewhere (with mod.Int) ? => no, might be a good idea
-//type suiteSchnorr struct {
-//	mod.Int
-//}
-//// Returns a new Suite backed by a SuiteSchnorr
-//func NewSuiteEC() Suite {
-//	return new(suiteSchnorr)
-//}
\ No newline at end of file
+// suiteSchnorr is the concrete implementation of the DAGA Suite interface that uses the same primitives than in
+// the DAGA paper, it works in the subgroup of prime order q of Zp*, where p = 2q+1 is a safe prime
+// (the 2048-bit MODP group of RFC 3526). Scalars are mod.Int modulo q.
+type suiteSchnorr struct {
+	*schnorrGroup
+}
+
+// Returns a new Suite backed by a suiteSchnorr
+func NewSuiteSchnorr() Suite {
+	return suiteSchnorr{newSchnorrGroupRFC3526()}
+}
+
+// returns new hash.Hash computing the SHA-256 checksum
+// this hash is used in DAGA to derive valid Scalars of the group used
+func (s suiteSchnorr) Hash() hash.Hash {
+	return sha256.New()
+}
+
+// hashToScalar implements the labeled random oracles of DAGA (see Suite), ScalarLen + 16 bytes are read
+// from a blake2xb XOF seeded with the domain-separated input and reduced mod q (hence the bias is negligible)
+func (s suiteSchnorr) hashToScalar(label HashLabel, data []byte) kyber.Scalar {
+	xof := blake2xb.New(nil)
+	xof.Write(labeledHashInput(s.String(), label, data))
+	out := make([]byte, s.ScalarLen()+16)
+	xof.Read(out)
+	return s.Scalar().SetBytes(out)
+}
+
+// hashToCurve hashes msg to an element of the subgroup of order q, see hashToSchnorrGroup
+func (s suiteSchnorr) hashToCurve(msg, dst []byte) (kyber.Point, error) {
+	return hashToSchnorrGroup(s.schnorrGroup, msg, dst)
+}
+
+func (s suiteSchnorr) hashToCurveID() string {
+	return schnorrHashToCurveID
+}
+
+// checkPoint returns an error if P is not an element of the subgroup of order q
+func (s suiteSchnorr) checkPoint(P kyber.Point) error {
+	if !s.inSubgroup(&P.(*schnorrPoint).V) {
+		return errors.New("point not in the subgroup of order q")
+	}
+	return nil
+}
+
+func (s suiteSchnorr) RandomStream() cipher.Stream {
+	return random.New()
+}
+
+// NewKey implements the key.Generator interface, returns a new (non-zero) private key
+func (s suiteSchnorr) NewKey(stream cipher.Stream) kyber.Scalar {
+	zero := s.Scalar().Zero()
+	for {
+		if k := s.Scalar().Pick(stream); !k.Equal(zero) {
+			return k
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// suiteRistretto is the concrete implementation of the DAGA Suite interface that works in the prime order group ristretto255,
+// it offers the same security level than suiteEC without its cofactor, hence the verification code
+// doesn't need to reason about torsion components of received points and secrets don't need to be clamped.
+type suiteRistretto struct {
+	ristrettoGroup
+}
+
+// Returns a new Suite backed by a suiteRistretto
+func NewSuiteRistretto() Suite {
+	return suiteRistretto{}
+}
+
+// returns new hash.Hash computing the SHA-512 checksum
+// this hash is used in DAGA to derive valid Scalars of the group used,
+// (the 64 bytes output is reduced modulo the group order by SetBytes, making the resulting scalars uniform)
+func (s suiteRistretto) Hash() hash.Hash {
+	return sha512.New()
+}
+
+// hashToScalar implements the labeled random oracles of DAGA (see Suite), the SHA-512 checksum of the
+// domain-separated input is reduced modulo the group order (as in ristretto255's FromUniformBytes)
+func (s suiteRistretto) hashToScalar(label HashLabel, data []byte) kyber.Scalar {
+	h := sha512.New()
+	h.Write(labeledHashInput(s.String(), label, data))
+	return s.Scalar().SetBytes(h.Sum(nil))
+}
+
+// hashToCurve implements hash_to_ristretto255 of RFC 9380 (ristretto255_XMD:SHA-512_R255MAP_RO_)
+func (s suiteRistretto) hashToCurve(msg, dst []byte) (kyber.Point, error) {
+	uniformBytes, err := expandMessageXMD(sha512.New, msg, dst, 64)
+	if err != nil {
+		return nil, err
+	}
+	P := s.Point().(*ristrettoPoint)
+	P.e.FromUniformBytes(uniformBytes)
+	return P, nil
+}
+
+func (s suiteRistretto) hashToCurveID() string {
+	return ristretto255HashToCurveID
+}
+
+// checkPoint always succeeds, all the elements of ristretto255 are elements of the prime order group
+func (s suiteRistretto) checkPoint(P kyber.Point) error {
+	return nil
+}
+
+func (s suiteRistretto) RandomStream() cipher.Stream {
+	return random.New()
+}
+
+// NewKey implements the key.Generator interface, returns a new (non-zero) private key,
+// since the group has prime order any non-zero scalar is a proper secret.
+func (s suiteRistretto) NewKey(stream cipher.Stream) kyber.Scalar {
+	zero := s.Scalar().Zero()
+	for {
+		if k := s.Scalar().Pick(stream); !k.Equal(zero) {
+			return k
+		}
+	}
+}
+
+// returns the input of the hash function labeled label of the suite named suiteName:
+// lp("DAGA/" + suiteName) || lp(label) || data, where lp(x) is the 4 bytes big-endian length of x followed by x,
+// this way the different hash functions (and those of different suites) are independent.
+func labeledHashInput(suiteName string, label HashLabel, data []byte) []byte {
+	buf := make([]byte, 0, 8+len("DAGA/")+len(suiteName)+len(label)+len(data))
+	for _, prefix := range []string{"DAGA/" + suiteName, string(label)} {
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], uint32(len(prefix)))
+		buf = append(buf, l[:]...)
+		buf = append(buf, prefix...)
+	}
+	return append(buf, data...)
+}
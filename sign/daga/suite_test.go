@@ -0,0 +1,306 @@
+package daga
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/xof/blake2xb"
+)
+
+// all the concrete Suites, the tests below are run against each of them to assert that DAGA is suite-agnostic
+var suites = []Suite{
+	NewSuiteEC(),
+	NewSuiteSchnorr(),
+	NewSuiteRistretto(),
+}
+
+// test helper that runs the distributed challenge generation (commit, open, round-robin) for the given commitments
+func runChallengeGeneration(t testing.TB, suite Suite, context AuthenticationContext, servers []Server, pkClientCommitments []kyber.Point) Challenge {
+	commits := make([]ChallengeCommitment, len(servers))
+	openings := make([]kyber.Scalar, len(servers))
+	for i, server := range servers {
+		commit, opening, err := NewChallengeCommitment(suite, context, server)
+		require.NoError(t, err)
+		commits[i], openings[i] = *commit, opening
+	}
+	challengeCheck, err := InitializeChallenge(suite, context, commits, openings)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, CheckUpdateChallenge(suite, context, challengeCheck, pkClientCommitments, server))
+	}
+	// back to the leader
+	require.NoError(t, CheckUpdateChallenge(suite, context, challengeCheck, pkClientCommitments, servers[0]))
+	challenge, err := FinalizeChallenge(context, challengeCheck)
+	require.NoError(t, err)
+	return challenge
+}
+
+// test helper that runs a full authentication of client against the servers and returns the final linkage tag
+func runAuthentication(t *testing.T, suite Suite, context AuthenticationContext, client Client, servers []Server) kyber.Point {
+	sendCommitsReceiveChallenge := func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+	}
+	authMsg, err := NewAuthenticationMessage(suite, context, client, sendCommitsReceiveChallenge)
+	require.NoError(t, err)
+	require.NoError(t, verifyAuthenticationMessage(suite, *authMsg))
+
+	servMsg, err := InitializeServerMessage(authMsg)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, ServerProtocol(suite, servMsg, server))
+	}
+	Tf, err := GetFinalLinkageTag(suite, context, *servMsg)
+	require.NoError(t, err)
+	require.NotNil(t, Tf)
+	return Tf
+}
+
+func TestSuites_Authentication(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+
+			// same client authenticating twice => same final tag, other client => another tag
+			Tf0 := runAuthentication(t, suite, context, clients[0], servers)
+			require.False(t, Tf0.Equal(suite.Point().Null()), "honest client got a null final tag")
+			Tf0Bis := runAuthentication(t, suite, context, clients[0], servers)
+			require.True(t, Tf0.Equal(Tf0Bis), "same client got different final tags in same context")
+			Tf1 := runAuthentication(t, suite, context, clients[1], servers)
+			require.False(t, Tf0.Equal(Tf1), "different clients got same final tags")
+
+			// tampered server message => rejected
+			authMsg, err := NewAuthenticationMessage(suite, context, clients[2], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+			servMsg, err := InitializeServerMessage(authMsg)
+			require.NoError(t, err)
+			for _, server := range servers {
+				require.NoError(t, ServerProtocol(suite, servMsg, server))
+			}
+			servMsg.Tags[0] = suite.Point().Pick(suite.RandomStream())
+			_, err = GetFinalLinkageTag(suite, context, *servMsg)
+			require.Error(t, err, "tampered tag accepted")
+		})
+	}
+}
+
+func TestSuites_SchnorrSignVerify(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			priv := suite.NewKey(suite.RandomStream())
+			pub := suite.Point().Mul(priv, nil)
+			msg := []byte("test message")
+			sig, err := SchnorrSign(suite, priv, msg)
+			require.NoError(t, err)
+			require.NoError(t, SchnorrVerify(suite, pub, msg, sig))
+			require.Error(t, SchnorrVerify(suite, pub, []byte("other message"), sig))
+		})
+	}
+}
+
+func TestSuites_HashToScalar(t *testing.T) {
+	labels := []HashLabel{HashSharedSecret, HashServerProof, HashMisbehavingProof, HashChallenge}
+	data := []byte("test")
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			for i, label := range labels {
+				c := suite.hashToScalar(label, data)
+				require.False(t, c.Equal(suite.Scalar().Zero()))
+				require.True(t, c.Equal(suite.hashToScalar(label, data)), "hashToScalar not deterministic")
+				require.False(t, c.Equal(suite.hashToScalar(label, []byte("other"))))
+				// the hash functions of different labels are independent
+				for _, other := range labels[i+1:] {
+					require.False(t, c.Equal(suite.hashToScalar(other, data)), "labels %s and %s map to same scalar", label, other)
+				}
+			}
+		})
+	}
+
+	// the label and data are unambiguously encoded
+	require.NotEqual(t, labeledHashInput("suite", "ab", []byte("c")), labeledHashInput("suite", "a", []byte("bc")))
+}
+
+func TestSuiteSchnorr_Group(t *testing.T) {
+	suite := NewSuiteSchnorr()
+	group := suite.(suiteSchnorr).schnorrGroup
+
+	// parameters
+	require.Equal(t, 2048, group.P.BitLen())
+	require.True(t, group.P.ProbablyPrime(20), "p not prime")
+	require.True(t, group.Q.ProbablyPrime(20), "q not prime")
+	require.Equal(t, 256, suite.PointLen())
+	require.Equal(t, 256, suite.ScalarLen())
+
+	// group laws
+	a := suite.Scalar().Pick(suite.RandomStream())
+	b := suite.Scalar().Pick(suite.RandomStream())
+	A := suite.Point().Mul(a, nil)
+	B := suite.Point().Mul(b, nil)
+	require.True(t, suite.Point().Add(A, B).Equal(suite.Point().Mul(suite.Scalar().Add(a, b), nil)))
+	require.True(t, suite.Point().Sub(A, B).Equal(suite.Point().Mul(suite.Scalar().Sub(a, b), nil)))
+	require.True(t, suite.Point().Add(A, suite.Point().Neg(A)).Equal(suite.Point().Null()))
+	require.True(t, suite.Point().Mul(b, A).Equal(suite.Point().Mul(a, B)))
+	require.True(t, suite.Point().Mul(suite.Scalar().SetInt64(0), A).Equal(suite.Point().Null()))
+	qMinusOne := suite.Scalar().Neg(suite.Scalar().One())
+	require.True(t, suite.Point().Mul(qMinusOne, A).Equal(suite.Point().Neg(A)))
+
+	// encoding
+	P := suite.Point().Pick(suite.RandomStream())
+	buf, err := P.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, P.MarshalSize(), len(buf))
+	P2 := suite.Point()
+	require.NoError(t, P2.UnmarshalBinary(buf))
+	require.True(t, P.Equal(P2))
+
+	// embedding
+	data := []byte("some data to embed")
+	E := suite.Point().Embed(data, suite.RandomStream())
+	extracted, err := E.Data()
+	require.NoError(t, err)
+	require.Equal(t, data, extracted)
+
+	// invalid encodings
+	require.Error(t, P2.UnmarshalBinary(buf[1:]), "wrong size accepted")
+	require.Error(t, P2.UnmarshalBinary(make([]byte, suite.PointLen())), "zero accepted")
+	// p-1 is not a quadratic residue (p = 3 mod 4) => not in the subgroup of order q
+	pMinusOne := new(big.Int).Sub(group.P, big.NewInt(1)).Bytes()
+	require.Error(t, P2.UnmarshalBinary(pMinusOne), "element outside of the subgroup accepted")
+	tooBig := make([]byte, suite.PointLen())
+	for i := range tooBig {
+		tooBig[i] = 0xff
+	}
+	require.Error(t, P2.UnmarshalBinary(tooBig), "element bigger than p accepted")
+
+	// client generators
+	commits := make([]kyber.Point, rand.Intn(5)+1)
+	for i := range commits {
+		commits[i] = suite.Point().Pick(suite.RandomStream())
+	}
+	gen, err := GenerateClientGenerator(suite, CurrentGenerators, 0, commits)
+	require.NoError(t, err)
+	genBuf, err := gen.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, suite.Point().UnmarshalBinary(genBuf), "client generator not in the subgroup")
+}
+
+func TestSuiteRistretto_Group(t *testing.T) {
+	suite := NewSuiteRistretto()
+
+	// scalars
+	a := suite.Scalar().Pick(suite.RandomStream())
+	b := suite.Scalar().Pick(suite.RandomStream())
+	require.True(t, suite.Scalar().Mul(suite.Scalar().Div(a, b), b).Equal(a))
+	require.True(t, suite.Scalar().Add(a, suite.Scalar().Neg(a)).Equal(suite.Scalar().Zero()))
+	require.True(t, suite.Scalar().SetInt64(-1).Equal(suite.Scalar().Neg(suite.Scalar().One())))
+	// SetBytes is little-endian and reduces modulo the group order
+	require.True(t, suite.Scalar().SetBytes([]byte{2}).Equal(suite.Scalar().SetInt64(2)))
+	wide := make([]byte, 64)
+	for i := range wide {
+		wide[i] = 0xff
+	}
+	require.NotPanics(t, func() { suite.Scalar().SetBytes(wide) })
+
+	// group laws
+	A := suite.Point().Mul(a, nil)
+	B := suite.Point().Mul(b, nil)
+	require.True(t, suite.Point().Add(A, B).Equal(suite.Point().Mul(suite.Scalar().Add(a, b), nil)))
+	require.True(t, suite.Point().Sub(A, B).Equal(suite.Point().Mul(suite.Scalar().Sub(a, b), nil)))
+	require.True(t, suite.Point().Mul(b, A).Equal(suite.Point().Mul(a, B)))
+	require.True(t, suite.Point().Mul(suite.Scalar().One(), nil).Equal(suite.Point().Base()))
+
+	// encoding
+	buf, err := A.MarshalBinary()
+	require.NoError(t, err)
+	A2 := suite.Point()
+	require.NoError(t, A2.UnmarshalBinary(buf))
+	require.True(t, A.Equal(A2))
+	sBuf, err := a.MarshalBinary()
+	require.NoError(t, err)
+	a2 := suite.Scalar()
+	require.NoError(t, a2.UnmarshalBinary(sBuf))
+	require.True(t, a.Equal(a2))
+
+	// invalid encodings
+	nonCanonical := make([]byte, 32)
+	for i := range nonCanonical {
+		nonCanonical[i] = 0xff
+	}
+	require.Error(t, A2.UnmarshalBinary(nonCanonical), "non canonical point encoding accepted")
+	require.Error(t, a2.UnmarshalBinary(nonCanonical), "non canonical scalar encoding accepted")
+	require.Error(t, A2.UnmarshalBinary(buf[1:]), "wrong size accepted")
+
+	// embedding data is not supported
+	require.Zero(t, A2.EmbedLen())
+	require.NotPanics(t, func() { A2.Embed(nil, suite.RandomStream()) })
+	require.Panics(t, func() { A2.Embed([]byte{1}, suite.RandomStream()) })
+}
+
+func TestSuiteByName(t *testing.T) {
+	for _, suite := range suites {
+		resolved, err := SuiteByName(suite.String())
+		require.NoError(t, err)
+		require.Equal(t, suite.String(), resolved.String())
+		require.IsType(t, suite, resolved)
+	}
+
+	_, err := SuiteByName("")
+	require.Error(t, err, "empty suite name resolved")
+	_, err = SuiteByName("P-256")
+	require.Error(t, err, "unknown suite name resolved")
+}
+
+// the same seed gives the same context, authentication message and server message, byte for byte
+func TestWithRandomStream(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			// returns the encodings of the context and of the messages of an authentication run with seed
+			run := func(seed string) [][]byte {
+				seeded := WithRandomStream(suite, blake2xb.New([]byte(seed)))
+				clients, servers, context, err := GenerateTestContext(seeded, 3, 2)
+				require.NoError(t, err)
+				authMsg, err := NewAuthenticationMessage(seeded, context, clients[1], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+					return runChallengeGeneration(t, seeded, context, servers, pkClientCommitments), nil
+				})
+				require.NoError(t, err)
+				servMsg, err := InitializeServerMessage(authMsg)
+				require.NoError(t, err)
+				for _, server := range servers {
+					require.NoError(t, ServerProtocol(seeded, servMsg, server))
+				}
+
+				var encodings [][]byte
+				contextBytes, err := AuthenticationContextToBytes(context)
+				require.NoError(t, err)
+				authBytes, err := authMsg.ToBytes()
+				require.NoError(t, err)
+				challengeBytes, err := authMsg.P0.Cs.ToBytes(ContextEncoding(context), authMsg.P0.T)
+				require.NoError(t, err)
+				tagsBytes, err := PointArrayToBytes(servMsg.Tags)
+				require.NoError(t, err)
+				encodings = append(encodings, contextBytes, authBytes, challengeBytes, tagsBytes)
+				for _, sig := range authMsg.P0.Cs.Sigs {
+					encodings = append(encodings, sig.Sig)
+				}
+				for i, proof := range servMsg.Proofs {
+					proofBytes, err := proof.ToBytes(ContextEncoding(context))
+					require.NoError(t, err)
+					encodings = append(encodings, proofBytes, servMsg.Sigs[i].Sig)
+				}
+				return encodings
+			}
+
+			replay := run("seed")
+			require.Equal(t, replay, run("seed"))
+			other := run("other seed")
+			require.Len(t, other, len(replay))
+			require.NotEqual(t, replay[0], other[0])
+			require.NotEqual(t, replay[1], other[1])
+		})
+	}
+}
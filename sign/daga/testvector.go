@@ -0,0 +1,571 @@
+package daga
+
+// This file contains the known-answer test vectors of DAGA, a TestVector records the keys and the random values of an
+// honest authentication together with all the resulting values and encodings. GenerateTestVector produces them
+// (see cmd/dagavectors) and CheckTestVector recomputes and checks everything from the keys and the random values,
+// so that other implementations can prove that they interoperate with this one.
+
+import (
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber"
+	"go.dedis.ch/kyber/util/key"
+	"go.dedis.ch/kyber/xof/blake2xb"
+)
+
+// TestVector is a known-answer test of an honest authentication of the client at ClientIndex, the servers process
+// the request in the order of their indexes. all the points, scalars, signatures and encodings are hex encoded
+// (points and scalars using the MarshalBinary of the suite).
+type TestVector struct {
+	Description string               `json:"description"`
+	Suite       string               `json:"suite"` // the name of the suite, see SuiteByName
+	Seed        string               `json:"seed"`  // the seed of the generator, informative only
+	Context     TestVectorContext    `json:"context"`
+	ClientIndex int                  `json:"clientIndex"`
+	Randomness  TestVectorRandomness `json:"randomness"`
+	Expected    TestVectorExpected   `json:"expected"`
+}
+
+// TestVectorContext holds the context of a TestVector and the private keys and per-round secrets of its members
+type TestVectorContext struct {
+	X                []string         `json:"x"`
+	Y                []string         `json:"y"`
+	H                []string         `json:"h"`
+	R                []string         `json:"r"`
+	EncodingVersion  EncodingVersion  `json:"encodingVersion"`
+	GeneratorVersion GeneratorVersion `json:"generatorVersion"`
+	SignatureScheme  SignatureScheme  `json:"signatureScheme"`
+	ClientKeys       []string         `json:"clientKeys"`   // the private keys of the clients
+	ServerKeys       []string         `json:"serverKeys"`   // the private keys of the servers
+	RoundSecrets     []string         `json:"roundSecrets"` // the per-round secrets of the servers
+	Encoding         string           `json:"encoding"`     // see AuthenticationContextToBytes
+}
+
+// TestVectorRandomness holds the random values drawn during the authentication of a TestVector,
+// (the random sub-challenges and responses of the simulated clauses of PKclient are part of the proof)
+type TestVectorRandomness struct {
+	Z        string   `json:"z"`        // the client's ephemeral DH private key
+	VX       string   `json:"vx"`       // the blindings of the true clause of PKclient
+	VS       string   `json:"vs"`       //
+	Openings []string `json:"openings"` // the openings of the challenge commitments of the servers
+	V1       []string `json:"v1"`       // the blindings of the proofs of the servers
+	V2       []string `json:"v2"`       //
+}
+
+// TestVectorSignature is a ServerSignature of a TestVector, the signatures depend on the randomness of the signer and
+// are only verified
+type TestVectorSignature struct {
+	Index int    `json:"index"`
+	Sig   string `json:"sig"`
+}
+
+// TestVectorServerProof is a ServerProof of a TestVector
+type TestVectorServerProof struct {
+	T1 string `json:"t1"`
+	T2 string `json:"t2"`
+	T3 string `json:"t3"`
+	C  string `json:"c"`
+	R1 string `json:"r1"`
+	R2 string `json:"r2"`
+}
+
+// TestVectorExpected holds the values and encodings resulting from the authentication of a TestVector
+type TestVectorExpected struct {
+	T0                      string                  `json:"t0"`
+	SCommits                []string                `json:"sCommits"`
+	ChallengeCommitments    []string                `json:"challengeCommitments"`
+	ChallengeCommitmentSigs []TestVectorSignature   `json:"challengeCommitmentSigs"`
+	Challenge               string                  `json:"challenge"`
+	ChallengeSigs           []TestVectorSignature   `json:"challengeSigs"`
+	T                       []string                `json:"t"` // the ClientProof
+	C                       []string                `json:"c"`
+	R                       []string                `json:"r"`
+	Tags                    []string                `json:"tags"`
+	ServerProofs            []TestVectorServerProof `json:"serverProofs"`
+	ServerSigs              []TestVectorSignature   `json:"serverSigs"`
+	FinalTag                string                  `json:"finalTag"`
+	Encodings               TestVectorEncodings     `json:"encodings"`
+}
+
+// TestVectorEncodings holds the encodings of the messages of a TestVector
+type TestVectorEncodings struct {
+	AuthenticationMessage string   `json:"authenticationMessage"` // see AuthenticationMessage.ToBytes
+	ClientProof           string   `json:"clientProof"`           // see ClientProof.ToBytes
+	Challenge             string   `json:"challenge"`             // see Challenge.ToBytes
+	ServerProofs          []string `json:"serverProofs"`          // see ServerProof.ToBytes
+}
+
+// GenerateTestVector returns the TestVector of an honest authentication of the client at clientIndex under a new context
+// of nbrClients clients and nbrServers servers, all the randomness is drawn from a blake2xb XOF seeded with seed
+// (see WithRandomStream)
+func GenerateTestVector(suite Suite, seed []byte, nbrClients, nbrServers, clientIndex int) (*TestVector, error) {
+	if clientIndex < 0 || clientIndex >= nbrClients {
+		return nil, fmt.Errorf("GenerateTestVector: client index %d out of range", clientIndex)
+	}
+	seeded := WithRandomStream(suite, blake2xb.New(seed))
+	clients, servers, context, err := GenerateTestContext(seeded, nbrClients, nbrServers)
+	if err != nil {
+		return nil, errors.New("GenerateTestVector: " + err.Error())
+	}
+	members := context.Members()
+	version := ContextEncoding(context)
+	client := clients[clientIndex]
+
+	// client's Steps 1 to 3 with a recorded ephemeral key
+	z := key.NewKeyPair(seeded).Private
+	tagAndCommitments, s, err := initialTagAndCommitmentsOf(seeded, version, z, members.Y, context.ClientsGenerators()[clientIndex])
+	if err != nil {
+		return nil, errors.New("GenerateTestVector: " + err.Error())
+	}
+
+	// client's Step 4, with a recorded challenge generation
+	commits := make([]ChallengeCommitment, len(servers))
+	openings := make([]kyber.Scalar, len(servers))
+	proof, err := newClientProof(seeded, context, client, *tagAndCommitments, s, func(pkClientCommitments []kyber.Point) (Challenge, error) {
+		for i, server := range servers {
+			commit, opening, err := NewChallengeCommitment(seeded, context, server)
+			if err != nil {
+				return Challenge{}, err
+			}
+			commits[i], openings[i] = *commit, opening
+		}
+		challengeCheck, err := InitializeChallenge(seeded, context, commits, openings)
+		if err != nil {
+			return Challenge{}, err
+		}
+		for _, server := range servers {
+			if err := CheckUpdateChallenge(seeded, context, challengeCheck, pkClientCommitments, server); err != nil {
+				return Challenge{}, err
+			}
+		}
+		// back to the leader
+		if err := CheckUpdateChallenge(seeded, context, challengeCheck, pkClientCommitments, servers[0]); err != nil {
+			return Challenge{}, err
+		}
+		return FinalizeChallenge(context, challengeCheck)
+	})
+	if err != nil {
+		return nil, errors.New("GenerateTestVector: " + err.Error())
+	}
+	authMsg := &AuthenticationMessage{
+		C:                        context,
+		initialTagAndCommitments: *tagAndCommitments,
+		P0:                       proof,
+	}
+
+	// the servers' protocol
+	servMsg, err := InitializeServerMessage(authMsg)
+	if err != nil {
+		return nil, errors.New("GenerateTestVector: " + err.Error())
+	}
+	for _, server := range servers {
+		if err := ServerProtocol(seeded, servMsg, server); err != nil {
+			return nil, errors.New("GenerateTestVector: " + err.Error())
+		}
+	}
+	Tf, err := GetFinalLinkageTag(seeded, context, *servMsg)
+	if err != nil {
+		return nil, errors.New("GenerateTestVector: " + err.Error())
+	}
+
+	// the blindings, from the responses: v = r + c*secret
+	ix, is := clientProofResponseIndexes(clientIndex)
+	cj := proof.C[clientIndex]
+	vx := suite.Scalar().Add(proof.R[ix], suite.Scalar().Mul(cj, client.PrivateKey()))
+	vs := suite.Scalar().Add(proof.R[is], suite.Scalar().Mul(cj, s))
+	v1 := make([]kyber.Scalar, len(servers))
+	v2 := make([]kyber.Scalar, len(servers))
+	for j, server := range servers {
+		sj, err := sharedSecretOf(suite, version, suite.Point().Mul(server.PrivateKey(), tagAndCommitments.SCommits[0]))
+		if err != nil {
+			return nil, errors.New("GenerateTestVector: " + err.Error())
+		}
+		p := servMsg.Proofs[j]
+		v1[j] = suite.Scalar().Add(p.R1, suite.Scalar().Mul(p.C, server.RoundSecret()))
+		v2[j] = suite.Scalar().Add(p.R2, suite.Scalar().Mul(p.C, sj))
+	}
+
+	e := &vectorEncoder{}
+	tv := &TestVector{
+		Suite:       suite.String(),
+		Seed:        hex.EncodeToString(seed),
+		ClientIndex: clientIndex,
+		Context: TestVectorContext{
+			X:                e.points(members.X),
+			Y:                e.points(members.Y),
+			H:                e.points(context.ClientsGenerators()),
+			R:                e.points(context.ServersSecretsCommitments()),
+			EncodingVersion:  version,
+			GeneratorVersion: ContextGeneratorVersion(context),
+			SignatureScheme:  ContextSignatureScheme(context),
+		},
+		Randomness: TestVectorRandomness{
+			Z:        e.hex(z),
+			VX:       e.hex(vx),
+			VS:       e.hex(vs),
+			Openings: e.scalars(openings),
+			V1:       e.scalars(v1),
+			V2:       e.scalars(v2),
+		},
+		Expected: TestVectorExpected{
+			T0:            e.hex(tagAndCommitments.T0),
+			SCommits:      e.points(tagAndCommitments.SCommits),
+			Challenge:     e.hex(proof.Cs.Cs),
+			ChallengeSigs: encodeSignatures(proof.Cs.Sigs),
+			T:             e.points(proof.T),
+			C:             e.scalars(proof.C),
+			R:             e.scalars(proof.R),
+			Tags:          e.points(servMsg.Tags),
+			ServerSigs:    encodeSignatures(servMsg.Sigs),
+			FinalTag:      e.hex(Tf),
+		},
+	}
+	for _, client := range clients {
+		tv.Context.ClientKeys = append(tv.Context.ClientKeys, e.hex(client.PrivateKey()))
+	}
+	for _, server := range servers {
+		tv.Context.ServerKeys = append(tv.Context.ServerKeys, e.hex(server.PrivateKey()))
+		tv.Context.RoundSecrets = append(tv.Context.RoundSecrets, e.hex(server.RoundSecret()))
+	}
+	for _, commit := range commits {
+		tv.Expected.ChallengeCommitments = append(tv.Expected.ChallengeCommitments, e.hex(commit.Commit))
+		tv.Expected.ChallengeCommitmentSigs = append(tv.Expected.ChallengeCommitmentSigs, encodeSignatures([]ServerSignature{commit.ServerSignature})...)
+	}
+	for _, p := range servMsg.Proofs {
+		tv.Expected.ServerProofs = append(tv.Expected.ServerProofs, TestVectorServerProof{
+			T1: e.hex(p.T1), T2: e.hex(p.T2), T3: e.hex(p.T3),
+			C: e.hex(p.C), R1: e.hex(p.R1), R2: e.hex(p.R2),
+		})
+		tv.Expected.Encodings.ServerProofs = append(tv.Expected.Encodings.ServerProofs, e.encoding(p.ToBytes(version)))
+	}
+	tv.Context.Encoding = e.encoding(AuthenticationContextToBytes(context))
+	tv.Expected.Encodings.AuthenticationMessage = e.encoding(authMsg.ToBytes())
+	tv.Expected.Encodings.ClientProof = e.encoding(proof.ToBytes(version))
+	tv.Expected.Encodings.Challenge = e.encoding(proof.Cs.ToBytes(version, proof.T))
+	if e.err != nil {
+		return nil, errors.New("GenerateTestVector: " + e.err.Error())
+	}
+	return tv, nil
+}
+
+// CheckTestVector recomputes, from the keys and the random values of tv, all the values and encodings of the
+// authentication and returns an error if one of them differs from the expected one, or if a signature or a proof is
+// not accepted
+func CheckTestVector(tv TestVector) error {
+	suite, err := SuiteByName(tv.Suite)
+	if err != nil {
+		return errors.New("CheckTestVector: " + err.Error())
+	}
+
+	d := &vectorDecoder{suite: suite}
+	context := &MinimumAuthenticationContext{
+		G: Members{
+			X: d.points(tv.Context.X),
+			Y: d.points(tv.Context.Y),
+		},
+		R:                d.points(tv.Context.R),
+		H:                d.points(tv.Context.H),
+		EncodingVersion:  tv.Context.EncodingVersion,
+		GeneratorVersion: tv.Context.GeneratorVersion,
+		SignatureScheme:  tv.Context.SignatureScheme,
+	}
+	clientKeys := d.scalars(tv.Context.ClientKeys)
+	serverKeys := d.scalars(tv.Context.ServerKeys)
+	roundSecrets := d.scalars(tv.Context.RoundSecrets)
+	z, vx, vs := d.scalar(tv.Randomness.Z), d.scalar(tv.Randomness.VX), d.scalar(tv.Randomness.VS)
+	openings := d.scalars(tv.Randomness.Openings)
+	v1, v2 := d.scalars(tv.Randomness.V1), d.scalars(tv.Randomness.V2)
+	C, R := d.scalars(tv.Expected.C), d.scalars(tv.Expected.R)
+	if d.err != nil {
+		return newError(ErrMalformedMessage, d.err, "CheckTestVector: %s", d.err)
+	}
+	n, m := len(context.G.X), len(context.G.Y)
+	j := tv.ClientIndex
+	if j < 0 || j >= n || len(clientKeys) != n || len(serverKeys) != m || len(roundSecrets) != m ||
+		len(openings) != m || len(v1) != m || len(v2) != m || len(C) != n || len(R) != 2*n ||
+		len(tv.Expected.ChallengeCommitments) != m || len(tv.Expected.ChallengeCommitmentSigs) != m || len(tv.Expected.Tags) != m ||
+		len(tv.Expected.ServerProofs) != m || len(tv.Expected.ServerSigs) != m || len(tv.Expected.Encodings.ServerProofs) != m {
+		return newError(ErrMalformedMessage, nil, "CheckTestVector: wrong number of elements")
+	}
+	if err := ValidateContextElements(suite, context); err != nil {
+		return fmt.Errorf("CheckTestVector: %s", err)
+	}
+	if err := VerifyClientGenerators(suite, context); err != nil {
+		return fmt.Errorf("CheckTestVector: %s", err)
+	}
+	version := ContextEncoding(context)
+
+	c := &vectorChecker{}
+	c.encoding("context encoding", tv.Context.Encoding)(AuthenticationContextToBytes(context))
+	for i, x := range clientKeys {
+		c.point(fmt.Sprintf("public key of client %d", i), suite.Point().Mul(x, nil), tv.Context.X[i])
+	}
+	for i := range serverKeys {
+		c.point(fmt.Sprintf("public key of server %d", i), suite.Point().Mul(serverKeys[i], nil), tv.Context.Y[i])
+		c.point(fmt.Sprintf("round secret commitment of server %d", i), suite.Point().Mul(roundSecrets[i], nil), tv.Context.R[i])
+	}
+
+	// client's Steps 1 to 3
+	tagAndCommitments, s, err := initialTagAndCommitmentsOf(suite, version, z, context.G.Y, context.H[j])
+	if err != nil {
+		return fmt.Errorf("CheckTestVector: %s", err)
+	}
+	c.point("T0", tagAndCommitments.T0, tv.Expected.T0)
+	c.points("SCommits", tagAndCommitments.SCommits, tv.Expected.SCommits)
+
+	// challenge generation
+	commits := make([]ChallengeCommitment, m)
+	for i, opening := range openings {
+		commits[i].Commit = suite.Point().Mul(opening, nil)
+		commits[i].ServerSignature = d.signature(tv.Expected.ChallengeCommitmentSigs[i])
+		c.point(fmt.Sprintf("challenge commitment of server %d", i), commits[i].Commit, tv.Expected.ChallengeCommitments[i])
+		if err := VerifyChallengeCommitmentSignature(suite, context, commits[i], context.G.Y[i]); err != nil {
+			c.fail(fmt.Errorf("challenge commitment of server %d: %s", i, err))
+		}
+	}
+	Cs, err := masterChallenge(suite, version, commits, openings)
+	if err != nil {
+		return fmt.Errorf("CheckTestVector: %s", err)
+	}
+	c.scalar("challenge", Cs, tv.Expected.Challenge)
+	challenge := Challenge{Cs: Cs}
+	for _, sig := range tv.Expected.ChallengeSigs {
+		challenge.Sigs = append(challenge.Sigs, d.signature(sig))
+	}
+
+	// client's proof, the commitments of the true clause from the blindings and the others from the
+	// (random) sub-challenges and responses
+	T := recomputeClientOrProofCommitments(suite, context, *tagAndCommitments, C, R)
+	T[3*j] = suite.Point().Mul(vx, nil)
+	T[3*j+1] = suite.Point().Mul(vs, nil)
+	T[3*j+2] = suite.Point().Mul(vs, context.H[j])
+	c.points("client proof commitments", T, tv.Expected.T)
+	cj := suite.Scalar().Set(Cs)
+	for k := range C {
+		if k != j {
+			cj.Sub(cj, C[k])
+		}
+	}
+	c.scalar("sub-challenge of the client", cj, tv.Expected.C[j])
+	ix, is := clientProofResponseIndexes(j)
+	c.scalar("response rx of the client", suite.Scalar().Sub(vx, suite.Scalar().Mul(cj, clientKeys[j])), tv.Expected.R[ix])
+	c.scalar("response rs of the client", suite.Scalar().Sub(vs, suite.Scalar().Mul(cj, s)), tv.Expected.R[is])
+	if err := challenge.VerifySignatures(suite, context, T); err != nil {
+		c.fail(fmt.Errorf("challenge: %s", err))
+	}
+	proof := ClientProof{Cs: challenge, T: T, C: C, R: R}
+	authMsg := AuthenticationMessage{C: context, initialTagAndCommitments: *tagAndCommitments, P0: proof}
+	if err := verifyAuthenticationMessage(suite, authMsg); err != nil {
+		c.fail(err)
+	}
+	c.encoding("authentication message encoding", tv.Expected.Encodings.AuthenticationMessage)(authMsg.ToBytes())
+	c.encoding("client proof encoding", tv.Expected.Encodings.ClientProof)(proof.ToBytes(version))
+	c.encoding("challenge encoding", tv.Expected.Encodings.Challenge)(challenge.ToBytes(version, T))
+
+	// servers' protocol, in the order of their indexes
+	servMsg := ServerMessage{Request: authMsg}
+	Z := tagAndCommitments.SCommits[0]
+	Tprevious := tagAndCommitments.T0
+	for i := 0; i < m; i++ {
+		si, err := sharedSecretOf(suite, version, suite.Point().Mul(serverKeys[i], Z))
+		if err != nil {
+			return fmt.Errorf("CheckTestVector: %s", err)
+		}
+		Ti := suite.Point().Mul(suite.Scalar().Mul(roundSecrets[i], suite.Scalar().Inv(si)), Tprevious)
+		c.point(fmt.Sprintf("tag of server %d", i), Ti, tv.Expected.Tags[i])
+
+		t1 := suite.Point().Sub(suite.Point().Mul(v1[i], Tprevious), suite.Point().Mul(v2[i], Ti))
+		t2 := suite.Point().Mul(v1[i], nil)
+		t3 := suite.Point().Mul(v2[i], tagAndCommitments.SCommits[i+1])
+		ci, err := serverProofChallenge(suite, version, Tprevious, Ti, context.R[i], tagAndCommitments.SCommits[i+2], tagAndCommitments.SCommits[i+1], t1, t2, t3)
+		if err != nil {
+			return fmt.Errorf("CheckTestVector: %s", err)
+		}
+		serverProof := ServerProof{
+			T1: t1, T2: t2, T3: t3,
+			C:  ci,
+			R1: suite.Scalar().Sub(v1[i], suite.Scalar().Mul(ci, roundSecrets[i])),
+			R2: suite.Scalar().Sub(v2[i], suite.Scalar().Mul(ci, si)),
+		}
+		expected := tv.Expected.ServerProofs[i]
+		name := fmt.Sprintf("proof of server %d", i)
+		c.point(name+" t1", serverProof.T1, expected.T1)
+		c.point(name+" t2", serverProof.T2, expected.T2)
+		c.point(name+" t3", serverProof.T3, expected.T3)
+		c.scalar(name+" c", serverProof.C, expected.C)
+		c.scalar(name+" r1", serverProof.R1, expected.R1)
+		c.scalar(name+" r2", serverProof.R2, expected.R2)
+		c.encoding(name+" encoding", tv.Expected.Encodings.ServerProofs[i])(serverProof.ToBytes(version))
+
+		servMsg.Tags = append(servMsg.Tags, Ti)
+		servMsg.Proofs = append(servMsg.Proofs, serverProof)
+		servMsg.Indexes = append(servMsg.Indexes, i)
+		servMsg.Sigs = append(servMsg.Sigs, d.signature(tv.Expected.ServerSigs[i]))
+		Tprevious = Ti
+	}
+	if d.err != nil {
+		return newError(ErrMalformedMessage, d.err, "CheckTestVector: %s", d.err)
+	}
+	if c.err != nil {
+		return fmt.Errorf("CheckTestVector: %s", c.err)
+	}
+
+	// the signatures of the servers
+	Tf, err := GetFinalLinkageTag(suite, context, servMsg)
+	if err != nil {
+		return fmt.Errorf("CheckTestVector: %s", err)
+	}
+	c.point("final tag", Tf, tv.Expected.FinalTag)
+	if c.err != nil {
+		return fmt.Errorf("CheckTestVector: %s", c.err)
+	}
+	return nil
+}
+
+// hex encodes the points, scalars and encodings of a TestVector, keeps the first error
+type vectorEncoder struct {
+	err error
+}
+
+func (e *vectorEncoder) hex(v encoding.BinaryMarshaler) string {
+	data, err := v.MarshalBinary()
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return hex.EncodeToString(data)
+}
+
+func (e *vectorEncoder) points(points []kyber.Point) []string {
+	encoded := make([]string, 0, len(points))
+	for _, P := range points {
+		encoded = append(encoded, e.hex(P))
+	}
+	return encoded
+}
+
+func (e *vectorEncoder) scalars(scalars []kyber.Scalar) []string {
+	encoded := make([]string, 0, len(scalars))
+	for _, s := range scalars {
+		encoded = append(encoded, e.hex(s))
+	}
+	return encoded
+}
+
+func (e *vectorEncoder) encoding(data []byte, err error) string {
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return hex.EncodeToString(data)
+}
+
+func encodeSignatures(sigs []ServerSignature) []TestVectorSignature {
+	encoded := make([]TestVectorSignature, 0, len(sigs))
+	for _, sig := range sigs {
+		encoded = append(encoded, TestVectorSignature{Index: sig.Index, Sig: hex.EncodeToString(sig.Sig)})
+	}
+	return encoded
+}
+
+// decodes the points, scalars and signatures of a TestVector, keeps the first error
+type vectorDecoder struct {
+	suite Suite
+	err   error
+}
+
+func (d *vectorDecoder) unmarshal(v encoding.BinaryUnmarshaler, s string) {
+	data, err := hex.DecodeString(s)
+	if err == nil {
+		err = v.UnmarshalBinary(data)
+	}
+	if err != nil && d.err == nil {
+		d.err = fmt.Errorf("failed to decode \"%s\": %s", s, err)
+	}
+}
+
+func (d *vectorDecoder) point(s string) kyber.Point {
+	P := d.suite.Point()
+	d.unmarshal(P, s)
+	return P
+}
+
+func (d *vectorDecoder) scalar(s string) kyber.Scalar {
+	x := d.suite.Scalar()
+	d.unmarshal(x, s)
+	return x
+}
+
+func (d *vectorDecoder) points(encoded []string) []kyber.Point {
+	points := make([]kyber.Point, 0, len(encoded))
+	for _, s := range encoded {
+		points = append(points, d.point(s))
+	}
+	return points
+}
+
+func (d *vectorDecoder) scalars(encoded []string) []kyber.Scalar {
+	scalars := make([]kyber.Scalar, 0, len(encoded))
+	for _, s := range encoded {
+		scalars = append(scalars, d.scalar(s))
+	}
+	return scalars
+}
+
+func (d *vectorDecoder) signature(sig TestVectorSignature) ServerSignature {
+	data, err := hex.DecodeString(sig.Sig)
+	if err != nil && d.err == nil {
+		d.err = fmt.Errorf("failed to decode signature \"%s\": %s", sig.Sig, err)
+	}
+	return ServerSignature{Index: sig.Index, Sig: data}
+}
+
+// compares the recomputed values of a TestVector to the expected ones, keeps the first mismatch
+type vectorChecker struct {
+	err error
+}
+
+func (c *vectorChecker) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *vectorChecker) equal(name string, v encoding.BinaryMarshaler, expected string) {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		c.fail(fmt.Errorf("%s: %s", name, err))
+	} else if got := hex.EncodeToString(data); got != expected {
+		c.fail(fmt.Errorf("%s mismatch, got %s expected %s", name, got, expected))
+	}
+}
+
+func (c *vectorChecker) point(name string, P kyber.Point, expected string) {
+	c.equal(name, P, expected)
+}
+
+func (c *vectorChecker) scalar(name string, s kyber.Scalar, expected string) {
+	c.equal(name, s, expected)
+}
+
+func (c *vectorChecker) points(name string, points []kyber.Point, expected []string) {
+	if len(points) != len(expected) {
+		c.fail(fmt.Errorf("%s: got %d points expected %d", name, len(points), len(expected)))
+		return
+	}
+	for i, P := range points {
+		c.point(fmt.Sprintf("%s[%d]", name, i), P, expected[i])
+	}
+}
+
+// returns a function checking an encoding (and its error) against the expected one
+func (c *vectorChecker) encoding(name string, expected string) func([]byte, error) {
+	return func(data []byte, err error) {
+		if err != nil {
+			c.fail(fmt.Errorf("%s: %s", name, err))
+		} else if got := hex.EncodeToString(data); got != expected {
+			c.fail(fmt.Errorf("%s mismatch, got %s expected %s", name, got, expected))
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package daga
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the known-answer test vectors, generated by cmd/dagavectors
+var testVectorsFile = filepath.Join("testdata", "vectors.json")
+
+// conformance runner, checks the known-answer test vectors
+func TestTestVectors(t *testing.T) {
+	data, err := ioutil.ReadFile(testVectorsFile)
+	require.NoError(t, err, "generate %s with cmd/dagavectors", testVectorsFile)
+	var vectors []TestVector
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	require.NotEmpty(t, vectors)
+	// the vectors of every suite are committed
+	covered := make(map[string]bool)
+	for _, tv := range vectors {
+		covered[tv.Suite] = true
+	}
+	for _, suite := range suites {
+		require.True(t, covered[suite.String()], "no vector for %s, regenerate %s with cmd/dagavectors", suite, testVectorsFile)
+	}
+	for _, tv := range vectors {
+		t.Run(tv.Description, func(t *testing.T) {
+			require.NoError(t, CheckTestVector(tv))
+		})
+	}
+}
+
+func TestGenerateTestVector(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			tv, err := GenerateTestVector(suite, []byte("seed"), 4, 3, 1)
+			require.NoError(t, err)
+
+			// survives the JSON encoding
+			data, err := json.Marshal(tv)
+			require.NoError(t, err)
+			var decoded TestVector
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			require.NoError(t, CheckTestVector(decoded))
+
+			// deterministic
+			again, err := GenerateTestVector(suite, []byte("seed"), 4, 3, 1)
+			require.NoError(t, err)
+			require.Equal(t, tv, again)
+
+			_, err = GenerateTestVector(suite, []byte("seed"), 4, 3, 4)
+			require.Error(t, err)
+
+			//Invalid vectors
+			tampered := decoded
+			tampered.Randomness.VX = tv.Randomness.VS
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Randomness.V1 = append([]string{}, tv.Randomness.V1...)
+			tampered.Randomness.V1[2] = tv.Randomness.V2[2]
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Expected.FinalTag = tv.Expected.T0
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Expected.Encodings.ClientProof = tv.Expected.Encodings.Challenge
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Expected.ServerSigs = tv.Expected.ServerSigs[1:]
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Context.ClientKeys = append([]string{}, tv.Context.ClientKeys...)
+			tampered.Context.ClientKeys[0] = "zz"
+			require.Error(t, CheckTestVector(tampered))
+
+			tampered = decoded
+			tampered.Suite = "unknown"
+			require.Error(t, CheckTestVector(tampered))
+		})
+	}
+}
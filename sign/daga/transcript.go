@@ -0,0 +1,485 @@
+package daga
+
+// This file contains the canonical encoder of the data that DAGA signs and hashes (the transcripts),
+// and the versioning needed to keep verifying the contexts created with the original (ambiguous) encoding.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.dedis.ch/kyber"
+	"hash"
+	"strconv"
+)
+
+// EncodingVersion identifies the byte encoding of the data that DAGA signs and hashes under a context
+type EncodingVersion int
+
+const (
+	// EncodingLegacy is the original encoding, a raw concatenation of the marshalled elements (integers written in decimal).
+	// it is ambiguous (no lengths, no labels) and only kept to be able to verify the contexts created before EncodingV1
+	EncodingLegacy EncodingVersion = 0
+	// EncodingV1 is the canonical encoding, see transcript
+	EncodingV1 EncodingVersion = 1
+	// CurrentEncoding is the encoding used for the newly created contexts
+	CurrentEncoding = EncodingV1
+)
+
+// VersionedContext is implemented by the AuthenticationContexts that specify the encoding of their transcripts,
+// the contexts that don't implement it use EncodingLegacy.
+type VersionedContext interface {
+	AuthenticationContext
+	Encoding() EncodingVersion
+}
+
+// ContextEncoding returns the EncodingVersion used for all the signatures and hashes computed under context
+func ContextEncoding(context AuthenticationContext) EncodingVersion {
+	if versioned, ok := context.(VersionedContext); ok {
+		return versioned.Encoding()
+	}
+	return EncodingLegacy
+}
+
+// domain-separation labels of the different transcripts
+const (
+	transcriptContext                   = "daga/context"
+	transcriptAuthenticationMessage     = "daga/authentication-message"
+	transcriptClientProof               = "daga/client-proof"
+	transcriptChallenge                 = "daga/challenge"
+	transcriptChallengeCommitment       = "daga/challenge-commitment"
+	transcriptServerMessage             = "daga/server-message"
+	transcriptServerProof               = "daga/server-proof"
+	transcriptServerProofChallenge      = "daga/server-proof-challenge"
+	transcriptMisbehavingProofChallenge = "daga/misbehaving-proof-challenge"
+	transcriptSharedSecret              = "daga/shared-secret"
+	transcriptClientGenerator           = "daga/client-generator"
+	transcriptMasterChallenge           = "daga/master-challenge"
+	transcriptPrecomputation            = "daga/precomputation"
+	transcriptKeyAggregation            = "daga/key-aggregation"
+	transcriptCosignature               = "daga/cosignature"
+	transcriptCosignatureBinding        = "daga/cosignature-binding"
+)
+
+// magic bytes starting every EncodingV1 transcript
+var transcriptMagic = []byte("DAGA")
+
+// transcript is the canonical encoder of the data that DAGA signs and hashes.
+//
+// with EncodingV1 a transcript is encoded as
+//
+//	"DAGA" || version (1 byte) || lp(domain) || element*
+//
+// where an element is lp(label) || lp(value), a list is lp(label) || count || lp(item)*
+// and lp(x) is the 4 bytes big-endian length of x followed by x.
+// hence the encoding is unambiguous and the transcripts of different domains (signatures/hashes) can't collide.
+//
+// with EncodingLegacy the version, domain, labels and lengths are omitted and the values are concatenated as is,
+// which yields exactly the bytes produced by the previous versions of this package.
+//
+// the first error encountered is kept and returned by Bytes/Sum, the subsequent appends are no-ops.
+type transcript struct {
+	version EncodingVersion
+	buf     []byte
+	err     error
+}
+
+// returns a new transcript using encoding version for the domain-separation label domain
+func newTranscript(version EncodingVersion, domain string) *transcript {
+	t := &transcript{version: version}
+	switch version {
+	case EncodingLegacy:
+	case EncodingV1:
+		t.buf = append(t.buf, transcriptMagic...)
+		t.buf = append(t.buf, byte(version))
+		t.appendLengthPrefixed([]byte(domain))
+	default:
+		t.err = fmt.Errorf("unsupported encoding version: %d", version)
+	}
+	return t
+}
+
+// appends x prefixed with its 4 bytes big-endian length
+func (t *transcript) appendLengthPrefixed(x []byte) {
+	t.appendUint32(uint32(len(x)))
+	t.buf = append(t.buf, x...)
+}
+
+func (t *transcript) appendUint32(v uint32) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], v)
+	t.buf = append(t.buf, l[:]...)
+}
+
+// appends the value labeled label to the transcript
+func (t *transcript) appendBytes(label string, value []byte) *transcript {
+	if t.err != nil {
+		return t
+	}
+	if t.version == EncodingLegacy {
+		t.buf = append(t.buf, value...)
+		return t
+	}
+	t.appendLengthPrefixed([]byte(label))
+	t.appendLengthPrefixed(value)
+	return t
+}
+
+// appends the integer v labeled label to the transcript (8 bytes big-endian, or decimal string for EncodingLegacy)
+func (t *transcript) appendInt(label string, v int) *transcript {
+	if t.version == EncodingLegacy {
+		return t.appendBytes(label, []byte(strconv.Itoa(v)))
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(int64(v)))
+	return t.appendBytes(label, buf[:])
+}
+
+// appends the value labeled label to the transcript, labeled and length-prefixed whatever the encoding version
+// (for the elements introduced after EncodingLegacy, that have no legacy encoding to stay compatible with)
+func (t *transcript) appendField(label string, value []byte) *transcript {
+	if t.err != nil {
+		return t
+	}
+	t.appendLengthPrefixed([]byte(label))
+	t.appendLengthPrefixed(value)
+	return t
+}
+
+// appends the integer v labeled label to the transcript (8 bytes big-endian) whatever the encoding version, see appendField
+func (t *transcript) appendInt64Field(label string, v int64) *transcript {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	return t.appendField(label, buf[:])
+}
+
+// appends the point P labeled label to the transcript
+func (t *transcript) appendPoint(label string, P kyber.Point) *transcript {
+	if t.err != nil {
+		return t
+	}
+	if P == nil {
+		t.err = fmt.Errorf("error marshalling %s: nil point", label)
+		return t
+	}
+	buf, err := P.MarshalBinary()
+	if err != nil {
+		t.err = fmt.Errorf("error marshalling %s: %s", label, err)
+		return t
+	}
+	return t.appendBytes(label, buf)
+}
+
+// appends the scalar s labeled label to the transcript
+func (t *transcript) appendScalar(label string, s kyber.Scalar) *transcript {
+	if t.err != nil {
+		return t
+	}
+	if s == nil {
+		t.err = fmt.Errorf("error marshalling %s: nil scalar", label)
+		return t
+	}
+	buf, err := s.MarshalBinary()
+	if err != nil {
+		t.err = fmt.Errorf("error marshalling %s: %s", label, err)
+		return t
+	}
+	return t.appendBytes(label, buf)
+}
+
+// appends the list of points labeled label to the transcript
+func (t *transcript) appendPoints(label string, points []kyber.Point) *transcript {
+	t.beginList(label, len(points))
+	for i, P := range points {
+		t.appendListItem(label+"["+strconv.Itoa(i)+"]", P)
+	}
+	return t
+}
+
+// appends the list of scalars labeled label to the transcript
+func (t *transcript) appendScalars(label string, scalars []kyber.Scalar) *transcript {
+	t.beginList(label, len(scalars))
+	for i, s := range scalars {
+		t.appendListItem(label+"["+strconv.Itoa(i)+"]", s)
+	}
+	return t
+}
+
+// writes the header of a list of n items labeled label
+func (t *transcript) beginList(label string, n int) {
+	if t.err != nil || t.version == EncodingLegacy {
+		return
+	}
+	t.appendLengthPrefixed([]byte(label))
+	t.appendUint32(uint32(n))
+}
+
+// writes an item (kyber.Point or kyber.Scalar) of a list, (lists items are not labeled, the label is only used in errors)
+func (t *transcript) appendListItem(label string, item kyber.Marshaling) {
+	if t.err != nil {
+		return
+	}
+	if item == nil {
+		t.err = fmt.Errorf("error marshalling %s: nil element", label)
+		return
+	}
+	buf, err := item.MarshalBinary()
+	if err != nil {
+		t.err = fmt.Errorf("error marshalling %s: %s", label, err)
+		return
+	}
+	if t.version == EncodingLegacy {
+		t.buf = append(t.buf, buf...)
+	} else {
+		t.appendLengthPrefixed(buf)
+	}
+}
+
+// Bytes returns the encoded transcript, or the first error encountered while building it
+func (t *transcript) Bytes() ([]byte, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.buf == nil {
+		return nil, errors.New("empty transcript")
+	}
+	return t.buf, nil
+}
+
+// Sum returns the digest of the encoded transcript computed using the hash function h
+func (t *transcript) Sum(h hash.Hash) ([]byte, error) {
+	data, err := t.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// hashToScalar maps the encoded transcript to a scalar using the hash function of suite labeled label,
+// with EncodingLegacy the (non domain-separated) suite.Hash() checksum is used as in the previous versions of this package
+func (t *transcript) hashToScalar(suite Suite, label HashLabel) (kyber.Scalar, error) {
+	if t.version == EncodingLegacy {
+		digest, err := t.Sum(suite.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return suite.Scalar().SetBytes(digest), nil
+	}
+	data, err := t.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return suite.hashToScalar(label, data), nil
+}
+
+// Transcript is the canonical encoder of the data that the users of DAGA sign and hash alongside the data of DAGA
+// (e.g. the receipts of the authentications), so that all of it follows one documented layout, the one of the
+// EncodingV1 transcripts of DAGA (see transcript):
+//
+//	"DAGA" || 1 || lp(domain) || (lp(label) || lp(value))*
+//
+// where lp(x) is the 4 bytes big-endian length of x followed by x and the integers are 8 bytes big-endian values.
+//
+// the first error encountered is kept and returned by Bytes/Hash, the subsequent appends are no-ops.
+type Transcript struct {
+	t *transcript
+}
+
+// NewTranscript returns a new transcript for the domain-separation label domain, that must be distinct from the
+// domains of DAGA ("daga/...") and of the other transcripts of the caller
+func NewTranscript(domain string) *Transcript {
+	return &Transcript{t: newTranscript(EncodingV1, domain)}
+}
+
+// AppendBytes appends the value labeled label to the transcript
+func (t *Transcript) AppendBytes(label string, value []byte) *Transcript {
+	t.t.appendBytes(label, value)
+	return t
+}
+
+// AppendString appends the string value labeled label to the transcript
+func (t *Transcript) AppendString(label string, value string) *Transcript {
+	return t.AppendBytes(label, []byte(value))
+}
+
+// AppendInt64 appends the integer v labeled label to the transcript
+func (t *Transcript) AppendInt64(label string, v int64) *Transcript {
+	t.t.appendInt64Field(label, v)
+	return t
+}
+
+// Bytes returns the encoded transcript, or the first error encountered while building it
+func (t *Transcript) Bytes() ([]byte, error) {
+	return t.t.Bytes()
+}
+
+// Hash returns the digest of the encoded transcript computed with the hash function of suite labeled label,
+// i.e. suite.Hash() of the input of the labeled hash functions of the suite (see HashLabel), the labels of the caller
+// must be distinct from those of DAGA
+func (t *Transcript) Hash(suite Suite, label HashLabel) ([]byte, error) {
+	data, err := t.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	h := suite.Hash()
+	h.Write(labeledHashInput(suite.String(), label, data))
+	return h.Sum(nil), nil
+}
+
+// transcriptReader decodes the elements of an EncodingV1 transcript (see transcript), in the order they were appended,
+// it is used to read back the data that DAGA persists (see Precomputation).
+//
+// the first error encountered is kept and returned by Done, the subsequent reads are no-ops (and return nil).
+type transcriptReader struct {
+	buf []byte
+	err error
+}
+
+// returns a new reader of the EncodingV1 transcript data of the domain-separation label domain
+func newTranscriptReader(data []byte, domain string) *transcriptReader {
+	r := &transcriptReader{buf: data}
+	if len(data) < len(transcriptMagic)+1 || string(data[:len(transcriptMagic)]) != string(transcriptMagic) ||
+		EncodingVersion(data[len(transcriptMagic)]) != EncodingV1 {
+		r.err = errors.New("not an EncodingV1 transcript")
+		return r
+	}
+	r.buf = data[len(transcriptMagic)+1:]
+	if string(r.readLengthPrefixed("domain")) != domain && r.err == nil {
+		r.err = fmt.Errorf("wrong transcript domain, expected %s", domain)
+	}
+	return r
+}
+
+func (r *transcriptReader) readUint32(label string) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = fmt.Errorf("error reading %s: truncated data", label)
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *transcriptReader) readLengthPrefixed(label string) []byte {
+	l := r.readUint32(label)
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.buf)) < l {
+		r.err = fmt.Errorf("error reading %s: truncated data", label)
+		return nil
+	}
+	x := r.buf[:l]
+	r.buf = r.buf[l:]
+	return x
+}
+
+// reads the value labeled label
+func (r *transcriptReader) readBytes(label string) []byte {
+	if string(r.readLengthPrefixed(label)) != label && r.err == nil {
+		r.err = fmt.Errorf("error reading %s: wrong label", label)
+	}
+	return r.readLengthPrefixed(label)
+}
+
+// reads the integer labeled label
+func (r *transcriptReader) readInt(label string) int {
+	buf := r.readBytes(label)
+	if r.err != nil {
+		return 0
+	}
+	if len(buf) != 8 {
+		r.err = fmt.Errorf("error reading %s: invalid integer", label)
+		return 0
+	}
+	return int(int64(binary.BigEndian.Uint64(buf)))
+}
+
+// reads the point labeled label
+func (r *transcriptReader) readPoint(suite Suite, label string) kyber.Point {
+	P := suite.Point()
+	if !r.unmarshal(label, r.readBytes(label), P) {
+		return nil
+	}
+	return P
+}
+
+// reads the scalar labeled label
+func (r *transcriptReader) readScalar(suite Suite, label string) kyber.Scalar {
+	s := suite.Scalar()
+	if !r.unmarshal(label, r.readBytes(label), s) {
+		return nil
+	}
+	return s
+}
+
+// reads the list of points labeled label
+func (r *transcriptReader) readPoints(suite Suite, label string) []kyber.Point {
+	n := r.beginList(label)
+	points := make([]kyber.Point, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		itemLabel := label + "[" + strconv.Itoa(i) + "]"
+		P := suite.Point()
+		r.unmarshal(itemLabel, r.readLengthPrefixed(itemLabel), P)
+		points = append(points, P)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return points
+}
+
+// reads the list of scalars labeled label
+func (r *transcriptReader) readScalars(suite Suite, label string) []kyber.Scalar {
+	n := r.beginList(label)
+	scalars := make([]kyber.Scalar, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		itemLabel := label + "[" + strconv.Itoa(i) + "]"
+		s := suite.Scalar()
+		r.unmarshal(itemLabel, r.readLengthPrefixed(itemLabel), s)
+		scalars = append(scalars, s)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return scalars
+}
+
+// reads the header of a list labeled label and returns its number of items
+func (r *transcriptReader) beginList(label string) int {
+	if string(r.readLengthPrefixed(label)) != label && r.err == nil {
+		r.err = fmt.Errorf("error reading %s: wrong label", label)
+	}
+	n := r.readUint32(label)
+	// an item takes at least 4 bytes, don't trust the count to allocate
+	if r.err == nil && uint64(n)*4 > uint64(len(r.buf)) {
+		r.err = fmt.Errorf("error reading %s: truncated data", label)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// unmarshals buf into item (kyber.Point or kyber.Scalar), returns false on error
+func (r *transcriptReader) unmarshal(label string, buf []byte, item kyber.Marshaling) bool {
+	if r.err != nil {
+		return false
+	}
+	if err := item.UnmarshalBinary(buf); err != nil {
+		r.err = fmt.Errorf("error unmarshalling %s: %s", label, err)
+		return false
+	}
+	return true
+}
+
+// Done returns the first error encountered while reading, or an error if some data was not read
+func (r *transcriptReader) Done() error {
+	if r.err == nil && len(r.buf) != 0 {
+		return errors.New("trailing data after transcript")
+	}
+	return r.err
+}
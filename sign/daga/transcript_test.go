@@ -0,0 +1,196 @@
+package daga
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+// test helper that returns a copy of the test context using the legacy encoding
+func legacyContext(t *testing.T, context AuthenticationContext) AuthenticationContext {
+	minimumContext, ok := context.(*MinimumAuthenticationContext)
+	require.True(t, ok)
+	legacy := *minimumContext
+	legacy.EncodingVersion = EncodingLegacy
+	legacy.SignatureScheme = SignatureSchnorr
+	return legacy
+}
+
+func TestContextEncoding(t *testing.T) {
+	_, _, context, err := GenerateTestContext(suite, 2, 2)
+	require.NoError(t, err)
+	require.Equal(t, CurrentEncoding, ContextEncoding(context), "new contexts don't use the current encoding")
+	require.Equal(t, EncodingLegacy, ContextEncoding(legacyContext(t, context)))
+
+	// contexts that don't specify their encoding use the legacy one
+	var unversioned struct{ AuthenticationContext }
+	unversioned.AuthenticationContext = context
+	require.Equal(t, EncodingLegacy, ContextEncoding(unversioned))
+}
+
+func TestTranscript_Legacy(t *testing.T) {
+	_, _, context, err := GenerateTestContext(suite, rand.Intn(10)+2, rand.Intn(10)+1)
+	require.NoError(t, err)
+	legacy := legacyContext(t, context)
+
+	// legacy encoding is the raw concatenation of the elements
+	var expected []byte
+	members := legacy.Members()
+	for _, points := range [][]kyber.Point{members.X, members.Y, legacy.ClientsGenerators(), legacy.ServersSecretsCommitments()} {
+		data, err := PointArrayToBytes(points)
+		require.NoError(t, err)
+		expected = append(expected, data...)
+	}
+	data, err := AuthenticationContextToBytes(legacy)
+	require.NoError(t, err)
+	require.Equal(t, expected, data, "legacy encoding of the context changed, stored contexts can't be verified anymore")
+
+	cs := suite.Scalar().Pick(suite.RandomStream())
+	commitments := []kyber.Point{suite.Point().Pick(suite.RandomStream()), suite.Point().Pick(suite.RandomStream())}
+	expected, err = cs.MarshalBinary()
+	require.NoError(t, err)
+	commitmentsBytes, err := PointArrayToBytes(commitments)
+	require.NoError(t, err)
+	expected = append(expected, commitmentsBytes...)
+	data, err = Challenge{Cs: cs}.ToBytes(EncodingLegacy, commitments)
+	require.NoError(t, err)
+	require.Equal(t, expected, data, "legacy encoding of the challenge changed")
+
+	data, err = newTranscript(EncodingLegacy, "test").appendInt("index", 42).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte(strconv.Itoa(42)), data)
+
+	// the optional elements of the challenge are labeled and length-prefixed, even in EncodingLegacy
+	data, err = Challenge{Cs: cs, Session: []byte("ab"), ID: []byte("c")}.ToBytes(EncodingLegacy, commitments)
+	require.NoError(t, err)
+	other, err := Challenge{Cs: cs, Session: []byte("a"), ID: []byte("bc")}.ToBytes(EncodingLegacy, commitments)
+	require.NoError(t, err)
+	require.NotEqual(t, data, other, "session and id can be confused")
+	require.True(t, bytes.HasPrefix(data, expected), "legacy encoding of the challenge changed")
+	expiry, err := newTranscript(EncodingLegacy, "test").appendInt64Field("expiry", 1<<40).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 6, 'e', 'x', 'p', 'i', 'r', 'y', 0, 0, 0, 8, 0, 0, 1, 0, 0, 0, 0, 0}, expiry)
+}
+
+func TestTranscript_V1(t *testing.T) {
+	P := suite.Point().Pick(suite.RandomStream())
+	Q := suite.Point().Pick(suite.RandomStream())
+
+	// header
+	data, err := newTranscript(EncodingV1, "test").appendPoint("P", P).Bytes()
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, append([]byte("DAGA"), byte(EncodingV1))), "missing magic and version")
+
+	// unambiguous, same concatenation of elements but different split => different encoding
+	split1, err := newTranscript(EncodingV1, "test").appendPoints("a", []kyber.Point{P, Q}).appendPoints("b", nil).Bytes()
+	require.NoError(t, err)
+	split2, err := newTranscript(EncodingV1, "test").appendPoints("a", []kyber.Point{P}).appendPoints("b", []kyber.Point{Q}).Bytes()
+	require.NoError(t, err)
+	require.NotEqual(t, split1, split2, "different lists have the same encoding")
+	legacy1, err := newTranscript(EncodingLegacy, "test").appendPoints("a", []kyber.Point{P, Q}).appendPoints("b", nil).Bytes()
+	require.NoError(t, err)
+	legacy2, err := newTranscript(EncodingLegacy, "test").appendPoints("a", []kyber.Point{P}).appendPoints("b", []kyber.Point{Q}).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, legacy1, legacy2)
+
+	// domain separation
+	domain1, err := newTranscript(EncodingV1, "domain1").appendPoint("P", P).Bytes()
+	require.NoError(t, err)
+	domain2, err := newTranscript(EncodingV1, "domain2").appendPoint("P", P).Bytes()
+	require.NoError(t, err)
+	require.NotEqual(t, domain1, domain2, "different domains have the same encoding")
+
+	// deterministic
+	again, err := newTranscript(EncodingV1, "domain1").appendPoint("P", P).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, domain1, again)
+
+	// errors
+	_, err = newTranscript(EncodingVersion(42), "test").appendPoint("P", P).Bytes()
+	require.Error(t, err, "unsupported version accepted")
+	_, err = newTranscript(EncodingV1, "test").appendPoint("P", nil).appendPoint("Q", Q).Bytes()
+	require.Error(t, err, "nil point accepted")
+	_, err = newTranscript(EncodingV1, "test").appendScalars("s", []kyber.Scalar{nil}).Bytes()
+	require.Error(t, err, "nil scalar accepted")
+}
+
+func TestTranscript_Exported(t *testing.T) {
+	// the documented layout
+	data, err := NewTranscript("test").AppendString("a", "xy").AppendInt64("n", -2).Bytes()
+	require.NoError(t, err)
+	expected := []byte("DAGA\x01\x00\x00\x00\x04test" +
+		"\x00\x00\x00\x01a\x00\x00\x00\x02xy" +
+		"\x00\x00\x00\x01n\x00\x00\x00\x08\xff\xff\xff\xff\xff\xff\xff\xfe")
+	require.Equal(t, expected, data)
+
+	// labeled hashes
+	for _, suite := range suites {
+		prefix, err := NewTranscript("test").AppendString("a", "xy").Bytes()
+		require.NoError(t, err)
+		digest, err := NewTranscript("test").AppendString("a", "xy").Hash(suite, "label")
+		require.NoError(t, err)
+		h := suite.Hash()
+		h.Write(labeledHashInput(suite.String(), "label", prefix))
+		require.Equal(t, h.Sum(nil), digest)
+		other, err := NewTranscript("test").AppendString("a", "xy").Hash(suite, "other label")
+		require.NoError(t, err)
+		require.NotEqual(t, digest, other, "different labels have the same hash")
+	}
+}
+
+func TestTranscript_Versions(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 2, 2)
+			require.NoError(t, err)
+			legacy := legacyContext(t, context)
+
+			// authentication works under both encodings (compatibility switch)
+			Tf := runAuthentication(t, suite, context, clients[0], servers)
+			TfLegacy := runAuthentication(t, suite, legacy, clients[0], servers)
+			require.NotNil(t, Tf)
+			require.NotNil(t, TfLegacy)
+
+			// the signed data differs between the encodings => signatures are not interchangeable
+			current, err := AuthenticationContextToBytes(context)
+			require.NoError(t, err)
+			old, err := AuthenticationContextToBytes(legacy)
+			require.NoError(t, err)
+			require.NotEqual(t, current, old)
+			scheme := ContextSignatureScheme(context)
+			sig, err := scheme.Sign(suite, servers[0].PrivateKey(), old)
+			require.NoError(t, err)
+			require.Error(t, scheme.Verify(suite, servers[0].PublicKey(), current, sig))
+
+			// the suite is a labeled element of the transcript of the context, omitted by the legacy encoding
+			withSuite, err := AuthenticationContextSuiteToBytes(context, suite.String())
+			require.NoError(t, err)
+			expected, err := contextTranscript(context).appendBytes("suite", []byte(suite.String())).Bytes()
+			require.NoError(t, err)
+			require.Equal(t, expected, withSuite)
+			otherSuite, err := AuthenticationContextSuiteToBytes(context, "other suite")
+			require.NoError(t, err)
+			require.NotEqual(t, withSuite, otherSuite)
+			legacyWithSuite, err := AuthenticationContextSuiteToBytes(legacy, suite.String())
+			require.NoError(t, err)
+			require.Equal(t, old, legacyWithSuite)
+
+			// a challenge signed under an encoding is rejected under the other
+			commitments := []kyber.Point{suite.Point().Pick(suite.RandomStream())}
+			challenge := Challenge{Cs: suite.Scalar().Pick(suite.RandomStream())}
+			signData, err := challenge.ToBytes(ContextEncoding(legacy), commitments)
+			require.NoError(t, err)
+			for _, server := range servers {
+				sig, err := scheme.Sign(suite, server.PrivateKey(), signData)
+				require.NoError(t, err)
+				challenge.Sigs = append(challenge.Sigs, ServerSignature{Index: server.Index(), Sig: sig})
+			}
+			require.NoError(t, challenge.VerifySignatures(suite, legacy, commitments))
+			require.Error(t, challenge.VerifySignatures(suite, context, commitments), "legacy challenge accepted under new encoding")
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package daga
+
+// This file contains the validation of the group elements and scalars received by the DAGA participants,
+// meant to reject malformed or malicious inputs before doing any (expensive) proof verification.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"go.dedis.ch/kyber"
+)
+
+// the reasons why an element is rejected, wrapped in a ValidationError
+var (
+	ErrNilElement           = errors.New("nil element")
+	ErrIdentityElement      = errors.New("identity element")
+	ErrInvalidElement       = errors.New("not an element of the prime order group")
+	ErrNonCanonicalEncoding = errors.New("non canonical encoding")
+	ErrDuplicateElement     = errors.New("duplicate element")
+	ErrInvalidLength        = errors.New("invalid length")
+	ErrInvalidIndex         = errors.New("invalid index")
+	ErrUnsupportedScheme    = errors.New("signature scheme not supported by the suite")
+)
+
+// ValidationError is returned when an input is rejected by the validation,
+// Field names the offending input (e.g. "context.X[3]") and Err is the reason (one of the Err* above)
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Err)
+}
+
+// Is returns whether target is ErrMalformedMessage, all the rejected inputs are malformed
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrMalformedMessage
+}
+
+// Unwrap returns the reason of the rejection
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func newValidationError(field string, err error) error {
+	return &ValidationError{Field: field, Err: err}
+}
+
+// returns whether x is nil or a nil pointer (e.g. a nil *ristrettoPoint in a kyber.Point)
+func isNil(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	v := reflect.ValueOf(x)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// returns an error if P is not a valid element of the group of suite (or is the identity and allowIdentity is false)
+func validatePoint(suite Suite, field string, P kyber.Point, allowIdentity bool) error {
+	if isNil(P) {
+		return newValidationError(field, ErrNilElement)
+	}
+	if reflect.TypeOf(P) != reflect.TypeOf(suite.Point()) {
+		return newValidationError(field, ErrInvalidElement)
+	}
+	if !allowIdentity && P.Equal(suite.Point().Null()) {
+		return newValidationError(field, ErrIdentityElement)
+	}
+	if err := suite.checkPoint(P); err != nil {
+		return newValidationError(field, ErrInvalidElement)
+	}
+	return nil
+}
+
+// validatePoint on all the points, the field of the i-th point is field[i]
+func validatePoints(suite Suite, field string, points []kyber.Point, allowIdentity bool) error {
+	for i, P := range points {
+		if err := validatePoint(suite, field+"["+strconv.Itoa(i)+"]", P, allowIdentity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns an error if points contains the same element twice
+func validateDistinct(field string, points []kyber.Point) error {
+	encountered := make(map[string]struct{}, len(points))
+	for i, P := range points {
+		buf, err := P.MarshalBinary()
+		if err != nil {
+			return newValidationError(field+"["+strconv.Itoa(i)+"]", ErrInvalidElement)
+		}
+		if _, ok := encountered[string(buf)]; ok {
+			return newValidationError(field+"["+strconv.Itoa(i)+"]", ErrDuplicateElement)
+		}
+		encountered[string(buf)] = struct{}{}
+	}
+	return nil
+}
+
+// returns an error if s is not a (canonical) scalar of suite
+func validateScalar(suite Suite, field string, s kyber.Scalar) error {
+	if isNil(s) {
+		return newValidationError(field, ErrNilElement)
+	}
+	if reflect.TypeOf(s) != reflect.TypeOf(suite.Scalar()) {
+		return newValidationError(field, ErrInvalidElement)
+	}
+	// the scalar must be reduced, i.e. its encoding is the encoding of the scalar reduced modulo the group order
+	buf, err := s.MarshalBinary()
+	if err != nil {
+		return newValidationError(field, ErrInvalidElement)
+	}
+	reduced, err := suite.Scalar().SetBytes(buf).MarshalBinary()
+	if err != nil || !bytes.Equal(buf, reduced) {
+		return newValidationError(field, ErrNonCanonicalEncoding)
+	}
+	return nil
+}
+
+// validateScalar on all the scalars, the field of the i-th scalar is field[i]
+func validateScalars(suite Suite, field string, scalars []kyber.Scalar) error {
+	for i, s := range scalars {
+		if err := validateScalar(suite, field+"["+strconv.Itoa(i)+"]", s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodePoint decodes the point encoded in buf, it returns an error if the encoding is not canonical
+// or if the point is not a valid element of the group of suite (the identity is accepted)
+func DecodePoint(suite Suite, buf []byte) (kyber.Point, error) {
+	P := suite.Point()
+	if err := P.UnmarshalBinary(buf); err != nil {
+		return nil, newValidationError("point", ErrInvalidElement)
+	}
+	if canonical, err := P.MarshalBinary(); err != nil || !bytes.Equal(buf, canonical) {
+		return nil, newValidationError("point", ErrNonCanonicalEncoding)
+	}
+	if err := validatePoint(suite, "point", P, true); err != nil {
+		return nil, err
+	}
+	return P, nil
+}
+
+// DecodeScalar decodes the scalar encoded in buf, it returns an error if the encoding is not canonical
+func DecodeScalar(suite Suite, buf []byte) (kyber.Scalar, error) {
+	s := suite.Scalar()
+	if err := s.UnmarshalBinary(buf); err != nil {
+		return nil, newValidationError("scalar", ErrNonCanonicalEncoding)
+	}
+	if canonical, err := s.MarshalBinary(); err != nil || !bytes.Equal(buf, canonical) {
+		return nil, newValidationError("scalar", ErrNonCanonicalEncoding)
+	}
+	if err := validateScalar(suite, "scalar", s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ValidateContextElements validates the context (see ValidateContext) and all its group elements:
+// the keys, commitments and generators must be valid elements of the group of suite other than the identity,
+// and there must be no duplicate client or server keys.
+// the returned error matches ErrInvalidContext and wraps the ValidationError
+func ValidateContextElements(suite Suite, context AuthenticationContext) error {
+	if err := validateContextElements(suite, context); err != nil {
+		return newError(ErrInvalidContext, err, "%s", err)
+	}
+	return nil
+}
+
+func validateContextElements(suite Suite, context AuthenticationContext) error {
+	if context == nil {
+		return newValidationError("context", ErrNilElement)
+	}
+	if err := ValidateContext(context); err != nil {
+		return newValidationError("context", ErrInvalidLength)
+	}
+	members := context.Members()
+	for _, field := range []struct {
+		name   string
+		points []kyber.Point
+	}{
+		{"context.X", members.X},
+		{"context.Y", members.Y},
+		{"context.R", context.ServersSecretsCommitments()},
+		{"context.H", context.ClientsGenerators()},
+	} {
+		if err := validatePoints(suite, field.name, field.points, false); err != nil {
+			return err
+		}
+	}
+	if err := validateDistinct("context.X", members.X); err != nil {
+		return err
+	}
+	if err := validateDistinct("context.Y", members.Y); err != nil {
+		return err
+	}
+	if ContextSignatureScheme(context).check(suite) != nil {
+		return newValidationError("context.SignatureScheme", ErrUnsupportedScheme)
+	}
+	return nil
+}
+
+// ValidateChallenge validates the elements of a challenge issued under context,
+// (one signature from each server of the context, the signatures themselves are not verified)
+func ValidateChallenge(suite Suite, context AuthenticationContext, challenge Challenge) error {
+	if err := validateScalar(suite, "challenge.Cs", challenge.Cs); err != nil {
+		return err
+	}
+	if len(challenge.Sigs) != len(context.Members().Y) {
+		return newValidationError("challenge.Sigs", ErrInvalidLength)
+	}
+	if len(challenge.Session) > MaxSessionSize {
+		return newValidationError("challenge.Session", ErrInvalidLength)
+	}
+	if len(challenge.ID) > MaxChallengeIDSize {
+		return newValidationError("challenge.ID", ErrInvalidLength)
+	}
+	if challenge.Expiry < 0 {
+		return newValidationError("challenge.Expiry", ErrInvalidElement)
+	}
+	return validateServerSignatures(context, "challenge.Sigs", challenge.Sigs)
+}
+
+// returns an error if the signatures are not from distinct servers of context
+func validateServerSignatures(context AuthenticationContext, field string, sigs []ServerSignature) error {
+	n := len(context.Members().Y)
+	encountered := make(map[int]struct{}, len(sigs))
+	for i, sig := range sigs {
+		sigField := field + "[" + strconv.Itoa(i) + "]"
+		if sig.Index < 0 || sig.Index >= n {
+			return newValidationError(sigField, ErrInvalidIndex)
+		}
+		if _, ok := encountered[sig.Index]; ok {
+			return newValidationError(sigField, ErrDuplicateElement)
+		}
+		encountered[sig.Index] = struct{}{}
+	}
+	return nil
+}
+
+// validates the elements of the server proof, field is the name of the proof
+func validateServerProof(suite Suite, field string, proof ServerProof) error {
+	for _, P := range []struct {
+		name  string
+		point kyber.Point
+	}{{".T1", proof.T1}, {".T2", proof.T2}, {".T3", proof.T3}} {
+		if err := validatePoint(suite, field+P.name, P.point, true); err != nil {
+			return err
+		}
+	}
+	if err := validateScalar(suite, field+".C", proof.C); err != nil {
+		return err
+	}
+	if err := validateScalar(suite, field+".R1", proof.R1); err != nil {
+		return err
+	}
+	// R2 is nil in the proofs of a misbehaving client
+	if proof.R2 != nil {
+		return validateScalar(suite, field+".R2", proof.R2)
+	}
+	return nil
+}
+
+// validateServerMessage validates the elements added to a server message by the servers,
+// (the request is validated separately, see validateAuthenticationMessage)
+func validateServerMessage(suite Suite, msg ServerMessage) error {
+	if msg.Request.C == nil {
+		return newValidationError("server message Request.C", ErrNilElement)
+	}
+	n := len(msg.Tags)
+	if len(msg.Proofs) != n || len(msg.Indexes) != n || len(msg.Sigs) != n || n > len(msg.Request.C.Members().Y) {
+		return newValidationError("server message", ErrInvalidLength)
+	}
+	// a misbehaving client gets the identity as tag
+	if err := validatePoints(suite, "server message Tags", msg.Tags, true); err != nil {
+		return err
+	}
+	for i, proof := range msg.Proofs {
+		if err := validateServerProof(suite, "server message Proofs["+strconv.Itoa(i)+"]", proof); err != nil {
+			return err
+		}
+	}
+	indexes := make([]ServerSignature, n)
+	for i, index := range msg.Indexes {
+		indexes[i] = ServerSignature{Index: index}
+	}
+	if err := validateServerSignatures(msg.Request.C, "server message Indexes", indexes); err != nil {
+		return err
+	}
+	return validateServerSignatures(msg.Request.C, "server message Sigs", msg.Sigs)
+}
@@ -0,0 +1,164 @@
+package daga
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber"
+)
+
+// test helper that asserts that err is (or wraps) a ValidationError with reason reason
+func requireValidationError(t *testing.T, err error, reason error, msgAndArgs ...interface{}) {
+	require.Error(t, err, msgAndArgs...)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "not a ValidationError: %s", err)
+	require.Equal(t, reason, validationErr.Err, msgAndArgs...)
+}
+
+// test helper that returns an element of the group of suite that is not in the prime order (sub)group, nil if there are none
+func outsideSubgroupPoint(t *testing.T, suite Suite) kyber.Point {
+	switch s := suite.(type) {
+	case *suiteEC:
+		// (0, -1), point of order 2
+		buf := make([]byte, 32)
+		buf[0] = 0xec
+		for i := 1; i < 31; i++ {
+			buf[i] = 0xff
+		}
+		buf[31] = 0x7f
+		torsion := suite.Point()
+		require.NoError(t, torsion.UnmarshalBinary(buf))
+		return suite.Point().Add(suite.Point().Pick(suite.RandomStream()), torsion)
+	case suiteSchnorr:
+		// p-1 is not a quadratic residue
+		return &schnorrPoint{V: *new(big.Int).Sub(s.P, big.NewInt(1)), g: s.schnorrGroup}
+	}
+	return nil
+}
+
+func TestValidateContextElements(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			_, _, context, err := GenerateTestContext(suite, 3, 2)
+			require.NoError(t, err)
+			require.NoError(t, ValidateContextElements(suite, context))
+
+			tamper := func(f func(context *MinimumAuthenticationContext)) AuthenticationContext {
+				tampered := *context.(*MinimumAuthenticationContext)
+				tampered.G.X = append([]kyber.Point{}, tampered.G.X...)
+				tampered.G.Y = append([]kyber.Point{}, tampered.G.Y...)
+				tampered.H = append([]kyber.Point{}, tampered.H...)
+				f(&tampered)
+				return tampered
+			}
+
+			requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+				c.G.X[1] = nil
+			})), ErrNilElement, "nil key accepted")
+			requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+				c.H[2] = suite.Point().Null()
+			})), ErrIdentityElement, "identity generator accepted")
+			requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+				c.G.X[2] = c.G.X[0]
+			})), ErrDuplicateElement, "duplicate client key accepted")
+			requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+				c.G.Y[1] = c.G.Y[0]
+			})), ErrDuplicateElement, "duplicate server key accepted")
+			requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+				c.H = c.H[1:]
+			})), ErrInvalidLength, "wrong number of generators accepted")
+			if P := outsideSubgroupPoint(t, suite); P != nil {
+				requireValidationError(t, ValidateContextElements(suite, tamper(func(c *MinimumAuthenticationContext) {
+					c.G.Y[0] = P
+				})), ErrInvalidElement, "server key outside of the prime order group accepted")
+			}
+		})
+	}
+}
+
+func TestValidateAuthenticationMessage_Elements(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			clients, servers, context, err := GenerateTestContext(suite, 2, 3)
+			require.NoError(t, err)
+			authMsg, err := NewAuthenticationMessage(suite, context, clients[0], func(pkClientCommitments []kyber.Point) (Challenge, error) {
+				return runChallengeGeneration(t, suite, context, servers, pkClientCommitments), nil
+			})
+			require.NoError(t, err)
+			require.NoError(t, validateAuthenticationMessage(suite, *authMsg))
+
+			tampered := *authMsg
+			tampered.SCommits = append([]kyber.Point{}, authMsg.SCommits...)
+			tampered.SCommits[0] = suite.Point().Null()
+			require.Error(t, validateAuthenticationMessage(suite, tampered), "identity Z accepted")
+			if P := outsideSubgroupPoint(t, suite); P != nil {
+				tampered.SCommits[0] = authMsg.SCommits[0]
+				tampered.SCommits[3] = P
+				require.Error(t, validateAuthenticationMessage(suite, tampered), "commitment outside of the prime order group accepted")
+			}
+
+			tampered = *authMsg
+			tampered.T0 = nil
+			require.Error(t, validateAuthenticationMessage(suite, tampered), "nil T0 accepted")
+
+			tampered = *authMsg
+			tampered.P0.R = append([]kyber.Scalar{}, authMsg.P0.R...)
+			tampered.P0.R[0] = nil
+			require.Error(t, validateAuthenticationMessage(suite, tampered), "nil response accepted")
+
+			tampered = *authMsg
+			tampered.P0.Cs.Sigs = append([]ServerSignature{}, authMsg.P0.Cs.Sigs...)
+			tampered.P0.Cs.Sigs[0].Index = len(servers)
+			require.Error(t, validateAuthenticationMessage(suite, tampered), "signature of unknown server accepted")
+			tampered.P0.Cs.Sigs[0].Index = tampered.P0.Cs.Sigs[1].Index
+			require.Error(t, validateAuthenticationMessage(suite, tampered), "duplicate signature accepted")
+
+			// server message with out of range index is rejected (instead of panicking)
+			servMsg, err := InitializeServerMessage(authMsg)
+			require.NoError(t, err)
+			require.NoError(t, ServerProtocol(suite, servMsg, servers[0]))
+			servMsg.Sigs[0].Index = -1
+			require.Error(t, ServerProtocol(suite, servMsg, servers[1]), "server signature with negative index accepted")
+			servMsg.Sigs[0].Index = 0
+			servMsg.Indexes[0] = len(servers)
+			require.Error(t, ServerProtocol(suite, servMsg, servers[1]), "out of range server index accepted")
+		})
+	}
+}
+
+func TestDecodePointScalar(t *testing.T) {
+	for _, suite := range suites {
+		t.Run(suite.String(), func(t *testing.T) {
+			P := suite.Point().Pick(suite.RandomStream())
+			buf, err := P.MarshalBinary()
+			require.NoError(t, err)
+			decoded, err := DecodePoint(suite, buf)
+			require.NoError(t, err)
+			require.True(t, P.Equal(decoded))
+
+			s := suite.Scalar().Pick(suite.RandomStream())
+			buf, err = s.MarshalBinary()
+			require.NoError(t, err)
+			decodedScalar, err := DecodeScalar(suite, buf)
+			require.NoError(t, err)
+			require.True(t, s.Equal(decodedScalar))
+
+			// bigger than the group order
+			tooBig := make([]byte, len(buf))
+			for i := range tooBig {
+				tooBig[i] = 0xff
+			}
+			_, err = DecodeScalar(suite, tooBig)
+			require.Error(t, err, "non canonical scalar accepted")
+
+			if P := outsideSubgroupPoint(t, suite); P != nil {
+				buf, err := P.MarshalBinary()
+				require.NoError(t, err)
+				_, err = DecodePoint(suite, buf)
+				require.Error(t, err, "point outside of the prime order group accepted")
+			}
+		})
+	}
+}